@@ -27,8 +27,8 @@ dependency "networking" {
   config_path = "../networking"
 
   mock_outputs = {
-    vm_subnet_id = "mock-subnet-id"
-    vm_nsg_id    = "mock-nsg-id"
+    vm_subnet_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/mock-rg/providers/Microsoft.Network/virtualNetworks/mock-virtualnetwork/subnets/mock-vm"
+    vm_nsg_id    = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/mock-rg/providers/Microsoft.Network/networkSecurityGroups/mock-vm"
   }
 }
 
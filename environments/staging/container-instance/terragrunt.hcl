@@ -27,7 +27,7 @@ dependency "log_analytics" {
   config_path = "../log-analytics"
 
   mock_outputs = {
-    workspace_customer_id = "mock-workspace-id"
+    workspace_customer_id = "00000000-0000-0000-0000-000000000000"
     primary_shared_key    = "mock-key"
   }
 }
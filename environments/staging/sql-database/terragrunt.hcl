@@ -27,7 +27,7 @@ dependency "networking" {
   config_path = "../networking"
 
   mock_outputs = {
-    database_subnet_id = "mock-subnet-id"
+    database_subnet_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/mock-rg/providers/Microsoft.Network/virtualNetworks/mock-virtualnetwork/subnets/mock-database"
   }
 }
 
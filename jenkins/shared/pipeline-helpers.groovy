@@ -62,50 +62,141 @@ def azureLogout() {
 
 /**
  * Run Terragrunt plan for all modules or a specific module
+ * Whether there are changes is read from each unit's saved plan by
+ * test/unit/cmd/tgplan rather than from the interleaved run-all output
  * @param environment The environment (staging/production)
  * @param targetModule The module to target or 'all'
  * @return boolean True if there are changes to apply, false otherwise
  */
 def terragruntPlan(String environment, String targetModule = 'all') {
-    def hasChanges = false
     dir("environments/${environment}") {
         if (targetModule == 'all') {
-            def planOutput = sh(
-                script: '''
-                    echo "Running Terragrunt plan for all modules..."
-                    terragrunt run-all plan \
-                        --terragrunt-non-interactive \
-                        --terragrunt-include-external-dependencies \
-                        -detailed-exitcode \
-                        -out=tfplan 2>&1 || echo "EXIT_CODE:$?"
-                ''',
-                returnStdout: true
-            ).trim()
-            echo planOutput
-            // Exit code 2 means there are changes to apply
-            hasChanges = planOutput.contains('EXIT_CODE:2') || 
-                         planOutput.contains('Plan:') && 
-                         !planOutput.contains('Plan: 0 to add, 0 to change, 0 to destroy')
+            sh '''
+                echo "Running Terragrunt plan for all modules..."
+                terragrunt run-all plan \
+                    --terragrunt-non-interactive \
+                    --terragrunt-include-external-dependencies \
+                    -out=tfplan
+            '''
         } else {
             dir("${targetModule}") {
-                def planOutput = sh(
-                    script: """
-                        echo "Running Terragrunt plan for ${targetModule}..."
-                        terragrunt plan \
-                            --terragrunt-non-interactive \
-                            -detailed-exitcode \
-                            -out=tfplan 2>&1 || echo "EXIT_CODE:\$?"
-                    """,
-                    returnStdout: true
-                ).trim()
-                echo planOutput
-                hasChanges = planOutput.contains('EXIT_CODE:2') || 
-                             planOutput.contains('Plan:') && 
-                             !planOutput.contains('Plan: 0 to add, 0 to change, 0 to destroy')
+                sh """
+                    echo "Running Terragrunt plan for ${targetModule}..."
+                    terragrunt plan \
+                        --terragrunt-non-interactive \
+                        -out=tfplan
+                """
             }
         }
     }
-    return hasChanges
+
+    def unitFlag = targetModule == 'all' ? '' : "-unit ${targetModule}"
+    def status
+    dir('test/unit') {
+        // Exit codes as terraform plan -detailed-exitcode: 0 no changes,
+        // 1 a plan errored or could not be read, 2 changes. go run would
+        // turn 2 into 1, so the command is built first.
+        status = sh(
+            script: """
+                go build -o bin/tgplan ./cmd/tgplan
+                bin/tgplan -env ${environment} ${unitFlag} -out plan-summary-${environment}.json \
+                    -title "Terraform plan: ${environment} (build #${env.BUILD_NUMBER})" \
+                    -markdown plan-${environment}.md \
+                    -discord plan-${environment}-discord.json \
+                    -jira plan-${environment}-jira.txt
+            """,
+            returnStatus: true
+        )
+        // plan-<environment>.md is ready to post as a pull request comment
+        archiveArtifacts artifacts: "plan-summary-${environment}.json,plan-${environment}.md,plan-${environment}-discord.json,plan-${environment}-jira.txt", allowEmptyArchive: true
+    }
+    if (status != 0 && status != 2) {
+        error("Could not summarise the ${environment} plan (tgplan exit code ${status})")
+    }
+    return status == 2
+}
+
+/**
+ * Fail when the saved plans of an environment delete or replace a resource
+ * listed in test/unit/guard/protected.yaml, printing the attributes forcing
+ * each replacement. The build only continues when a member of the approvers
+ * group (PRODUCTION_APPROVERS, default infra-approvers) other than the user
+ * who started the build enters the override token printed for exactly these
+ * changes and a reason; the override is recorded in the archived
+ * destroy-overrides.jsonl with both users.
+ * @param environment The environment whose plans are checked
+ */
+def guardDestructiveChanges(String environment) {
+    // Empty for builds started by a push or a timer
+    def startedBy = currentBuild.getBuildCauses('hudson.model.Cause$UserIdCause').collect { it.userId }.find { it } ?: ''
+    dir('test/unit') {
+        sh 'go build -o bin/planguard ./cmd/planguard'
+        def status = sh(script: "bin/planguard -env ${environment}", returnStatus: true)
+        if (status == 0) {
+            return
+        }
+        if (status != 1) {
+            error("Could not check the ${environment} plan for destructive changes (planguard exit code ${status})")
+        }
+
+        def override
+        timeout(time: 60, unit: 'MINUTES') {
+            override = input(
+                message: "Protected resources in ${environment} would be deleted or replaced, see the planguard output above. Enter its override token and a reason to continue. Someone other than the user who started the build must approve.",
+                ok: 'Override',
+                // Only approvers may submit; the token alone, printed above, is not enough
+                submitter: env.PRODUCTION_APPROVERS ?: 'infra-approvers',
+                parameters: [
+                    string(name: 'TOKEN', defaultValue: '', description: 'Override token printed by planguard'),
+                    string(name: 'REASON', defaultValue: '', description: 'Why the destructive changes are accepted')
+                ],
+                submitterParameter: 'SUBMITTER'
+            )
+        }
+        if (startedBy && override.SUBMITTER == startedBy) {
+            error("${startedBy} started the build, so someone else must approve the override")
+        }
+        // Passed through the environment so the reason is not interpreted by the shell
+        withEnv(["BUILD_USER_ID=${override.SUBMITTER}", "BUILD_STARTED_BY=${startedBy}", "OVERRIDE_TOKEN=${override.TOKEN}", "OVERRIDE_REASON=${override.REASON}"]) {
+            try {
+                sh "bin/planguard -env ${environment} -override \"\$OVERRIDE_TOKEN\" -reason \"\$OVERRIDE_REASON\" -audit destroy-overrides.jsonl"
+            } finally {
+                archiveArtifacts artifacts: 'destroy-overrides.jsonl', allowEmptyArchive: true
+            }
+        }
+    }
+}
+
+/**
+ * Run a refresh-only plan in every unit of an environment and report what
+ * was changed outside Terraform, by resource and attribute, with
+ * test/unit/cmd/drift. Writes drift-<environment>.json, .md and -jira.txt
+ * in test/unit and archives them.
+ * @param environment The environment to check (staging/production)
+ * @return boolean True if a resource or output drifted, false otherwise
+ */
+def detectDrift(String environment) {
+    def status
+    dir('test/unit') {
+        // Built separately: drift exits 1 on drift, so a failing build must
+        // not be read as drift
+        sh 'go build -o bin/drift ./cmd/drift'
+        // Exit codes: 0 no drift, 1 drift, 2 a plan errored or could not be read
+        status = sh(
+            script: """
+                bin/drift -env ${environment} -out drift-${environment}.json \
+                    -title "Drift: ${environment} (build #${env.BUILD_NUMBER})" \
+                    -markdown drift-${environment}.md \
+                    -jira drift-${environment}-jira.txt
+            """,
+            returnStatus: true
+        )
+        archiveArtifacts artifacts: "drift-${environment}.json,drift-${environment}.md,drift-${environment}-jira.txt", allowEmptyArchive: true
+    }
+    if (status != 0 && status != 1) {
+        error("Could not check ${environment} for drift (drift exit code ${status})")
+    }
+    return status == 1
 }
 
 /**
@@ -187,8 +278,9 @@ def terragruntOutput(String environment) {
  * @param buildUrl Jenkins build URL
  * @param buildNumber Jenkins build number
  * @param additionalMessage Optional additional message
+ * @param planEmbedFile Optional plan summary embed written by tgplan -discord, sent as a second embed
  */
-def sendDiscordNotification(String webhookUrl, String status, String environment, String action, String targetModule, String buildUrl, String buildNumber, String additionalMessage = '') {
+def sendDiscordNotification(String webhookUrl, String status, String environment, String action, String targetModule, String buildUrl, String buildNumber, String additionalMessage = '', String planEmbedFile = '') {
     def color
     def emoji
     def title
@@ -258,6 +350,11 @@ def sendDiscordNotification(String webhookUrl, String status, String environment
         """
     }
     
+    def planEmbed = ''
+    if (planEmbedFile && fileExists(planEmbedFile)) {
+        planEmbed = ",\n        ${readFile(planEmbedFile).trim()}"
+    }
+    
     def payload = """
     {
         "embeds": [{
@@ -269,13 +366,14 @@ def sendDiscordNotification(String webhookUrl, String status, String environment
                 "text": "Gogs Infrastructure Azure"
             },
             "timestamp": "${new Date().format("yyyy-MM-dd'T'HH:mm:ss'Z'", TimeZone.getTimeZone('UTC'))}"
-        }]
+        }${planEmbed}]
     }
     """
     
+    // Plan summaries may contain quotes; close, escape and reopen them
     sh """
         curl -s -X POST -H "Content-Type: application/json" \
-            -d '${payload}' \
+            -d '${payload.replace("'", "'\\''")}' \
             "${webhookUrl}" || true
     """
 }
@@ -291,8 +389,9 @@ def sendDiscordNotification(String webhookUrl, String status, String environment
  * @param targetModule Target module
  * @param buildUrl Jenkins build URL
  * @param errorMessage Error message or details
+ * @param planSummaryFile Optional plan summary written by tgplan -jira, added to the description
  */
-def createJiraTicket(String jiraUrl, String jiraUser, String jiraToken, String projectKey, String environment, String action, String targetModule, String buildUrl, String errorMessage = '') {
+def createJiraTicket(String jiraUrl, String jiraUser, String jiraToken, String projectKey, String environment, String action, String targetModule, String buildUrl, String errorMessage = '', String planSummaryFile = '') {
     def priority = environment == 'production' ? 'Critical' : 'High'
     def summary = "Infrastructure ${action} Failed - ${environment.toUpperCase()}"
     
@@ -315,6 +414,10 @@ h3. Next Steps
 # Fix the issue and re-run the pipeline
     """.trim()
     
+    if (planSummaryFile && fileExists(planSummaryFile)) {
+        description += "\n\n${readFile(planSummaryFile).trim()}"
+    }
+    
     // Escape special characters for JSON
     def escapedDescription = description.replace('\\', '\\\\').replace('"', '\\"').replace('\n', '\\n')
     
@@ -342,7 +445,7 @@ h3. Next Steps
             curl -s -X POST \
                 -H "Content-Type: application/json" \
                 -u "${jiraUser}:${jiraToken}" \
-                -d '${payload}' \
+                -d '${payload.replace("'", "'\\''")}' \
                 "${jiraUrl}/rest/api/2/issue"
         """,
         returnStdout: true
@@ -371,6 +474,7 @@ def cleanup() {
     sh '''
         find . -name "*.tfplan" -delete || true
         find . -name "tfplan" -delete || true
+        find . -name "tfdrift" -delete || true
         find . -name ".terraform.lock.hcl" -delete || true
     '''
 }
@@ -388,4 +492,52 @@ def validateHcl(String environment) {
     }
 }
 
+/**
+ * Validate the Terragrunt inputs of an environment against its modules
+ * Fails the build on invalid inputs before anything is planned
+ * @param environment The environment to validate
+ */
+def validateInputs(String environment) {
+    dir('test/unit') {
+        sh """
+            echo "Validating Terragrunt inputs for ${environment}..."
+            go run ./cmd/validate-inputs -env ${environment}
+        """
+    }
+}
+
+/**
+ * Print the NSG exposure matrix of an environment
+ * Fails the build when SSH, RDP or Splunk Web is reachable from the internet
+ * in production, unless test/unit/exposure/allowlist.yaml accepts it
+ * @param environment The environment to check
+ */
+def checkExposure(String environment) {
+    dir('test/unit') {
+        sh """
+            echo "Checking NSG exposure for ${environment}..."
+            go run ./cmd/nsgexposure -env ${environment} -strict production
+        """
+    }
+}
+
+/**
+ * Evaluate the policy rules against the units of an environment
+ * Fails the build on error-severity violations that are not waived in
+ * test/unit/policy/waivers.yaml
+ * @param environment The environment to check
+ */
+def checkPolicy(String environment) {
+    dir('test/unit') {
+        try {
+            sh """
+                echo "Evaluating policy rules for ${environment}..."
+                go run ./cmd/policy -env ${environment} -sarif policy-${environment}.sarif
+            """
+        } finally {
+            archiveArtifacts artifacts: "policy-${environment}.sarif", allowEmptyArchive: true
+        }
+    }
+}
+
 return this
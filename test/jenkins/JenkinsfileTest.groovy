@@ -65,10 +65,14 @@ class JenkinsfileTest extends BasePipelineTest {
         helper.registerAllowedMethod('success', [Closure], null)
         helper.registerAllowedMethod('failure', [Closure], null)
         helper.registerAllowedMethod('aborted', [Closure], null)
+        helper.registerAllowedMethod('cleanup', [Closure], null)
+        helper.registerAllowedMethod('unstable', [Closure], null)
+        helper.registerAllowedMethod('unstable', [String], { String msg -> println "Unstable: ${msg}" })
 
         // Triggers and options
         helper.registerAllowedMethod('triggers', [Closure], null)
         helper.registerAllowedMethod('githubPush', [], null)
+        helper.registerAllowedMethod('cron', [String], null)
         helper.registerAllowedMethod('options', [Closure], null)
         helper.registerAllowedMethod('buildDiscarder', [Object], null)
         helper.registerAllowedMethod('logRotator', [Map], null)
@@ -172,6 +176,13 @@ class JenkinsfileTest extends BasePipelineTest {
                 // Return based on environment for testing different scenarios
                 return binding.getVariable('env')["${environment.toUpperCase()}_HAS_CHANGES"] == 'true'
             },
+            guardDestructiveChanges: { String environment ->
+                println "Mock: Guarding destructive changes in ${environment}"
+            },
+            detectDrift: { String environment ->
+                println "Mock: Detecting drift in ${environment}"
+                return false
+            },
             terragruntApply: { String environment, String targetModule = 'all' ->
                 println "Mock: Terragrunt apply for ${environment}"
             },
@@ -180,12 +191,14 @@ class JenkinsfileTest extends BasePipelineTest {
             },
             sendDiscordNotification: { String webhookUrl, String status, String environment,
                                         String action, String targetModule, String buildUrl,
-                                        String buildNumber, String additionalMessage = '' ->
+                                        String buildNumber, String additionalMessage = '',
+                                        String planEmbedFile = '' ->
                 println "Mock: Discord notification - ${status} for ${environment}"
             },
             createJiraTicket: { String jiraUrl, String jiraUser, String jiraToken,
                                  String projectKey, String environment, String action,
-                                 String targetModule, String buildUrl, String errorMessage = '' ->
+                                 String targetModule, String buildUrl, String errorMessage = '',
+                                 String planSummaryFile = '' ->
                 println "Mock: Creating Jira ticket for ${environment}"
                 return 'INFRA-123'
             },
@@ -323,7 +336,8 @@ class JenkinsfileTest extends BasePipelineTest {
 
         mockUtils.sendDiscordNotification = { String webhookUrl, String status, String environment,
                                                String action, String targetModule, String buildUrl,
-                                               String buildNumber, String additionalMessage = '' ->
+                                               String buildNumber, String additionalMessage = '',
+                                               String planEmbedFile = '' ->
             if (status == 'STARTED') {
                 discordCalled = true
                 discordStatus = status
@@ -337,6 +351,53 @@ class JenkinsfileTest extends BasePipelineTest {
         assertEquals("Status should be STARTED", 'STARTED', discordStatus)
     }
 
+    @Test
+    void testNotificationsCarryPlanSummaries() {
+        def embeds = [:]
+
+        mockUtils.terragruntPlan = { String environment, String targetModule = 'all' ->
+            return true
+        }
+        mockUtils.sendDiscordNotification = { String webhookUrl, String status, String environment,
+                                               String action, String targetModule, String buildUrl,
+                                               String buildNumber, String additionalMessage = '',
+                                               String planEmbedFile = '' ->
+            if (planEmbedFile) {
+                embeds[status] = planEmbedFile
+            }
+        }
+
+        def script = loadScript('Jenkinsfile')
+        script.run()
+
+        assertEquals("The staging apply notification should carry the staging plan",
+            'test/unit/plan-staging-discord.json', embeds['STARTED'])
+        assertEquals("The approval request should carry the production plan",
+            'test/unit/plan-production-discord.json', embeds['APPROVAL_REQUIRED'])
+    }
+
+    @Test
+    void testFailureTicketAttachesLastPlannedEnvironment() {
+        def reports = []
+        mockUtils.createJiraTicket = { String jiraUrl, String jiraUser, String jiraToken,
+                                       String projectKey, String environment, String action,
+                                       String targetModule, String buildUrl, String errorMessage = '',
+                                       String planSummaryFile = '' ->
+            reports << planSummaryFile
+        }
+        mockUtils.terragruntPlan = { String environment, String targetModule = 'all' ->
+            assertEquals("The planned environment should be recorded before planning",
+                environment, binding.getVariable('env').LAST_PLANNED_ENV)
+            return false
+        }
+
+        def script = loadScript('Jenkinsfile')
+        script.run()
+
+        assertEquals("The failure ticket should attach the plan of the last environment planned",
+            ['test/unit/plan-production-jira.txt'], reports)
+    }
+
     @Test
     void testAzureLoginCalled() {
         def azureLoginCalled = false
@@ -452,6 +513,71 @@ class JenkinsfileTest extends BasePipelineTest {
         assertTrue("Pipeline summary should be printed", summaryPrinted)
     }
 
+    // ==================== Drift Pipeline ====================
+
+    @Test
+    void testDriftPipelineLoadsSuccessfully() {
+        def script = loadScript('Jenkinsfile.drift')
+
+        assertNotNull("Drift pipeline script should load successfully", script)
+    }
+
+    @Test
+    void testDriftPipelineWithoutDrift() {
+        def checked = []
+        def tickets = []
+        mockUtils.detectDrift = { String environment ->
+            checked << environment
+            return false
+        }
+        mockUtils.createJiraTicket = { String jiraUrl, String jiraUser, String jiraToken,
+                                       String projectKey, String environment, String action,
+                                       String targetModule, String buildUrl, String errorMessage = '',
+                                       String planSummaryFile = '' ->
+            tickets << environment
+        }
+
+        def script = loadScript('Jenkinsfile.drift')
+        script.run()
+
+        assertEquals("Both environments should be checked", ['staging', 'production'], checked)
+        assertTrue("No Jira ticket should be created without drift", tickets.isEmpty())
+        assertFalse("The build should not be marked unstable", helper.callStack.any { call ->
+            call.methodName == 'unstable' && callArgsToString(call).contains('changed outside Terraform')
+        })
+    }
+
+    @Test
+    void testDriftPipelineFilesJiraTicketForDriftedEnvironment() {
+        def tickets = []
+        mockUtils.detectDrift = { String environment ->
+            return environment == 'production'
+        }
+        mockUtils.createJiraTicket = { String jiraUrl, String jiraUser, String jiraToken,
+                                       String projectKey, String environment, String action,
+                                       String targetModule, String buildUrl, String errorMessage = '',
+                                       String planSummaryFile = '' ->
+            tickets << [environment: environment, action: action, report: planSummaryFile]
+            return 'INFRA-124'
+        }
+
+        def script = loadScript('Jenkinsfile.drift')
+        script.run()
+
+        assertEquals("Only the drifted environment should get a ticket",
+            [[environment: 'production', action: 'drift check', report: 'test/unit/drift-production-jira.txt']], tickets)
+        assertEquals("Drift should be recorded", 'true', binding.getVariable('env').PRODUCTION_DRIFTED)
+        assertEquals("Staging should not be marked drifted", 'false', binding.getVariable('env').STAGING_DRIFTED)
+        assertTrue("The build should be marked unstable", helper.callStack.any { call ->
+            call.methodName == 'unstable' && callArgsToString(call).contains('Production was changed outside Terraform')
+        })
+        ['tf-unique-suffix-prod', 'db-admin-username-prod', 'db-admin-password-prod', 'admin-ip-range-prod'].each { id ->
+            assertTrue("Production should be checked with ${id}", helper.callStack.any { call ->
+                call.methodName == 'credentials' && callArgsToString(call) == id
+            })
+        }
+    }
+
     // ==================== Helper Methods ====================
 
     /**
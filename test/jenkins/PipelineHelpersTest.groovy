@@ -54,6 +54,14 @@ class PipelineHelpersTest extends BasePipelineTest {
         helper.registerAllowedMethod('readJSON', [Map], { Map m ->
             return [key: 'INFRA-123']
         })
+        helper.registerAllowedMethod('archiveArtifacts', [Map], { Map m ->
+            println "Mock archiveArtifacts: ${m.artifacts}"
+        })
+        helper.registerAllowedMethod('error', [String], { String msg ->
+            throw new Exception(msg)
+        })
+        helper.registerAllowedMethod('fileExists', [String], { String path -> false })
+        helper.registerAllowedMethod('readFile', [String], { String path -> '' })
     }
 
     protected void setupBinding() {
@@ -119,12 +127,21 @@ class PipelineHelpersTest extends BasePipelineTest {
         assertNotNull("terragruntPlan should be defined", pipelineHelpers.terragruntPlan)
     }
 
-    @Test
-    void testTerragruntPlanReturnsBoolean() {
-        // Mock sh to return plan output indicating no changes
+    /**
+     * Mock sh so the tgplan summary exits with status, recording its script
+     */
+    protected List mockPlanSummary(int status) {
+        def scripts = []
         helper.registerAllowedMethod('sh', [Map], { Map m ->
-            return 'Plan: 0 to add, 0 to change, 0 to destroy'
+            scripts << m.script
+            return m.returnStatus ? status : ''
         })
+        return scripts
+    }
+
+    @Test
+    void testTerragruntPlanReturnsBoolean() {
+        mockPlanSummary(0)
 
         def result = pipelineHelpers.terragruntPlan('staging', 'all')
         assertTrue("terragruntPlan should return a boolean", result instanceof Boolean)
@@ -132,9 +149,7 @@ class PipelineHelpersTest extends BasePipelineTest {
 
     @Test
     void testTerragruntPlanDetectsNoChanges() {
-        helper.registerAllowedMethod('sh', [Map], { Map m ->
-            return 'Plan: 0 to add, 0 to change, 0 to destroy'
-        })
+        mockPlanSummary(0)
 
         def hasChanges = pipelineHelpers.terragruntPlan('staging', 'all')
         assertFalse("Should detect no changes", hasChanges)
@@ -142,22 +157,167 @@ class PipelineHelpersTest extends BasePipelineTest {
 
     @Test
     void testTerragruntPlanDetectsChanges() {
-        helper.registerAllowedMethod('sh', [Map], { Map m ->
-            return 'Plan: 2 to add, 1 to change, 0 to destroy\nEXIT_CODE:2'
-        })
+        def scripts = mockPlanSummary(2)
 
         def hasChanges = pipelineHelpers.terragruntPlan('staging', 'all')
         assertTrue("Should detect changes", hasChanges)
+        assertTrue("Should read the saved plans with tgplan",
+            scripts.any { it.contains('bin/tgplan -env staging') })
     }
 
     @Test
     void testTerragruntPlanWithSpecificModule() {
-        helper.registerAllowedMethod('sh', [Map], { Map m ->
-            return 'Plan: 1 to add, 0 to change, 0 to destroy\nEXIT_CODE:2'
-        })
+        def scripts = mockPlanSummary(2)
 
         def hasChanges = pipelineHelpers.terragruntPlan('staging', 'resource-group')
         assertTrue("Should work with specific module", hasChanges)
+        assertTrue("Should only read the module's plan",
+            scripts.any { it.contains('-unit resource-group') })
+    }
+
+    @Test
+    void testTerragruntPlanRendersSummaries() {
+        def scripts = mockPlanSummary(2)
+        def artifacts = []
+        helper.registerAllowedMethod('archiveArtifacts', [Map], { Map m -> artifacts << m.artifacts })
+
+        pipelineHelpers.terragruntPlan('production', 'all')
+        assertTrue("Should render the Markdown, Discord and Jira summaries",
+            scripts.any {
+                it.contains('-markdown plan-production.md') &&
+                    it.contains('-discord plan-production-discord.json') &&
+                    it.contains('-jira plan-production-jira.txt')
+            })
+        assertTrue("Should archive the rendered summaries",
+            artifacts.any { it.contains('plan-production.md') && it.contains('plan-production-jira.txt') })
+    }
+
+    @Test
+    void testTerragruntPlanFailsWhenPlanCannotBeRead() {
+        mockPlanSummary(1)
+
+        try {
+            pipelineHelpers.terragruntPlan('staging', 'all')
+            fail("terragruntPlan should fail when tgplan exits 1")
+        } catch (Exception e) {
+            assertTrue(e.message.contains('tgplan exit code 1'))
+        }
+    }
+
+    // ==================== guardDestructiveChanges Tests ====================
+
+    /**
+     * Mock the planguard run, the override input and the steps around it
+     */
+    protected List mockPlanGuard(int status, String startedBy = 'asmith') {
+        def scripts = []
+        binding.setVariable('currentBuild', [
+            getBuildCauses: { String type -> startedBy ? [[userId: startedBy]] : [] }
+        ])
+        helper.registerAllowedMethod('sh', [String], { String cmd -> scripts << cmd })
+        helper.registerAllowedMethod('sh', [Map], { Map m ->
+            scripts << m.script
+            return m.returnStatus ? status : ''
+        })
+        helper.registerAllowedMethod('timeout', [Map, Closure], { Map m, Closure c -> c.call() })
+        helper.registerAllowedMethod('string', [Map], { Map m -> m })
+        helper.registerAllowedMethod('input', [Map], { Map m ->
+            scripts << "input submitter=${m.submitter}"
+            return [TOKEN: 'destroy-production-0123456789ab', REASON: 'Rebuild the data disk', SUBMITTER: 'jdoe']
+        })
+        helper.registerAllowedMethod('withEnv', [List, Closure], { List vars, Closure c ->
+            scripts << vars.join(' ')
+            c.call()
+        })
+        return scripts
+    }
+
+    @Test
+    void testGuardDestructiveChangesPasses() {
+        def scripts = mockPlanGuard(0)
+
+        pipelineHelpers.guardDestructiveChanges('production')
+        assertFalse("Should not ask for an override", scripts.any { it.contains('-override') })
+    }
+
+    @Test
+    void testGuardDestructiveChangesRequiresOverride() {
+        def scripts = mockPlanGuard(1)
+
+        pipelineHelpers.guardDestructiveChanges('production')
+        assertTrue("Should only let approvers submit", scripts.contains('input submitter=infra-approvers'))
+        assertTrue("Should record the approver", scripts.any { it.contains('BUILD_USER_ID=jdoe') })
+        assertTrue("Should record who started the build", scripts.any { it.contains('BUILD_STARTED_BY=asmith') })
+        assertTrue("Should rerun planguard with the override",
+            scripts.any { it.contains('-override') && it.contains('-audit destroy-overrides.jsonl') })
+    }
+
+    @Test
+    void testGuardDestructiveChangesRejectsApprovalByBuildStarter() {
+        def scripts = mockPlanGuard(1, 'jdoe')
+
+        try {
+            pipelineHelpers.guardDestructiveChanges('production')
+            fail("guardDestructiveChanges should reject an override approved by the user who started the build")
+        } catch (Exception e) {
+            assertTrue(e.message.contains('someone else must approve'))
+        }
+        assertFalse("Should not rerun planguard with the override", scripts.any { it.contains('-override') })
+    }
+
+    @Test
+    void testGuardDestructiveChangesFailsWhenPlanCannotBeRead() {
+        mockPlanGuard(2)
+
+        try {
+            pipelineHelpers.guardDestructiveChanges('production')
+            fail("guardDestructiveChanges should fail when planguard exits 2")
+        } catch (Exception e) {
+            assertTrue(e.message.contains('planguard exit code 2'))
+        }
+    }
+
+    // ==================== detectDrift Tests ====================
+
+    @Test
+    void testDetectDriftIsDefined() {
+        assertNotNull("detectDrift should be defined", pipelineHelpers.detectDrift)
+    }
+
+    @Test
+    void testDetectDriftDetectsNoDrift() {
+        mockPlanSummary(0)
+
+        assertFalse("Should detect no drift", pipelineHelpers.detectDrift('production'))
+    }
+
+    @Test
+    void testDetectDriftDetectsDrift() {
+        def scripts = mockPlanSummary(1)
+        def artifacts = []
+        helper.registerAllowedMethod('archiveArtifacts', [Map], { Map m -> artifacts << m.artifacts })
+
+        assertTrue("Should detect drift", pipelineHelpers.detectDrift('production'))
+        assertTrue("Should write the JSON, Markdown and Jira reports",
+            scripts.any {
+                it.contains('bin/drift -env production -out drift-production.json') &&
+                    it.contains('-markdown drift-production.md') &&
+                    it.contains('-jira drift-production-jira.txt')
+            })
+        assertTrue("Should archive the reports",
+            artifacts.any { it.contains('drift-production.md') && it.contains('drift-production-jira.txt') })
+    }
+
+    @Test
+    void testDetectDriftFailsWhenPlanCannotBeRead() {
+        mockPlanSummary(2)
+
+        try {
+            pipelineHelpers.detectDrift('production')
+            fail("detectDrift should fail when drift exits 2")
+        } catch (Exception e) {
+            assertTrue(e.message.contains('drift exit code 2'))
+        }
     }
 
     // ==================== terragruntApply Tests ====================
@@ -314,6 +474,32 @@ class PipelineHelpersTest extends BasePipelineTest {
         }
     }
 
+    @Test
+    void testSendDiscordNotificationWithPlanEmbed() {
+        def scripts = []
+        helper.registerAllowedMethod('sh', [String], { String cmd -> scripts << cmd })
+        helper.registerAllowedMethod('fileExists', [String], { String path -> path == 'test/unit/plan-production-discord.json' })
+        helper.registerAllowedMethod('readFile', [String], { String path ->
+            return '{"title": "Terraform plan: production", "description": "Don\'t panic", "color": 15158332, "fields": []}'
+        })
+
+        pipelineHelpers.sendDiscordNotification(
+            'https://discord.webhook.url',
+            'APPROVAL_REQUIRED',
+            'production',
+            'apply',
+            'all',
+            'http://jenkins/build/1',
+            '1',
+            'Waiting for approval',
+            'test/unit/plan-production-discord.json'
+        )
+        assertTrue("Should send the plan summary as a second embed",
+            scripts.any { it.contains('"title": "Terraform plan: production"') })
+        assertTrue("Should escape quotes for the shell",
+            scripts.any { it.contains("Don'\\''t panic") })
+    }
+
     // ==================== createJiraTicket Tests ====================
 
     @Test
@@ -367,6 +553,34 @@ class PipelineHelpersTest extends BasePipelineTest {
         }
     }
 
+    @Test
+    void testCreateJiraTicketWithPlanSummary() {
+        def scripts = []
+        helper.registerAllowedMethod('sh', [Map], { Map m ->
+            scripts << m.script
+            return '{"key": "INFRA-124"}'
+        })
+        helper.registerAllowedMethod('fileExists', [String], { String path -> true })
+        helper.registerAllowedMethod('readFile', [String], { String path ->
+            return 'h3. Terraform plan: production\n||Unit||Action||Resource||Details||'
+        })
+
+        pipelineHelpers.createJiraTicket(
+            'https://jira.example.com',
+            'user@example.com',
+            'api-token',
+            'INFRA',
+            'production',
+            'apply',
+            'all',
+            'http://jenkins/build/1',
+            'Apply failed',
+            'test/unit/plan-production-jira.txt'
+        )
+        assertTrue("Should add the plan summary to the description",
+            scripts.any { it.contains('h3. Terraform plan: production') })
+    }
+
     // ==================== cleanup Tests ====================
 
     @Test
@@ -423,6 +637,8 @@ class PipelineHelpersTest extends BasePipelineTest {
             'azureLogin',
             'azureLogout',
             'terragruntPlan',
+            'guardDestructiveChanges',
+            'detectDrift',
             'terragruntApply',
             'terragruntDestroy',
             'terragruntOutput',
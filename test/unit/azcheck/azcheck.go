@@ -0,0 +1,98 @@
+// Package azcheck checks that the resources a module applied exist in Azure.
+//
+// A Checker reads resources through the Resource Manager endpoint it was
+// created with, so the module tests point it at the subscription under test
+// while its own tests point it at internal/fakearm. Lookups of a resource
+// that is not there report false; any other failure, such as a denied
+// request, is returned as an error rather than read as a missing resource.
+package azcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2018-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/mgmt/2016-10-01/keyvault"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// Checker looks up resources in one subscription
+type Checker struct {
+	Groups          resources.GroupsClient
+	Networks        network.VirtualNetworksClient
+	Subnets         network.SubnetsClient
+	SecurityGroups  network.SecurityGroupsClient
+	Vaults          keyvault.VaultsClient
+	ContainerGroups containerinstance.ContainerGroupsClient
+}
+
+// New returns a Checker using the Resource Manager endpoint at baseURI
+func New(subscriptionID string, baseURI string, authorizer autorest.Authorizer) *Checker {
+	c := &Checker{
+		Groups:          resources.NewGroupsClientWithBaseURI(baseURI, subscriptionID),
+		Networks:        network.NewVirtualNetworksClientWithBaseURI(baseURI, subscriptionID),
+		Subnets:         network.NewSubnetsClientWithBaseURI(baseURI, subscriptionID),
+		SecurityGroups:  network.NewSecurityGroupsClientWithBaseURI(baseURI, subscriptionID),
+		Vaults:          keyvault.NewVaultsClientWithBaseURI(baseURI, subscriptionID),
+		ContainerGroups: containerinstance.NewContainerGroupsClientWithBaseURI(baseURI, subscriptionID),
+	}
+	c.Groups.Authorizer = authorizer
+	c.Networks.Authorizer = authorizer
+	c.Subnets.Authorizer = authorizer
+	c.SecurityGroups.Authorizer = authorizer
+	c.Vaults.Authorizer = authorizer
+	c.ContainerGroups.Authorizer = authorizer
+	return c
+}
+
+// ResourceGroupExists reports whether the resource group exists
+func (c *Checker) ResourceGroupExists(ctx context.Context, name string) (bool, error) {
+	_, err := c.Groups.Get(ctx, name)
+	return exists(err)
+}
+
+// VirtualNetworkExists reports whether the virtual network exists in the
+// resource group
+func (c *Checker) VirtualNetworkExists(ctx context.Context, resourceGroup string, name string) (bool, error) {
+	_, err := c.Networks.Get(ctx, resourceGroup, name, "")
+	return exists(err)
+}
+
+// SubnetExists reports whether the subnet exists in the virtual network
+func (c *Checker) SubnetExists(ctx context.Context, resourceGroup string, vnet string, name string) (bool, error) {
+	_, err := c.Subnets.Get(ctx, resourceGroup, vnet, name, "")
+	return exists(err)
+}
+
+// NetworkSecurityGroupExists reports whether the network security group
+// exists in the resource group
+func (c *Checker) NetworkSecurityGroupExists(ctx context.Context, resourceGroup string, name string) (bool, error) {
+	_, err := c.SecurityGroups.Get(ctx, resourceGroup, name, "")
+	return exists(err)
+}
+
+// KeyVaultExists reports whether the Key Vault exists in the resource group.
+// A soft-deleted vault does not.
+func (c *Checker) KeyVaultExists(ctx context.Context, resourceGroup string, name string) (bool, error) {
+	_, err := c.Vaults.Get(ctx, resourceGroup, name)
+	return exists(err)
+}
+
+// ContainerGroupExists reports whether the container group exists in the
+// resource group
+func (c *Checker) ContainerGroupExists(ctx context.Context, resourceGroup string, name string) (bool, error) {
+	_, err := c.ContainerGroups.Get(ctx, resourceGroup, name)
+	return exists(err)
+}
+
+// exists maps the error of a Get onto whether the resource exists
+func exists(err error) (bool, error) {
+	var detailed autorest.DetailedError
+	if errors.As(err, &detailed) && detailed.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
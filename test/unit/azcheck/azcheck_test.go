@@ -0,0 +1,96 @@
+package azcheck
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/fakearm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seed(arm *fakearm.Server) {
+	arm.AddResourceGroup("test-net-rg", "eastus", nil)
+	arm.Put(arm.ResourceID("test-net-rg", fakearm.TypeVirtualNetwork, "test-vnet"), fakearm.Object{
+		"location": "eastus",
+		"properties": fakearm.Object{
+			"addressSpace": fakearm.Object{"addressPrefixes": []interface{}{"10.0.0.0/16"}},
+			"subnets": []interface{}{
+				fakearm.Object{"name": "test-vnet-vm-subnet", "properties": fakearm.Object{"addressPrefix": "10.0.3.0/24"}},
+			},
+		},
+	})
+	arm.Put(arm.ResourceID("test-net-rg", fakearm.TypeNetworkSecurityGroup, "test-vnet-vm-nsg"), fakearm.Object{"location": "eastus"})
+	arm.Put(arm.ResourceID("test-net-rg", fakearm.TypeKeyVault, "testkv"), fakearm.Object{"location": "eastus"})
+	arm.Put(arm.ResourceID("test-net-rg", fakearm.TypeContainerGroup, "test-aci"), fakearm.Object{"location": "eastus"})
+}
+
+func TestChecker(t *testing.T) {
+	arm := fakearm.New(t)
+	seed(arm)
+	c := New(arm.SubscriptionID, arm.URL, autorest.NullAuthorizer{})
+	ctx := context.Background()
+
+	for name, check := range map[string]func(context.Context) (bool, error){
+		"resource group": func(ctx context.Context) (bool, error) { return c.ResourceGroupExists(ctx, "test-net-rg") },
+		"virtual network": func(ctx context.Context) (bool, error) {
+			return c.VirtualNetworkExists(ctx, "test-net-rg", "test-vnet")
+		},
+		"subnet": func(ctx context.Context) (bool, error) {
+			return c.SubnetExists(ctx, "test-net-rg", "test-vnet", "test-vnet-vm-subnet")
+		},
+		"network security group": func(ctx context.Context) (bool, error) {
+			return c.NetworkSecurityGroupExists(ctx, "test-net-rg", "test-vnet-vm-nsg")
+		},
+		"key vault":       func(ctx context.Context) (bool, error) { return c.KeyVaultExists(ctx, "test-net-rg", "testkv") },
+		"container group": func(ctx context.Context) (bool, error) { return c.ContainerGroupExists(ctx, "test-net-rg", "test-aci") },
+	} {
+		exists, err := check(ctx)
+		require.NoError(t, err, name)
+		assert.True(t, exists, name)
+	}
+}
+
+func TestCheckerReportsMissingResources(t *testing.T) {
+	arm := fakearm.New(t)
+	seed(arm)
+	c := New(arm.SubscriptionID, arm.URL, autorest.NullAuthorizer{})
+	ctx := context.Background()
+
+	exists, err := c.ResourceGroupExists(ctx, "test-missing-rg")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = c.SubnetExists(ctx, "test-net-rg", "test-vnet", "test-vnet-database-subnet")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = c.VirtualNetworkExists(ctx, "test-missing-rg", "test-vnet")
+	require.NoError(t, err)
+	assert.False(t, exists, "a missing resource group holds no network")
+
+	// a deleted vault is soft-deleted, which is not the vault existing
+	req, err := http.NewRequest(http.MethodDelete, arm.URL+arm.ResourceID("test-net-rg", fakearm.TypeKeyVault, "testkv"), nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	exists, err = c.KeyVaultExists(ctx, "test-net-rg", "testkv")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, []string{"testkv"}, arm.DeletedVaults())
+}
+
+func TestCheckerReturnsOtherFailures(t *testing.T) {
+	arm := fakearm.New(t)
+	seed(arm)
+	arm.Fail(http.MethodGet, arm.ResourceID("test-net-rg", fakearm.TypeContainerGroup, "test-aci"), http.StatusForbidden, "AuthorizationFailed")
+	c := New(arm.SubscriptionID, arm.URL, autorest.NullAuthorizer{})
+
+	exists, err := c.ContainerGroupExists(context.Background(), "test-net-rg", "test-aci")
+	assert.False(t, exists)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AuthorizationFailed")
+}
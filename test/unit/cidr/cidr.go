@@ -0,0 +1,242 @@
+// Package cidr checks and plans the address ranges of the networking module:
+// every subnet prefix must lie inside the virtual network's address_space,
+// subnets must not overlap each other, and the virtual networks of different
+// environments must not overlap, so they can be peered later. It also
+// proposes the next free subnet in a network and the next free address
+// space for a new environment.
+package cidr
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+)
+
+// SubnetInputs are the networking module variables holding subnet prefixes,
+// in the order the module creates the subnets
+var SubnetInputs = []string{"container_subnet_prefix", "database_subnet_prefix", "vm_subnet_prefix"}
+
+// ErrNoSpace is returned when no free prefix of the requested size is left
+var ErrNoSpace = errors.New("no free prefix of the requested size")
+
+// Network is one virtual network, usually the networking unit of an
+// environment
+type Network struct {
+	Name         string         `json:"name"`
+	AddressSpace []netip.Prefix `json:"address_space"`
+	Subnets      []Subnet       `json:"subnets"`
+}
+
+// Subnet is a subnet prefix of a network, named after its module variable
+type Subnet struct {
+	Name   string       `json:"name"`
+	Prefix netip.Prefix `json:"prefix"`
+}
+
+// Problem is an address range that is outside its network or overlaps another
+type Problem struct {
+	Network string `json:"network"`
+	// Subnet is empty for problems with the address space itself
+	Subnet string `json:"subnet,omitempty"`
+	// Overlaps names the subnet or network this one overlaps, if any
+	Overlaps string `json:"overlaps,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Subnet == "" {
+		return fmt.Sprintf("%s: %s", p.Network, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Network, p.Subnet, p.Message)
+}
+
+// ParsePrefix parses a CIDR prefix the way Azure accepts it: the address must
+// be the first address of the range, so 10.1.2.0/24 is accepted and
+// 10.1.2.5/24 is not
+func ParsePrefix(s string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is not a CIDR prefix", s)
+	}
+	if prefix.Masked() != prefix {
+		return netip.Prefix{}, fmt.Errorf("%q has host bits set, use %s", s, prefix.Masked())
+	}
+	return prefix, nil
+}
+
+// Contains reports whether inner lies entirely inside outer
+func Contains(outer netip.Prefix, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+// FromVars returns the network described by the inputs of a networking module
+func FromVars(name string, vars tfvars.NetworkingVars) (Network, error) {
+	network := Network{Name: name}
+	for _, space := range vars.AddressSpace {
+		prefix, err := ParsePrefix(space)
+		if err != nil {
+			return Network{}, fmt.Errorf("%s: address_space: %w", name, err)
+		}
+		network.AddressSpace = append(network.AddressSpace, prefix)
+	}
+
+	prefixes := []string{vars.ContainerSubnetPrefix, vars.DatabaseSubnetPrefix, vars.VMSubnetPrefix}
+	for i, input := range SubnetInputs {
+		prefix, err := ParsePrefix(prefixes[i])
+		if err != nil {
+			return Network{}, fmt.Errorf("%s: %s: %w", name, input, err)
+		}
+		network.Subnets = append(network.Subnets, Subnet{Name: input, Prefix: prefix})
+	}
+	return network, nil
+}
+
+// Check returns every subnet outside its network's address space, every pair
+// of overlapping subnets within a network and every pair of overlapping
+// networks. The subnets of a network without an address space are only
+// checked for overlaps.
+func Check(networks ...Network) []Problem {
+	var problems []Problem
+	for _, network := range networks {
+		for i, subnet := range network.Subnets {
+			if len(network.AddressSpace) > 0 && !network.contains(subnet.Prefix) {
+				problems = append(problems, Problem{
+					Network: network.Name,
+					Subnet:  subnet.Name,
+					Message: fmt.Sprintf("%s is outside address_space %s", subnet.Prefix, join(network.AddressSpace)),
+				})
+			}
+			for _, other := range network.Subnets[:i] {
+				if subnet.Prefix.Overlaps(other.Prefix) {
+					problems = append(problems, Problem{
+						Network:  network.Name,
+						Subnet:   subnet.Name,
+						Overlaps: other.Name,
+						Message:  fmt.Sprintf("%s overlaps %s %s", subnet.Prefix, other.Name, other.Prefix),
+					})
+				}
+			}
+		}
+	}
+
+	for i, network := range networks {
+		for _, other := range networks[:i] {
+			for _, space := range network.AddressSpace {
+				for _, otherSpace := range other.AddressSpace {
+					if space.Overlaps(otherSpace) {
+						problems = append(problems, Problem{
+							Network:  network.Name,
+							Overlaps: other.Name,
+							Message:  fmt.Sprintf("address_space %s overlaps %s address_space %s", space, other.Name, otherSpace),
+						})
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func (n Network) contains(prefix netip.Prefix) bool {
+	for _, space := range n.AddressSpace {
+		if Contains(space, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextSubnet returns the lowest prefix of the given length inside the
+// network's address space that overlaps none of its subnets
+func NextSubnet(network Network, bits int) (netip.Prefix, error) {
+	taken := make([]netip.Prefix, len(network.Subnets))
+	for i, subnet := range network.Subnets {
+		taken[i] = subnet.Prefix
+	}
+	for _, space := range network.AddressSpace {
+		if prefix, err := next(space, taken, bits); err == nil {
+			return prefix, nil
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("%s: %w /%d", network.Name, ErrNoSpace, bits)
+}
+
+// NextRange returns the lowest prefix of the given length inside pool that
+// overlaps the address space of none of the networks, to use as the address
+// space of a new environment
+func NextRange(pool netip.Prefix, networks []Network, bits int) (netip.Prefix, error) {
+	var taken []netip.Prefix
+	for _, network := range networks {
+		taken = append(taken, network.AddressSpace...)
+	}
+	prefix, err := next(pool, taken, bits)
+	if errors.Is(err, ErrNoSpace) {
+		return netip.Prefix{}, fmt.Errorf("%s: %w /%d", pool, err, bits)
+	}
+	return prefix, err
+}
+
+// next walks the aligned prefixes of the given length in pool in address
+// order, jumping past every taken prefix a candidate overlaps
+func next(pool netip.Prefix, taken []netip.Prefix, bits int) (netip.Prefix, error) {
+	pool = pool.Masked()
+	if bits < pool.Bits() || bits > pool.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("/%d does not fit in %s", bits, pool)
+	}
+	candidate := netip.PrefixFrom(pool.Addr(), bits)
+	for pool.Contains(candidate.Addr()) {
+		blocked := false
+		for _, prefix := range taken {
+			if !candidate.Overlaps(prefix) {
+				continue
+			}
+			blocked = true
+			// continue after the end of whichever of the two is larger
+			end := prefix
+			if candidate.Bits() < prefix.Bits() {
+				end = candidate
+			}
+			after, ok := nextAfter(end, bits)
+			if !ok {
+				return netip.Prefix{}, ErrNoSpace
+			}
+			candidate = after
+			break
+		}
+		if !blocked {
+			return candidate, nil
+		}
+	}
+	return netip.Prefix{}, ErrNoSpace
+}
+
+// nextAfter returns the prefix of the given length that starts right after
+// the last address of prefix. prefix is either a candidate of that length or
+// a larger prefix, so the address after it is aligned.
+func nextAfter(prefix netip.Prefix, bits int) (netip.Prefix, bool) {
+	addr := lastAddr(prefix.Masked()).Next()
+	if !addr.IsValid() {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, bits), true
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+func join(prefixes []netip.Prefix) string {
+	names := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		names[i] = prefix.String()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,100 @@
+package cidr
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func network(name string, space string, subnets ...string) Network {
+	n := Network{Name: name, AddressSpace: []netip.Prefix{netip.MustParsePrefix(space)}}
+	for i, prefix := range subnets {
+		n.Subnets = append(n.Subnets, Subnet{Name: SubnetInputs[i], Prefix: netip.MustParsePrefix(prefix)})
+	}
+	return n
+}
+
+func TestParsePrefix(t *testing.T) {
+	prefix, err := ParsePrefix("10.1.2.0/24")
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("10.1.2.0/24"), prefix)
+
+	_, err = ParsePrefix("10.1.2.5/24")
+	assert.EqualError(t, err, `"10.1.2.5/24" has host bits set, use 10.1.2.0/24`)
+	_, err = ParsePrefix("10.1.2.0")
+	assert.EqualError(t, err, `"10.1.2.0" is not a CIDR prefix`)
+}
+
+func TestFromVarsUsesModuleDefaults(t *testing.T) {
+	network, err := FromVars("default", tfvars.NewNetworkingVars())
+	require.NoError(t, err)
+	assert.Empty(t, Check(network))
+
+	vars := tfvars.NewNetworkingVars()
+	vars.VMSubnetPrefix = "10.0.3.1/24"
+	_, err = FromVars("bad", vars)
+	assert.EqualError(t, err, `bad: vm_subnet_prefix: "10.0.3.1/24" has host bits set, use 10.0.3.0/24`)
+}
+
+func TestCheck(t *testing.T) {
+	assert.Empty(t, Check(
+		network("staging", "10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"),
+		network("test", "172.16.0.0/16", "172.16.10.0/24", "172.16.20.0/24", "172.16.30.0/24"),
+	))
+
+	problems := Check(
+		network("staging", "10.0.0.0/16", "10.1.1.0/24", "10.0.2.0/24", "10.0.2.128/25"),
+		network("production", "10.0.128.0/17", "10.0.129.0/24"),
+	)
+	var got []string
+	for _, problem := range problems {
+		got = append(got, problem.String())
+	}
+	assert.Equal(t, []string{
+		"staging: container_subnet_prefix: 10.1.1.0/24 is outside address_space 10.0.0.0/16",
+		"staging: vm_subnet_prefix: 10.0.2.128/25 overlaps database_subnet_prefix 10.0.2.0/24",
+		"production: address_space 10.0.128.0/17 overlaps staging address_space 10.0.0.0/16",
+	}, got)
+	assert.Equal(t, "database_subnet_prefix", problems[1].Overlaps)
+	assert.Equal(t, "staging", problems[2].Overlaps)
+}
+
+func TestNextSubnet(t *testing.T) {
+	prefix, err := NextSubnet(network("staging", "10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"), 24)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/24", prefix.String())
+
+	prefix, err = NextSubnet(network("staging", "10.0.0.0/16", "10.0.0.0/24", "10.0.1.0/24", "10.0.4.0/22"), 23)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.2.0/23", prefix.String())
+
+	_, err = NextSubnet(network("staging", "10.0.0.0/16", "10.0.0.0/17"), 16)
+	assert.ErrorIs(t, err, ErrNoSpace)
+
+	_, err = NextSubnet(network("full", "10.0.0.0/24", "10.0.0.0/25", "10.0.0.128/25"), 26)
+	assert.ErrorIs(t, err, ErrNoSpace)
+}
+
+func TestNextRange(t *testing.T) {
+	networks := []Network{
+		network("staging", "10.0.0.0/16"),
+		network("production", "10.1.0.0/16"),
+		network("sandbox", "10.3.0.0/16"),
+	}
+	prefix, err := NextRange(netip.MustParsePrefix("10.0.0.0/8"), networks, 16)
+	require.NoError(t, err)
+	assert.Equal(t, "10.2.0.0/16", prefix.String())
+
+	prefix, err = NextRange(netip.MustParsePrefix("10.0.0.0/8"), networks, 15)
+	require.NoError(t, err)
+	assert.Equal(t, "10.4.0.0/15", prefix.String())
+
+	_, err = NextRange(netip.MustParsePrefix("10.0.0.0/15"), networks, 16)
+	assert.ErrorIs(t, err, ErrNoSpace)
+
+	_, err = NextRange(netip.MustParsePrefix("10.0.0.0/16"), nil, 8)
+	assert.EqualError(t, err, "/8 does not fit in 10.0.0.0/16")
+}
@@ -0,0 +1,126 @@
+// Command cidrplan checks and plans the address ranges of the environments'
+// networking units. By default it prints the address space and subnets of
+// every environment and reports subnets outside their address space,
+// overlapping subnets and overlapping environments.
+//
+// Usage:
+//
+//	go run ./cmd/cidrplan [-root <repository root>] [-json]
+//	go run ./cmd/cidrplan -next-subnet <environment> [-bits 24]
+//	go run ./cmd/cidrplan -next-env [-pool 10.0.0.0/8] [-bits 16]
+//
+// -next-subnet prints the lowest free prefix of the given length inside the
+// environment's address space, and -next-env the lowest free address space
+// in the pool for a new environment. It exits 1 when the ranges overlap or
+// nothing is free, or 2 when the configuration cannot be read.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"text/tabwriter"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/cidr"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	asJSON := flag.Bool("json", false, "write the networks and problems as JSON")
+	nextSubnet := flag.String("next-subnet", "", "propose a free subnet in this environment's address space")
+	nextEnv := flag.Bool("next-env", false, "propose a free address space for a new environment")
+	pool := flag.String("pool", "10.0.0.0/8", "range environment address spaces are allocated from, with -next-env")
+	bits := flag.Int("bits", 0, "prefix length to propose (default: 24 with -next-subnet, 16 with -next-env)")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+	networks, err := inputs.Networks(*root)
+	if err != nil {
+		fail(err)
+	}
+
+	switch {
+	case *nextSubnet != "":
+		if *bits == 0 {
+			*bits = 24
+		}
+		for _, network := range networks {
+			if network.Name == *nextSubnet {
+				propose(cidr.NextSubnet(network, *bits))
+			}
+		}
+		fail(fmt.Errorf("no networking unit in environment %q", *nextSubnet))
+	case *nextEnv:
+		if *bits == 0 {
+			*bits = 16
+		}
+		prefix, err := cidr.ParsePrefix(*pool)
+		if err != nil {
+			fail(err)
+		}
+		propose(cidr.NextRange(prefix, networks, *bits))
+	}
+
+	problems := cidr.Check(networks...)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(struct {
+			Networks []cidr.Network `json:"networks"`
+			Problems []cidr.Problem `json:"problems"`
+		}{networks, problems})
+	} else {
+		err = writeText(networks, problems)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d address range problem(s)\n", len(problems))
+		os.Exit(1)
+	}
+}
+
+func writeText(networks []cidr.Network, problems []cidr.Problem) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENVIRONMENT\tNAME\tPREFIX")
+	for _, network := range networks {
+		for _, space := range network.AddressSpace {
+			fmt.Fprintf(w, "%s\taddress_space\t%s\n", network.Name, space)
+		}
+		for _, subnet := range network.Subnets {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", network.Name, subnet.Name, subnet.Prefix)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	return nil
+}
+
+func propose(prefix netip.Prefix, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(prefix)
+	os.Exit(0)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
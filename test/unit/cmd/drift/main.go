@@ -0,0 +1,135 @@
+// Command drift runs a refresh-only plan in every unit of an environment and
+// reports what was changed outside Terraform, such as an NSG rule added in the
+// portal, by resource and attribute.
+//
+// Usage:
+//
+//	go run ./cmd/drift -env <environment> [-root <repository root>] [-unit <name>]... [-plan tfdrift] [-terragrunt terragrunt] [-from-json <file>] [-json] [-out <file>] [-title <text>] [-markdown <file>] [-jira <file>]
+//
+// It runs terragrunt plan -refresh-only -out=<plan> in each unit and reads
+// the plan's resource drift through terragrunt show -json, or with -from-json
+// reads refresh-only plans already converted into that file of each unit.
+// Each drifted resource is changed or deleted, and each of its attributes
+// added, removed or changed; sensitive values are masked. -out also writes
+// the JSON report to a file, -markdown and -jira write it as Markdown and in
+// Jira wiki markup. It exits 1 when a unit drifted, or 2 when a plan errored
+// or cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+)
+
+// namesFlag collects repeated -unit flags
+type namesFlag []string
+
+func (f *namesFlag) String() string     { return strings.Join(*f, ",") }
+func (f *namesFlag) Set(s string) error { *f = append(*f, s); return nil }
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "", "environment whose units are checked, e.g. production")
+	var names namesFlag
+	flag.Var(&names, "unit", "only this unit, e.g. networking, may be repeated")
+	planFile := flag.String("plan", "tfdrift", "plan file the refresh-only plan is saved to in each unit")
+	terragrunt := flag.String("terragrunt", "terragrunt", "terragrunt binary")
+	fromJSON := flag.String("from-json", "", "read the JSON refresh-only plan from this file in each unit instead of running terragrunt")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	out := flag.String("out", "", "also write the JSON report to this file")
+	title := flag.String("title", "", "title of the rendered reports (default: Drift: <environment>)")
+	markdown := flag.String("markdown", "", "also write the report as Markdown to this file")
+	jira := flag.String("jira", "", "also write the report in Jira wiki markup to this file")
+	flag.Parse()
+
+	if *env == "" {
+		fail(fmt.Errorf("-env is required"))
+	}
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+	if *title == "" {
+		*title = "Drift: " + *env
+	}
+
+	units, err := plan.Units(*root, *env, names...)
+	if err != nil {
+		fail(err)
+	}
+	show := plan.RefreshOnly(*terragrunt, *planFile)
+	if *fromJSON != "" {
+		show = plan.Files(*fromJSON)
+	}
+	report, err := plan.CollectDrift(*root, *env, units, show)
+	if err != nil {
+		fail(err)
+	}
+
+	files := []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{*out, report.WriteJSON},
+		{*markdown, func(w io.Writer) error {
+			_, err := io.WriteString(w, report.Markdown(*title))
+			return err
+		}},
+		{*jira, func(w io.Writer) error {
+			_, err := io.WriteString(w, report.Jira(*title))
+			return err
+		}},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		if err := writeFile(file.path, file.write); err != nil {
+			fail(err)
+		}
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	switch {
+	case report.Errored():
+		fail(fmt.Errorf("a refresh-only plan errored and is incomplete"))
+	case len(report.Drifted) > 0:
+		fmt.Fprintf(os.Stderr, "%d of %d unit(s) drifted: %d resource(s) changed outside Terraform\n", len(report.Drifted), len(report.Units), report.Resources)
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "no drift in %d unit(s)\n", len(report.Units))
+	}
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
@@ -0,0 +1,77 @@
+// Command envdiff resolves the inputs of every unit in two environments,
+// including those inherited from env.hcl, and prints how they differ. Every
+// difference is either declared in the allowlist file with a reason or
+// reported as unexpected.
+//
+// Usage:
+//
+//	go run ./cmd/envdiff [-root <repository root>] [-from staging] [-to production] [-allowlist <file>] [-json]
+//
+// get_env reads the environment, so run it with the same TF_VAR_ variables as
+// the plan, or with none for the defaults. It exits 1 when a difference is
+// not declared, or 2 when the configuration or the allowlist cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/envdiff"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+// defaultAllowlist is read when -allowlist is not given, relative to the
+// repository root
+const defaultAllowlist = "test/unit/envdiff/allowlist.yaml"
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	from := flag.String("from", "staging", "environment to compare from")
+	to := flag.String("to", "production", "environment to compare to")
+	allowlistPath := flag.String("allowlist", "", "intentional differences (default: "+defaultAllowlist+")")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+
+	path := *allowlistPath
+	if path == "" {
+		path = filepath.Join(*root, defaultAllowlist)
+	}
+	allowlist, err := envdiff.LoadAllowlist(path)
+	if err != nil && (*allowlistPath != "" || !os.IsNotExist(err)) {
+		fail(err)
+	}
+
+	report, err := envdiff.Compare(*root, *from, *to, allowlist)
+	if err != nil {
+		fail(err)
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	expected, unexpected := report.Counts()
+	fmt.Fprintf(os.Stderr, "%d unexpected, %d expected difference(s) between %s and %s\n", unexpected, expected, *from, *to)
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
@@ -0,0 +1,75 @@
+// Command janitor deletes resource groups leaked by the tests. It lists the
+// groups tagged ManagedBy=terratest, deletes those whose ExpiresAt tag (or
+// CreatedAt tag plus -ttl) has passed, and purges soft-deleted Key Vaults
+// carrying the same tag.
+//
+// Usage:
+//
+//	go run ./cmd/janitor [-subscription <id>] [-dry-run] [-json] [-ttl <duration>] [-timeout <duration>] [-endpoint <url>]
+//
+// Credentials are read like terratest's azure package does: AZURE_CLIENT_ID
+// and AZURE_TENANT_ID (with a secret or certificate), AZURE_AUTH_LOCATION, or
+// the Azure CLI login. It exits 1 when any resource could not be removed, or 2
+// when the resources cannot be listed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	az "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/janitor"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/gruntwork-io/terratest/modules/azure"
+)
+
+func main() {
+	subscription := flag.String("subscription", "", "subscription to clean (default: $ARM_SUBSCRIPTION_ID)")
+	dryRun := flag.Bool("dry-run", false, "report what would be deleted without deleting anything")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	ttl := flag.Duration("ttl", naming.DefaultTTL, "lifetime of groups that have a CreatedAt tag but no ExpiresAt tag")
+	timeout := flag.Duration("timeout", 30*time.Minute, "maximum time to wait for deletions to complete")
+	endpoint := flag.String("endpoint", az.PublicCloud.ResourceManagerEndpoint, "Azure Resource Manager endpoint")
+	flag.Parse()
+
+	subscriptionID, err := azure.GetTargetAzureSubscription(*subscription)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	authorizer, err := azure.NewAuthorizer()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	reaper := janitor.New(subscriptionID, *endpoint, *authorizer)
+	reaper.TTL = *ttl
+	reaper.DryRun = *dryRun
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, runErr := reaper.Run(ctx)
+	if report != nil {
+		if *asJSON {
+			err = report.WriteJSON(os.Stdout)
+		} else {
+			err = report.WriteText(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if runErr != nil {
+		fmt.Fprintln(os.Stderr, runErr)
+		os.Exit(2)
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
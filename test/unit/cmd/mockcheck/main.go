@@ -0,0 +1,124 @@
+// Command mockcheck checks that the mock_outputs of every Terragrunt
+// dependency block look like the outputs they stand in for: an ARM resource
+// ID of the right type, a GUID, an FQDN or a location, as inferred from the
+// resource attribute the dependency's module outputs.
+//
+// Usage:
+//
+//	go run ./cmd/mockcheck [-root <repository root>] [-generate | -fix] [-location eastus] [-json]
+//
+// -generate prints each dependency block with realistic mocks for every output
+// the unit mocks or reads; -fix rewrites the mock_outputs that do not look
+// right, or are missing outputs, in place. It exits 1 when a mock does not
+// look right, or 2 when the configuration cannot be read.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/mocks"
+)
+
+// output is an entry of the JSON report
+type output struct {
+	Unit       string `json:"unit"`
+	Dependency string `json:"dependency"`
+	Output     string `json:"output"`
+	mocks.Expectation
+	Mock     string `json:"mock,omitempty"`
+	Location string `json:"location,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	generate := flag.Bool("generate", false, "print the dependency blocks with generated mocks")
+	fix := flag.Bool("fix", false, "rewrite mock_outputs that do not look right in place")
+	location := flag.String("location", "eastus", "location used in generated mocks")
+	asJSON := flag.Bool("json", false, "write every mocked or read output with its expected shape as JSON")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+
+	dependencies, err := mocks.Load(*root)
+	if err != nil {
+		fail(err)
+	}
+
+	switch {
+	case *generate:
+		for _, d := range dependencies {
+			fmt.Printf("# %s\n%s\n", d.Unit, d.Block(d.Generate(*location)))
+		}
+		return
+	case *fix:
+		changed, err := mocks.Fix(*root, dependencies, *location)
+		if err != nil {
+			fail(err)
+		}
+		for _, path := range changed {
+			fmt.Println(path)
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s) rewritten\n", len(changed))
+		return
+	}
+
+	count := 0
+	var outputs []output
+	for _, d := range dependencies {
+		diagnostics := d.Check()
+		count += len(diagnostics)
+		if !*asJSON {
+			for _, diagnostic := range diagnostics {
+				fmt.Println(diagnostic)
+			}
+			continue
+		}
+		problems := map[string]string{}
+		for _, mock := range d.Mocks {
+			for _, diagnostic := range diagnostics {
+				if diagnostic.Range == mock.Range {
+					problems[mock.Output] = diagnostic.Message
+				}
+			}
+		}
+		for _, name := range d.Outputs {
+			entry := output{Unit: d.Unit, Dependency: d.Name, Output: name, Expectation: d.Expect(name), Problem: problems[name]}
+			for _, mock := range d.Mocks {
+				if mock.Output == name {
+					entry.Mock = mock.Text
+					entry.Location = fmt.Sprintf("%s:%d:%d", mock.Range.Filename, mock.Range.Start.Line, mock.Range.Start.Column)
+				}
+			}
+			outputs = append(outputs, entry)
+		}
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(outputs); err != nil {
+			fail(err)
+		}
+	}
+
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "%d unrealistic mock output(s), run with -fix to replace them\n", count)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "All mock outputs look like the outputs they stand in for")
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
@@ -0,0 +1,89 @@
+// Command nsgexposure resolves the network security group rules of every
+// environment with the environment's Terragrunt inputs and prints, for every
+// port a rule opens, whether the internet, a specific public range, private
+// addresses and the virtual network can reach it.
+//
+// SSH, RDP and Splunk Web reachable from the whole internet are reported in
+// every environment and fail the check in the strict ones. Exposures accepted
+// on purpose go in the allowlist file with a reason.
+//
+// Usage:
+//
+//	go run ./cmd/nsgexposure [-root <repository root>] [-env <environment>] [-strict production] [-allowlist <file>] [-json]
+//
+// get_env reads the environment, so run it with the same TF_VAR_ variables as
+// the plan. It exits 1 when a strict environment exposes a sensitive port, or
+// 2 when the configuration or the allowlist cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/exposure"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+// defaultAllowlist is read when -allowlist is not given, relative to the
+// repository root
+const defaultAllowlist = "test/unit/exposure/allowlist.yaml"
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "", "only analyze the units of this environment, e.g. production")
+	strict := flag.String("strict", "production", "comma-separated environments in which internet-exposed sensitive ports fail")
+	allowlistPath := flag.String("allowlist", "", "accepted exposures (default: "+defaultAllowlist+")")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+
+	options := exposure.Options{Env: *env}
+	for _, name := range strings.Split(*strict, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			options.Strict = append(options.Strict, name)
+		}
+	}
+	path := *allowlistPath
+	if path == "" {
+		path = filepath.Join(*root, defaultAllowlist)
+	}
+	allowlist, err := exposure.LoadAllowlist(path)
+	switch {
+	case err == nil:
+		options.Allowlist = allowlist
+	case *allowlistPath != "" || !os.IsNotExist(err):
+		fail(err)
+	}
+
+	report, err := exposure.Analyze(*root, options)
+	if err != nil {
+		fail(err)
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if report.Failed() {
+		fmt.Fprintln(os.Stderr, "sensitive ports are reachable from the internet in a strict environment")
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
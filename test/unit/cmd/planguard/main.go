@@ -0,0 +1,146 @@
+// Command planguard reads the saved plan of every unit of an environment and
+// fails when a protected resource would be deleted or replaced, printing the
+// attributes that force each replacement.
+//
+// Usage:
+//
+//	go run ./cmd/planguard [-root <repository root>] [-env production] [-protected <file>] [-plan tfplan] [-terragrunt terragrunt] [-from-json <file>] [-override <token> -reason <text>] [-audit <file>] [-json]
+//
+// A blocked run prints an override token for exactly its destructive
+// changes. Running again with that token and a reason lets the plan through
+// and appends who accepted what, and why, to the -audit log. It exits 1 when
+// a protected resource would be destroyed without a valid override, or 2
+// when a plan errored or a plan or the protected list cannot be read.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/guard"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+)
+
+// defaultProtected is read when -protected is not given, relative to the
+// repository root
+const defaultProtected = "test/unit/guard/protected.yaml"
+
+// report is the JSON output
+type report struct {
+	Environment string            `json:"environment"`
+	Violations  []guard.Violation `json:"violations"`
+	Token       string            `json:"token,omitempty"`
+	Override    *guard.Override   `json:"override,omitempty"`
+	Summary     *plan.Summary     `json:"summary"`
+}
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "production", "environment whose units' plans are checked")
+	protectedPath := flag.String("protected", "", "protected resources (default: "+defaultProtected+")")
+	planFile := flag.String("plan", "tfplan", "plan file terragrunt plan -out wrote in each unit")
+	terragrunt := flag.String("terragrunt", "terragrunt", "terragrunt binary")
+	fromJSON := flag.String("from-json", "", "read the JSON plan from this file in each unit instead of running terragrunt show")
+	token := flag.String("override", "", "override token printed by a blocked run, accepting its destructive changes")
+	reason := flag.String("reason", "", "why the destructive changes are accepted, required with -override")
+	audit := flag.String("audit", "destroy-overrides.jsonl", "JSON lines file accepted overrides are appended to")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+	if *protectedPath == "" {
+		*protectedPath = filepath.Join(*root, defaultProtected)
+	}
+	protected, err := guard.LoadProtected(*protectedPath)
+	if err != nil {
+		fail(err)
+	}
+
+	units, err := plan.Units(*root, *env)
+	if err != nil {
+		fail(err)
+	}
+	show := plan.Terragrunt(*terragrunt, *planFile)
+	if *fromJSON != "" {
+		show = plan.Files(*fromJSON)
+	}
+	summary, err := plan.Collect(*root, units, show)
+	if err != nil {
+		fail(err)
+	}
+	// an errored plan lists only some of its resource changes, so what it
+	// would destroy is unknown
+	var errored []string
+	for _, u := range summary.Units {
+		if u.Errored {
+			errored = append(errored, u.Unit)
+		}
+	}
+	if len(errored) > 0 {
+		fail(fmt.Errorf("the plan of %s errored, so its destructive changes cannot be checked", strings.Join(errored, ", ")))
+	}
+
+	out := report{Environment: *env, Violations: protected.Check(summary), Summary: summary}
+	if out.Violations == nil {
+		out.Violations = []guard.Violation{}
+	}
+	var overrideErr error
+	if len(out.Violations) > 0 {
+		out.Token = guard.Token(*env, out.Violations)
+		if *token != "" {
+			out.Override, overrideErr = guard.Authorize(*env, out.Violations, *token, *reason, time.Now())
+		}
+	}
+	if out.Override != nil {
+		if err := out.Override.Append(*audit); err != nil {
+			fail(err)
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			fail(err)
+		}
+	} else {
+		for _, violation := range out.Violations {
+			fmt.Println(violation)
+		}
+	}
+
+	switch {
+	case len(out.Violations) == 0:
+		fmt.Fprintf(os.Stderr, "no protected resource would be deleted or replaced in %d unit(s)\n", len(summary.Units))
+		if *token != "" {
+			fmt.Fprintln(os.Stderr, "the override was not needed and is not recorded")
+		}
+	case out.Override != nil:
+		fmt.Fprintf(os.Stderr, "%d protected resource(s) would be deleted or replaced; override %s accepted by %s: %s (recorded in %s)\n",
+			len(out.Violations), out.Token, out.Override.User, out.Override.Reason, *audit)
+	default:
+		if overrideErr != nil {
+			fmt.Fprintln(os.Stderr, overrideErr)
+		}
+		fmt.Fprintf(os.Stderr, "%d protected resource(s) would be deleted or replaced. To apply anyway, review them and rerun with -override %s -reason <why>\n",
+			len(out.Violations), out.Token)
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
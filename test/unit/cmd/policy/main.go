@@ -0,0 +1,127 @@
+// Command policy evaluates the repository's policy rules against every
+// Terragrunt unit, with the unit's inputs resolved as validate-inputs does.
+// The rules are the Go rules of the policy package and those in
+// policy/rules.yaml; waivers in policy/waivers.yaml accept violations until
+// they expire.
+//
+// Usage:
+//
+//	go run ./cmd/policy [-root <repository root>] [-env <environment>] [-rules <file>]... [-waivers <file>] [-format text|json|sarif] [-sarif <file>] [-list]
+//
+// -sarif writes a SARIF log for code scanning next to the regular output.
+// get_env reads the environment, so run it with the same TF_VAR_ variables as
+// the plan. It exits 1 when an error-severity violation is not waived, or 2
+// when the configuration, rules or waivers cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/policy"
+)
+
+// rulesFlag collects repeated -rules flags
+type rulesFlag []string
+
+func (f *rulesFlag) String() string     { return strings.Join(*f, ",") }
+func (f *rulesFlag) Set(s string) error { *f = append(*f, s); return nil }
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "", "only check the units of this environment, e.g. production")
+	var rulePaths rulesFlag
+	flag.Var(&rulePaths, "rules", "YAML rule file, may be repeated (default: test/unit/policy/rules.yaml)")
+	waiversPath := flag.String("waivers", "", "waiver file (default: test/unit/policy/waivers.yaml)")
+	format := flag.String("format", "text", "output format: text, json or sarif")
+	sarifPath := flag.String("sarif", "", "also write a SARIF log to this file")
+	list := flag.Bool("list", false, "list the rules and their severities instead of evaluating them")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+	if len(rulePaths) == 0 {
+		rulePaths = rulesFlag{filepath.Join(*root, "test", "unit", "policy", "rules.yaml")}
+	}
+	if *waiversPath == "" {
+		*waiversPath = filepath.Join(*root, "test", "unit", "policy", "waivers.yaml")
+	}
+
+	rules, err := policy.LoadRules(rulePaths...)
+	if err != nil {
+		fail(err)
+	}
+	if *list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RULE\tMODULE\tSEVERITY\tDESCRIPTION")
+		for _, rule := range rules {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rule.ID, rule.Module, policy.Describe(rule), rule.Description)
+		}
+		if err := w.Flush(); err != nil {
+			fail(err)
+		}
+		return
+	}
+	waivers, err := policy.LoadWaivers(*waiversPath)
+	if err != nil && !os.IsNotExist(err) {
+		fail(err)
+	}
+
+	engine := &policy.Engine{Rules: rules, Waivers: waivers}
+	report, err := engine.Evaluate(*root, *env)
+	if err != nil {
+		fail(err)
+	}
+
+	switch *format {
+	case "text":
+		err = report.WriteText(os.Stdout)
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	case "sarif":
+		err = report.WriteSARIF(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if *sarifPath != "" {
+		if err := writeSARIF(report, *sarifPath); err != nil {
+			fail(err)
+		}
+	}
+
+	failing, waived, other := report.Counts()
+	fmt.Fprintf(os.Stderr, "%d failing, %d waived, %d other policy result(s)\n", failing, waived, other)
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+func writeSARIF(report *policy.Report, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteSARIF(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
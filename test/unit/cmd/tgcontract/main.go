@@ -0,0 +1,54 @@
+// Command tgcontract checks every environments/<env>/<unit>/terragrunt.hcl
+// against the Terraform modules it applies and depends on, without Azure
+// credentials or a Terragrunt backend:
+//
+//   - dependency.<name>.outputs.<key> references and mock_outputs keys must
+//     be outputs of the dependency's module
+//   - every key in inputs must be a variable of the unit's module
+//   - every module variable without a default must be set in inputs
+//
+// Usage:
+//
+//	go run ./cmd/tgcontract [-root <repository root>]
+//
+// It prints one file:line:column diagnostic per violation and exits 1 when
+// any are found, or 2 when the configuration cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		*root = detected
+	}
+
+	diagnostics, err := contract.CheckEnvironments(*root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	for _, diagnostic := range diagnostics {
+		fmt.Println(diagnostic)
+	}
+	if len(diagnostics) > 0 {
+		fmt.Fprintf(os.Stderr, "%d Terragrunt contract violation(s)\n", len(diagnostics))
+		os.Exit(1)
+	}
+	fmt.Println("All Terragrunt units match their modules")
+}
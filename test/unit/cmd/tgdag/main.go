@@ -0,0 +1,145 @@
+// Command tgdag builds the Terragrunt dependency graph of the environments
+// from their dependency blocks and prints the apply order, the destroy order
+// or the graph itself. Dependency cycles are reported with the units that
+// form them.
+//
+// Usage:
+//
+//	go run ./cmd/tgdag [-root <repository root>] [-env <environment>] [-format text|json|dot|mermaid] [-destroy] [-affected <path>]...
+//
+// -affected lists the units a change to the given files or directories
+// affects, such as modules/networking, in apply order; with dot or mermaid
+// it highlights them instead. It exits 1 when the graph has a cycle, or 2
+// when the configuration cannot be read.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/dag"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+// pathsFlag collects repeated -affected flags
+type pathsFlag []string
+
+func (f *pathsFlag) String() string     { return strings.Join(*f, ",") }
+func (f *pathsFlag) Set(s string) error { *f = append(*f, s); return nil }
+
+// report is the JSON output
+type report struct {
+	Units    []*dag.Unit `json:"units"`
+	Apply    [][]string  `json:"apply"`
+	Destroy  [][]string  `json:"destroy"`
+	Cycles   [][]string  `json:"cycles"`
+	Affected []string    `json:"affected,omitempty"`
+}
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "", "only the units of this environment and what they depend on, e.g. staging")
+	format := flag.String("format", "text", "output format: text, json, dot or mermaid")
+	destroy := flag.Bool("destroy", false, "print the destroy order instead of the apply order")
+	var changed pathsFlag
+	flag.Var(&changed, "affected", "changed file or directory relative to the repository root, may be repeated")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+
+	g, err := dag.Load(*root, *env)
+	if err != nil {
+		fail(err)
+	}
+	var affected []string
+	if len(changed) > 0 {
+		affected = g.Affected(changed...)
+	}
+	cycles := g.Cycles()
+	var highlight []string
+	for _, cycle := range cycles {
+		highlight = append(highlight, cycle...)
+	}
+	if len(changed) > 0 {
+		highlight = affected
+	}
+
+	switch *format {
+	case "text":
+		err = writeText(g, affected, len(changed) > 0, *destroy)
+	case "json":
+		err = writeJSON(g, cycles, affected)
+	case "dot":
+		err = g.WriteDOT(os.Stdout, highlight...)
+	case "mermaid":
+		err = g.WriteMermaid(os.Stdout, highlight...)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	var cycleErr *dag.CycleError
+	if err != nil && !errors.As(err, &cycleErr) {
+		fail(err)
+	}
+
+	if len(cycles) > 0 {
+		fmt.Fprintln(os.Stderr, &dag.CycleError{Cycles: cycles})
+		os.Exit(1)
+	}
+	if len(changed) > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d unit(s) affected\n", len(affected), len(g.Units))
+	}
+}
+
+// writeText prints the affected units one per line, or the groups of the
+// order numbered from 1
+func writeText(g *dag.Graph, affected []string, onlyAffected bool, destroy bool) error {
+	if onlyAffected {
+		for _, path := range affected {
+			fmt.Println(path)
+		}
+		return nil
+	}
+	order := g.ApplyOrder
+	if destroy {
+		order = g.DestroyOrder
+	}
+	groups, err := order()
+	if err != nil {
+		return err
+	}
+	for i, group := range groups {
+		fmt.Printf("%d\t%s\n", i+1, strings.Join(group, " "))
+	}
+	return nil
+}
+
+func writeJSON(g *dag.Graph, cycles [][]string, affected []string) error {
+	out := report{Cycles: cycles, Affected: affected}
+	if out.Cycles == nil {
+		out.Cycles = [][]string{}
+	}
+	for _, path := range g.Paths() {
+		out.Units = append(out.Units, g.Units[path])
+	}
+	out.Apply, _ = g.ApplyOrder()
+	out.Destroy, _ = g.DestroyOrder()
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
@@ -0,0 +1,145 @@
+// Command tgplan summarises the saved Terraform plans of the units of an
+// environment from their JSON form: what each plan does to every resource
+// address, the counts per unit and which outputs change.
+//
+// Usage:
+//
+//	go run ./cmd/tgplan -env <environment> [-root <repository root>] [-unit <name>]... [-plan tfplan] [-terragrunt terragrunt] [-from-json <file>] [-json] [-out <file>] [-title <text>] [-markdown <file>] [-discord <file>] [-jira <file>]
+//
+// It runs terragrunt show -json on the plan file terragrunt plan -out left in
+// each unit, or with -from-json reads plans already converted into that file
+// of each unit. -out also writes the JSON summary to a file. -markdown,
+// -discord and -jira write the summary for a pull request comment, as a
+// Discord embed and in Jira wiki markup, with replacements highlighted and
+// sensitive values masked.
+//
+// Like terraform plan -detailed-exitcode it exits 0 when no plan has changes,
+// 2 when one has and 1 when a plan errored or cannot be read. go run exits 1
+// for any failure, so build the command when branching on the exit code.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+)
+
+// namesFlag collects repeated -unit flags
+type namesFlag []string
+
+func (f *namesFlag) String() string     { return strings.Join(*f, ",") }
+func (f *namesFlag) Set(s string) error { *f = append(*f, s); return nil }
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "", "environment whose units' plans are read, e.g. staging")
+	var names namesFlag
+	flag.Var(&names, "unit", "only this unit, e.g. networking, may be repeated")
+	planFile := flag.String("plan", "tfplan", "plan file terragrunt plan -out wrote in each unit")
+	terragrunt := flag.String("terragrunt", "terragrunt", "terragrunt binary")
+	fromJSON := flag.String("from-json", "", "read the JSON plan from this file in each unit instead of running terragrunt show")
+	asJSON := flag.Bool("json", false, "write the summary as JSON")
+	out := flag.String("out", "", "also write the JSON summary to this file")
+	title := flag.String("title", "", "title of the rendered summaries (default: Terraform plan: <environment>)")
+	markdown := flag.String("markdown", "", "also write the summary as Markdown for a pull request comment to this file")
+	discord := flag.String("discord", "", "also write the summary as a Discord embed to this file")
+	jira := flag.String("jira", "", "also write the summary in Jira wiki markup to this file")
+	flag.Parse()
+
+	if *env == "" {
+		fail(fmt.Errorf("-env is required"))
+	}
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fail(err)
+		}
+		*root = detected
+	}
+	if *title == "" {
+		*title = "Terraform plan: " + *env
+	}
+
+	units, err := plan.Units(*root, *env, names...)
+	if err != nil {
+		fail(err)
+	}
+	show := plan.Terragrunt(*terragrunt, *planFile)
+	if *fromJSON != "" {
+		show = plan.Files(*fromJSON)
+	}
+	summary, err := plan.Collect(*root, units, show)
+	if err != nil {
+		fail(err)
+	}
+
+	files := []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{*out, summary.WriteJSON},
+		{*markdown, func(w io.Writer) error {
+			_, err := io.WriteString(w, summary.Markdown(*title))
+			return err
+		}},
+		{*discord, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(summary.Discord(*title))
+		}},
+		{*jira, func(w io.Writer) error {
+			_, err := io.WriteString(w, summary.Jira(*title))
+			return err
+		}},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		if err := writeFile(file.path, file.write); err != nil {
+			fail(err)
+		}
+	}
+	if *asJSON {
+		err = summary.WriteJSON(os.Stdout)
+	} else {
+		err = summary.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	code := summary.ExitCode()
+	switch code {
+	case plan.ExitError:
+		fmt.Fprintln(os.Stderr, "a plan errored and is incomplete")
+	case plan.ExitChanges:
+		fmt.Fprintf(os.Stderr, "%d of %d unit(s) have changes: %s\n", len(summary.Changed), len(summary.Units), summary.Counts)
+	default:
+		fmt.Fprintf(os.Stderr, "no changes in %d unit(s)\n", len(summary.Units))
+	}
+	os.Exit(code)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(plan.ExitError)
+}
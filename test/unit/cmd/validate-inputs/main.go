@@ -0,0 +1,61 @@
+// Command validate-inputs evaluates the inputs block of every
+// environments/<env>/<unit>/terragrunt.hcl and checks the values against the
+// module the unit applies, without Azure credentials or a Terragrunt backend:
+//
+//   - every input must convert to its variable's type and pass the
+//     variable's validation blocks
+//   - per-module rules reject values Azure only refuses at apply time, such
+//     as subnet prefixes outside address_space, unknown SQL or Log Analytics
+//     SKUs, retention periods out of range or an OS disk smaller than the
+//     image
+//
+// get_env reads the environment, so run it with the same TF_VAR_ variables
+// as the plan. Inputs built from dependency outputs are only known after the
+// dependency is applied and are skipped.
+//
+// Usage:
+//
+//	go run ./cmd/validate-inputs [-root <repository root>] [-env <environment>]
+//
+// It prints one file:line:column diagnostic per invalid input and exits 1
+// when any are found, or 2 when the configuration cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	env := flag.String("env", "", "only check the units of this environment, e.g. production")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		*root = detected
+	}
+
+	diagnostics, err := inputs.Validate(*root, *env)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	for _, diagnostic := range diagnostics {
+		fmt.Println(diagnostic)
+	}
+	if len(diagnostics) > 0 {
+		fmt.Fprintf(os.Stderr, "%d invalid Terragrunt input(s)\n", len(diagnostics))
+		os.Exit(1)
+	}
+	fmt.Println("All Terragrunt inputs are valid")
+}
@@ -0,0 +1,60 @@
+// Command varcoverage reports which module variables and count/for_each
+// branches the Go test suite exercises, by reading the tests' tfvars input
+// structs statically and comparing them with each module's variables.tf.
+//
+// Usage:
+//
+//	go run ./cmd/varcoverage [-root <repository root>] [-json] [-min-variables <percent>] [-min-branches <percent>]
+//
+// It exits 1 when any module is below one of the minimums, or 2 when the
+// sources cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/coverage"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+)
+
+func main() {
+	root := flag.String("root", "", "repository root (default: detected from the working directory)")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	minVariables := flag.Float64("min-variables", 0, "minimum percentage of variables per module tested with a non-default value")
+	minBranches := flag.Float64("min-branches", 0, "minimum percentage of count/for_each on/off states per module hit by the tests")
+	flag.Parse()
+
+	if *root == "" {
+		detected, err := repo.Root()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		*root = detected
+	}
+
+	report, err := coverage.Analyze(*root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if failures := report.BelowMinimum(*minVariables, *minBranches); len(failures) > 0 {
+		for _, failure := range failures {
+			fmt.Fprintln(os.Stderr, failure)
+		}
+		os.Exit(1)
+	}
+}
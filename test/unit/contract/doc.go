@@ -0,0 +1,21 @@
+// Package contract holds offline checks that the Go tests, the Terragrunt
+// environments and the Terraform modules agree with each other. Nothing in
+// this package talks to Azure or runs terraform; the checks parse the module
+// and Terragrunt sources with the HCL parser and the test sources with
+// go/parser, so they finish in seconds and catch mismatches long before an
+// apply would.
+//
+// outputs_test.go fails when a terraform.Output call in any Go file under
+// test/unit reads an output name that the module's outputs.tf does not
+// declare.
+//
+// CheckEnvironments resolves the terraform.source of every
+// environments/<env>/<unit>/terragrunt.hcl to a module under modules/ and
+// reports dependency outputs and mock_outputs keys the dependency's module
+// does not declare, inputs that are not module variables, and variables
+// without a default that no input sets. It backs both terragrunt_test.go and
+// the tgcontract command:
+//
+//	go test ./contract/...
+//	go run ./cmd/tgcontract
+package contract
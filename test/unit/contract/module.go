@@ -0,0 +1,100 @@
+package contract
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// Module is the interface of a Terraform module: the variables it accepts and
+// the outputs it declares, gathered from every .tf file in its directory
+type Module struct {
+	Dir       string
+	Variables map[string]Variable
+	Outputs   map[string]hcl.Range
+}
+
+// Variable is a declared module variable
+type Variable struct {
+	Name       string
+	HasDefault bool
+	Range      hcl.Range
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+	},
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "default"}},
+}
+
+// LoadModule parses the .tf files of the module in dir
+func LoadModule(dir string) (*Module, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s: no .tf files found", dir)
+	}
+
+	module := &Module{
+		Dir:       dir,
+		Variables: map[string]Variable{},
+		Outputs:   map[string]hcl.Range{},
+	}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if err := module.addDeclarations(file.Body); err != nil {
+			return nil, err
+		}
+	}
+	return module, nil
+}
+
+// addDeclarations records the variable and output blocks found in body. It
+// is also used for the .tf contents that Terragrunt generate blocks write
+// next to the module.
+func (m *Module) addDeclarations(body hcl.Body) error {
+	content, _, diags := body.PartialContent(moduleSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, block := range content.Blocks {
+		name := block.Labels[0]
+		switch block.Type {
+		case "variable":
+			attrs, _, diags := block.Body.PartialContent(variableSchema)
+			if diags.HasErrors() {
+				return diags
+			}
+			_, hasDefault := attrs.Attributes["default"]
+			m.Variables[name] = Variable{Name: name, HasDefault: hasDefault, Range: block.DefRange}
+		case "output":
+			m.Outputs[name] = block.DefRange
+		}
+	}
+	return nil
+}
+
+// OutputNames returns the declared output names in sorted order
+func (m *Module) OutputNames() []string {
+	names := make([]string, 0, len(m.Outputs))
+	for name := range m.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
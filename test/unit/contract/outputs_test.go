@@ -0,0 +1,293 @@
+package contract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	terraformImport = "github.com/gruntwork-io/terratest/modules/terraform"
+	tfvarsImport    = "github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	fixturesImport  = "github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/fixtures"
+)
+
+// outputRead is one terraform.Output* call reading a literal output name
+type outputRead struct {
+	Pos    token.Position
+	Module string
+	Output string
+}
+
+func (r outputRead) String() string {
+	return fmt.Sprintf("%s: %s output %q", r.Pos, r.Module, r.Output)
+}
+
+// TestOutputReadsMatchModuleOutputs fails when a Go source reads an output
+// that modules/<module>/outputs.tf does not declare
+func TestOutputReadsMatchModuleOutputs(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	reads, unresolved := scanDir(t, filepath.Join(root, "test", "unit"))
+	require.NotEmpty(t, reads, "no terraform.Output calls found; has the scanner lost track of the test sources?")
+
+	for _, call := range unresolved {
+		t.Errorf("%s: cannot tell which module these options belong to; build them with tfvars.New<Module>Vars().ToOptions(t) or load them with fixtures.LoadOptions(t, \"<module>\")", call)
+	}
+
+	declared := map[string]map[string]bool{}
+	for _, read := range reads {
+		if _, ok := declared[read.Module]; !ok {
+			declared[read.Module] = loadOutputs(t, read.Module)
+		}
+		outputs := declared[read.Module]
+		assert.True(t, outputs[read.Output], "%s is not declared in modules/%s/outputs.tf (declared: %s)",
+			read, read.Module, strings.Join(sortedKeys(outputs), ", "))
+	}
+}
+
+// TestScannerResolvesOptionsToModules guards the scanner itself, so a change
+// in how tests build their options cannot silently turn the contract check
+// into a no-op
+func TestScannerResolvesOptionsToModules(t *testing.T) {
+	const src = `package test
+
+import (
+	tf "github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/fixtures"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+)
+
+func TestExample(t *testing.T) {
+	kvVars := tfvars.NewKeyVaultVars()
+	kvOptions := kvVars.ToOptions(t)
+	_ = tf.Output(t, kvOptions, "key_vault_uri")
+	_ = tf.OutputRequired(t, kvOptions, "vault_uri")
+	_ = tf.OutputAll(t, kvOptions)
+
+	other := &tf.Options{}
+	_ = tf.Output(t, other, "id")
+
+	fixtures.Validate(t, func() {
+		kvOptions := fixtures.LoadOptions(t, "resource-group")
+		_ = tf.Output(t, kvOptions, "resource_group_name")
+	})
+}
+`
+	reads, unresolved := scanSource(t, "example_test.go", src)
+
+	require.Len(t, reads, 3)
+	assert.Equal(t, "key-vault", reads[0].Module)
+	assert.Equal(t, "key_vault_uri", reads[0].Output)
+	assert.Equal(t, "vault_uri", reads[1].Output)
+	assert.False(t, loadOutputs(t, "key-vault")[reads[1].Output], "vault_uri should be reported as undeclared")
+	assert.Equal(t, "resource-group", reads[2].Module, "loaded options replace the ones built earlier")
+
+	require.Len(t, unresolved, 1)
+	assert.Equal(t, 17, unresolved[0].Line)
+}
+
+// loadOutputs returns the output names declared by the given module
+func loadOutputs(t *testing.T, module string) map[string]bool {
+	t.Helper()
+
+	dir, err := repo.ModuleDir(module)
+	require.NoError(t, err)
+
+	loaded, err := LoadModule(dir)
+	require.NoError(t, err)
+
+	outputs := make(map[string]bool, len(loaded.Outputs))
+	for name := range loaded.Outputs {
+		outputs[name] = true
+	}
+	return outputs
+}
+
+// scanDir parses every Go file under dir and returns the output reads it finds
+func scanDir(t *testing.T, dir string) ([]outputRead, []token.Position) {
+	t.Helper()
+
+	var reads []outputRead
+	var unresolved []token.Position
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == "testdata" || strings.HasPrefix(entry.Name(), ".") && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		r, u := scanSource(t, path, string(src))
+		reads = append(reads, r...)
+		unresolved = append(unresolved, u...)
+		return nil
+	})
+	require.NoError(t, err)
+	return reads, unresolved
+}
+
+// scanSource finds terraform.Output* calls with a literal output name and
+// resolves their options argument to a module. Options are resolved by
+// following `opts := vars.ToOptions(t)` back to `vars := tfvars.New<X>Vars()`
+// inside the same function, or from `opts := fixtures.LoadOptions(t, "<module>")`.
+func scanSource(t *testing.T, filename string, src string) ([]outputRead, []token.Position) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	require.NoError(t, err)
+
+	terraformName := importName(file, terraformImport)
+	if terraformName == "" {
+		return nil, nil
+	}
+	tfvarsName := importName(file, tfvarsImport)
+	fixturesName := importName(file, fixturesImport)
+	constructors := constructorModules()
+
+	var reads []outputRead
+	var unresolved []token.Position
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		varsModule := map[string]string{}
+		optionsModule := map[string]string{}
+
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.AssignStmt:
+				if len(node.Lhs) != 1 || len(node.Rhs) != 1 {
+					return true
+				}
+				lhs, ok := node.Lhs[0].(*ast.Ident)
+				if !ok {
+					return true
+				}
+				call, ok := node.Rhs[0].(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				recv, ok := sel.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+
+				switch {
+				case recv.Name == tfvarsName:
+					if module, ok := constructors[sel.Sel.Name]; ok {
+						varsModule[lhs.Name] = module
+					}
+				case recv.Name == fixturesName && sel.Sel.Name == "LoadOptions":
+					delete(optionsModule, lhs.Name)
+					if len(call.Args) == 2 {
+						if lit, ok := call.Args[1].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+							module, err := strconv.Unquote(lit.Value)
+							require.NoError(t, err)
+							optionsModule[lhs.Name] = module
+						}
+					}
+				case sel.Sel.Name == "ToOptions":
+					if module, ok := varsModule[recv.Name]; ok {
+						optionsModule[lhs.Name] = module
+					}
+				}
+
+			case *ast.CallExpr:
+				sel, ok := node.Fun.(*ast.SelectorExpr)
+				if !ok || !strings.HasPrefix(sel.Sel.Name, "Output") || len(node.Args) < 3 {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != terraformName {
+					return true
+				}
+				lit, ok := node.Args[2].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				name, err := strconv.Unquote(lit.Value)
+				require.NoError(t, err)
+
+				pos := fset.Position(node.Pos())
+				module := ""
+				if options, ok := node.Args[1].(*ast.Ident); ok {
+					module = optionsModule[options.Name]
+				}
+				if module == "" {
+					unresolved = append(unresolved, pos)
+					return true
+				}
+				reads = append(reads, outputRead{Pos: pos, Module: module, Output: name})
+			}
+			return true
+		})
+	}
+	return reads, unresolved
+}
+
+// importName returns the name a file uses for the given import path, or ""
+// when the file does not import it
+func importName(file *ast.File, path string) string {
+	for _, spec := range file.Imports {
+		if importPath, _ := strconv.Unquote(spec.Path.Value); importPath != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return path[strings.LastIndex(path, "/")+1:]
+	}
+	return ""
+}
+
+// constructorModules maps each tfvars.New<X>Vars constructor name to the
+// module its struct belongs to
+func constructorModules() map[string]string {
+	constructors := map[string]string{}
+	for module, vars := range tfvars.Registry() {
+		typ := reflect.Indirect(reflect.ValueOf(vars)).Type()
+		constructors["New"+typ.Name()] = module
+	}
+	return constructors
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
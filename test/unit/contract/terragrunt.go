@@ -0,0 +1,634 @@
+package contract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// UnitFileName is the configuration file of every Terragrunt unit
+const UnitFileName = "terragrunt.hcl"
+
+// Unit is one environments/<env>/<name>/terragrunt.hcl, reduced to the parts
+// the contract checks need. Nothing is evaluated beyond the handful of path
+// functions Terragrunt offers, so units can be checked without Azure,
+// credentials or a backend.
+type Unit struct {
+	Env  string
+	Name string
+	Dir  string
+	// Path is relative to the repository root and is used in diagnostics
+	Path string
+
+	// ModuleDir is the local module terraform.source resolves to
+	ModuleDir   string
+	SourceRange hcl.Range
+
+	Dependencies []Dependency
+	// Inputs holds the keys of the unit's own inputs block; InputsDynamic is
+	// set when inputs is not an object literal and cannot be checked
+	Inputs        []Key
+	InputsDynamic bool
+	// Includes are the included files, relative to the repository root
+	Includes []string
+	// InheritedInputs are the input keys merged in from included files
+	InheritedInputs map[string]bool
+	// Generated holds the variables declared by generate blocks writing .tf
+	// files next to the module, in the unit itself or in included files
+	Generated *Module
+
+	// OutputRefs are the dependency.<name>.outputs.<key> references
+	OutputRefs []OutputRef
+}
+
+// Dependency is a dependency block of a unit
+type Dependency struct {
+	Name string
+	// Dir is the absolute directory config_path points at
+	Dir         string
+	Range       hcl.Range
+	MockOutputs []Key
+}
+
+// Key is an object key together with where it was written
+type Key struct {
+	Name  string
+	Range hcl.Range
+}
+
+// OutputRef is a dependency.<name>.outputs.<key> reference
+type OutputRef struct {
+	Dependency string
+	Output     string
+	Range      hcl.Range
+}
+
+// Diagnostic is one contract violation
+type Diagnostic struct {
+	Range   hcl.Range
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.Range.Filename, d.Range.Start.Line, d.Range.Start.Column, d.Message)
+}
+
+var unitSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "inputs"}},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "terraform"},
+		{Type: "include", LabelNames: []string{"name"}},
+		{Type: "dependency", LabelNames: []string{"name"}},
+		{Type: "generate", LabelNames: []string{"name"}},
+	},
+}
+
+var terraformSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "source"}},
+}
+
+var includeSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "path", Required: true}},
+}
+
+var dependencySchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "config_path", Required: true}, {Name: "mock_outputs"}},
+}
+
+var generateSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "path", Required: true}, {Name: "contents", Required: true}},
+}
+
+// FindUnits returns the terragrunt.hcl of every unit under root/environments
+func FindUnits(root string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, "environments", "*", "*", UnitFileName))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ParseUnit parses the unit whose terragrunt.hcl is at path
+func ParseUnit(root string, path string) (*Unit, error) {
+	dir := filepath.Dir(path)
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := &Unit{
+		Env:             filepath.Base(filepath.Dir(dir)),
+		Name:            filepath.Base(dir),
+		Dir:             dir,
+		Path:            rel,
+		InheritedInputs: map[string]bool{},
+		Generated:       &Module{Dir: dir, Variables: map[string]Variable{}, Outputs: map[string]hcl.Range{}},
+	}
+
+	body, err := ParseConfig(path, rel)
+	if err != nil {
+		return nil, err
+	}
+	content, _, diags := body.PartialContent(unitSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	ctx := EvalContext(root, dir)
+
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "terraform":
+			if err := unit.parseTerraform(ctx, block); err != nil {
+				return nil, err
+			}
+		case "include":
+			if err := unit.parseInclude(root, ctx, block); err != nil {
+				return nil, err
+			}
+		case "dependency":
+			if err := unit.parseDependency(ctx, block); err != nil {
+				return nil, err
+			}
+		case "generate":
+			if err := unit.parseGenerate(ctx, block); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if attr, ok := content.Attributes["inputs"]; ok {
+		unit.Inputs, unit.InputsDynamic = objectKeys(attr.Expr)
+	}
+
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		if expr, ok := node.(*hclsyntax.ScopeTraversalExpr); ok {
+			if ref, ok := outputRef(expr); ok {
+				unit.OutputRefs = append(unit.OutputRefs, ref)
+			}
+		}
+		return nil
+	})
+	return unit, nil
+}
+
+func (u *Unit) parseTerraform(ctx *hcl.EvalContext, block *hcl.Block) error {
+	attrs, _, diags := block.Body.PartialContent(terraformSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+	attr, ok := attrs.Attributes["source"]
+	if !ok {
+		return nil
+	}
+
+	source, err := evalString(ctx, attr.Expr)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(source, "::") || strings.Contains(source, "://") {
+		return fmt.Errorf("%s: terraform.source %q is not a local module", u.Path, source)
+	}
+
+	// Terragrunt's "path//subdir" form only changes what is copied, not
+	// which module is applied
+	source = strings.Replace(source, "//", "/", 1)
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(u.Dir, source)
+	}
+	u.ModuleDir = filepath.Clean(source)
+	u.SourceRange = attr.Expr.Range()
+	return nil
+}
+
+// parseInclude collects the inputs and generated variables of an included
+// file. Terragrunt only supports one level of includes, so the included
+// file's own include blocks are not followed.
+func (u *Unit) parseInclude(root string, ctx *hcl.EvalContext, block *hcl.Block) error {
+	attrs, _, diags := block.Body.PartialContent(includeSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+	path, err := evalString(ctx, attrs.Attributes["path"].Expr)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(u.Dir, path)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	u.Includes = append(u.Includes, rel)
+
+	body, err := ParseConfig(path, rel)
+	if err != nil {
+		return err
+	}
+	content, _, diags := body.PartialContent(unitSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	if attr, ok := content.Attributes["inputs"]; ok {
+		keys, _ := objectKeys(attr.Expr)
+		for _, key := range keys {
+			u.InheritedInputs[key.Name] = true
+		}
+	}
+
+	includeCtx := EvalContext(root, filepath.Dir(path))
+	for _, block := range content.Blocks {
+		if block.Type == "generate" {
+			if err := u.parseGenerate(includeCtx, block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *Unit) parseDependency(ctx *hcl.EvalContext, block *hcl.Block) error {
+	attrs, _, diags := block.Body.PartialContent(dependencySchema)
+	if diags.HasErrors() {
+		return diags
+	}
+	configPath, err := evalString(ctx, attrs.Attributes["config_path"].Expr)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(u.Dir, configPath)
+	}
+
+	dep := Dependency{
+		Name:  block.Labels[0],
+		Dir:   filepath.Clean(configPath),
+		Range: block.DefRange,
+	}
+	if attr, ok := attrs.Attributes["mock_outputs"]; ok {
+		dep.MockOutputs, _ = objectKeys(attr.Expr)
+	}
+	u.Dependencies = append(u.Dependencies, dep)
+	return nil
+}
+
+// parseGenerate records the variables declared by a generate block that
+// writes a .tf file; other generated files are ignored
+func (u *Unit) parseGenerate(ctx *hcl.EvalContext, block *hcl.Block) error {
+	attrs, _, diags := block.Body.PartialContent(generateSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+	path, err := evalString(ctx, attrs.Attributes["path"].Expr)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) != ".tf" {
+		return nil
+	}
+
+	contents, err := evalString(ctx, attrs.Attributes["contents"].Expr)
+	if err != nil {
+		return err
+	}
+	file, diags := hclparse.NewParser().ParseHCL([]byte(contents), block.DefRange.Filename+":generate."+block.Labels[0])
+	if diags.HasErrors() {
+		return diags
+	}
+	return u.Generated.addDeclarations(file.Body)
+}
+
+// CheckEnvironments checks every unit under root/environments against the
+// modules their terraform.source and dependencies resolve to
+func CheckEnvironments(root string) ([]Diagnostic, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no %s found under %s", UnitFileName, filepath.Join(root, "environments"))
+	}
+
+	checker := &checker{
+		root:    root,
+		units:   map[string]*Unit{},
+		modules: map[string]*Module{},
+	}
+
+	var diagnostics []Diagnostic
+	for _, path := range paths {
+		unit, err := checker.unit(filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		found, err := checker.check(unit)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Range, diagnostics[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return diagnostics, nil
+}
+
+// errNoUnit is returned for a dependency that points at a directory without
+// a terragrunt.hcl; it is reported as a diagnostic rather than an error
+var errNoUnit = errors.New("no " + UnitFileName)
+
+type checker struct {
+	root    string
+	units   map[string]*Unit
+	modules map[string]*Module
+}
+
+func (c *checker) unit(dir string) (*Unit, error) {
+	if unit, ok := c.units[dir]; ok {
+		return unit, nil
+	}
+	path := filepath.Join(dir, UnitFileName)
+	if _, err := os.Stat(path); err != nil {
+		return nil, errNoUnit
+	}
+	unit, err := ParseUnit(c.root, path)
+	if err != nil {
+		return nil, err
+	}
+	c.units[dir] = unit
+	return unit, nil
+}
+
+func (c *checker) module(dir string) (*Module, error) {
+	if module, ok := c.modules[dir]; ok {
+		return module, nil
+	}
+	module, err := LoadModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	c.modules[dir] = module
+	return module, nil
+}
+
+func (c *checker) rel(dir string) string {
+	if rel, err := filepath.Rel(c.root, dir); err == nil {
+		return rel
+	}
+	return dir
+}
+
+func (c *checker) check(unit *Unit) ([]Diagnostic, error) {
+	var diagnostics []Diagnostic
+	report := func(rng hcl.Range, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Range: rng, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if unit.ModuleDir == "" {
+		report(hcl.Range{Filename: unit.Path, Start: hcl.InitialPos}, "terraform.source is not set")
+		return diagnostics, nil
+	}
+	module, err := c.module(unit.ModuleDir)
+	if err != nil {
+		report(unit.SourceRange, "terraform.source does not resolve to a module: %v", err)
+		return diagnostics, nil
+	}
+	moduleName := c.rel(unit.ModuleDir)
+
+	// dependency outputs, both mocked and referenced
+	depOutputs := map[string]*Module{}
+	for _, dep := range unit.Dependencies {
+		depUnit, err := c.unit(dep.Dir)
+		if errors.Is(err, errNoUnit) {
+			report(dep.Range, "dependency %q: %s has no %s", dep.Name, c.rel(dep.Dir), UnitFileName)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if depUnit.ModuleDir == "" {
+			continue
+		}
+		depModule, err := c.module(depUnit.ModuleDir)
+		if err != nil {
+			continue // reported when the dependency unit itself is checked
+		}
+		depOutputs[dep.Name] = depModule
+
+		for _, key := range dep.MockOutputs {
+			if _, ok := depModule.Outputs[key.Name]; !ok {
+				report(key.Range, "dependency %q: mock_outputs key %q is not an output of %s (declared: %s)",
+					dep.Name, key.Name, c.rel(depModule.Dir), strings.Join(depModule.OutputNames(), ", "))
+			}
+		}
+	}
+
+	declaredDeps := map[string]bool{}
+	for _, dep := range unit.Dependencies {
+		declaredDeps[dep.Name] = true
+	}
+	for _, ref := range unit.OutputRefs {
+		if !declaredDeps[ref.Dependency] {
+			report(ref.Range, "dependency %q is referenced but not declared", ref.Dependency)
+			continue
+		}
+		depModule, ok := depOutputs[ref.Dependency]
+		if !ok {
+			continue
+		}
+		if _, ok := depModule.Outputs[ref.Output]; !ok {
+			report(ref.Range, "dependency.%s.outputs.%s is not an output of %s (declared: %s)",
+				ref.Dependency, ref.Output, c.rel(depModule.Dir), strings.Join(depModule.OutputNames(), ", "))
+		}
+	}
+
+	if unit.InputsDynamic {
+		return diagnostics, nil
+	}
+
+	// inputs against variables
+	supplied := map[string]bool{}
+	for name := range unit.InheritedInputs {
+		supplied[name] = true
+	}
+	for _, key := range unit.Inputs {
+		supplied[key.Name] = true
+		_, declared := module.Variables[key.Name]
+		_, generated := unit.Generated.Variables[key.Name]
+		if !declared && !generated {
+			report(key.Range, "input %q is not a variable of %s", key.Name, moduleName)
+		}
+	}
+
+	for _, variables := range []map[string]Variable{module.Variables, unit.Generated.Variables} {
+		for _, name := range sortedVariableNames(variables) {
+			if !variables[name].HasDefault && !supplied[name] {
+				report(unit.SourceRange, "variable %q of %s has no default and is not set in inputs", name, moduleName)
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+func sortedVariableNames(variables map[string]Variable) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseConfig parses a Terragrunt file, naming it rel in diagnostics
+func ParseConfig(path string, rel string) (*hclsyntax.Body, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, diags := hclparse.NewParser().ParseHCL(src, rel)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return file.Body.(*hclsyntax.Body), nil
+}
+
+// objectKeys returns the keys of an object literal. dynamic is true when the
+// expression is something else, such as a merge() call.
+func objectKeys(expr hcl.Expression) (keys []Key, dynamic bool) {
+	pairs, diags := hcl.ExprMap(expr)
+	if diags.HasErrors() {
+		return nil, true
+	}
+	for _, pair := range pairs {
+		name := hcl.ExprAsKeyword(pair.Key)
+		if name == "" {
+			value, diags := pair.Key.Value(nil)
+			if diags.HasErrors() || value.IsNull() || !value.Type().Equals(cty.String) {
+				return nil, true
+			}
+			name = value.AsString()
+		}
+		keys = append(keys, Key{Name: name, Range: pair.Key.Range()})
+	}
+	return keys, false
+}
+
+// outputRef recognises dependency.<name>.outputs.<key>
+func outputRef(expr *hclsyntax.ScopeTraversalExpr) (OutputRef, bool) {
+	traversal := expr.Traversal
+	if traversal.RootName() != "dependency" || len(traversal) < 4 {
+		return OutputRef{}, false
+	}
+	dep, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return OutputRef{}, false
+	}
+	if outputs, ok := traversal[2].(hcl.TraverseAttr); !ok || outputs.Name != "outputs" {
+		return OutputRef{}, false
+	}
+
+	ref := OutputRef{Dependency: dep.Name, Range: expr.Range()}
+	switch step := traversal[3].(type) {
+	case hcl.TraverseAttr:
+		ref.Output = step.Name
+	case hcl.TraverseIndex:
+		if !step.Key.Type().Equals(cty.String) {
+			return OutputRef{}, false
+		}
+		ref.Output = step.Key.AsString()
+	default:
+		return OutputRef{}, false
+	}
+	return ref, true
+}
+
+func evalString(ctx *hcl.EvalContext, expr hcl.Expression) (string, error) {
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return "", diags
+	}
+	if value.IsNull() || !value.IsKnown() || !value.Type().Equals(cty.String) {
+		return "", fmt.Errorf("%s: expected a string", expr.Range())
+	}
+	return value.AsString(), nil
+}
+
+// EvalContext provides the Terragrunt functions used to build paths. Every
+// other function or variable makes the expression fail to evaluate, which
+// is reported instead of guessed at.
+func EvalContext(root string, dir string) *hcl.EvalContext {
+	constant := func(value string) function.Function {
+		return function.New(&function.Spec{
+			Type: function.StaticReturnType(cty.String),
+			Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+				return cty.StringVal(value), nil
+			},
+		})
+	}
+
+	return &hcl.EvalContext{
+		Functions: map[string]function.Function{
+			"get_repo_root":             constant(root),
+			"get_terragrunt_dir":        constant(dir),
+			"get_parent_terragrunt_dir": constant(dir),
+			"path_relative_to_include":  constant("."),
+			"get_env": function.New(&function.Spec{
+				Params:   []function.Parameter{{Name: "name", Type: cty.String}},
+				VarParam: &function.Parameter{Name: "default", Type: cty.String},
+				Type:     function.StaticReturnType(cty.String),
+				Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+					if value, ok := os.LookupEnv(args[0].AsString()); ok {
+						return cty.StringVal(value), nil
+					}
+					if len(args) > 1 {
+						return args[1], nil
+					}
+					return cty.StringVal(""), nil
+				},
+			}),
+			"find_in_parent_folders": function.New(&function.Spec{
+				VarParam: &function.Parameter{Name: "args", Type: cty.String},
+				Type:     function.StaticReturnType(cty.String),
+				Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+					name := UnitFileName
+					if len(args) > 0 {
+						name = args[0].AsString()
+					}
+					for current := filepath.Dir(dir); ; current = filepath.Dir(current) {
+						candidate := filepath.Join(current, name)
+						if _, err := os.Stat(candidate); err == nil {
+							return cty.StringVal(candidate), nil
+						}
+						if current == filepath.Dir(current) || current == root {
+							break
+						}
+					}
+					if len(args) > 1 {
+						return args[1], nil
+					}
+					return cty.NilVal, fmt.Errorf("could not find %s in any parent folder of %s", name, dir)
+				},
+			}),
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package contract
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnvironmentsMatchModules fails when an environments/*/*/terragrunt.hcl
+// drifts from the modules it applies or depends on
+func TestEnvironmentsMatchModules(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	diagnostics, err := CheckEnvironments(root)
+	require.NoError(t, err)
+
+	for _, diagnostic := range diagnostics {
+		t.Error(diagnostic)
+	}
+}
+
+func TestParseUnitResolvesSourceAndDependencies(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	unit, err := ParseUnit(root, filepath.Join(root, "environments", "staging", "splunk-vm", UnitFileName))
+	require.NoError(t, err)
+
+	assert.Equal(t, "staging", unit.Env)
+	assert.Equal(t, "splunk-vm", unit.Name)
+	assert.Equal(t, filepath.Join(root, "modules", "virtual-machine"), unit.ModuleDir)
+
+	require.Len(t, unit.Dependencies, 2)
+	assert.Equal(t, "networking", unit.Dependencies[1].Name)
+	assert.Equal(t, filepath.Join(root, "environments", "staging", "networking"), unit.Dependencies[1].Dir)
+
+	assert.Contains(t, unit.OutputRefs, OutputRef{
+		Dependency: "networking",
+		Output:     "vm_subnet_id",
+		Range:      findRef(t, unit, "networking", "vm_subnet_id").Range,
+	})
+	assert.True(t, unit.InheritedInputs["environment"], "env.hcl inputs should be inherited")
+	assert.Contains(t, unit.Generated.Variables, "project_name", "root generate blocks should declare variables")
+}
+
+func TestCheckEnvironmentsReportsEveryViolation(t *testing.T) {
+	diagnostics, err := CheckEnvironments(absTestdata(t, "broken"))
+	require.NoError(t, err)
+
+	var messages []string
+	for _, diagnostic := range diagnostics {
+		messages = append(messages, diagnostic.String())
+	}
+
+	const app = "environments/dev/app/terragrunt.hcl"
+	assert.Equal(t, []string{
+		app + `:6:12: variable "size" of modules/app has no default and is not set in inputs`,
+		app + `:14:5: dependency "base": mock_outputs key "id" is not an output of modules/base (declared: base_id)`,
+		app + `:18:1: dependency "missing": environments/dev/does-not-exist has no terragrunt.hcl`,
+		app + `:23:13: dependency.base.outputs.id is not an output of modules/base (declared: base_id)`,
+		app + `:24:3: input "extra" is not a variable of modules/app`,
+		app + `:24:13: dependency "ghost" is referenced but not declared`,
+	}, messages)
+}
+
+func findRef(t *testing.T, unit *Unit, dependency string, output string) OutputRef {
+	t.Helper()
+
+	for _, ref := range unit.OutputRefs {
+		if ref.Dependency == dependency && ref.Output == output {
+			return ref
+		}
+	}
+	t.Fatalf("%s does not reference dependency.%s.outputs.%s", unit.Path, dependency, output)
+	return OutputRef{}
+}
+
+func absTestdata(t *testing.T, name string) string {
+	t.Helper()
+
+	dir, err := filepath.Abs(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return dir
+}
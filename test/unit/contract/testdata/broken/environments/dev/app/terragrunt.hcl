@@ -0,0 +1,25 @@
+include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "../../../modules//app"
+}
+
+dependency "base" {
+  config_path = "../base"
+
+  mock_outputs = {
+    base_id = "mock-base-id"
+    id      = "mock-stale-id"
+  }
+}
+
+dependency "missing" {
+  config_path = "../does-not-exist"
+}
+
+inputs = {
+  base_id = dependency.base.outputs.id
+  extra   = dependency.ghost.outputs.name
+}
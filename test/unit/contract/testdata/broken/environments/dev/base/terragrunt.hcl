@@ -0,0 +1,11 @@
+include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "${get_repo_root()}/modules/base"
+}
+
+inputs = {
+  name = "base"
+}
@@ -0,0 +1,3 @@
+output "app_id" {
+  value = "app-${var.base_id}"
+}
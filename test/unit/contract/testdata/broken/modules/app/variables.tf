@@ -0,0 +1,7 @@
+variable "base_id" {
+  type = string
+}
+
+variable "size" {
+  type = number
+}
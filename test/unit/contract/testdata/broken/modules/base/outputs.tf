@@ -0,0 +1,3 @@
+output "base_id" {
+  value = "id-${var.name}"
+}
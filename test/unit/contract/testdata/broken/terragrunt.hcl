@@ -0,0 +1,13 @@
+generate "common_variables" {
+  path      = "common_variables.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<EOT
+variable "project" {
+  type = string
+}
+EOT
+}
+
+inputs = {
+  project = "contract-test"
+}
@@ -0,0 +1,295 @@
+// Package coverage reports which module variables and count/for_each
+// branches the Go test suite actually exercises. It reads the tests
+// statically: every tfvars.New<Module>Vars() value and the fields assigned to
+// it afterwards, compared against each module's variables.tf and the
+// count/for_each expressions in its resources and dynamic blocks. Nothing is
+// applied, so the report runs offline in seconds.
+//
+// A variable is covered when at least one test passes it a value other than
+// its default. A branch is a count or for_each; it has an "on" state (one or
+// more instances or blocks) and an "off" state (none), and each state is
+// covered when at least one test's inputs evaluate to it.
+//
+// Values the scanner cannot read, such as fmt.Sprintf results or fixture
+// outputs, count as non-default. Strings among them are assumed non-empty
+// when evaluating branches; other types make the branch state unknown.
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Report is the coverage of every module under modules/
+type Report struct {
+	Modules []ModuleReport `json:"modules"`
+}
+
+// ModuleReport is the coverage of one module
+type ModuleReport struct {
+	Module string `json:"module"`
+	// Instances lists where the tests build inputs for the module
+	Instances []string           `json:"instances"`
+	Variables []VariableCoverage `json:"variables"`
+	Branches  []BranchCoverage   `json:"branches"`
+
+	VariablePercent float64 `json:"variable_percent"`
+	BranchPercent   float64 `json:"branch_percent"`
+}
+
+// VariableCoverage is the coverage of one module variable
+type VariableCoverage struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Default  string `json:"default,omitempty"`
+	// Values are the distinct non-default values the tests pass, as written
+	// in the Go source
+	Values  []string `json:"values,omitempty"`
+	Covered bool     `json:"covered"`
+}
+
+// BranchCoverage is the coverage of one count or for_each
+type BranchCoverage struct {
+	Address   string   `json:"address"`
+	Kind      string   `json:"kind"`
+	Variables []string `json:"variables,omitempty"`
+	On        bool     `json:"on"`
+	Off       bool     `json:"off"`
+	// Unknown counts the instances whose inputs could not be evaluated
+	Unknown int `json:"unknown,omitempty"`
+}
+
+// Analyze builds the report for the modules under root/modules from the Go
+// sources under root/test/unit
+func Analyze(root string) (*Report, error) {
+	instances, err := scanDir(filepath.Join(root, "test", "unit"))
+	if err != nil {
+		return nil, err
+	}
+
+	byModule := map[string][]instance{}
+	for _, inst := range instances {
+		if rel, err := filepath.Rel(root, inst.Pos.Filename); err == nil {
+			inst.Pos.Filename = rel
+		}
+		byModule[inst.Module] = append(byModule[inst.Module], inst)
+	}
+
+	modules := make([]string, 0, len(tfvars.Registry()))
+	for module := range tfvars.Registry() {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	report := &Report{}
+	for _, module := range modules {
+		info, err := loadModuleInfo(filepath.Join(root, "modules", module))
+		if err != nil {
+			return nil, err
+		}
+		report.Modules = append(report.Modules, analyzeModule(module, info, byModule[module]))
+	}
+	return report, nil
+}
+
+func analyzeModule(module string, info *moduleInfo, instances []instance) ModuleReport {
+	report := ModuleReport{Module: module}
+	for _, inst := range instances {
+		report.Instances = append(report.Instances, fmt.Sprintf("%s:%d %s", inst.Pos.Filename, inst.Pos.Line, inst.Func))
+	}
+
+	names := make([]string, 0, len(info.Variables))
+	for name := range info.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	covered := 0
+	for _, name := range names {
+		decl := info.Variables[name]
+		v := VariableCoverage{Name: name, Required: !decl.HasDefault}
+		if decl.HasDefault {
+			v.Default = render(decl.Default)
+		}
+
+		seen := map[string]bool{}
+		for _, inst := range instances {
+			assigned, ok := inst.Values[name]
+			if !ok || (assigned.Literal && decl.HasDefault && sameValue(assigned.Value, decl.Default)) {
+				continue
+			}
+			if !seen[assigned.Source] {
+				seen[assigned.Source] = true
+				v.Values = append(v.Values, assigned.Source)
+			}
+		}
+		v.Covered = len(v.Values) > 0
+		if v.Covered {
+			covered++
+		}
+		report.Variables = append(report.Variables, v)
+	}
+	report.VariablePercent = percent(covered, len(names))
+
+	states := 0
+	for _, b := range info.Branches {
+		coverage := BranchCoverage{Address: b.Address, Kind: b.Kind, Variables: b.Variables}
+		for _, inst := range instances {
+			on, known := evalBranch(b, info, inst)
+			switch {
+			case !known:
+				coverage.Unknown++
+			case on:
+				coverage.On = true
+			default:
+				coverage.Off = true
+			}
+		}
+		if coverage.On {
+			states++
+		}
+		if coverage.Off {
+			states++
+		}
+		report.Branches = append(report.Branches, coverage)
+	}
+	report.BranchPercent = percent(states, 2*len(info.Branches))
+	return report
+}
+
+// evalBranch evaluates a count or for_each with the inputs of one instance,
+// falling back to the module defaults for variables the test left alone
+func evalBranch(b branch, info *moduleInfo, inst instance) (on bool, known bool) {
+	vars := make(map[string]cty.Value, len(info.Variables))
+	for name, decl := range info.Variables {
+		switch assigned, ok := inst.Values[name]; {
+		case ok:
+			vars[name] = assigned.Value
+		case decl.HasDefault:
+			vars[name] = decl.Default
+		default:
+			vars[name] = cty.DynamicVal
+		}
+	}
+
+	value, diags := b.Expr.Value(&hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": cty.ObjectVal(vars)},
+	})
+	if diags.HasErrors() || !value.IsKnown() {
+		return false, false
+	}
+	if value.IsNull() {
+		return false, true
+	}
+
+	switch {
+	case value.Type() == cty.Number:
+		count, _ := value.AsBigFloat().Int64()
+		return count > 0, true
+	case value.CanIterateElements():
+		return value.LengthInt() > 0, true
+	}
+	return false, false
+}
+
+// sameValue compares a literal Go value with a variable default. Both sides
+// are compared as JSON, so a Go slice matches a list default and an empty
+// map matches {}.
+func sameValue(a cty.Value, b cty.Value) bool {
+	if a.IsNull() || b.IsNull() {
+		return a.IsNull() && b.IsNull()
+	}
+	if !a.IsWhollyKnown() || !b.IsWhollyKnown() {
+		return false
+	}
+	return render(a) == render(b)
+}
+
+func render(value cty.Value) string {
+	if value.IsNull() {
+		return "null"
+	}
+	raw, err := ctyjson.SimpleJSONValue{Value: value}.MarshalJSON()
+	if err != nil {
+		return value.GoString()
+	}
+	return string(raw)
+}
+
+func percent(covered int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(total)
+}
+
+// BelowMinimum describes every module whose variable or branch coverage is
+// under the given percentages
+func (r *Report) BelowMinimum(minVariables float64, minBranches float64) []string {
+	var failures []string
+	for _, m := range r.Modules {
+		if m.VariablePercent < minVariables {
+			failures = append(failures, fmt.Sprintf("%s: variable coverage %.1f%% is below %.1f%%", m.Module, m.VariablePercent, minVariables))
+		}
+		if m.BranchPercent < minBranches {
+			failures = append(failures, fmt.Sprintf("%s: branch coverage %.1f%% is below %.1f%%", m.Module, m.BranchPercent, minBranches))
+		}
+	}
+	return failures
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes a per-module matrix of variables and branches
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, m := range r.Modules {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "== %s: variables %.1f%%, branches %.1f%% (%d test instance(s))\n",
+			m.Module, m.VariablePercent, m.BranchPercent, len(m.Instances))
+
+		fmt.Fprintln(tw, "VARIABLE\tTESTED\tVALUES")
+		for _, v := range m.Variables {
+			values := strings.Join(v.Values, ", ")
+			if !v.Covered {
+				values = "default " + v.Default
+				if v.Required {
+					values = "never set"
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Name, yesNo(v.Covered), values)
+		}
+
+		if len(m.Branches) > 0 {
+			fmt.Fprintln(tw, "BRANCH\tON\tOFF\tVARIABLES")
+			for _, b := range m.Branches {
+				fmt.Fprintf(tw, "%s (%s)\t%s\t%s\t%s\n", b.Address, b.Kind, yesNo(b.On), yesNo(b.Off), strings.Join(b.Variables, ", "))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
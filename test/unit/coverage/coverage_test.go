@@ -0,0 +1,131 @@
+package coverage
+
+import (
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeCoversEveryModule(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	report, err := Analyze(root)
+	require.NoError(t, err)
+
+	require.Len(t, report.Modules, 7)
+	for _, m := range report.Modules {
+		assert.NotEmpty(t, m.Instances, "%s should be built by at least one test or fixture", m.Module)
+		for _, v := range m.Variables {
+			if v.Required {
+				assert.True(t, v.Covered, "%s: required variable %s should be set by every test", m.Module, v.Name)
+			}
+		}
+	}
+
+	logAnalytics := findModule(t, report, "log-analytics")
+	insights := findBranch(t, logAnalytics, "azurerm_log_analytics_solution.container_insights")
+	assert.True(t, insights.On, "the all-solutions test enables Container Insights")
+	assert.True(t, insights.Off, "the fixture disables Container Insights")
+}
+
+func TestAnalyzeModuleEvaluatesLiteralInputs(t *testing.T) {
+	const src = `package test
+
+import (
+	"fmt"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+)
+
+func TestVolumes(t *testing.T) {
+	aciVars := tfvars.NewContainerInstanceVars()
+	aciVars.ContainerGroupName = fmt.Sprintf("aci-%s", uniqueID)
+	aciVars.CPU = 1
+	aciVars.Volumes = []tfvars.Volume{{Name: "data", MountPath: "/data"}}
+	aciVars.DockerhubUsername = username
+}
+
+func TestDefaults(t *testing.T) {
+	aciVars := tfvars.NewContainerInstanceVars()
+	aciVars.Volumes = []tfvars.Volume{}
+}
+`
+	instances, err := scanFile("example_test.go", src, constructorModules())
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	assert.Equal(t, "TestVolumes", instances[0].Func)
+
+	dir, err := repo.ModuleDir("container-instance")
+	require.NoError(t, err)
+	info, err := loadModuleInfo(dir)
+	require.NoError(t, err)
+
+	report := analyzeModule("container-instance", info, instances)
+
+	cpu := findVariable(t, report, "cpu")
+	assert.False(t, cpu.Covered, "cpu = 1 is the module default")
+
+	volumes := findVariable(t, report, "volumes")
+	assert.True(t, volumes.Covered)
+	assert.Equal(t, []string{`[]tfvars.Volume{…}`}, volumes.Values, "the empty slice matches the default and is not listed")
+
+	volume := findBranch(t, report, "azurerm_container_group.main.volume")
+	assert.True(t, volume.On)
+	assert.True(t, volume.Off)
+
+	registry := findBranch(t, report, "azurerm_container_group.main.image_registry_credential")
+	assert.True(t, registry.On, "non-literal strings are assumed non-empty")
+	assert.True(t, registry.Off)
+}
+
+func TestBelowMinimum(t *testing.T) {
+	report := &Report{Modules: []ModuleReport{
+		{Module: "a", VariablePercent: 50, BranchPercent: 100},
+		{Module: "b", VariablePercent: 90, BranchPercent: 25},
+	}}
+
+	assert.Empty(t, report.BelowMinimum(50, 25))
+	assert.Equal(t, []string{
+		"a: variable coverage 50.0% is below 60.0%",
+		"b: branch coverage 25.0% is below 30.0%",
+	}, report.BelowMinimum(60, 30))
+}
+
+func findModule(t *testing.T, report *Report, module string) ModuleReport {
+	t.Helper()
+
+	for _, m := range report.Modules {
+		if m.Module == module {
+			return m
+		}
+	}
+	t.Fatalf("no report for module %s", module)
+	return ModuleReport{}
+}
+
+func findVariable(t *testing.T, report ModuleReport, name string) VariableCoverage {
+	t.Helper()
+
+	for _, v := range report.Variables {
+		if v.Name == name {
+			return v
+		}
+	}
+	t.Fatalf("%s has no variable %s", report.Module, name)
+	return VariableCoverage{}
+}
+
+func findBranch(t *testing.T, report ModuleReport, address string) BranchCoverage {
+	t.Helper()
+
+	for _, b := range report.Branches {
+		if b.Address == address {
+			return b
+		}
+	}
+	t.Fatalf("%s has no branch %s", report.Module, address)
+	return BranchCoverage{}
+}
@@ -0,0 +1,131 @@
+package coverage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// moduleVariable is a declared variable with its default, if any
+type moduleVariable struct {
+	Name       string
+	Default    cty.Value
+	HasDefault bool
+}
+
+// branch is a count or for_each that decides whether a resource, data
+// source or dynamic block exists
+type branch struct {
+	// Address is the resource address, followed by the dynamic block type
+	// for dynamic blocks, e.g. azurerm_container_group.main.volume
+	Address string
+	// Kind is "count" or "for_each"
+	Kind string
+	Expr hcl.Expression
+	// Variables are the module variables the expression reads
+	Variables []string
+}
+
+type moduleInfo struct {
+	Variables map[string]moduleVariable
+	Branches  []branch
+}
+
+// loadModuleInfo parses the .tf files of the module in dir
+func loadModuleInfo(dir string) (*moduleInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s: no .tf files found", dir)
+	}
+	sort.Strings(paths)
+
+	info := &moduleInfo{Variables: map[string]moduleVariable{}}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body := file.Body.(*hclsyntax.Body)
+
+		for _, block := range body.Blocks {
+			switch block.Type {
+			case "variable":
+				v := moduleVariable{Name: block.Labels[0]}
+				if attr, ok := block.Body.Attributes["default"]; ok {
+					value, diags := attr.Expr.Value(nil)
+					if diags.HasErrors() {
+						return nil, diags
+					}
+					v.Default = value
+					v.HasDefault = true
+				}
+				info.Variables[v.Name] = v
+			case "resource", "data":
+				address := block.Labels[0] + "." + block.Labels[1]
+				if block.Type == "data" {
+					address = "data." + address
+				}
+				info.Branches = append(info.Branches, branches(address, block.Body)...)
+			}
+		}
+	}
+	return info, nil
+}
+
+// branches returns the count/for_each of a resource body and of every
+// dynamic block nested in it
+func branches(address string, body *hclsyntax.Body) []branch {
+	var found []branch
+	for _, kind := range []string{"count", "for_each"} {
+		if attr, ok := body.Attributes[kind]; ok {
+			found = append(found, branch{
+				Address:   address,
+				Kind:      kind,
+				Expr:      attr.Expr,
+				Variables: referencedVariables(attr.Expr),
+			})
+		}
+	}
+	return append(found, dynamicBranches(address, body)...)
+}
+
+// dynamicBranches finds dynamic blocks at any depth, including inside
+// ordinary nested blocks such as the volume blocks of a container block
+func dynamicBranches(address string, body *hclsyntax.Body) []branch {
+	var found []branch
+	for _, block := range body.Blocks {
+		if block.Type == "dynamic" && len(block.Labels) > 0 {
+			found = append(found, branches(address+"."+block.Labels[0], block.Body)...)
+			continue
+		}
+		found = append(found, dynamicBranches(address, block.Body)...)
+	}
+	return found
+}
+
+func referencedVariables(expr hcl.Expression) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "var" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok || seen[attr.Name] {
+			continue
+		}
+		seen[attr.Name] = true
+		names = append(names, attr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
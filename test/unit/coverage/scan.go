@@ -0,0 +1,321 @@
+package coverage
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const tfvarsImport = "github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+
+// dynamicString stands in for string values the scanner cannot read, such
+// as fmt.Sprintf results or fixture outputs. Tests only ever pass those to
+// name or wire up resources, so they are treated as non-empty.
+const dynamicString = "<dynamic>"
+
+// instance is one tfvars.New<Module>Vars() value built by a test or fixture
+// together with the fields assigned to it afterwards
+type instance struct {
+	Module string
+	Pos    token.Position
+	Func   string
+	Values map[string]assignment
+}
+
+// assignment is a value assigned to an input struct field
+type assignment struct {
+	// Source is the Go expression as written
+	Source string
+	// Value is the literal value, or a placeholder when Literal is false
+	Value   cty.Value
+	Literal bool
+}
+
+// scanDir finds every input struct instance in the Go files under dir
+func scanDir(dir string) ([]instance, error) {
+	modules := constructorModules()
+
+	var instances []instance
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && (entry.Name() == "testdata" || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		found, err := scanFile(path, nil, modules)
+		if err != nil {
+			return err
+		}
+		instances = append(instances, found...)
+		return nil
+	})
+	return instances, err
+}
+
+// scanFile finds the input struct instances in one Go file. src is passed
+// to go/parser and may be nil to read the file from disk.
+func scanFile(filename string, src interface{}, modules map[string]moduleStruct) ([]instance, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	tfvarsName := importName(file, tfvarsImport)
+	if tfvarsName == "" {
+		return nil, nil
+	}
+
+	var instances []instance
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		// index into instances of the value each identifier currently holds,
+		// and the input struct fields of that value
+		current := map[string]int{}
+		fields := map[string]map[string]tfvars.Field{}
+
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			assign, ok := node.(*ast.AssignStmt)
+			if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+				return true
+			}
+
+			switch lhs := assign.Lhs[0].(type) {
+			case *ast.Ident:
+				if module, ok := constructorCall(assign.Rhs[0], tfvarsName, modules); ok {
+					current[lhs.Name] = len(instances)
+					fields[lhs.Name] = module.Fields
+					instances = append(instances, instance{
+						Module: module.Module,
+						Pos:    fset.Position(assign.Pos()),
+						Func:   fn.Name.Name,
+						Values: map[string]assignment{},
+					})
+				}
+
+			case *ast.SelectorExpr:
+				recv, ok := lhs.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				index, ok := current[recv.Name]
+				if !ok {
+					return true
+				}
+				field, ok := fields[recv.Name][lhs.Sel.Name]
+				if !ok {
+					return true
+				}
+				value, literal := goValue(assign.Rhs[0], field.Type)
+				instances[index].Values[field.Variable] = assignment{
+					Source:  types.ExprString(assign.Rhs[0]),
+					Value:   value,
+					Literal: literal,
+				}
+			}
+			return true
+		})
+	}
+	return instances, nil
+}
+
+// moduleStruct describes the input struct of one module
+type moduleStruct struct {
+	Module string
+	Fields map[string]tfvars.Field
+}
+
+// constructorModules maps each tfvars.New<X>Vars constructor name to its
+// module and struct fields
+func constructorModules() map[string]moduleStruct {
+	constructors := map[string]moduleStruct{}
+	for module, vars := range tfvars.Registry() {
+		fields := map[string]tfvars.Field{}
+		for _, field := range tfvars.Fields(vars) {
+			fields[field.GoName] = field
+		}
+		typ := reflect.Indirect(reflect.ValueOf(vars)).Type()
+		constructors["New"+typ.Name()] = moduleStruct{Module: module, Fields: fields}
+	}
+	return constructors
+}
+
+func constructorCall(expr ast.Expr, tfvarsName string, modules map[string]moduleStruct) (moduleStruct, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return moduleStruct{}, false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return moduleStruct{}, false
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != tfvarsName {
+		return moduleStruct{}, false
+	}
+	module, ok := modules[sel.Sel.Name]
+	return module, ok
+}
+
+func importName(file *ast.File, path string) string {
+	for _, spec := range file.Imports {
+		if importPath, _ := strconv.Unquote(spec.Path.Value); importPath != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return path[strings.LastIndex(path, "/")+1:]
+	}
+	return ""
+}
+
+// goValue converts a Go expression assigned to a field of type goType into
+// the value Terraform would receive. Only literals can be converted; for
+// anything else a placeholder is returned with literal set to false.
+func goValue(expr ast.Expr, goType reflect.Type) (value cty.Value, literal bool) {
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		switch expr.Kind {
+		case token.STRING:
+			if s, err := strconv.Unquote(expr.Value); err == nil {
+				return cty.StringVal(s), true
+			}
+		case token.INT, token.FLOAT:
+			if n, err := cty.ParseNumberVal(expr.Value); err == nil {
+				return n, true
+			}
+		}
+
+	case *ast.Ident:
+		switch expr.Name {
+		case "true":
+			return cty.True, true
+		case "false":
+			return cty.False, true
+		case "nil":
+			return cty.NullVal(cty.DynamicPseudoType), true
+		}
+
+	case *ast.UnaryExpr:
+		switch expr.Op {
+		case token.AND:
+			return goValue(expr.X, goType)
+		case token.SUB:
+			if n, ok := goValue(expr.X, goType); ok && n.Type() == cty.Number {
+				return n.Negate(), true
+			}
+		}
+
+	case *ast.CompositeLit:
+		if value, ok := compositeValue(expr, goType); ok {
+			return value, true
+		}
+	}
+
+	return placeholder(goType), false
+}
+
+func compositeValue(expr *ast.CompositeLit, goType reflect.Type) (cty.Value, bool) {
+	switch goType.Kind() {
+	case reflect.Slice:
+		if len(expr.Elts) == 0 {
+			return cty.EmptyTupleVal, true
+		}
+		elems := make([]cty.Value, len(expr.Elts))
+		for i, elt := range expr.Elts {
+			value, ok := goValue(elt, goType.Elem())
+			if !ok {
+				return cty.NilVal, false
+			}
+			elems[i] = value
+		}
+		return cty.TupleVal(elems), true
+
+	case reflect.Map:
+		if len(expr.Elts) == 0 {
+			return cty.EmptyObjectVal, true
+		}
+		attrs := make(map[string]cty.Value, len(expr.Elts))
+		for _, elt := range expr.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return cty.NilVal, false
+			}
+			key, ok := goValue(kv.Key, goType.Key())
+			if !ok || key.Type() != cty.String {
+				return cty.NilVal, false
+			}
+			value, ok := goValue(kv.Value, goType.Elem())
+			if !ok {
+				return cty.NilVal, false
+			}
+			attrs[key.AsString()] = value
+		}
+		return cty.ObjectVal(attrs), true
+
+	case reflect.Struct:
+		attrs := map[string]cty.Value{}
+		for _, elt := range expr.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return cty.NilVal, false
+			}
+			name, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				return cty.NilVal, false
+			}
+			field, ok := goType.FieldByName(name.Name)
+			if !ok {
+				return cty.NilVal, false
+			}
+			tag, _, _ := strings.Cut(field.Tag.Get("tfvar"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			value, ok := goValue(kv.Value, field.Type)
+			if !ok {
+				return cty.NilVal, false
+			}
+			attrs[tag] = value
+		}
+		return cty.ObjectVal(attrs), true
+	}
+	return cty.NilVal, false
+}
+
+func placeholder(goType reflect.Type) cty.Value {
+	switch goType.Kind() {
+	case reflect.String:
+		return cty.StringVal(dynamicString)
+	case reflect.Bool:
+		return cty.UnknownVal(cty.Bool)
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return cty.UnknownVal(cty.Number)
+	default:
+		return cty.DynamicVal
+	}
+}
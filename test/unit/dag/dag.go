@@ -0,0 +1,420 @@
+// Package dag builds the graph of Terragrunt units from their dependency
+// blocks, the same graph run-all orders its work by, without Terragrunt or a
+// backend.
+//
+// A unit depends on every unit a dependency block's config_path or a
+// dependencies block's paths point at. The graph gives the apply order, its
+// reverse for destroy, the cycles that make run-all fail, and the units a
+// change to a module, a unit or an included file affects.
+package dag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Unit is a node of the graph
+type Unit struct {
+	// Path is the unit directory relative to the repository root, e.g.
+	// environments/staging/networking. It identifies the unit in the graph.
+	Path string `json:"path"`
+	Env  string `json:"env"`
+	Name string `json:"name"`
+	// Module is the module directory terraform.source resolves to, relative
+	// to the repository root
+	Module string `json:"module,omitempty"`
+	// Includes are the included files, relative to the repository root
+	Includes []string `json:"includes,omitempty"`
+	// Dependencies are the paths of the units that must be applied first,
+	// sorted
+	Dependencies []string `json:"dependencies"`
+}
+
+// Graph is the dependency graph of a set of units
+type Graph struct {
+	Units map[string]*Unit
+}
+
+// CycleError reports the dependency cycles that make an order impossible
+type CycleError struct {
+	// Cycles are paths that start and end with the same unit
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	var cycles []string
+	for _, cycle := range e.Cycles {
+		cycles = append(cycles, strings.Join(cycle, " -> "))
+	}
+	return "dependency cycle: " + strings.Join(cycles, "; ")
+}
+
+// Load builds the graph of the units under root/environments/<env>, or of
+// every environment when env is empty. Units of other environments that a
+// unit depends on are part of the graph too.
+func Load(root string, env string) (*Graph, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{Units: map[string]*Unit{}}
+	var pending []string
+	for _, path := range paths {
+		if env == "" || filepath.Base(filepath.Dir(filepath.Dir(path))) == env {
+			pending = append(pending, filepath.Dir(path))
+		}
+	}
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("no units under environments/%s", env)
+	}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		if _, done := g.Units[rel]; done {
+			continue
+		}
+		unit, err := load(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		g.Units[rel] = unit
+		for _, dependency := range unit.Dependencies {
+			dir := filepath.Join(root, dependency)
+			if _, err := os.Stat(filepath.Join(dir, contract.UnitFileName)); err != nil {
+				return nil, fmt.Errorf("%s depends on %s, which has no %s", unit.Path, dependency, contract.UnitFileName)
+			}
+			pending = append(pending, dir)
+		}
+	}
+	return g, nil
+}
+
+// load parses one unit
+func load(root string, dir string) (*Unit, error) {
+	path := filepath.Join(dir, contract.UnitFileName)
+	parsed, err := contract.ParseUnit(root, path)
+	if err != nil {
+		return nil, err
+	}
+	unit := &Unit{
+		Path:     filepath.Dir(parsed.Path),
+		Env:      parsed.Env,
+		Name:     parsed.Name,
+		Includes: parsed.Includes,
+	}
+	if parsed.ModuleDir != "" {
+		if unit.Module, err = filepath.Rel(root, parsed.ModuleDir); err != nil {
+			return nil, err
+		}
+	}
+
+	dirs := map[string]bool{}
+	for _, dependency := range parsed.Dependencies {
+		dirs[dependency.Dir] = true
+	}
+	ordering, err := dependencyPaths(root, parsed)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range ordering {
+		dirs[dir] = true
+	}
+	for dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		unit.Dependencies = append(unit.Dependencies, rel)
+	}
+	sort.Strings(unit.Dependencies)
+	return unit, nil
+}
+
+// dependencyPaths returns the directories of a dependencies block, which
+// orders units without reading their outputs
+func dependencyPaths(root string, unit *contract.Unit) ([]string, error) {
+	body, err := contract.ParseConfig(filepath.Join(unit.Dir, contract.UnitFileName), unit.Path)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, block := range body.Blocks {
+		if block.Type != "dependencies" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["paths"]
+		if !ok {
+			continue
+		}
+		value, diags := attr.Expr.Value(contract.EvalContext(root, unit.Dir))
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if !value.IsWhollyKnown() || value.IsNull() || !value.CanIterateElements() {
+			return nil, fmt.Errorf("%s: dependencies.paths must be a list of strings", rangeString(attr.Expr.Range()))
+		}
+		for it := value.ElementIterator(); it.Next(); {
+			_, element := it.Element()
+			if !element.Type().Equals(cty.String) {
+				return nil, fmt.Errorf("%s: dependencies.paths must be a list of strings", rangeString(attr.Expr.Range()))
+			}
+			dir := element.AsString()
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(unit.Dir, dir)
+			}
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs, nil
+}
+
+func rangeString(rng hcl.Range) string {
+	return fmt.Sprintf("%s:%d:%d", rng.Filename, rng.Start.Line, rng.Start.Column)
+}
+
+// Paths returns the paths of the units, sorted
+func (g *Graph) Paths() []string {
+	paths := make([]string, 0, len(g.Units))
+	for path := range g.Units {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Dependents returns the paths of the units that depend on path directly,
+// sorted
+func (g *Graph) Dependents(path string) []string {
+	var dependents []string
+	for _, other := range g.Paths() {
+		for _, dependency := range g.Units[other].Dependencies {
+			if dependency == path {
+				dependents = append(dependents, other)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// Cycles returns one cycle through each group of units that depend on each
+// other, as a path starting and ending with the group's first unit. It is
+// empty when the graph is acyclic.
+func (g *Graph) Cycles() [][]string {
+	var cycles [][]string
+	for _, component := range g.components() {
+		start := component[0]
+		if len(component) == 1 && !contains(g.Units[start].Dependencies, start) {
+			continue
+		}
+		members := map[string]bool{}
+		for _, path := range component {
+			members[path] = true
+		}
+		cycles = append(cycles, g.shortestCycle(start, members))
+	}
+	return cycles
+}
+
+// components returns the strongly connected components of the graph with
+// Tarjan's algorithm, each sorted, in order of their first unit
+func (g *Graph) components() [][]string {
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+
+	var visit func(path string)
+	visit = func(path string) {
+		index[path] = len(index)
+		low[path] = index[path]
+		stack = append(stack, path)
+		onStack[path] = true
+		for _, dependency := range g.Units[path].Dependencies {
+			if _, seen := index[dependency]; !seen {
+				visit(dependency)
+				low[path] = min(low[path], low[dependency])
+			} else if onStack[dependency] {
+				low[path] = min(low[path], index[dependency])
+			}
+		}
+		if low[path] != index[path] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == path {
+				break
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	for _, path := range g.Paths() {
+		if _, seen := index[path]; !seen {
+			visit(path)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+// shortestCycle returns the shortest path from start back to itself through
+// members
+func (g *Graph) shortestCycle(start string, members map[string]bool) []string {
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for _, dependency := range g.Units[path].Dependencies {
+			if !members[dependency] {
+				continue
+			}
+			if dependency == start {
+				cycle := []string{start}
+				for at := path; at != start; at = previous[at] {
+					cycle = append([]string{at}, cycle...)
+				}
+				return append([]string{start}, cycle...)
+			}
+			if _, seen := previous[dependency]; !seen {
+				previous[dependency] = path
+				queue = append(queue, dependency)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyOrder returns the units in groups, each sorted. A group only depends
+// on earlier groups, so its units can be applied in parallel, as run-all
+// does. It returns a *CycleError when the graph has cycles.
+func (g *Graph) ApplyOrder() ([][]string, error) {
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+	depth := map[string]int{}
+	var measure func(path string) int
+	measure = func(path string) int {
+		if d, ok := depth[path]; ok {
+			return d
+		}
+		d := 0
+		for _, dependency := range g.Units[path].Dependencies {
+			d = max(d, measure(dependency)+1)
+		}
+		depth[path] = d
+		return d
+	}
+
+	var groups [][]string
+	for _, path := range g.Paths() {
+		d := measure(path)
+		for len(groups) <= d {
+			groups = append(groups, nil)
+		}
+		groups[d] = append(groups[d], path)
+	}
+	return groups, nil
+}
+
+// DestroyOrder returns the groups of ApplyOrder in reverse: dependents are
+// destroyed before what they depend on
+func (g *Graph) DestroyOrder() ([][]string, error) {
+	groups, err := g.ApplyOrder()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+		groups[i], groups[j] = groups[j], groups[i]
+	}
+	return groups, nil
+}
+
+// Affected returns the units a change to the given files or directories
+// affects, relative to the repository root: the units applying a changed
+// module, the units whose directory or included files changed, and every
+// unit depending on those, transitively. They are sorted by apply order
+// when the graph is acyclic, by path otherwise.
+func (g *Graph) Affected(changed ...string) []string {
+	affected := map[string]bool{}
+	var queue []string
+	for _, path := range g.Paths() {
+		unit := g.Units[path]
+		touched := append([]string{unit.Path}, unit.Includes...)
+		if unit.Module != "" {
+			touched = append(touched, unit.Module)
+		}
+		for _, change := range changed {
+			change = filepath.Clean(change)
+			for _, t := range touched {
+				if within(change, t) || within(t, change) {
+					if !affected[path] {
+						affected[path] = true
+						queue = append(queue, path)
+					}
+				}
+			}
+		}
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for _, dependent := range g.Dependents(path) {
+			if !affected[dependent] {
+				affected[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	var ordered []string
+	groups, err := g.ApplyOrder()
+	if err != nil {
+		groups = [][]string{g.Paths()}
+	}
+	for _, group := range groups {
+		for _, path := range group {
+			if affected[path] {
+				ordered = append(ordered, path)
+			}
+		}
+	}
+	return ordered
+}
+
+// within reports whether path is dir or inside it
+func within(path string, dir string) bool {
+	return path == dir || dir == "." || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,164 @@
+package dag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentsHaveNoCycles(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	g, err := Load(root, "")
+	require.NoError(t, err)
+	assert.Empty(t, g.Cycles())
+
+	g, err = Load(root, "staging")
+	require.NoError(t, err)
+	apply, err := g.ApplyOrder()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"environments/staging/resource-group"},
+		{"environments/staging/key-vault", "environments/staging/log-analytics", "environments/staging/networking"},
+		{"environments/staging/container-instance", "environments/staging/splunk-vm", "environments/staging/sql-database"},
+	}, apply)
+
+	destroy, err := g.DestroyOrder()
+	require.NoError(t, err)
+	assert.Equal(t, apply[2], destroy[0])
+	assert.Equal(t, apply[0], destroy[2])
+}
+
+func TestAffected(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	g, err := Load(root, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"environments/production/networking",
+		"environments/staging/networking",
+		"environments/production/splunk-vm",
+		"environments/production/sql-database",
+		"environments/staging/splunk-vm",
+		"environments/staging/sql-database",
+	}, g.Affected("modules/networking/main.tf"))
+
+	assert.Equal(t, []string{
+		"environments/staging/log-analytics",
+		"environments/staging/container-instance",
+	}, g.Affected("environments/staging/log-analytics"))
+
+	assert.Len(t, g.Affected("environments/production/env.hcl"), 7, "every unit including env.hcl")
+	assert.Len(t, g.Affected("terragrunt.hcl"), 14, "every unit including the root configuration")
+	assert.Empty(t, g.Affected("README.md"))
+}
+
+// writeUnit writes environments/<env>/<name>/terragrunt.hcl with a
+// dependency block for each of dependencies
+func writeUnit(t *testing.T, root string, env string, name string, dependencies ...string) {
+	t.Helper()
+	var config bytes.Buffer
+	for _, dependency := range dependencies {
+		fmt.Fprintf(&config, "dependency %q {\n  config_path = \"../%s\"\n}\n\n", dependency, dependency)
+	}
+	repotest.WriteUnit(t, root, env, name, "", config.String())
+}
+
+func TestCycles(t *testing.T) {
+	root := t.TempDir()
+	writeUnit(t, root, "dev", "app", "database")
+	writeUnit(t, root, "dev", "database", "network")
+	writeUnit(t, root, "dev", "network", "app", "vault")
+	writeUnit(t, root, "dev", "vault", "vault")
+	writeUnit(t, root, "dev", "web", "app")
+
+	g, err := Load(root, "dev")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"environments/dev/app", "environments/dev/database", "environments/dev/network", "environments/dev/app"},
+		{"environments/dev/vault", "environments/dev/vault"},
+	}, g.Cycles())
+
+	_, err = g.ApplyOrder()
+	var cycleErr *CycleError
+	require.True(t, errors.As(err, &cycleErr))
+	assert.EqualError(t, err, "dependency cycle: environments/dev/app -> environments/dev/database -> environments/dev/network -> environments/dev/app; environments/dev/vault -> environments/dev/vault")
+	_, err = g.DestroyOrder()
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"environments/dev/app", "environments/dev/database", "environments/dev/network", "environments/dev/web"},
+		g.Affected("environments/dev/database"), "sorted by path when there is no order")
+}
+
+func TestLoadFollowsDependencies(t *testing.T) {
+	root := t.TempDir()
+	writeUnit(t, root, "shared", "dns")
+	writeUnit(t, root, "dev", "app", "network")
+	writeUnit(t, root, "dev", "network")
+	dir := filepath.Join(root, "environments", "dev", "network")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "terragrunt.hcl"), []byte(`dependencies {
+  paths = ["../../shared/dns"]
+}
+`), 0o644))
+
+	g, err := Load(root, "dev")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environments/dev/app", "environments/dev/network", "environments/shared/dns"}, g.Paths(), "dependencies outside dev are loaded")
+	assert.Equal(t, []string{"environments/shared/dns"}, g.Units["environments/dev/network"].Dependencies)
+
+	writeUnit(t, root, "dev", "web", "cdn")
+	_, err = Load(root, "dev")
+	assert.EqualError(t, err, "environments/dev/web depends on environments/dev/cdn, which has no terragrunt.hcl")
+}
+
+func TestExport(t *testing.T) {
+	root := t.TempDir()
+	writeUnit(t, root, "dev", "app", "network")
+	writeUnit(t, root, "dev", "network")
+	writeUnit(t, root, "prod", "network")
+	g, err := Load(root, "")
+	require.NoError(t, err)
+
+	var dot bytes.Buffer
+	require.NoError(t, g.WriteDOT(&dot, "environments/dev/app"))
+	assert.Equal(t, `digraph terragrunt {
+  rankdir = LR;
+  node [shape = box];
+  subgraph "cluster_dev" {
+    label = "dev";
+    "environments/dev/app" [label = "app", style = filled, fillcolor = "#f9d67a"];
+    "environments/dev/network" [label = "network"];
+  }
+  subgraph "cluster_prod" {
+    label = "prod";
+    "environments/prod/network" [label = "network"];
+  }
+  "environments/dev/app" -> "environments/dev/network";
+}
+`, dot.String())
+
+	var mermaid bytes.Buffer
+	require.NoError(t, g.WriteMermaid(&mermaid, "environments/dev/app"))
+	assert.Equal(t, `flowchart LR
+  subgraph dev
+    dev_app["app"]
+    dev_network["network"]
+  end
+  subgraph prod
+    prod_network["network"]
+  end
+  dev_app --> dev_network
+  classDef highlighted fill:#f9d67a
+  class dev_app highlighted
+`, mermaid.String())
+}
@@ -0,0 +1,103 @@
+package dag
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Edges point from a unit to what it depends on, as in terragrunt
+// graph-dependencies. Units are grouped by environment, and the highlighted
+// ones, such as the units a change affects or the units of a cycle, are
+// filled.
+
+// WriteDOT writes the graph in Graphviz DOT
+func (g *Graph) WriteDOT(w io.Writer, highlight ...string) error {
+	marked := set(highlight)
+	var b strings.Builder
+	b.WriteString("digraph terragrunt {\n  rankdir = LR;\n  node [shape = box];\n")
+	for _, env := range g.envs() {
+		fmt.Fprintf(&b, "  subgraph %q {\n    label = %q;\n", "cluster_"+env, env)
+		for _, path := range g.Paths() {
+			unit := g.Units[path]
+			if unit.Env != env {
+				continue
+			}
+			style := ""
+			if marked[path] {
+				style = `, style = filled, fillcolor = "#f9d67a"`
+			}
+			fmt.Fprintf(&b, "    %q [label = %q%s];\n", path, unit.Name, style)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, path := range g.Paths() {
+		for _, dependency := range g.Units[path].Dependencies {
+			fmt.Fprintf(&b, "  %q -> %q;\n", path, dependency)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart
+func (g *Graph) WriteMermaid(w io.Writer, highlight ...string) error {
+	marked := set(highlight)
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, env := range g.envs() {
+		fmt.Fprintf(&b, "  subgraph %s\n", mermaidID(env))
+		for _, path := range g.Paths() {
+			unit := g.Units[path]
+			if unit.Env == env {
+				fmt.Fprintf(&b, "    %s[\"%s\"]\n", mermaidID(path), unit.Name)
+			}
+		}
+		b.WriteString("  end\n")
+	}
+	for _, path := range g.Paths() {
+		for _, dependency := range g.Units[path].Dependencies {
+			fmt.Fprintf(&b, "  %s --> %s\n", mermaidID(path), mermaidID(dependency))
+		}
+	}
+	if len(marked) > 0 {
+		b.WriteString("  classDef highlighted fill:#f9d67a\n")
+		for _, path := range g.Paths() {
+			if marked[path] {
+				fmt.Fprintf(&b, "  class %s highlighted\n", mermaidID(path))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var notIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// mermaidID turns a path into a Mermaid node ID
+func mermaidID(path string) string {
+	return notIdentifier.ReplaceAllString(strings.TrimPrefix(path, "environments/"), "_")
+}
+
+// envs returns the environments of the units, sorted
+func (g *Graph) envs() []string {
+	seen := map[string]bool{}
+	var envs []string
+	for _, path := range g.Paths() {
+		if env := g.Units[path].Env; !seen[env] {
+			seen[env] = true
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+func set(values []string) map[string]bool {
+	s := map[string]bool{}
+	for _, value := range values {
+		s[value] = true
+	}
+	return s
+}
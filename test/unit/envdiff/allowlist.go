@@ -0,0 +1,80 @@
+package envdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Allowlist declares the differences between environments that are
+// intentional. A declared difference is still reported but does not fail the
+// check.
+//
+//	differences:
+//	  - unit: container-instance
+//	    input: cpu
+//	    reason: Production runs two cores
+//	  - unit: "*"
+//	    input: tags.Environment
+//	    reason: Tags name the environment
+type Allowlist struct {
+	Differences []Entry `yaml:"differences"`
+}
+
+// Entry declares the differences of one input. Unit "*" matches every unit,
+// and an input matches its keys too: tags matches tags.Environment. The
+// reason is required.
+type Entry struct {
+	Unit   string `yaml:"unit" json:"unit"`
+	Input  string `yaml:"input" json:"input"`
+	Reason string `yaml:"reason" json:"reason"`
+}
+
+// LoadAllowlist reads an allowlist file. Unknown keys and entries without a
+// unit, input or reason are errors.
+func LoadAllowlist(path string) (*Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	allowlist := &Allowlist{}
+	if err := decoder.Decode(allowlist); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var errs []error
+	for i, entry := range allowlist.Differences {
+		if entry.Unit == "" || entry.Input == "" || entry.Reason == "" {
+			errs = append(errs, fmt.Errorf("%s: entry %d needs a unit, input and reason", path, i+1))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return allowlist, nil
+}
+
+// Match returns the index of the first entry declaring the difference, or -1.
+// A nil allowlist declares nothing, and only an entry with input "*" declares
+// a unit missing from one environment.
+func (a *Allowlist) Match(difference Difference) int {
+	if a == nil {
+		return -1
+	}
+	for i, entry := range a.Differences {
+		if entry.Unit != "*" && entry.Unit != difference.Unit {
+			continue
+		}
+		if entry.Input == "*" || entry.Input == difference.Input || strings.HasPrefix(difference.Input, entry.Input+".") {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,349 @@
+// Package envdiff compares the resolved inputs of two environments unit by
+// unit, so that every way staging and production differ is either declared
+// on purpose or reported.
+//
+// Each unit's inputs are the inputs of its included files, such as env.hcl,
+// overridden by its own, evaluated as validate-inputs does. Objects and maps
+// are compared key by key (tags.Environment), everything else as a whole.
+// An input one environment leaves unset is compared with the module default.
+// Inputs that cannot be evaluated offline, such as dependency outputs, are
+// compared by their source text.
+package envdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Value is one side of a difference
+type Value struct {
+	// Text is the value in HCL syntax, or the source of the expression when
+	// it cannot be evaluated offline
+	Text string `json:"value"`
+	// Location is file:line:col of the expression, empty for module defaults
+	Location string `json:"location,omitempty"`
+	// Default is set when the unit leaves the input to the module default
+	Default bool `json:"default,omitempty"`
+}
+
+func (v *Value) String() string {
+	switch {
+	case v == nil:
+		return "-"
+	case v.Default:
+		return v.Text + " (default)"
+	}
+	return v.Text
+}
+
+// Difference is an input, or a key of an object input, whose value differs
+// between the two environments. Input is empty when the whole unit exists in
+// only one of them.
+type Difference struct {
+	Unit  string `json:"unit"`
+	Input string `json:"input,omitempty"`
+	// From and To are nil when the environment does not set the input and
+	// the module has no default, or does not have the unit
+	From *Value `json:"from"`
+	To   *Value `json:"to"`
+	// Reason is the allowlist's reason when the difference is declared
+	Reason string `json:"reason,omitempty"`
+}
+
+// Expected reports whether the allowlist declares the difference
+func (d Difference) Expected() bool {
+	return d.Reason != ""
+}
+
+// Report is the comparison of environment From with environment To
+type Report struct {
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	Differences []Difference `json:"differences"`
+	// Unused lists the allowlist entries matching no difference, which can
+	// be removed
+	Unused []Entry `json:"unused"`
+}
+
+// Failed reports whether any difference is not declared in the allowlist
+func (r *Report) Failed() bool {
+	for _, difference := range r.Differences {
+		if !difference.Expected() {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of expected and unexpected differences
+func (r *Report) Counts() (expected int, unexpected int) {
+	for _, difference := range r.Differences {
+		if difference.Expected() {
+			expected++
+		} else {
+			unexpected++
+		}
+	}
+	return expected, unexpected
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes one row per difference, unexpected ones first, followed
+// by the unused allowlist entries
+func (r *Report) WriteText(w io.Writer) error {
+	differences := append([]Difference(nil), r.Differences...)
+	sort.SliceStable(differences, func(i, j int) bool {
+		return !differences[i].Expected() && differences[j].Expected()
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "UNIT\tINPUT\t%s\t%s\tSTATUS\n", strings.ToUpper(r.From), strings.ToUpper(r.To))
+	for _, difference := range differences {
+		status := "unexpected"
+		if difference.Expected() {
+			status = "expected: " + difference.Reason
+		}
+		input := difference.Input
+		if input == "" {
+			input = "(unit)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", difference.Unit, input, difference.From, difference.To, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, entry := range r.Unused {
+		if _, err := fmt.Fprintf(w, "unused allowlist entry: %s %s (%s)\n", entry.Unit, entry.Input, entry.Reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compare resolves the units of environments from and to under
+// root/environments and returns their differences, with the reasons of the
+// allowlist. A nil allowlist declares nothing.
+func Compare(root string, from string, to string, allowlist *Allowlist) (*Report, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	r := &resolver{root: root, sources: map[string][]byte{}}
+	fromUnits, err := r.environment(from)
+	if err != nil {
+		return nil, err
+	}
+	toUnits, err := r.environment(to)
+	if err != nil {
+		return nil, err
+	}
+	if len(fromUnits) == 0 {
+		return nil, fmt.Errorf("environment %q has no units", from)
+	}
+	if len(toUnits) == 0 {
+		return nil, fmt.Errorf("environment %q has no units", to)
+	}
+
+	report := &Report{From: from, To: to, Differences: []Difference{}, Unused: []Entry{}}
+	for _, name := range unitNames(fromUnits, toUnits) {
+		fromUnit, toUnit := fromUnits[name], toUnits[name]
+		if fromUnit == nil || toUnit == nil {
+			difference := Difference{Unit: name}
+			if fromUnit != nil {
+				difference.From = &Value{Text: fromUnit.Path}
+			} else {
+				difference.To = &Value{Text: toUnit.Path}
+			}
+			report.Differences = append(report.Differences, difference)
+			continue
+		}
+		report.Differences = append(report.Differences, diff(name, r.values(fromUnit, toUnit), r.values(toUnit, fromUnit))...)
+	}
+
+	used := map[int]bool{}
+	for i := range report.Differences {
+		if index := allowlist.Match(report.Differences[i]); index >= 0 {
+			report.Differences[i].Reason = allowlist.Differences[index].Reason
+			used[index] = true
+		}
+	}
+	if allowlist != nil {
+		for i, entry := range allowlist.Differences {
+			if !used[i] {
+				report.Unused = append(report.Unused, entry)
+			}
+		}
+	}
+	return report, nil
+}
+
+type resolver struct {
+	root    string
+	sources map[string][]byte
+}
+
+// environment loads the units of one environment, keyed by name
+func (r *resolver) environment(env string) (map[string]*inputs.Unit, error) {
+	paths, err := contract.FindUnits(r.root)
+	if err != nil {
+		return nil, err
+	}
+	units := map[string]*inputs.Unit{}
+	for _, path := range paths {
+		if filepath.Base(filepath.Dir(filepath.Dir(path))) != env {
+			continue
+		}
+		unit, err := inputs.LoadUnit(r.root, path)
+		if err != nil {
+			return nil, err
+		}
+		units[unit.Name] = unit
+	}
+	return units, nil
+}
+
+func unitNames(a map[string]*inputs.Unit, b map[string]*inputs.Unit) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, units := range []map[string]*inputs.Unit{a, b} {
+		for name := range units {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// values flattens the resolved inputs of unit into key paths. Inputs only
+// other sets are filled in with the module default, when there is one.
+func (r *resolver) values(unit *inputs.Unit, other *inputs.Unit) map[string]*Value {
+	resolved := map[string]inputs.Input{}
+	for name, input := range unit.Inherited {
+		resolved[name] = input
+	}
+	for name, input := range unit.Values {
+		resolved[name] = input
+	}
+
+	values := map[string]*Value{}
+	for name, input := range resolved {
+		location := fmt.Sprintf("%s:%d:%d", input.Range.Filename, input.Range.Start.Line, input.Range.Start.Column)
+		if !input.Value.IsWhollyKnown() {
+			values[name] = &Value{Text: r.source(input.Range), Location: location}
+			continue
+		}
+		flatten(values, name, input.Value, func(text string) *Value {
+			return &Value{Text: text, Location: location}
+		})
+	}
+
+	if unit.Module == nil {
+		return values
+	}
+	for name := range other.Values {
+		r.fillDefault(values, unit, name)
+	}
+	for name := range other.Inherited {
+		r.fillDefault(values, unit, name)
+	}
+	return values
+}
+
+func (r *resolver) fillDefault(values map[string]*Value, unit *inputs.Unit, name string) {
+	if _, set := unit.Values[name]; set {
+		return
+	}
+	if _, set := unit.Inherited[name]; set {
+		return
+	}
+	variable, ok := unit.Module.Variables[name]
+	if !ok || !variable.HasDefault || !variable.Default.IsWhollyKnown() {
+		return
+	}
+	flatten(values, name, variable.Default, func(text string) *Value {
+		return &Value{Text: text, Default: true}
+	})
+}
+
+// flatten adds value under path, and each attribute or element of a known,
+// non-empty object or map under path.<key>
+func flatten(values map[string]*Value, path string, value cty.Value, newValue func(string) *Value) {
+	ty := value.Type()
+	if !value.IsNull() && (ty.IsObjectType() || ty.IsMapType()) && value.LengthInt() > 0 {
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+			flatten(values, path+"."+key.AsString(), element, newValue)
+		}
+		return
+	}
+	values[path] = newValue(format(value))
+}
+
+var whitespace = regexp.MustCompile(`\s*\n\s*`)
+
+// format writes a known value in HCL syntax on one line
+func format(value cty.Value) string {
+	text := strings.TrimSpace(string(hclwrite.TokensForValue(value).Bytes()))
+	return whitespace.ReplaceAllString(text, " ")
+}
+
+// source returns the source text of an expression, on one line
+func (r *resolver) source(rng hcl.Range) string {
+	src, ok := r.sources[rng.Filename]
+	if !ok {
+		src, _ = os.ReadFile(filepath.Join(r.root, rng.Filename))
+		r.sources[rng.Filename] = src
+	}
+	if rng.End.Byte > len(src) {
+		return "?"
+	}
+	return whitespace.ReplaceAllString(string(rng.SliceBytes(src)), " ")
+}
+
+// diff returns the key paths whose values differ, in path order
+func diff(unit string, from map[string]*Value, to map[string]*Value) []Difference {
+	paths := map[string]bool{}
+	for path := range from {
+		paths[path] = true
+	}
+	for path := range to {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var differences []Difference
+	for _, path := range sorted {
+		a, b := from[path], to[path]
+		if a != nil && b != nil && a.Text == b.Text {
+			continue
+		}
+		differences = append(differences, Difference{Unit: unit, Input: path, From: a, To: b})
+	}
+	return differences
+}
@@ -0,0 +1,132 @@
+package envdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnvironmentDifferencesAreDeclared fails when staging and production
+// differ in a way envdiff/allowlist.yaml does not declare, or when the
+// allowlist declares a difference that no longer exists
+func TestEnvironmentDifferencesAreDeclared(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	allowlist, err := LoadAllowlist(filepath.Join(root, "test", "unit", "envdiff", "allowlist.yaml"))
+	require.NoError(t, err)
+
+	report, err := Compare(root, "staging", "production", allowlist)
+	require.NoError(t, err)
+	for _, difference := range report.Differences {
+		if !difference.Expected() {
+			t.Errorf("%s %s: %s in staging, %s in production", difference.Unit, difference.Input, difference.From, difference.To)
+		}
+	}
+	for _, entry := range report.Unused {
+		t.Errorf("allowlist entry %s %s matches no difference", entry.Unit, entry.Input)
+	}
+}
+
+// writeEnvironment writes env.hcl and key-vault and resource-group units
+// into environments/<env> of a scratch repository
+func writeEnvironment(t *testing.T, root string, env string, units map[string]string) {
+	t.Helper()
+
+	dir := filepath.Join(root, "environments", env)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	envHCL := "inputs = {\n  environment = \"" + env + "\"\n  tags = { Environment = \"" + env + "\", Project = \"gogs-infra\" }\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "env.hcl"), []byte(envHCL), 0o644))
+
+	for module, inputs := range units {
+		repotest.WriteUnit(t, root, env, module, module, `
+include "env" {
+  path = find_in_parent_folders("env.hcl")
+}
+
+dependency "resource_group" {
+  config_path = "../resource-group"
+}
+`+repotest.Inputs(inputs))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	root := t.TempDir()
+	writeEnvironment(t, root, "staging", map[string]string{
+		"key-vault": `  key_vault_name      = "kv-gogs-001"
+  resource_group_name = dependency.resource_group.outputs.resource_group_name
+  sku_name            = "standard"
+  location            = dependency.resource_group.outputs.resource_group_location
+`,
+		"resource-group": "  resource_group_name = \"rg-staging\"\n",
+	})
+	writeEnvironment(t, root, "production", map[string]string{
+		"key-vault": `  key_vault_name             = "kv-gogs-001"
+  resource_group_name        = dependency.resource_group.outputs.resource_group_name
+  soft_delete_retention_days = 90
+  location                   = dependency.resource_group.outputs["resource_group_location"]
+`,
+	})
+
+	report, err := Compare(root, "staging", "production", &Allowlist{Differences: []Entry{
+		{Unit: "*", Input: "environment", Reason: "named after the environment"},
+		{Unit: "key-vault", Input: "tags", Reason: "tagged with the environment"},
+		{Unit: "splunk-vm", Input: "vm_size", Reason: "stale"},
+	}})
+	require.NoError(t, err)
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	// sku_name is set to its default in staging only, which is no difference
+	assert.Equal(t, `UNIT            INPUT                       STAGING                                                    PRODUCTION                                                    STATUS
+key-vault       location                    dependency.resource_group.outputs.resource_group_location  dependency.resource_group.outputs["resource_group_location"]  unexpected
+key-vault       soft_delete_retention_days  7 (default)                                                90                                                            unexpected
+resource-group  (unit)                      environments/staging/resource-group/terragrunt.hcl         -                                                             unexpected
+key-vault       environment                 "staging"                                                  "production"                                                  expected: named after the environment
+key-vault       tags.Environment            "staging"                                                  "production"                                                  expected: tagged with the environment
+unused allowlist entry: splunk-vm vm_size (stale)
+`, text.String())
+	assert.True(t, report.Failed())
+	expected, unexpected := report.Counts()
+	assert.Equal(t, 2, expected)
+	assert.Equal(t, 3, unexpected)
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteJSON(&out))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	require.Len(t, decoded.Differences, 5)
+	retention := decoded.Differences[2]
+	assert.Equal(t, "soft_delete_retention_days", retention.Input)
+	assert.Equal(t, &Value{Text: "7", Default: true}, retention.From)
+	assert.Equal(t, &Value{Text: "90", Location: "environments/production/key-vault/terragrunt.hcl:16:32"}, retention.To)
+	assert.Equal(t, "environments/production/env.hcl:2:17", decoded.Differences[0].To.Location)
+}
+
+func TestLoadAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("differences:\n  - unit: key-vault\n    input: sku_name\n  - unit: networking\n    reason: open\n"), 0o644))
+	_, err := LoadAllowlist(path)
+	assert.ErrorContains(t, err, "entry 1 needs a unit, input and reason")
+	assert.ErrorContains(t, err, "entry 2 needs a unit, input and reason")
+
+	require.NoError(t, os.WriteFile(path, []byte("differences:\n  - unit: key-vault\n    input: sku_name\n    reson: typo\n"), 0o644))
+	_, err = LoadAllowlist(path)
+	assert.ErrorContains(t, err, "field reson not found")
+
+	allowlist := &Allowlist{Differences: []Entry{
+		{Unit: "*", Input: "tags", Reason: "a"},
+		{Unit: "splunk-vm", Input: "*", Reason: "b"},
+	}}
+	assert.Equal(t, 0, allowlist.Match(Difference{Unit: "networking", Input: "tags.Environment"}))
+	assert.Equal(t, -1, allowlist.Match(Difference{Unit: "networking", Input: "tagsuffix"}))
+	assert.Equal(t, 1, allowlist.Match(Difference{Unit: "splunk-vm"}))
+	assert.Equal(t, -1, (*Allowlist)(nil).Match(Difference{Unit: "splunk-vm"}))
+}
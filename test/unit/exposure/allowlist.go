@@ -0,0 +1,72 @@
+package exposure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Allowlist lists accepted exposures of sensitive ports. An accepted finding
+// is still reported but does not fail the check.
+//
+//	exceptions:
+//	  - environment: production
+//	    nsg: vm
+//	    port: 22
+//	    reason: Bastion is not deployed yet, admin_ip_range is set by Jenkins
+type Allowlist struct {
+	Exceptions []Exception `yaml:"exceptions"`
+}
+
+// Exception accepts the exposure of one port of a network security group in
+// one environment. The reason is required.
+type Exception struct {
+	Environment string `yaml:"environment"`
+	NSG         string `yaml:"nsg"`
+	Port        string `yaml:"port"`
+	Reason      string `yaml:"reason"`
+}
+
+// LoadAllowlist reads an allowlist file. Unknown keys and exceptions without
+// an environment, NSG, port or reason are errors.
+func LoadAllowlist(path string) (*Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	allowlist := &Allowlist{}
+	if err := decoder.Decode(allowlist); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var errs []error
+	for i, exception := range allowlist.Exceptions {
+		if exception.Environment == "" || exception.NSG == "" || exception.Port == "" || exception.Reason == "" {
+			errs = append(errs, fmt.Errorf("%s: exception %d needs an environment, nsg, port and reason", path, i+1))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return allowlist, nil
+}
+
+// Match returns the exception accepting the finding, or nil. A nil allowlist
+// accepts nothing.
+func (a *Allowlist) Match(finding Finding) *Exception {
+	if a == nil {
+		return nil
+	}
+	for i, exception := range a.Exceptions {
+		if exception.Environment == finding.Environment && exception.NSG == finding.NSG && exception.Port == finding.Port {
+			return &a.Exceptions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,551 @@
+// Package exposure resolves the inbound rules of the network security groups
+// each environment creates and reports which ports are reachable from where.
+//
+// The rules are read from the module the unit applies and evaluated with the
+// unit's inputs, so var.admin_ip_range and var.address_space[0] become the
+// ranges the plan would use. For every port an explicit rule mentions, the
+// rules are walked in priority order, Azure's default inbound rules included,
+// for four kinds of source: the whole internet, a specific public range such
+// as an office IP, private addresses outside the virtual network and the
+// virtual network itself. The first rule covering the source decides.
+//
+// Sensitive ports reachable from the whole internet are findings. They fail
+// the check in the environments it is strict for unless an allowlist entry
+// accepts them.
+package exposure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Scope is a kind of traffic source
+type Scope string
+
+const (
+	// Internet is any address, the whole internet included
+	Internet Scope = "internet"
+	// Public is a specific public range, such as an office IP
+	Public Scope = "public range"
+	// Private is a private address outside the virtual network
+	Private Scope = "private"
+	// VirtualNetwork is an address inside the virtual network
+	VirtualNetwork Scope = "virtual network"
+)
+
+// Scopes lists the scopes in the order of the matrix columns
+var Scopes = []Scope{Internet, Public, Private, VirtualNetwork}
+
+// Services names well-known ports in the matrix
+var Services = map[string]string{
+	"22":   "SSH",
+	"80":   "HTTP",
+	"443":  "HTTPS",
+	"3389": "RDP",
+	"8000": "Splunk Web",
+	"8088": "Splunk HEC",
+	"9997": "Splunk forwarder",
+}
+
+// SensitivePorts are the ports that must not be reachable from the internet
+var SensitivePorts = []string{"22", "3389", "8000"}
+
+// NSGType is the resource type of a network security group
+const NSGType = "azurerm_network_security_group"
+
+// Rule is an evaluated security_rule block. Ports and sources that cannot be
+// evaluated offline are nil.
+type Rule struct {
+	NSG       string      `json:"nsg"`
+	Name      string      `json:"name"`
+	Priority  int         `json:"priority"`
+	Direction string      `json:"direction"`
+	Access    string      `json:"access"`
+	Protocol  string      `json:"protocol"`
+	Ports     []string    `json:"ports"`
+	Sources   []string    `json:"sources"`
+	Range     hcl.Range   `json:"-"`
+	ports     []portRange // parsed Ports
+}
+
+// defaultRules are the inbound rules Azure adds to every network security
+// group, after the explicit ones
+var defaultRules = []Rule{
+	{Name: "AllowVnetInBound", Priority: 65000, Direction: "Inbound", Access: "Allow", Protocol: "*", Ports: []string{"*"}, Sources: []string{"VirtualNetwork"}, ports: []portRange{allPorts}},
+	{Name: "AllowAzureLoadBalancerInBound", Priority: 65001, Direction: "Inbound", Access: "Allow", Protocol: "*", Ports: []string{"*"}, Sources: []string{"AzureLoadBalancer"}, ports: []portRange{allPorts}},
+	{Name: "DenyAllInBound", Priority: 65500, Direction: "Inbound", Access: "Deny", Protocol: "*", Ports: []string{"*"}, Sources: []string{"*"}, ports: []portRange{allPorts}},
+}
+
+// Cell is the decision for one port and scope
+type Cell struct {
+	// Access is Allow, Deny, or empty when a rule that may apply could not be
+	// evaluated offline
+	Access string `json:"access"`
+	Rule   string `json:"rule"`
+	Source string `json:"source,omitempty"`
+}
+
+// Row is one port of a network security group
+type Row struct {
+	NSG     string         `json:"nsg"`
+	Port    string         `json:"port"`
+	Service string         `json:"service,omitempty"`
+	Cells   map[Scope]Cell `json:"cells"`
+}
+
+// Finding is a sensitive port reachable from the whole internet
+type Finding struct {
+	Environment string `json:"environment"`
+	NSG         string `json:"nsg"`
+	Port        string `json:"port"`
+	Service     string `json:"service,omitempty"`
+	Rule        string `json:"rule"`
+	Source      string `json:"source"`
+	// Fails is true when the environment is strict and no allowlist entry
+	// accepts the finding
+	Fails bool `json:"fails"`
+	// Accepted holds the reason of the allowlist entry that accepts it
+	Accepted string `json:"accepted,omitempty"`
+}
+
+func (c Cell) String() string {
+	switch {
+	case c.Access == "":
+		return "? " + c.Rule
+	case c.Rule == "DenyAllInBound":
+		return "-"
+	case strings.EqualFold(c.Access, "Allow"):
+		return "allow " + c.Rule
+	default:
+		return "deny " + c.Rule
+	}
+}
+
+func (f Finding) String() string {
+	service := f.Port
+	if f.Service != "" {
+		service = fmt.Sprintf("%s (%s)", f.Service, f.Port)
+	}
+	s := fmt.Sprintf("%s: %s NSG allows %s from the internet via %s (source %s)", f.Environment, f.NSG, service, f.Rule, f.Source)
+	if f.Accepted != "" {
+		s += ", accepted: " + f.Accepted
+	}
+	return s
+}
+
+// Environment is the exposure of one environment
+type Environment struct {
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+	// AddressSpace is the address space of the virtual networks the module
+	// creates, which sources are classified against
+	AddressSpace []netip.Prefix `json:"address_space"`
+	Rules        []Rule         `json:"rules"`
+	Matrix       []Row          `json:"matrix"`
+	Findings     []Finding      `json:"findings"`
+}
+
+// Options controls Analyze
+type Options struct {
+	// Env limits the analysis to one environment when it is not empty
+	Env string
+	// Strict lists the environments whose findings fail
+	Strict []string
+	// Allowlist accepts findings that would otherwise fail
+	Allowlist *Allowlist
+}
+
+// Report is the exposure of every environment
+type Report struct {
+	Environments []*Environment `json:"environments"`
+}
+
+// Analyze resolves the network security groups of every unit under
+// root/environments whose module creates any
+func Analyze(root string, options Options) (*Report, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, path := range paths {
+		unit, err := inputs.LoadUnit(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if unit.Module == nil || (options.Env != "" && unit.Env != options.Env) {
+			continue
+		}
+		env, err := Resolve(unit)
+		if err != nil {
+			return nil, err
+		}
+		if env == nil {
+			continue
+		}
+		env.findings(options)
+		report.Environments = append(report.Environments, env)
+	}
+	if options.Env != "" && len(report.Environments) == 0 {
+		return nil, fmt.Errorf("no network security groups found in environment %q", options.Env)
+	}
+	return report, nil
+}
+
+// Resolve evaluates the network security groups of a unit's module with the
+// unit's inputs and builds the exposure matrix. It returns nil when the module
+// creates none.
+func Resolve(unit *inputs.Unit) (*Environment, error) {
+	ctx, err := unit.Module.EvalContext(unit.Variables())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", unit.Path, err)
+	}
+
+	env := &Environment{Name: unit.Env, Unit: unit.Path}
+	for _, resource := range unit.Module.Resources {
+		switch resource.Type {
+		case NSGType:
+			for _, block := range resource.Body.Blocks {
+				if block.Type != "security_rule" {
+					continue
+				}
+				rule, err := evalRule(resource.Name, block, ctx)
+				if err != nil {
+					return nil, err
+				}
+				env.Rules = append(env.Rules, rule)
+			}
+		case "azurerm_virtual_network":
+			if attr, ok := resource.Body.Attributes["address_space"]; ok {
+				for _, space := range evalStrings(attr.Expr, ctx) {
+					if prefix, err := netip.ParsePrefix(space); err == nil {
+						env.AddressSpace = append(env.AddressSpace, prefix.Masked())
+					}
+				}
+			}
+		}
+	}
+	if len(env.Rules) == 0 {
+		return nil, nil
+	}
+	env.matrix()
+	return env, nil
+}
+
+func evalRule(nsg string, block *hclsyntax.Block, ctx *hcl.EvalContext) (Rule, error) {
+	rule := Rule{NSG: nsg, Range: block.DefRange()}
+	attrs := block.Body.Attributes
+	rule.Name = evalString(attrs["name"], ctx)
+	rule.Direction = evalString(attrs["direction"], ctx)
+	rule.Access = evalString(attrs["access"], ctx)
+	rule.Protocol = evalString(attrs["protocol"], ctx)
+	if attr, ok := attrs["priority"]; ok {
+		value, diags := attr.Expr.Value(ctx)
+		if !diags.HasErrors() && value.IsKnown() && !value.IsNull() && value.Type().Equals(cty.Number) {
+			priority, _ := value.AsBigFloat().Int64()
+			rule.Priority = int(priority)
+		}
+	}
+	rule.Ports = evalList(attrs, "destination_port_range", "destination_port_ranges", ctx)
+	rule.Sources = evalList(attrs, "source_address_prefix", "source_address_prefixes", ctx)
+
+	for _, port := range rule.Ports {
+		parsed, err := parsePortRange(port)
+		if err != nil {
+			return Rule{}, fmt.Errorf("%s: security rule %s: %w", rule.Range, rule.Name, err)
+		}
+		rule.ports = append(rule.ports, parsed)
+	}
+	return rule, nil
+}
+
+func evalString(attr *hclsyntax.Attribute, ctx *hcl.EvalContext) string {
+	if attr == nil {
+		return ""
+	}
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || !value.Type().Equals(cty.String) {
+		return ""
+	}
+	return value.AsString()
+}
+
+// evalList returns the values of the singular and plural form of a rule
+// argument, or nil when either cannot be evaluated
+func evalList(attrs hclsyntax.Attributes, single string, plural string, ctx *hcl.EvalContext) []string {
+	var values []string
+	if attr, ok := attrs[single]; ok {
+		value := evalString(attr, ctx)
+		if value == "" {
+			return nil
+		}
+		values = append(values, value)
+	}
+	if attr, ok := attrs[plural]; ok {
+		list := evalStrings(attr.Expr, ctx)
+		if list == nil {
+			return nil
+		}
+		values = append(values, list...)
+	}
+	return values
+}
+
+func evalStrings(expr hcl.Expression, ctx *hcl.EvalContext) []string {
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() || !value.IsWhollyKnown() || value.IsNull() || !value.CanIterateElements() {
+		return nil
+	}
+	values := []string{}
+	for it := value.ElementIterator(); it.Next(); {
+		_, element := it.Element()
+		if element.IsNull() || !element.Type().Equals(cty.String) {
+			return nil
+		}
+		values = append(values, element.AsString())
+	}
+	return values
+}
+
+// portRange is an inclusive range of ports
+type portRange struct {
+	from, to int
+}
+
+var allPorts = portRange{0, 65535}
+
+func parsePortRange(s string) (portRange, error) {
+	if s == "*" {
+		return allPorts, nil
+	}
+	from, to, isRange := strings.Cut(s, "-")
+	first, err := strconv.Atoi(from)
+	if err != nil {
+		return portRange{}, fmt.Errorf("invalid port %q", s)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return portRange{}, fmt.Errorf("invalid port range %q", s)
+		}
+	}
+	return portRange{first, last}, nil
+}
+
+func (r Rule) coversPort(port portRange) bool {
+	for _, p := range r.ports {
+		if p.from <= port.from && port.to <= p.to {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports which of the sources covers the scope, if any
+func covers(sources []string, scope Scope, spaces []netip.Prefix) (string, bool) {
+	for _, source := range sources {
+		if classify(source, spaces)[scope] {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// classify returns the scopes a rule source covers
+func classify(source string, spaces []netip.Prefix) map[Scope]bool {
+	switch strings.ToLower(source) {
+	case "*", "any", "0.0.0.0/0", "::/0":
+		return map[Scope]bool{Internet: true, Public: true, Private: true, VirtualNetwork: true}
+	case "internet":
+		return map[Scope]bool{Internet: true, Public: true}
+	case "virtualnetwork":
+		return map[Scope]bool{VirtualNetwork: true}
+	}
+
+	prefix, err := netip.ParsePrefix(source)
+	if err != nil {
+		addr, err := netip.ParseAddr(source)
+		if err != nil {
+			return nil // another service tag
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	for _, space := range spaces {
+		if space.Bits() <= prefix.Bits() && space.Contains(prefix.Addr()) {
+			return map[Scope]bool{VirtualNetwork: true}
+		}
+	}
+	if prefix.Addr().IsPrivate() {
+		return map[Scope]bool{Private: true}
+	}
+	return map[Scope]bool{Public: true}
+}
+
+// nsgs returns the names of the network security groups in the order the
+// module declares them
+func (e *Environment) nsgs() []string {
+	var names []string
+	for _, rule := range e.Rules {
+		if len(names) == 0 || names[len(names)-1] != rule.NSG {
+			names = append(names, rule.NSG)
+		}
+	}
+	return names
+}
+
+// rules returns the inbound rules of a network security group in the order
+// Azure evaluates them, the default rules last
+func (e *Environment) rules(nsg string) []Rule {
+	var rules []Rule
+	for _, rule := range e.Rules {
+		if rule.NSG == nsg && strings.EqualFold(rule.Direction, "Inbound") {
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority < rules[j].Priority })
+	return append(rules, defaultRules...)
+}
+
+// matrix decides every scope for every port an explicit inbound rule names
+func (e *Environment) matrix() {
+	for _, nsg := range e.nsgs() {
+		rules := e.rules(nsg)
+		seen := map[string]bool{}
+		for _, rule := range rules[:len(rules)-len(defaultRules)] {
+			for i, port := range rule.Ports {
+				if seen[port] {
+					continue
+				}
+				seen[port] = true
+				row := Row{NSG: nsg, Port: port, Service: Services[port], Cells: map[Scope]Cell{}}
+				for _, scope := range Scopes {
+					row.Cells[scope] = decide(rules, rule.ports[i], scope, e.AddressSpace)
+				}
+				e.Matrix = append(e.Matrix, row)
+			}
+		}
+	}
+}
+
+// decide walks the rules in priority order and returns the first that
+// applies to traffic from the scope to the port
+func decide(rules []Rule, port portRange, scope Scope, spaces []netip.Prefix) Cell {
+	for _, rule := range rules {
+		if rule.Ports != nil && !rule.coversPort(port) {
+			continue
+		}
+		if rule.Ports == nil || rule.Sources == nil || rule.Access == "" {
+			// the rule may apply but cannot be evaluated offline
+			return Cell{Rule: rule.Name}
+		}
+		if source, ok := covers(rule.Sources, scope, spaces); ok {
+			return Cell{Access: rule.Access, Rule: rule.Name, Source: source}
+		}
+	}
+	return Cell{Access: "Deny", Rule: "DenyAllInBound"}
+}
+
+// findings checks every sensitive port of every network security group, so a
+// rule opening a port range or * is caught as well
+func (e *Environment) findings(options Options) {
+	strict := false
+	for _, env := range options.Strict {
+		strict = strict || env == e.Name
+	}
+	for _, nsg := range e.nsgs() {
+		rules := e.rules(nsg)
+		for _, port := range SensitivePorts {
+			number, _ := strconv.Atoi(port)
+			cell := decide(rules, portRange{number, number}, Internet, e.AddressSpace)
+			if !strings.EqualFold(cell.Access, "Allow") {
+				continue
+			}
+			finding := Finding{
+				Environment: e.Name,
+				NSG:         nsg,
+				Port:        port,
+				Service:     Services[port],
+				Rule:        cell.Rule,
+				Source:      cell.Source,
+			}
+			if entry := options.Allowlist.Match(finding); entry != nil {
+				finding.Accepted = entry.Reason
+			}
+			finding.Fails = strict && finding.Accepted == ""
+			e.Findings = append(e.Findings, finding)
+		}
+	}
+}
+
+// Failed reports whether any finding fails
+func (r *Report) Failed() bool {
+	for _, env := range r.Environments {
+		for _, finding := range env.Findings {
+			if finding.Fails {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the matrix of every environment followed by its findings.
+// A cell names the rule that decides it; a dash is the default deny and a
+// question mark a rule that cannot be evaluated offline.
+func (r *Report) WriteText(w io.Writer) error {
+	for i, env := range r.Environments {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n", env.Name, env.Unit)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprint(tw, "NSG\tPORT\tSERVICE")
+		for _, scope := range Scopes {
+			fmt.Fprintf(tw, "\t%s", strings.ToUpper(string(scope)))
+		}
+		fmt.Fprintln(tw)
+		for _, row := range env.Matrix {
+			fmt.Fprintf(tw, "%s\t%s\t%s", row.NSG, row.Port, row.Service)
+			for _, scope := range Scopes {
+				fmt.Fprintf(tw, "\t%s", row.Cells[scope])
+			}
+			fmt.Fprintln(tw)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, finding := range env.Findings {
+			level := "warning"
+			if finding.Fails {
+				level = "error"
+			}
+			fmt.Fprintf(w, "%s: %s\n", level, finding)
+		}
+	}
+	return nil
+}
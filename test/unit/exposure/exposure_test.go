@@ -0,0 +1,205 @@
+package exposure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func environment(t *testing.T, report *Report, name string) *Environment {
+	t.Helper()
+	for _, env := range report.Environments {
+		if env.Name == name {
+			return env
+		}
+	}
+	require.FailNow(t, "environment not analyzed", name)
+	return nil
+}
+
+func row(t *testing.T, env *Environment, nsg string, port string) Row {
+	t.Helper()
+	for _, row := range env.Matrix {
+		if row.NSG == nsg && row.Port == port {
+			return row
+		}
+	}
+	require.FailNow(t, "port not in matrix", "%s %s", nsg, port)
+	return Row{}
+}
+
+func TestProductionFailsWhenAdminPortsAreOpenToTheInternet(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	t.Setenv("TF_VAR_admin_ip_range", "0.0.0.0/0")
+
+	report, err := Analyze(root, Options{Strict: []string{"production"}})
+	require.NoError(t, err)
+	assert.True(t, report.Failed())
+
+	production := environment(t, report, "production")
+	require.Len(t, production.Findings, 2)
+	assert.Equal(t, "production: vm NSG allows SSH (22) from the internet via AllowSSH (source 0.0.0.0/0)", production.Findings[0].String())
+	assert.Equal(t, "8000", production.Findings[1].Port)
+	assert.True(t, production.Findings[1].Fails)
+
+	staging := environment(t, report, "staging")
+	require.Len(t, staging.Findings, 2, "staging sets admin_ip_range = \"*\"")
+	assert.False(t, staging.Findings[0].Fails, "staging is not strict")
+
+	forwarder := row(t, staging, "vm", "9997")
+	assert.Equal(t, "Deny", forwarder.Cells[Internet].Access)
+	assert.Equal(t, Cell{Access: "Allow", Rule: "AllowSplunkForwarder", Source: "10.0.0.0/16"}, forwarder.Cells[VirtualNetwork])
+	assert.Equal(t, "allow AllowHTTPS", row(t, staging, "container", "443").Cells[Internet].String())
+}
+
+func TestRestrictedAdminRange(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	t.Setenv("TF_VAR_admin_ip_range", "203.0.113.0/24")
+
+	report, err := Analyze(root, Options{Env: "production", Strict: []string{"production"}})
+	require.NoError(t, err)
+	require.Len(t, report.Environments, 1)
+	assert.False(t, report.Failed())
+
+	ssh := row(t, report.Environments[0], "vm", "22")
+	assert.Equal(t, "-", ssh.Cells[Internet].String())
+	assert.Equal(t, "allow AllowSSH", ssh.Cells[Public].String())
+	assert.Equal(t, "-", ssh.Cells[Private].String())
+	assert.Equal(t, "AllowVnetInBound", ssh.Cells[VirtualNetwork].Rule, "Azure's default rule lets the virtual network in")
+
+	_, err = Analyze(root, Options{Env: "sandbox"})
+	assert.Error(t, err)
+}
+
+func TestAllowlistAcceptsFindings(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	t.Setenv("TF_VAR_admin_ip_range", "*")
+
+	path := filepath.Join(t.TempDir(), "allowlist.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`exceptions:
+  - environment: production
+    nsg: vm
+    port: 22
+    reason: bastion pending
+  - environment: production
+    nsg: vm
+    port: 8000
+    reason: bastion pending
+`), 0o644))
+	allowlist, err := LoadAllowlist(path)
+	require.NoError(t, err)
+
+	report, err := Analyze(root, Options{Env: "production", Strict: []string{"production"}, Allowlist: allowlist})
+	require.NoError(t, err)
+	assert.False(t, report.Failed())
+	findings := report.Environments[0].Findings
+	require.Len(t, findings, 2)
+	assert.Equal(t, "bastion pending", findings[0].Accepted)
+	assert.Contains(t, findings[0].String(), "accepted: bastion pending")
+
+	allowlist.Exceptions = allowlist.Exceptions[:1]
+	report, err = Analyze(root, Options{Env: "production", Strict: []string{"production"}, Allowlist: allowlist})
+	require.NoError(t, err)
+	assert.True(t, report.Failed(), "Splunk Web is no longer accepted")
+}
+
+func TestLoadAllowlist(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	_, err = LoadAllowlist(filepath.Join(root, "test", "unit", "exposure", "allowlist.yaml"))
+	require.NoError(t, err, "the checked-in allowlist must load")
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.yaml")
+	require.NoError(t, os.WriteFile(missing, []byte("exceptions:\n  - environment: production\n    nsg: vm\n    port: 22\n"), 0o644))
+	_, err = LoadAllowlist(missing)
+	assert.ErrorContains(t, err, "exception 1 needs an environment, nsg, port and reason")
+
+	unknown := filepath.Join(dir, "unknown.yaml")
+	require.NoError(t, os.WriteFile(unknown, []byte("exceptions:\n  - env: production\n"), 0o644))
+	_, err = LoadAllowlist(unknown)
+	assert.ErrorContains(t, err, "field env not found")
+}
+
+func TestRulesAreEvaluatedInPriorityOrder(t *testing.T) {
+	root := t.TempDir()
+	module := filepath.Join(root, "modules", "firewall")
+	require.NoError(t, os.MkdirAll(module, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(module, "main.tf"), []byte(`
+variable "office" {
+  type = string
+}
+
+resource "azurerm_virtual_network" "main" {
+  address_space = ["10.5.0.0/16"]
+}
+
+resource "azurerm_network_security_group" "edge" {
+  security_rule {
+    name                       = "AllowAll"
+    priority                   = 300
+    direction                  = "Inbound"
+    access                     = "Allow"
+    protocol                   = "*"
+    destination_port_range     = "*"
+    source_address_prefix      = "*"
+  }
+
+  security_rule {
+    name                       = "DenyInternetAdmin"
+    priority                   = 100
+    direction                  = "Inbound"
+    access                     = "Deny"
+    protocol                   = "Tcp"
+    destination_port_ranges    = ["20-23", "3389"]
+    source_address_prefix      = "Internet"
+  }
+
+  security_rule {
+    name                       = "AllowOffice"
+    priority                   = 200
+    direction                  = "Inbound"
+    access                     = "Allow"
+    protocol                   = "Tcp"
+    destination_port_range     = "8000"
+    source_address_prefixes    = [var.office, "192.168.0.0/16"]
+  }
+}
+`), 0o644))
+	dir := filepath.Join(root, "environments", "test", "firewall")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "terragrunt.hcl"), []byte(`terraform {
+  source = "../../../modules/firewall"
+}
+
+inputs = {
+  office = "198.51.100.7"
+}
+`), 0o644))
+
+	report, err := Analyze(root, Options{Strict: []string{"test"}})
+	require.NoError(t, err)
+	require.Len(t, report.Environments, 1)
+	env := report.Environments[0]
+
+	admin := row(t, env, "edge", "20-23")
+	assert.Equal(t, "deny DenyInternetAdmin", admin.Cells[Internet].String())
+	assert.Equal(t, "allow AllowAll", admin.Cells[Private].String(), "the Internet tag does not cover private addresses")
+
+	web := row(t, env, "edge", "8000")
+	assert.Equal(t, Cell{Access: "Allow", Rule: "AllowOffice", Source: "198.51.100.7"}, web.Cells[Public])
+	assert.Equal(t, "192.168.0.0/16", web.Cells[Private].Source)
+	assert.Equal(t, "allow AllowAll", web.Cells[Internet].String())
+
+	require.Len(t, env.Findings, 1, "22 and 3389 are denied; * opens 8000 to the internet")
+	assert.Equal(t, "8000", env.Findings[0].Port)
+	assert.Equal(t, "AllowAll", env.Findings[0].Rule)
+	assert.True(t, report.Failed())
+}
@@ -0,0 +1,201 @@
+// Package fixtures provides the shared Azure infrastructure that most module
+// tests need before they can apply the module under test: a resource group,
+// a Log Analytics workspace and a virtual network with its subnets and NSGs.
+//
+// Every fixture applies its module with terratest and registers the matching
+// terraform.Destroy through t.Cleanup. Cleanup functions run in last-in,
+// first-out order, so fixtures created in dependency order (resource group,
+// then workspace or network, then the module under test) are torn down in
+// reverse without any extra bookkeeping in the tests.
+//
+// Tests are split into the stages of stages.go: Setup applies the fixtures
+// and the module under test, Validate runs the assertions and the teardown
+// stage destroys everything. Each stage can be skipped with SKIP_<stage>, so
+// a slow module can be deployed once and validated repeatedly.
+package fixtures
+
+import (
+	"testing"
+
+	az "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/azcheck"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/gruntwork-io/terratest/modules/azure"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultTags are applied to every fixture created by this package, together
+// with the standard test tags added by Tags
+var DefaultTags = map[string]string{"Environment": "test"}
+
+// Tags returns DefaultTags stamped with the test's standard tags (TestID,
+// TestName, CreatedAt, ExpiresAt and ManagedBy=terratest)
+func Tags(t *testing.T) map[string]string {
+	return naming.For(t).Tags(DefaultTags)
+}
+
+// ResourceGroup holds the outputs of an applied resource-group module
+type ResourceGroup struct {
+	Name     string
+	ID       string
+	Location string
+
+	Options *terraform.Options
+}
+
+// LogAnalytics holds the outputs of an applied log-analytics module
+type LogAnalytics struct {
+	ID               string
+	Name             string
+	CustomerID       string
+	PrimarySharedKey string
+
+	Options *terraform.Options
+}
+
+// Networking holds the outputs of an applied networking module
+type Networking struct {
+	VnetID            string
+	VnetName          string
+	ContainerSubnetID string
+	DatabaseSubnetID  string
+	VMSubnetID        string
+	ContainerNsgID    string
+	VMNsgID           string
+
+	Options *terraform.Options
+}
+
+// NewResourceGroup creates a resource group and destroys it when the test finishes
+func NewResourceGroup(t *testing.T, name string, location string) *ResourceGroup {
+	t.Helper()
+
+	vars := tfvars.NewResourceGroupVars()
+	vars.ResourceGroupName = name
+	vars.Location = location
+	vars.Tags = Tags(t)
+
+	options := vars.ToOptions(t)
+	apply(t, options)
+
+	return &ResourceGroup{
+		Name:     terraform.Output(t, options, "resource_group_name"),
+		ID:       terraform.Output(t, options, "resource_group_id"),
+		Location: terraform.Output(t, options, "resource_group_location"),
+		Options:  options,
+	}
+}
+
+// NewLogAnalytics creates a Log Analytics workspace without optional solutions
+// inside the given resource group and destroys it when the test finishes
+func NewLogAnalytics(t *testing.T, rg *ResourceGroup, workspaceName string) *LogAnalytics {
+	t.Helper()
+
+	vars := tfvars.NewLogAnalyticsVars()
+	vars.WorkspaceName = workspaceName
+	vars.ResourceGroupName = rg.Name
+	vars.Location = rg.Location
+	vars.EnableContainerInsights = false
+	vars.EnableSqlAnalytics = false
+	vars.Tags = Tags(t)
+
+	options := vars.ToOptions(t)
+	apply(t, options)
+
+	return &LogAnalytics{
+		ID:               terraform.Output(t, options, "workspace_id"),
+		Name:             terraform.Output(t, options, "workspace_name"),
+		CustomerID:       terraform.Output(t, options, "workspace_customer_id"),
+		PrimarySharedKey: terraform.Output(t, options, "primary_shared_key"),
+		Options:          options,
+	}
+}
+
+// NewNetworking creates a 10.0.0.0/16 virtual network with the container,
+// database and VM subnets inside the given resource group and destroys it
+// when the test finishes
+func NewNetworking(t *testing.T, rg *ResourceGroup, vnetName string) *Networking {
+	t.Helper()
+
+	vars := tfvars.NewNetworkingVars()
+	vars.VnetName = vnetName
+	vars.ResourceGroupName = rg.Name
+	vars.Location = rg.Location
+	vars.AdminIPRange = "0.0.0.0/0"
+	vars.Tags = Tags(t)
+
+	options := vars.ToOptions(t)
+	apply(t, options)
+
+	return &Networking{
+		VnetID:            terraform.Output(t, options, "vnet_id"),
+		VnetName:          terraform.Output(t, options, "vnet_name"),
+		ContainerSubnetID: terraform.Output(t, options, "container_subnet_id"),
+		DatabaseSubnetID:  terraform.Output(t, options, "database_subnet_id"),
+		VMSubnetID:        terraform.Output(t, options, "vm_subnet_id"),
+		ContainerNsgID:    terraform.Output(t, options, "container_nsg_id"),
+		VMNsgID:           terraform.Output(t, options, "vm_nsg_id"),
+		Options:           options,
+	}
+}
+
+// Apply runs InitAndApply for the module under test and registers its
+// destroy so it is torn down before any fixture it depends on
+func Apply(t *testing.T, options *terraform.Options) {
+	t.Helper()
+	apply(t, options)
+}
+
+// Planned returns the resource addresses the module in options would plan,
+// evaluated offline from the module's count and for_each arguments, so a
+// test can check its toggles before spending minutes on an apply
+func Planned(t *testing.T, options *terraform.Options) []string {
+	t.Helper()
+
+	module, err := tfmodule.Load(options.TerraformDir)
+	require.NoError(t, err)
+	instances, err := module.Expand(options.Vars)
+	require.NoError(t, err)
+	return tfmodule.Addresses(instances)
+}
+
+// SubscriptionID returns the subscription targeted by the tests, taken from
+// the ARM_SUBSCRIPTION_ID environment variable
+func SubscriptionID(t *testing.T) string {
+	t.Helper()
+
+	subscriptionID, err := azure.GetTargetAzureSubscription("")
+	require.NoError(t, err)
+	return subscriptionID
+}
+
+// ARMEndpoint is the Resource Manager endpoint Checker reads resources from
+var ARMEndpoint = az.PublicCloud.ResourceManagerEndpoint
+
+// Checker returns an azcheck.Checker for the subscription targeted by the
+// tests, authorized like terratest's azure package
+func Checker(t *testing.T) *azcheck.Checker {
+	t.Helper()
+
+	authorizer, err := azure.NewAuthorizer()
+	require.NoError(t, err)
+	return azcheck.New(SubscriptionID(t), ARMEndpoint, *authorizer)
+}
+
+// apply records the module and registers its destroy before applying, so a
+// partially applied module is still cleaned up when InitAndApply fails
+// halfway through. The destroy runs as part of the teardown stage and is
+// skipped when SKIP_teardown is set.
+func apply(t *testing.T, options *terraform.Options) {
+	record(t, options)
+	t.Cleanup(func() {
+		test_structure.RunTestStage(t, StageTeardown, func() {
+			destroy(t, options)
+		})
+	})
+	terraform.InitAndApply(t, options)
+}
@@ -0,0 +1,112 @@
+package fixtures
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/require"
+)
+
+// Test stages. Setting SKIP_<stage> (for example SKIP_teardown=true) skips
+// that stage, as with terratest's test_structure.RunTestStage.
+const (
+	StageSetup    = "setup"
+	StageValidate = "validate"
+	StageTeardown = "teardown"
+)
+
+// appliedFile lists, in apply order, the options of every module a test has
+// applied and not yet destroyed
+const appliedFile = "applied.json"
+
+// Setup runs the setup stage, which creates the fixtures and applies the
+// module under test. Every module applied through this package is recorded
+// under repo.TestDataDir(t), so later runs can validate or destroy it.
+//
+// When setup is skipped but teardown is not, the modules recorded by an
+// earlier run are destroyed, newest first, when the test finishes.
+func Setup(t *testing.T, stage func()) {
+	t.Helper()
+
+	if os.Getenv("SKIP_"+StageSetup) != "" {
+		t.Cleanup(func() {
+			test_structure.RunTestStage(t, StageTeardown, func() {
+				applied := loadApplied(t)
+				for i := len(applied) - 1; i >= 0; i-- {
+					destroy(t, applied[i])
+				}
+			})
+		})
+	}
+	test_structure.RunTestStage(t, StageSetup, stage)
+}
+
+// Validate runs the validate stage. Assertions that may need to be re-run
+// against already deployed resources belong here; they should read
+// everything they need through LoadOptions rather than from variables
+// captured during setup.
+func Validate(t *testing.T, stage func()) {
+	t.Helper()
+	test_structure.RunTestStage(t, StageValidate, stage)
+}
+
+// LoadOptions returns the options of the given module as applied by the
+// setup stage of this test, in this run or an earlier one
+func LoadOptions(t *testing.T, module string) *terraform.Options {
+	t.Helper()
+
+	applied := loadApplied(t)
+	for i := len(applied) - 1; i >= 0; i-- {
+		if filepath.Base(applied[i].TerraformDir) == module {
+			return applied[i]
+		}
+	}
+	require.FailNow(t, "module not applied", "module %s has not been applied by the setup stage of %s; run setup first", module, t.Name())
+	return nil
+}
+
+// record adds options to the applied list before they are applied, so a
+// module that fails halfway through its apply is still destroyed
+func record(t *testing.T, options *terraform.Options) {
+	saveApplied(t, append(loadApplied(t), options))
+}
+
+// destroy destroys a recorded module and forgets it. Once nothing is left
+// the test's data folder is removed.
+func destroy(t *testing.T, options *terraform.Options) {
+	terraform.Destroy(t, options)
+
+	var remaining []*terraform.Options
+	for _, applied := range loadApplied(t) {
+		if applied.TerraformDir != options.TerraformDir {
+			remaining = append(remaining, applied)
+		}
+	}
+	if len(remaining) > 0 {
+		saveApplied(t, remaining)
+		return
+	}
+	require.NoError(t, os.RemoveAll(repo.TestDataDir(t)))
+}
+
+func appliedPath(t *testing.T) string {
+	return test_structure.FormatTestDataPath(repo.TestDataDir(t), appliedFile)
+}
+
+func loadApplied(t *testing.T) []*terraform.Options {
+	path := appliedPath(t)
+	if !test_structure.IsTestDataPresent(t, path) {
+		return nil
+	}
+	var applied []*terraform.Options
+	test_structure.LoadTestData(t, path, &applied)
+	return applied
+}
+
+func saveApplied(t *testing.T, applied []*terraform.Options) {
+	test_structure.SaveTestData(t, appliedPath(t), true, applied)
+}
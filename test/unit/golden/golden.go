@@ -0,0 +1,175 @@
+// Package golden keeps the plan of each module scenario in a golden file, so
+// reviews see when a change to a module changes what it would create.
+//
+// A scenario is a module with a set of inputs, e.g. the key-vault module
+// storing the database and Docker Hub credentials. Plan evaluates the module
+// with a stand-in for the azurerm provider, needing neither Terraform nor
+// Azure credentials, and the normalized plan is compared with the file under
+// testdata/<module>/<scenario>.json. After an intended change, rewrite the
+// files and review their diff:
+//
+//	go test ./golden/ -update
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+)
+
+// Scenario is a module planned with a set of inputs
+type Scenario struct {
+	// Module is the module's directory name, e.g. key-vault
+	Module string
+	// Name describes the inputs, e.g. "with secrets"
+	Name string
+	// Vars are the inputs, as terratest's Options.Vars
+	Vars map[string]interface{}
+}
+
+// nonSlug matches the characters a file name replaces with a dash
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// File returns the golden file of the scenario relative to dir, e.g.
+// testdata/key-vault/with-secrets.json
+func (s Scenario) File(dir string) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s.Name), "-"), "-")
+	return filepath.Join(dir, "testdata", s.Module, slug+".json")
+}
+
+// Plan returns the normalized plan of the scenario, taking its module from
+// modules
+func (s Scenario) Plan(modules map[string]*tfmodule.Module) (*plan.Plan, error) {
+	module, ok := modules[s.Module]
+	if !ok {
+		return nil, fmt.Errorf("no module %q", s.Module)
+	}
+	p, err := Plan(module, s.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", s.Module, s.Name, err)
+	}
+	return plan.Normalize(p), nil
+}
+
+// Marshal writes a plan as a golden file: indented JSON with sorted keys
+func Marshal(p *plan.Plan) ([]byte, error) {
+	// Before and After are re-encoded by Normalize, so their keys are sorted
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Write writes the golden file at path
+func Write(path string, p *plan.Plan) error {
+	data, err := Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Diff returns how the planned changes of got differ from those of want, one
+// line per resource or output: "+ <address>: now planned (<action>)" for
+// those only got plans, "- <address>: no longer planned" for those only want
+// plans and "~ <address>: <attribute> <before> → <after>; ..." for those
+// planned with other values.
+func Diff(want, got *plan.Plan) []string {
+	wanted := changes(want)
+	planned := changes(got)
+	var lines []string
+	for _, address := range union(wanted, planned) {
+		w, inWant := wanted[address]
+		g, inGot := planned[address]
+		switch {
+		case !inWant:
+			lines = append(lines, fmt.Sprintf("+ %s: now planned (%s)", address, g.Action()))
+		case !inGot:
+			lines = append(lines, fmt.Sprintf("- %s: no longer planned", address))
+		default:
+			if detail := compare(w, g); detail != "" {
+				lines = append(lines, fmt.Sprintf("~ %s: %s", address, detail))
+			}
+		}
+	}
+	return lines
+}
+
+// changes maps resource addresses and output.<name> to their changes
+func changes(p *plan.Plan) map[string]plan.Change {
+	all := map[string]plan.Change{}
+	for _, rc := range p.ResourceChanges {
+		all[rc.Address] = rc.Change
+	}
+	for name, change := range p.OutputChanges {
+		all["output."+name] = *change
+	}
+	return all
+}
+
+func union(a, b map[string]plan.Change) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compare describes how got differs from want, or returns ""
+func compare(want, got plan.Change) string {
+	var details []string
+	if want.Action() != got.Action() {
+		details = append(details, fmt.Sprintf("%s → %s", want.Action(), got.Action()))
+	}
+	values := plan.Change{Before: want.After, After: got.After}
+	for _, attribute := range values.Attributes() {
+		path := attribute.Path
+		if path == "" {
+			path = "value"
+		}
+		details = append(details, fmt.Sprintf("%s %s → %s", path, attribute.Before, attribute.After))
+	}
+	for _, marker := range []struct {
+		name      string
+		want, got json.RawMessage
+	}{
+		{"known after apply", want.AfterUnknown, got.AfterUnknown},
+		{"sensitive", want.AfterSensitive, got.AfterSensitive},
+	} {
+		if !equalJSON(marker.want, marker.got) {
+			details = append(details, fmt.Sprintf("%s values changed from %s to %s", marker.name, compact(marker.want), compact(marker.got)))
+		}
+	}
+	return strings.Join(details, "; ")
+}
+
+func equalJSON(a, b json.RawMessage) bool {
+	return compact(a) == compact(b)
+}
+
+// compact writes a JSON value with sorted keys and no spaces
+func compact(raw json.RawMessage) string {
+	var value any
+	if len(raw) == 0 || json.Unmarshal(raw, &value) != nil {
+		return string(raw)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
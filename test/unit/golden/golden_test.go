@@ -0,0 +1,196 @@
+package golden
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current plans")
+
+// scenarios are the module inputs whose plans are kept in testdata
+func scenarios() []Scenario {
+	keyVault := tfvars.NewKeyVaultVars()
+	keyVault.KeyVaultName = "kv-gogs-golden"
+	keyVault.Location = "eastus"
+	keyVault.ResourceGroupName = "rg-gogs-golden"
+
+	keyVaultSecrets := keyVault
+	keyVaultSecrets.StoreDbCredentials = true
+	keyVaultSecrets.DbAdminUsername = "gogsadmin"
+	keyVaultSecrets.DbAdminPassword = "golden-db-password"
+	keyVaultSecrets.StoreDockerhubCredentials = true
+	keyVaultSecrets.DockerhubUsername = "gogs"
+	keyVaultSecrets.DockerhubPassword = "golden-dockerhub-token"
+
+	vm := tfvars.NewVirtualMachineVars()
+	vm.VMName = "vm-splunk-golden"
+	vm.Location = "eastus"
+	vm.ResourceGroupName = "rg-gogs-golden"
+	vm.SubnetID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-gogs-golden/providers/Microsoft.Network/virtualNetworks/vnet-gogs-golden/subnets/snet-vm"
+	vm.SSHPublicKey = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC golden"
+	vm.CustomData = "#cloud-config\npackages:\n  - docker.io\n"
+
+	vmWithoutDisk := vm
+	vmWithoutDisk.CreateDataDisk = false
+	vmWithoutDisk.CreatePublicIP = false
+	vmWithoutDisk.CustomData = ""
+	vmWithoutDisk.NetworkSecurityGroupID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-gogs-golden/providers/Microsoft.Network/networkSecurityGroups/nsg-vm"
+
+	sql := tfvars.NewSqlDatabaseVars()
+	sql.SqlServerName = "sql-gogs-golden"
+	sql.DatabaseName = "gogs"
+	sql.Location = "eastus"
+	sql.ResourceGroupName = "rg-gogs-golden"
+	sql.AdminUsername = "sqladmin"
+	sql.AdminPassword = "golden-sql-password"
+	sql.FirewallRules = map[string]tfvars.FirewallRule{
+		"office": {StartIP: "203.0.113.10", EndIP: "203.0.113.20"},
+		"vpn":    {StartIP: "198.51.100.5", EndIP: "198.51.100.5"},
+	}
+
+	resourceGroup := tfvars.NewResourceGroupVars()
+	resourceGroup.ResourceGroupName = "rg-gogs-golden"
+	resourceGroup.Location = "eastus"
+	resourceGroup.Tags = map[string]string{"Environment": "golden", "Project": "gogs"}
+
+	networking := tfvars.NewNetworkingVars()
+	networking.VnetName = "vnet-gogs-golden"
+	networking.Location = "eastus"
+	networking.ResourceGroupName = "rg-gogs-golden"
+	networking.AdminIPRange = "203.0.113.0/24"
+
+	logAnalytics := tfvars.NewLogAnalyticsVars()
+	logAnalytics.WorkspaceName = "log-gogs-golden"
+	logAnalytics.Location = "eastus"
+	logAnalytics.ResourceGroupName = "rg-gogs-golden"
+
+	containers := tfvars.NewContainerInstanceVars()
+	containers.ContainerGroupName = "aci-gogs-golden"
+	containers.ContainerName = "gogs"
+	containers.Location = "eastus"
+	containers.ResourceGroupName = "rg-gogs-golden"
+	containers.DockerImage = "gogs/gogs:0.13"
+	containers.DockerhubUsername = "gogs"
+	containers.DockerhubPassword = "golden-dockerhub-token"
+	containers.ContainerPort = 3000
+	containers.EnvironmentVariables = map[string]string{"RUN_CROND": "true"}
+	containers.SecureEnvironmentVariables = map[string]string{"DB_PASSWORD": "golden-db-password"}
+
+	return []Scenario{
+		{Module: "key-vault", Name: "defaults", Vars: tfvars.ToMap(keyVault)},
+		{Module: "key-vault", Name: "with secrets", Vars: tfvars.ToMap(keyVaultSecrets)},
+		{Module: "virtual-machine", Name: "with data disk", Vars: tfvars.ToMap(vm)},
+		{Module: "virtual-machine", Name: "without data disk or public ip", Vars: tfvars.ToMap(vmWithoutDisk)},
+		{Module: "sql-database", Name: "with firewall rules", Vars: tfvars.ToMap(sql)},
+		{Module: "resource-group", Name: "defaults", Vars: tfvars.ToMap(resourceGroup)},
+		{Module: "networking", Name: "defaults", Vars: tfvars.ToMap(networking)},
+		{Module: "log-analytics", Name: "with solutions", Vars: tfvars.ToMap(logAnalytics)},
+		{Module: "container-instance", Name: "with registry credentials", Vars: tfvars.ToMap(containers)},
+	}
+}
+
+// TestGolden fails when a module's plan for a scenario no longer matches its
+// golden file. Run go test ./golden/ -update after an intended change and
+// review the diff of testdata.
+func TestGolden(t *testing.T) {
+	modulesDir, err := repo.ModulesDir()
+	require.NoError(t, err)
+	modules, err := tfmodule.LoadAll(modulesDir)
+	require.NoError(t, err)
+
+	for _, s := range scenarios() {
+		s := s
+		t.Run(s.Module+"/"+s.Name, func(t *testing.T) {
+			got, err := s.Plan(modules)
+			require.NoError(t, err)
+			file := s.File(".")
+			if *update {
+				require.NoError(t, Write(file, got))
+				return
+			}
+
+			data, err := os.ReadFile(file)
+			require.NoError(t, err, "no golden file; run go test ./golden/ -update")
+			want, err := plan.Parse(data)
+			require.NoError(t, err)
+			if diff := Diff(want, got); len(diff) > 0 {
+				t.Fatalf("the plan differs from %s; if intended, run go test ./golden/ -update\n%s", file, strings.Join(diff, "\n"))
+			}
+			encoded, err := Marshal(got)
+			require.NoError(t, err)
+			assert.Equal(t, string(data), string(encoded), "%s is not formatted as -update writes it", file)
+		})
+	}
+}
+
+func TestPlanMarksUnknownAndSensitiveValues(t *testing.T) {
+	modulesDir, err := repo.ModulesDir()
+	require.NoError(t, err)
+	module, err := tfmodule.Load(modulesDir + "/key-vault")
+	require.NoError(t, err)
+
+	vars := tfvars.NewKeyVaultVars()
+	vars.KeyVaultName = "kv"
+	vars.Location = "eastus"
+	vars.ResourceGroupName = "rg"
+	vars.StoreDbCredentials = true
+	vars.DbAdminPassword = "hunter2"
+	p, err := Plan(module, tfvars.ToMap(vars))
+	require.NoError(t, err)
+
+	var addresses []string
+	for _, rc := range p.ResourceChanges {
+		addresses = append(addresses, rc.Address)
+	}
+	assert.NotContains(t, addresses, "data.azurerm_client_config.current", "data sources are read while planning")
+
+	secret := p.ResourceChanges[2]
+	require.Equal(t, "azurerm_key_vault_secret.db_admin_password[0]", secret.Address)
+	assert.JSONEq(t, `{"id": true, "key_vault_id": true}`, string(secret.Change.AfterUnknown))
+	assert.JSONEq(t, `{"value": true}`, string(secret.Change.AfterSensitive))
+	assert.Equal(t, "0", string(secret.Index))
+
+	for _, rc := range plan.Normalize(p).ResourceChanges {
+		if rc.Address == secret.Address {
+			assert.JSONEq(t, `{"id": null, "key_vault_id": null, "name": "db-admin-password", "value": "(sensitive value)"}`,
+				string(rc.Change.After), "golden files keep no secrets")
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := &plan.Plan{ResourceChanges: []plan.ResourceChange{
+		{Address: "azurerm_key_vault.main", Change: plan.Change{
+			Actions:      []string{"create"},
+			After:        json.RawMessage(`{"name": "kv", "purge_protection_enabled": false}`),
+			AfterUnknown: json.RawMessage(`{"id": true}`),
+		}},
+		{Address: "azurerm_key_vault_secret.db_admin_password[0]", Change: plan.Change{Actions: []string{"create"}}},
+	}}
+	got := &plan.Plan{
+		ResourceChanges: []plan.ResourceChange{
+			{Address: "azurerm_key_vault.main", Change: plan.Change{
+				Actions:      []string{"create"},
+				After:        json.RawMessage(`{"name": "kv", "purge_protection_enabled": true}`),
+				AfterUnknown: json.RawMessage(`{"id": true}`),
+			}},
+		},
+		OutputChanges: map[string]*plan.Change{"key_vault_id": {Actions: []string{"create"}, AfterUnknown: json.RawMessage(`true`)}},
+	}
+	assert.Equal(t, []string{
+		"~ azurerm_key_vault.main: purge_protection_enabled false → true",
+		"- azurerm_key_vault_secret.db_admin_password[0]: no longer planned",
+		"+ output.key_vault_id: now planned (create)",
+	}, Diff(want, got))
+	assert.Empty(t, Diff(got, got))
+}
@@ -0,0 +1,373 @@
+package golden
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/sensitive"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// formatVersion is the JSON plan format version of the plans Plan returns
+const formatVersion = "1.2"
+
+// mark is the type of the cty mark of sensitive values
+type mark string
+
+const sensitiveMark mark = "sensitive"
+
+// metaArguments are the resource arguments Terraform handles itself
+var metaArguments = map[string]bool{
+	"count":      true,
+	"for_each":   true,
+	"depends_on": true,
+	"provider":   true,
+}
+
+// metaBlocks are the nested blocks Terraform handles itself
+var metaBlocks = map[string]bool{
+	"lifecycle":   true,
+	"provisioner": true,
+	"connection":  true,
+}
+
+// Plan returns the plan terraform plan would show for the module with the
+// given inputs against an empty state, using a stand-in for the azurerm
+// provider: every managed resource instance is created with the arguments
+// its configuration sets, nested blocks become lists of objects, and the
+// provider computes only id. Values read from other resources and from data
+// sources are unknown until apply, as they are in a first plan. Values of
+// sensitive variables stay sensitive wherever they flow, and string arguments
+// whose name looks like a secret are sensitive as the provider's schema
+// would mark them.
+//
+// Data sources are read during planning, so like terraform show -json the
+// plan has no resource changes for them.
+func Plan(module *tfmodule.Module, vars map[string]interface{}) (*plan.Plan, error) {
+	instances, err := module.Expand(vars)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := module.Inputs(vars)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range inputs {
+		if module.Variables[name].Sensitive {
+			inputs[name] = value.Mark(sensitiveMark)
+		}
+	}
+	ctx, err := module.EvalContext(inputs)
+	if err != nil {
+		return nil, err
+	}
+	for name, f := range functions {
+		ctx.Functions[name] = f
+	}
+
+	p := &plan.Plan{FormatVersion: formatVersion, ResourceChanges: []plan.ResourceChange{}, OutputChanges: map[string]*plan.Change{}}
+	for _, instance := range instances {
+		r := instance.Resource
+		if r.Mode != tfmodule.ManagedMode {
+			continue
+		}
+		scope, err := instanceScope(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
+		value, err := evalBody(r.Body, scope)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", instance.Address, err)
+		}
+		attributes := value.AsValueMap()
+		attributes["id"] = cty.UnknownVal(cty.String)
+		after, unknown, secret := encode(cty.ObjectVal(attributes))
+
+		rc := plan.ResourceChange{
+			Address: instance.Address,
+			Mode:    r.Mode,
+			Type:    r.Type,
+			Name:    r.Name,
+			Change:  change(after, unknown, secret),
+		}
+		if instance.Key != cty.NilVal {
+			rc.Index, _, _ = encode(instance.Key)
+		}
+		p.ResourceChanges = append(p.ResourceChanges, rc)
+	}
+
+	for _, name := range module.OutputNames() {
+		output := module.Outputs[name]
+		value, diags := output.Value.Value(ctx)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if output.Sensitive {
+			value = value.Mark(sensitiveMark)
+		}
+		after, unknown, secret := encode(value)
+		c := change(after, unknown, secret)
+		p.OutputChanges[name] = &c
+	}
+	return p, nil
+}
+
+func change(after, unknown, secret json.RawMessage) plan.Change {
+	return plan.Change{
+		Actions:         []string{string(plan.Create)},
+		Before:          json.RawMessage("null"),
+		After:           after,
+		AfterUnknown:    unknown,
+		BeforeSensitive: json.RawMessage("false"),
+		AfterSensitive:  secret,
+	}
+}
+
+// instanceScope adds count.index or each.key and each.value for instance
+func instanceScope(ctx *hcl.EvalContext, instance tfmodule.Instance) (*hcl.EvalContext, error) {
+	scope := ctx.NewChild()
+	scope.Variables = map[string]cty.Value{}
+	r := instance.Resource
+	switch {
+	case r.Count != nil:
+		scope.Variables["count"] = cty.ObjectVal(map[string]cty.Value{"index": instance.Key})
+	case r.ForEach != nil:
+		collection, diags := r.ForEach.Value(ctx)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		collection, marks := collection.Unmark()
+		value := instance.Key
+		if !collection.Type().IsSetType() {
+			value = collection.Index(instance.Key)
+		}
+		scope.Variables["each"] = cty.ObjectVal(map[string]cty.Value{
+			"key":   instance.Key,
+			"value": value.WithMarks(marks),
+		})
+	}
+	return scope, nil
+}
+
+// evalBody evaluates the arguments and nested blocks of a body into an
+// object. Blocks of a type, static and dynamic, become a list in the order
+// they are written.
+func evalBody(body *hclsyntax.Body, ctx *hcl.EvalContext) (cty.Value, error) {
+	attributes := map[string]cty.Value{}
+	for name, attr := range body.Attributes {
+		if metaArguments[name] {
+			continue
+		}
+		value, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			return cty.NilVal, diags
+		}
+		if value.Type() == cty.String && sensitive.SecretName.MatchString(name) {
+			value = value.Mark(sensitiveMark)
+		}
+		attributes[name] = value
+	}
+
+	blocks := map[string][]cty.Value{}
+	var order []string
+	add := func(typ string, values ...cty.Value) {
+		if _, ok := blocks[typ]; !ok {
+			order = append(order, typ)
+		}
+		blocks[typ] = append(blocks[typ], values...)
+	}
+	for _, block := range body.Blocks {
+		switch {
+		case metaBlocks[block.Type]:
+		case block.Type == "dynamic" && len(block.Labels) == 1:
+			values, err := evalDynamic(block, ctx)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			add(block.Labels[0], values...)
+		default:
+			value, err := evalBody(block.Body, ctx)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			add(block.Type, value)
+		}
+	}
+	for _, typ := range order {
+		if len(blocks[typ]) == 0 {
+			attributes[typ] = cty.ListValEmpty(cty.DynamicPseudoType)
+			continue
+		}
+		attributes[typ] = cty.TupleVal(blocks[typ])
+	}
+	return cty.ObjectVal(attributes), nil
+}
+
+// evalDynamic returns a value of the content block for each element of the
+// dynamic block's for_each
+func evalDynamic(block *hclsyntax.Block, ctx *hcl.EvalContext) ([]cty.Value, error) {
+	iterator := block.Labels[0]
+	if attr, ok := block.Body.Attributes["iterator"]; ok {
+		iterator = hcl.ExprAsKeyword(attr.Expr)
+	}
+	var content *hclsyntax.Body
+	for _, nested := range block.Body.Blocks {
+		if nested.Type == "content" {
+			content = nested.Body
+		}
+	}
+	attr, ok := block.Body.Attributes["for_each"]
+	if !ok || content == nil {
+		return nil, fmt.Errorf("%s: dynamic %q needs a for_each and a content block", block.DefRange(), iterator)
+	}
+	collection, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	collection, marks := collection.Unmark()
+	if !collection.IsWhollyKnown() {
+		return []cty.Value{cty.DynamicVal}, nil
+	}
+	if collection.IsNull() {
+		return nil, nil
+	}
+
+	var values []cty.Value
+	for it := collection.ElementIterator(); it.Next(); {
+		key, element := it.Element()
+		if collection.Type().IsSetType() {
+			key = element
+		}
+		scope := ctx.NewChild()
+		scope.Variables = map[string]cty.Value{iterator: cty.ObjectVal(map[string]cty.Value{
+			"key":   key.WithMarks(marks),
+			"value": element.WithMarks(marks),
+		})}
+		value, err := evalBody(content, scope)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// encode writes a value as the after, after_unknown and after_sensitive of
+// a JSON plan. Unknown values are null in after and true in after_unknown,
+// and sensitive values are true in after_sensitive.
+func encode(value cty.Value) (after, unknown, secret json.RawMessage) {
+	a, u, s := values(value)
+	after, _ = json.Marshal(a)
+	unknown, _ = json.Marshal(orFalse(u))
+	secret, _ = json.Marshal(orFalse(s))
+	return after, unknown, secret
+}
+
+func orFalse(marker any) any {
+	if marker == nil {
+		return false
+	}
+	return marker
+}
+
+// values returns the JSON form of value together with its unknown and
+// sensitive markers, nil where nothing is unknown or sensitive
+func values(value cty.Value) (after any, unknown any, secret any) {
+	value, marks := value.Unmark()
+	if _, ok := marks[sensitiveMark]; ok {
+		secret = true
+	}
+	if !value.IsKnown() {
+		return nil, true, secret
+	}
+	if value.IsNull() {
+		return nil, nil, secret
+	}
+
+	ty := value.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		object := map[string]any{}
+		unknowns, secrets := map[string]any{}, map[string]any{}
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+			a, u, s := values(element)
+			object[key.AsString()] = a
+			if u != nil {
+				unknowns[key.AsString()] = u
+			}
+			if s != nil {
+				secrets[key.AsString()] = s
+			}
+		}
+		return object, nonEmpty(unknowns), orWhole(secret, nonEmpty(secrets))
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		list := []any{}
+		var unknowns, secrets []any
+		anyUnknown, anySecret := false, false
+		for it := value.ElementIterator(); it.Next(); {
+			_, element := it.Element()
+			a, u, s := values(element)
+			list = append(list, a)
+			unknowns = append(unknowns, orFalse(u))
+			secrets = append(secrets, orFalse(s))
+			anyUnknown = anyUnknown || u != nil
+			anySecret = anySecret || s != nil
+		}
+		if !anyUnknown {
+			unknowns = nil
+		}
+		if !anySecret {
+			secrets = nil
+		}
+		return list, nilIfEmpty(unknowns), orWhole(secret, nilIfEmpty(secrets))
+	case ty == cty.String:
+		return value.AsString(), nil, secret
+	case ty == cty.Number:
+		return json.Number(value.AsBigFloat().Text('f', -1)), nil, secret
+	case ty == cty.Bool:
+		return value.True(), nil, secret
+	}
+	return nil, true, secret
+}
+
+func nonEmpty(m map[string]any) any {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func nilIfEmpty(list []any) any {
+	if list == nil {
+		return nil
+	}
+	return list
+}
+
+// orWhole is true when the whole value is sensitive, else the markers of its
+// elements
+func orWhole(whole any, elements any) any {
+	if whole != nil {
+		return whole
+	}
+	return elements
+}
+
+// functions are the Terraform functions the modules call beyond those
+// tfmodule provides
+var functions = map[string]function.Function{
+	"base64encode": function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "str", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.StringVal(base64.StdEncoding.EncodeToString([]byte(args[0].AsString()))), nil
+		},
+	}),
+}
@@ -0,0 +1,146 @@
+// Package guard stops plans from deleting or replacing resources that hold
+// data, such as the SQL database, the Key Vault and the Splunk data disk.
+//
+// The protected resource types and addresses are listed in a YAML file. A
+// blocked plan can only go ahead with the override token the guard prints
+// for exactly its set of destructive changes, together with a reason, and
+// every override is appended to an audit log.
+package guard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+	"gopkg.in/yaml.v3"
+)
+
+// Protection protects every resource of a type, or one address, optionally
+// only in one unit. Addresses match every instance, so
+// azurerm_mssql_firewall_rule.custom also matches
+// azurerm_mssql_firewall_rule.custom["office"].
+//
+//	protected:
+//	  - type: azurerm_mssql_database
+//	    reason: Holds the Gogs database
+//	  - address: azurerm_managed_disk.splunk_data
+//	    unit: splunk-vm
+//	    reason: Holds the indexed Splunk data
+type Protection struct {
+	Type    string `yaml:"type" json:"type,omitempty"`
+	Address string `yaml:"address" json:"address,omitempty"`
+	// Unit is the unit's directory name; empty matches every unit
+	Unit   string `yaml:"unit" json:"unit,omitempty"`
+	Reason string `yaml:"reason" json:"reason"`
+}
+
+// Protected is the list of protected resources
+type Protected struct {
+	Protected []Protection `yaml:"protected"`
+}
+
+// LoadProtected reads a protected list. Every entry needs either a type or an
+// address, and a reason.
+func LoadProtected(path string) (*Protected, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	protected := &Protected{}
+	if err := decoder.Decode(protected); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var errs []error
+	for i, protection := range protected.Protected {
+		if (protection.Type == "") == (protection.Address == "") || protection.Reason == "" {
+			errs = append(errs, fmt.Errorf("%s: entry %d needs either a type or an address, and a reason", path, i+1))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return protected, nil
+}
+
+// Match returns the protection covering the resource in unit, or nil
+func (p *Protected) Match(unit string, resource plan.ResourceSummary) *Protection {
+	for i, protection := range p.Protected {
+		if protection.Unit != "" && protection.Unit != path.Base(unit) {
+			continue
+		}
+		address := resource.Address
+		if resource.Change != nil && resource.Change.ModuleAddress != "" {
+			address = strings.TrimPrefix(address, resource.Change.ModuleAddress+".")
+		}
+		switch {
+		case protection.Type != "" && protection.Type == resource.Type:
+		case protection.Address != "" && (address == protection.Address || strings.HasPrefix(address, protection.Address+"[")):
+		default:
+			continue
+		}
+		return &p.Protected[i]
+	}
+	return nil
+}
+
+// Violation is a protected resource a plan deletes or replaces
+type Violation struct {
+	Unit       string      `json:"unit"`
+	Address    string      `json:"address"`
+	Action     plan.Action `json:"action"`
+	ReplacedBy []string    `json:"replaced_by,omitempty"`
+	// Reason is terraform's reason for the action, e.g.
+	// replace_because_tainted
+	Reason     string     `json:"reason,omitempty"`
+	Protection Protection `json:"protection"`
+}
+
+func (v Violation) String() string {
+	verb := "deleted"
+	if v.Action == plan.Replace {
+		verb = "replaced"
+	}
+	cause := ""
+	switch {
+	case len(v.ReplacedBy) > 0:
+		cause = ", forced by a change to " + strings.Join(v.ReplacedBy, ", ")
+	case strings.Contains(v.Reason, "_because_"):
+		// replace_because_tainted reads as because tainted
+		_, because, _ := strings.Cut(v.Reason, "_because_")
+		cause = " because " + strings.ReplaceAll(because, "_", " ")
+	}
+	return fmt.Sprintf("%s: %s would be %s%s (protected: %s)", v.Unit, v.Address, verb, cause, v.Protection.Reason)
+}
+
+// Check returns the protected resources the plans delete or replace
+func (p *Protected) Check(summary *plan.Summary) []Violation {
+	var violations []Violation
+	for _, u := range summary.Units {
+		for _, resource := range u.Resources {
+			if resource.Action != plan.Delete && resource.Action != plan.Replace {
+				continue
+			}
+			protection := p.Match(u.Unit, resource)
+			if protection == nil {
+				continue
+			}
+			violations = append(violations, Violation{
+				Unit:       u.Unit,
+				Address:    resource.Address,
+				Action:     resource.Action,
+				ReplacedBy: resource.ReplacedBy,
+				Reason:     resource.Reason,
+				Protection: *protection,
+			})
+		}
+	}
+	return violations
+}
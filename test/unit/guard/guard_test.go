@@ -0,0 +1,140 @@
+package guard
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProtectedResourcesExist fails when guard/protected.yaml names a
+// resource type or address no module declares, e.g. after a rename
+func TestProtectedResourcesExist(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	protected, err := LoadProtected(filepath.Join(root, "test", "unit", "guard", "protected.yaml"))
+	require.NoError(t, err)
+	modulesDir, err := repo.ModulesDir()
+	require.NoError(t, err)
+	modules, err := tfmodule.LoadAll(modulesDir)
+	require.NoError(t, err)
+
+	types, addresses := map[string]bool{}, map[string]bool{}
+	for _, module := range modules {
+		for _, resource := range module.Resources {
+			types[resource.Type] = true
+			addresses[resource.Address] = true
+		}
+	}
+	for _, protection := range protected.Protected {
+		if protection.Type != "" {
+			assert.True(t, types[protection.Type], "no module declares a %s", protection.Type)
+		} else {
+			assert.True(t, addresses[protection.Address], "no module declares %s", protection.Address)
+		}
+	}
+}
+
+func change(address string, typ string, reason string, actions ...string) plan.ResourceChange {
+	return plan.ResourceChange{Address: address, Mode: "managed", Type: typ, ActionReason: reason, Change: plan.Change{Actions: actions}}
+}
+
+func summary() *plan.Summary {
+	database := change("azurerm_mssql_database.main", "azurerm_mssql_database", "replace_because_cannot_update", "delete", "create")
+	database.Change.ReplacePaths = [][]any{{"collation"}, {"short_term_retention_policy", 0, "retention_days"}}
+	return plan.NewSummary(
+		plan.Summarize("environments/production/sql-database", &plan.Plan{ResourceChanges: []plan.ResourceChange{
+			database,
+			change(`azurerm_mssql_firewall_rule.custom["office"]`, "azurerm_mssql_firewall_rule", "", "delete"),
+			change("azurerm_mssql_server.main", "azurerm_mssql_server", "", "update"),
+		}}),
+		plan.Summarize("environments/production/splunk-vm", &plan.Plan{ResourceChanges: []plan.ResourceChange{
+			change("azurerm_managed_disk.splunk_data", "azurerm_managed_disk", "replace_because_tainted", "create", "delete"),
+			change("azurerm_managed_disk.scratch", "azurerm_managed_disk", "", "delete"),
+		}}),
+	)
+}
+
+func TestCheck(t *testing.T) {
+	protected := &Protected{Protected: []Protection{
+		{Type: "azurerm_mssql_database", Reason: "Holds the Gogs database"},
+		{Type: "azurerm_mssql_server", Reason: "Holds the databases"},
+		{Address: "azurerm_managed_disk.splunk_data", Unit: "splunk-vm", Reason: "Holds the indexed Splunk data"},
+	}}
+
+	var lines []string
+	for _, violation := range protected.Check(summary()) {
+		lines = append(lines, violation.String())
+	}
+	assert.Equal(t, []string{
+		"environments/production/sql-database: azurerm_mssql_database.main would be replaced, forced by a change to collation, short_term_retention_policy[0].retention_days (protected: Holds the Gogs database)",
+		"environments/production/splunk-vm: azurerm_managed_disk.splunk_data would be replaced because tainted (protected: Holds the indexed Splunk data)",
+	}, lines, "updates and unprotected deletes pass")
+
+	protected.Protected[2].Unit = "networking"
+	assert.Len(t, protected.Check(summary()), 1, "the disk is only protected in networking")
+
+	protected = &Protected{Protected: []Protection{{Address: "azurerm_mssql_firewall_rule.custom", Reason: "Office access"}}}
+	assert.Len(t, protected.Check(summary()), 1, "an address matches its instances")
+}
+
+func TestLoadProtectedRequiresTypeOrAddressAndReason(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protected.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`protected:
+  - type: azurerm_key_vault
+    address: azurerm_key_vault.main
+    reason: Both
+  - address: azurerm_key_vault.main
+`), 0o644))
+	_, err := LoadProtected(path)
+	assert.EqualError(t, err, path+": entry 1 needs either a type or an address, and a reason\n"+
+		path+": entry 2 needs either a type or an address, and a reason")
+}
+
+func TestOverride(t *testing.T) {
+	protected := &Protected{Protected: []Protection{{Type: "azurerm_managed_disk", Reason: "Holds data"}}}
+	violations := protected.Check(summary())
+	require.Len(t, violations, 2)
+
+	token := Token("production", violations)
+	assert.Regexp(t, `^destroy-production-[0-9a-f]{12}$`, token)
+	assert.Equal(t, token, Token("production", []Violation{violations[1], violations[0]}), "independent of order")
+	assert.NotEqual(t, token, Token("production", violations[:1]), "another set of changes needs another token")
+	assert.NotEqual(t, token, Token("staging", violations))
+
+	now := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	_, err := Authorize("production", violations, Token("production", violations[:1]), "Rebuilding the disk", now)
+	assert.ErrorContains(t, err, "does not match the destructive changes of this plan; review them and use "+token)
+	_, err = Authorize("production", violations, token, " ", now)
+	assert.EqualError(t, err, "an override needs a reason")
+
+	t.Setenv("BUILD_USER_ID", "jdoe")
+	t.Setenv("BUILD_STARTED_BY", "jdoe")
+	t.Setenv("BUILD_URL", "https://jenkins.example.com/job/infra/42/")
+	_, err = Authorize("production", violations, token, "Rebuilding the disk", now)
+	assert.EqualError(t, err, "jdoe started the build, so someone else must approve the override")
+
+	t.Setenv("BUILD_STARTED_BY", "asmith")
+	override, err := Authorize("production", violations, token, "Rebuilding the disk", now)
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", override.User)
+	assert.Equal(t, "asmith", override.StartedBy)
+
+	audit := filepath.Join(t.TempDir(), "overrides.jsonl")
+	require.NoError(t, override.Append(audit))
+	require.NoError(t, override.Append(audit))
+	f, err := os.Open(audit)
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	assert.Contains(t, scanner.Text(), `{"time":"2026-10-17T09:30:00Z","environment":"production","token":"`+token+`","reason":"Rebuilding the disk","user":"jdoe","started_by":"asmith","build":"https://jenkins.example.com/job/infra/42/"`)
+	require.True(t, scanner.Scan(), "overrides are appended")
+}
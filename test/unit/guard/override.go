@@ -0,0 +1,95 @@
+package guard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Token returns the override token for a set of violations in env. It is
+// derived from the units, addresses and actions only, so it stays the same
+// when the plan is run again, and changes when another protected resource
+// would be destroyed.
+func Token(env string, violations []Violation) string {
+	lines := []string{env}
+	for _, v := range violations {
+		lines = append(lines, v.Unit+" "+v.Address+" "+string(v.Action))
+	}
+	sort.Strings(lines[1:])
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return "destroy-" + env + "-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// Override is an accepted set of destructive changes, as written to the
+// audit log
+type Override struct {
+	Time        time.Time `json:"time"`
+	Environment string    `json:"environment"`
+	Token       string    `json:"token"`
+	Reason      string    `json:"reason"`
+	User        string    `json:"user"`
+	// StartedBy is who started the build, never the same as User
+	StartedBy  string      `json:"started_by,omitempty"`
+	Build      string      `json:"build,omitempty"`
+	Violations []Violation `json:"violations"`
+}
+
+// Authorize returns the override accepting violations in env, or an error
+// when token is not the one Token returns for them, reason is empty or the
+// override is approved by the user who started the build. The approver, the
+// user who started the build and the build are read from the Jenkins
+// environment: BUILD_USER_ID, BUILD_STARTED_BY and BUILD_URL.
+func Authorize(env string, violations []Violation, token string, reason string, now time.Time) (*Override, error) {
+	if expected := Token(env, violations); token != expected {
+		return nil, fmt.Errorf("override token %q does not match the destructive changes of this plan; review them and use %s", token, expected)
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("an override needs a reason")
+	}
+	user := firstEnv("BUILD_USER_ID", "USER")
+	startedBy := os.Getenv("BUILD_STARTED_BY")
+	if startedBy != "" && startedBy == user {
+		return nil, fmt.Errorf("%s started the build, so someone else must approve the override", user)
+	}
+	return &Override{
+		Time:        now.UTC(),
+		Environment: env,
+		Token:       token,
+		Reason:      reason,
+		User:        user,
+		StartedBy:   startedBy,
+		Build:       os.Getenv("BUILD_URL"),
+		Violations:  violations,
+	}, nil
+}
+
+// Append adds the override to the JSON lines audit log at path
+func (o *Override) Append(path string) error {
+	line, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return "unknown"
+}
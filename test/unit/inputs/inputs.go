@@ -0,0 +1,345 @@
+// Package inputs evaluates the inputs of every Terragrunt unit and checks
+// them against the module they are passed to, before anything is planned.
+//
+// Every input is first converted to its variable's type and run through the
+// variable's validation blocks, as Terraform would. Per-module rules then
+// catch values Azure only rejects at apply time, such as a subnet prefix
+// outside the virtual network's address space or a SQL sku_name typo.
+//
+// Inputs are evaluated with the Terragrunt path functions, get_env and the
+// Terraform functions that need no provider. Dependency outputs and locals
+// are unknown offline, so inputs built from them are skipped rather than
+// checked against mock values.
+package inputs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/cidr"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Unit is a Terragrunt unit together with the values of its inputs
+type Unit struct {
+	*contract.Unit
+
+	// Module is the module terraform.source resolves to, nil when it does not
+	// resolve; tgcontract reports that case
+	Module *tfmodule.Module
+	// Values holds the unit's own inputs block. Values that cannot be
+	// evaluated offline are unknown.
+	Values map[string]Input
+	// Inherited holds the inputs merged in from included files such as
+	// env.hcl, in include order. Terragrunt lets Values override them.
+	Inherited map[string]Input
+}
+
+// Input is one key of a unit's inputs block
+type Input struct {
+	Name  string
+	Value cty.Value
+	// Range is the range of the value expression
+	Range hcl.Range
+}
+
+// LoadUnit parses the unit whose terragrunt.hcl is at path and evaluates its
+// inputs
+func LoadUnit(root string, path string) (*Unit, error) {
+	parsed, err := contract.ParseUnit(root, path)
+	if err != nil {
+		return nil, err
+	}
+	unit := &Unit{Unit: parsed, Values: map[string]Input{}, Inherited: map[string]Input{}}
+	if parsed.ModuleDir != "" {
+		if module, err := tfmodule.Load(parsed.ModuleDir); err == nil {
+			unit.Module = module
+		}
+	}
+
+	body, err := contract.ParseConfig(path, parsed.Path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := evalContext(root, parsed.Dir)
+	ctx.Variables = map[string]cty.Value{
+		"include":    unit.includes(root, body, ctx),
+		"dependency": dependencies(body),
+		"local":      cty.DynamicVal,
+	}
+
+	attr, ok := body.Attributes["inputs"]
+	if !ok {
+		return unit, nil
+	}
+	object, ok := attr.Expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return unit, nil
+	}
+	for _, item := range object.Items {
+		name := hcl.ExprAsKeyword(item.KeyExpr)
+		if name == "" {
+			key, diags := item.KeyExpr.Value(ctx)
+			if diags.HasErrors() || key.IsNull() || !key.IsKnown() || !key.Type().Equals(cty.String) {
+				continue
+			}
+			name = key.AsString()
+		}
+
+		value, diags := item.ValueExpr.Value(ctx)
+		if diags.HasErrors() {
+			value = cty.DynamicVal
+		}
+		unit.Values[name] = Input{Name: name, Value: value, Range: item.ValueExpr.Range()}
+	}
+	return unit, nil
+}
+
+// evalContext adds the Terraform functions to the Terragrunt ones
+func evalContext(root string, dir string) *hcl.EvalContext {
+	ctx := contract.EvalContext(root, dir)
+	for name, fn := range tfmodule.Functions() {
+		if _, ok := ctx.Functions[name]; !ok {
+			ctx.Functions[name] = fn
+		}
+	}
+	return ctx
+}
+
+// includes returns include.<name>.inputs for every include block and records
+// the included inputs in u.Inherited. Included files are evaluated in their
+// own directory with nothing but functions, which is enough for env.hcl;
+// anything more is unknown.
+func (u *Unit) includes(root string, body *hclsyntax.Body, ctx *hcl.EvalContext) cty.Value {
+	found := map[string]cty.Value{}
+	for _, block := range body.Blocks {
+		if block.Type != "include" || len(block.Labels) == 0 {
+			continue
+		}
+		value, inherited := includedInputs(root, u.Dir, block, ctx)
+		found[block.Labels[0]] = cty.ObjectVal(map[string]cty.Value{"inputs": value})
+		for name, input := range inherited {
+			u.Inherited[name] = input
+		}
+	}
+	return cty.ObjectVal(found)
+}
+
+func includedInputs(root string, dir string, block *hclsyntax.Block, ctx *hcl.EvalContext) (cty.Value, map[string]Input) {
+	attr, ok := block.Body.Attributes["path"]
+	if !ok {
+		return cty.DynamicVal, nil
+	}
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || !value.Type().Equals(cty.String) {
+		return cty.DynamicVal, nil
+	}
+	path := value.AsString()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	included, err := contract.ParseConfig(path, rel)
+	if err != nil {
+		return cty.DynamicVal, nil
+	}
+	inputs, ok := included.Attributes["inputs"]
+	if !ok {
+		return cty.EmptyObjectVal, nil
+	}
+	includedCtx := evalContext(root, filepath.Dir(path))
+	value, diags = inputs.Expr.Value(includedCtx)
+	if diags.HasErrors() {
+		return cty.DynamicVal, nil
+	}
+
+	inherited := map[string]Input{}
+	if object, ok := inputs.Expr.(*hclsyntax.ObjectConsExpr); ok {
+		for _, item := range object.Items {
+			name := hcl.ExprAsKeyword(item.KeyExpr)
+			if name == "" {
+				continue
+			}
+			itemValue, diags := item.ValueExpr.Value(includedCtx)
+			if diags.HasErrors() {
+				itemValue = cty.DynamicVal
+			}
+			inherited[name] = Input{Name: name, Value: itemValue, Range: item.ValueExpr.Range()}
+		}
+	}
+	return value, inherited
+}
+
+// dependencies returns dependency.<name>.outputs as unknown values, since the
+// real outputs only exist once the dependency is applied
+func dependencies(body *hclsyntax.Body) cty.Value {
+	found := map[string]cty.Value{}
+	for _, block := range body.Blocks {
+		if block.Type == "dependency" && len(block.Labels) > 0 {
+			found[block.Labels[0]] = cty.ObjectVal(map[string]cty.Value{"outputs": cty.DynamicVal})
+		}
+	}
+	return cty.ObjectVal(found)
+}
+
+// Validate checks the inputs of every unit under root/environments, or only
+// those of env when it is not empty
+func Validate(root string, env string) ([]contract.Diagnostic, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []contract.Diagnostic
+	checked := 0
+	for _, path := range paths {
+		if env != "" && filepath.Base(filepath.Dir(filepath.Dir(path))) != env {
+			continue
+		}
+		unit, err := LoadUnit(root, path)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, Check(unit)...)
+		checked++
+	}
+	if checked == 0 {
+		where := filepath.Join(root, "environments")
+		if env != "" {
+			where = filepath.Join(where, env)
+		}
+		if _, err := os.Stat(where); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no %s found under %s", contract.UnitFileName, where)
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Range, diagnostics[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return diagnostics, nil
+}
+
+// Check converts the unit's known inputs to their variable types, runs the
+// variables' validation blocks and then the rules of the unit's module
+func Check(unit *Unit) []contract.Diagnostic {
+	if unit.Module == nil {
+		return nil
+	}
+	c := newChecker(unit)
+	for _, rule := range moduleRules[unit.Module.Name] {
+		rule(c)
+	}
+	return c.diagnostics
+}
+
+// Networks returns the virtual network of every environment, built from the
+// inputs of its networking unit and the networking module defaults. Address
+// ranges that cannot be evaluated offline or are invalid are left out;
+// validate-inputs reports the invalid ones.
+func Networks(root string) ([]cidr.Network, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []cidr.Network
+	for _, path := range paths {
+		unit, err := LoadUnit(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if unit.Module == nil || unit.Module.Name != "networking" {
+			continue
+		}
+		network, _ := newChecker(unit).network()
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Variables returns the value of every variable of the unit's module as the
+// plan would see it: the converted input when it is known and valid, the
+// default when the variable is not set, and an unknown value otherwise. The
+// unit's module must resolve.
+func (u *Unit) Variables() map[string]cty.Value {
+	values := newChecker(u).values
+	variables := make(map[string]cty.Value, len(u.Module.Variables))
+	for name, variable := range u.Module.Variables {
+		_, set := u.Values[name]
+		switch value, ok := values[name]; {
+		case ok:
+			variables[name] = value
+		case !set && variable.HasDefault:
+			variables[name] = variable.Default
+		default:
+			variables[name] = cty.UnknownVal(variable.Type)
+		}
+	}
+	return variables
+}
+
+// newChecker converts the unit's known inputs and runs their validation
+// blocks, reporting the inputs that fail
+func newChecker(unit *Unit) *checker {
+	c := &checker{unit: unit, values: map[string]cty.Value{}}
+
+	for _, name := range sortedInputNames(unit.Values) {
+		input := unit.Values[name]
+		variable, ok := unit.Module.Variables[name]
+		if !ok || !input.Value.IsWhollyKnown() {
+			continue
+		}
+		value, err := variable.Convert(input.Value)
+		if err != nil {
+			c.report(input.Range, "input %q: %v", name, err)
+			continue
+		}
+		validation := &hcl.EvalContext{
+			Variables: map[string]cty.Value{"var": cty.ObjectVal(map[string]cty.Value{name: value})},
+			Functions: tfmodule.Functions(),
+		}
+		failed := false
+		for _, rule := range variable.Validations {
+			if err := rule.Check(validation); err != nil {
+				c.report(input.Range, "input %q: %v", name, err)
+				failed = true
+			}
+		}
+		if !failed {
+			c.values[name] = value
+		}
+	}
+	return c
+}
+
+func sortedInputNames(values map[string]Input) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
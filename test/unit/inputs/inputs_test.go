@@ -0,0 +1,159 @@
+package inputs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/cidr"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEnvironmentInputsAreValid(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	diagnostics, err := Validate(root, "")
+	require.NoError(t, err)
+	for _, diagnostic := range diagnostics {
+		t.Error(diagnostic)
+	}
+}
+
+func TestLoadUnitEvaluatesInputs(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	unit, err := LoadUnit(root, filepath.Join(root, "environments", "production", "networking", "terragrunt.hcl"))
+	require.NoError(t, err)
+	require.NotNil(t, unit.Module)
+	assert.Equal(t, "networking", unit.Module.Name)
+
+	assert.Equal(t, cty.StringVal("vnet-production-gogs-infra"), unit.Values["vnet_name"].Value, "include.env.inputs is evaluated")
+	assert.True(t, unit.Values["address_space"].Value.IsWhollyKnown())
+	assert.False(t, unit.Values["location"].Value.IsKnown(), "dependency outputs are unknown")
+	assert.Equal(t, filepath.Join("environments", "production", "networking", "terragrunt.hcl"), unit.Values["vm_subnet_prefix"].Range.Filename)
+
+	assert.Equal(t, cty.StringVal("production"), unit.Inherited["environment"].Value, "env.hcl inputs are inherited")
+	assert.Equal(t, filepath.Join("environments", "production", "env.hcl"), unit.Inherited["environment"].Range.Filename)
+}
+
+func TestValidateReportsInvalidInputs(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "test", "networking", "networking", repotest.Inputs(`  address_space           = ["10.1.0.0/16"]
+  container_subnet_prefix = "10.2.1.0/24"
+  database_subnet_prefix  = "10.1.2.0/24"
+  vm_subnet_prefix        = "10.1.2.128/25"
+  admin_ip_range          = "10.0.0.300"
+`))
+	repotest.WriteUnit(t, root, "test", "sql-database", "sql-database", repotest.Inputs(`  sku_name              = "GP_Gen5_3"
+  backup_retention_days = 90
+  backup_interval_hours = 6
+  ltr_weekly_retention  = "1 week"
+  max_size_gb           = "lots"
+`))
+	repotest.WriteUnit(t, root, "test", "log-analytics", "log-analytics", repotest.Inputs(`  sku               = "pergb2018"
+  retention_in_days = 14
+`))
+	repotest.WriteUnit(t, root, "test", "virtual-machine", "virtual-machine", repotest.Inputs(`  vm_name         = "splunk"
+  os_disk_size_gb = 16
+  admin_username  = "admin"
+`))
+	repotest.WriteUnit(t, root, "test", "key-vault", "key-vault", repotest.Inputs(`  key_vault_name             = "kv-${get_env("TF_VAR_unique_suffix", "a-very-long-suffix-for-kv")}"
+  soft_delete_retention_days = 120
+  allowed_ip_ranges          = ["203.0.113.0/24", "office"]
+`))
+	repotest.WriteUnit(t, root, "test", "container-instance", "container-instance", repotest.Inputs(`  ip_address_type = "Private"
+  dns_name_label  = "gogs-test"
+  cpu             = 8
+`))
+
+	diagnostics, err := Validate(root, "test")
+	require.NoError(t, err)
+
+	var lines []string
+	for _, diagnostic := range diagnostics {
+		lines = append(lines, diagnostic.String())
+	}
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"container-instance/terragrunt.hcl:8:21: cpu is 8, must be between 0.1 and 4",
+		"container-instance/terragrunt.hcl:7:21: dns_name_label is only supported with ip_address_type Public, not Private",
+		"key-vault/terragrunt.hcl:6:32: key_vault_name: key vault name",
+		"key-vault/terragrunt.hcl:7:32: soft_delete_retention_days is 120, must be between 7 and 90",
+		`key-vault/terragrunt.hcl:8:32: allowed_ip_ranges: "office" is not an IP address or CIDR prefix`,
+		`log-analytics/terragrunt.hcl:6:23: sku "pergb2018" is not one of Free, PerNode, Premium, Standard, Standalone, Unlimited, CapacityReservation, PerGB2018 (did you mean "PerGB2018"?)`,
+		"log-analytics/terragrunt.hcl:7:23: retention_in_days is 14, must be between 30 and 730",
+		"networking/terragrunt.hcl:7:29: container_subnet_prefix 10.2.1.0/24 is outside address_space 10.1.0.0/16",
+		"networking/terragrunt.hcl:9:29: vm_subnet_prefix 10.1.2.128/25 overlaps database_subnet_prefix 10.1.2.0/24",
+		`networking/terragrunt.hcl:10:29: admin_ip_range "10.0.0.300" is not *, a service tag, an IP address or a CIDR prefix`,
+		`sql-database/terragrunt.hcl:6:27: sku_name "GP_Gen5_3" is not an Azure SQL Database SKU`,
+		"sql-database/terragrunt.hcl:7:27: backup_retention_days is 90, must be between 1 and 35",
+		"sql-database/terragrunt.hcl:8:27: backup_interval_hours is 6, must be 12 or 24",
+		`sql-database/terragrunt.hcl:9:27: ltr_weekly_retention "1 week" is not an ISO 8601 duration`,
+		`sql-database/terragrunt.hcl:10:27: input "max_size_gb": a number is required`,
+		"virtual-machine/terragrunt.hcl:7:21: os_disk_size_gb is 16, must be between 30 and 4095",
+		`virtual-machine/terragrunt.hcl:8:21: admin_username "admin" is reserved by Azure`,
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.Len(t, diagnostics, 17, got)
+
+	t.Setenv("TF_VAR_unique_suffix", "001")
+	diagnostics, err = Validate(root, "test")
+	require.NoError(t, err)
+	assert.Len(t, diagnostics, 16, "get_env reads the environment")
+}
+
+func TestValidateRunsModuleValidationBlocks(t *testing.T) {
+	root := t.TempDir()
+	module := filepath.Join(root, "modules", "sized")
+	require.NoError(t, os.MkdirAll(module, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(module, "variables.tf"), []byte(`
+variable "size" {
+  type = number
+  validation {
+    condition     = var.size % 2 == 0
+    error_message = "The size must be even."
+  }
+}
+`), 0o644))
+	dir := filepath.Join(root, "environments", "test", "sized")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "terragrunt.hcl"), []byte(`terraform {
+  source = "../../../modules/sized"
+}
+
+inputs = {
+  size = 3
+}
+`), 0o644))
+
+	diagnostics, err := Validate(root, "")
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, `environments/test/sized/terragrunt.hcl:6:10: input "size": The size must be even.`, diagnostics[0].String())
+
+	_, err = Validate(root, "production")
+	assert.Error(t, err)
+}
+
+func TestEnvironmentNetworksDoNotOverlap(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	networks, err := Networks(root)
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	for _, network := range networks {
+		assert.Len(t, network.Subnets, len(cidr.SubnetInputs), network.Name)
+	}
+	for _, problem := range cidr.Check(networks...) {
+		t.Error(problem)
+	}
+}
@@ -0,0 +1,375 @@
+package inputs
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/cidr"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// rule checks the inputs of one module. Rules only see inputs that are set in
+// the unit, known offline and already converted to their variable's type;
+// inputs left to the module default have been checked by the module itself.
+type rule func(c *checker)
+
+// moduleRules are keyed by module directory name
+var moduleRules = map[string][]rule{
+	"container-instance": {containerInstanceRules},
+	"key-vault":          {keyVaultRules},
+	"log-analytics":      {logAnalyticsRules},
+	"networking":         {networkingRules},
+	"resource-group":     {resourceGroupRules},
+	"sql-database":       {sqlDatabaseRules},
+	"virtual-machine":    {virtualMachineRules},
+}
+
+type checker struct {
+	unit        *Unit
+	values      map[string]cty.Value
+	diagnostics []contract.Diagnostic
+}
+
+func (c *checker) report(rng hcl.Range, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, contract.Diagnostic{Range: rng, Message: fmt.Sprintf(format, args...)})
+}
+
+// input returns a set, known and non-null input
+func (c *checker) input(name string) (cty.Value, hcl.Range, bool) {
+	value, ok := c.values[name]
+	if !ok || value.IsNull() {
+		return cty.NilVal, hcl.Range{}, false
+	}
+	return value, c.unit.Values[name].Range, true
+}
+
+// value returns the input if it is set and the module default otherwise, for
+// rules that compare one input with another
+func (c *checker) value(name string) (cty.Value, bool) {
+	if value, _, ok := c.input(name); ok {
+		return value, true
+	}
+	if _, set := c.unit.Values[name]; set {
+		return cty.NilVal, false // unknown or invalid
+	}
+	variable, ok := c.unit.Module.Variables[name]
+	if !ok || !variable.HasDefault || variable.Default.IsNull() {
+		return cty.NilVal, false
+	}
+	return variable.Default, true
+}
+
+func (c *checker) str(name string) (string, hcl.Range, bool) {
+	value, rng, ok := c.input(name)
+	if !ok || !value.Type().Equals(cty.String) {
+		return "", rng, false
+	}
+	return value.AsString(), rng, true
+}
+
+func (c *checker) number(name string) (*big.Float, hcl.Range, bool) {
+	value, rng, ok := c.input(name)
+	if !ok || !value.Type().Equals(cty.Number) {
+		return nil, rng, false
+	}
+	return value.AsBigFloat(), rng, true
+}
+
+func (c *checker) strings(name string) ([]string, hcl.Range, bool) {
+	value, rng, ok := c.input(name)
+	if !ok {
+		return nil, rng, false
+	}
+	return stringList(value), rng, true
+}
+
+func stringList(value cty.Value) []string {
+	if !value.CanIterateElements() {
+		return nil
+	}
+	var list []string
+	for it := value.ElementIterator(); it.Next(); {
+		_, element := it.Element()
+		if !element.IsNull() && element.Type().Equals(cty.String) {
+			list = append(list, element.AsString())
+		}
+	}
+	return list
+}
+
+func (c *checker) oneOf(name string, allowed ...string) {
+	value, rng, ok := c.str(name)
+	if !ok {
+		return
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	hint := ""
+	for _, candidate := range allowed {
+		if strings.EqualFold(value, candidate) {
+			hint = fmt.Sprintf(" (did you mean %q?)", candidate)
+		}
+	}
+	c.report(rng, "%s %q is not one of %s%s", name, value, strings.Join(allowed, ", "), hint)
+}
+
+func (c *checker) between(name string, min float64, max float64) {
+	value, rng, ok := c.number(name)
+	if !ok {
+		return
+	}
+	if value.Cmp(big.NewFloat(min)) < 0 || value.Cmp(big.NewFloat(max)) > 0 {
+		c.report(rng, "%s is %s, must be between %s and %s", name, value.Text('g', -1), format(min), format(max))
+	}
+}
+
+func (c *checker) name(input string, rule naming.Rule) {
+	value, rng, ok := c.str(input)
+	if !ok {
+		return
+	}
+	if err := rule.Validate(value); err != nil {
+		c.report(rng, "%s: %v", input, err)
+	}
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// isAddressOrPrefix accepts a single IP address or a CIDR prefix
+func isAddressOrPrefix(value string) bool {
+	if _, err := netip.ParseAddr(value); err == nil {
+		return true
+	}
+	_, err := cidr.ParsePrefix(value)
+	return err == nil
+}
+
+var serviceTag = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9.]*$`)
+
+func networkingRules(c *checker) {
+	c.name("vnet_name", naming.VirtualNetwork)
+
+	network, ranges := c.network()
+	for _, subnet := range network.Subnets {
+		rng, set := ranges[subnet.Name]
+		if set && subnet.Prefix.Addr().Is4() && subnet.Prefix.Bits() > 29 {
+			c.report(rng, "%s %s is smaller than /29, the smallest subnet Azure allows", subnet.Name, subnet.Prefix)
+		}
+	}
+
+	// problems are reported on the subnet input set in the unit; one between
+	// two module defaults is the module's own
+	for _, problem := range cidr.Check(network) {
+		rng, set := ranges[problem.Subnet]
+		if !set {
+			rng, set = ranges[problem.Overlaps]
+		}
+		if set {
+			c.report(rng, "%s %s", problem.Subnet, problem.Message)
+		}
+	}
+
+	if value, rng, ok := c.str("admin_ip_range"); ok {
+		if value != "*" && !serviceTag.MatchString(value) && !isAddressOrPrefix(value) {
+			c.report(rng, "admin_ip_range %q is not *, a service tag, an IP address or a CIDR prefix", value)
+		}
+	}
+}
+
+// network returns the virtual network a networking unit creates, from its
+// inputs and the module defaults, together with the ranges of the subnet
+// inputs set in the unit. Invalid prefixes are reported and left out.
+func (c *checker) network() (cidr.Network, map[string]hcl.Range) {
+	network := cidr.Network{Name: c.unit.Env}
+	if value, ok := c.value("address_space"); ok {
+		_, rng, set := c.input("address_space")
+		for _, space := range stringList(value) {
+			prefix, err := cidr.ParsePrefix(space)
+			if err != nil {
+				if set {
+					c.report(rng, "address_space: %v", err)
+				}
+				continue
+			}
+			network.AddressSpace = append(network.AddressSpace, prefix)
+		}
+	}
+
+	ranges := map[string]hcl.Range{}
+	for _, input := range cidr.SubnetInputs {
+		value, ok := c.value(input)
+		if !ok || !value.Type().Equals(cty.String) {
+			continue
+		}
+		_, rng, set := c.input(input)
+		prefix, err := cidr.ParsePrefix(value.AsString())
+		if err != nil {
+			if set {
+				c.report(rng, "%s: %v", input, err)
+			}
+			continue
+		}
+		network.Subnets = append(network.Subnets, cidr.Subnet{Name: input, Prefix: prefix})
+		if set {
+			ranges[input] = rng
+		}
+	}
+	return network, ranges
+}
+
+func resourceGroupRules(c *checker) {
+	c.name("resource_group_name", naming.ResourceGroup)
+}
+
+func logAnalyticsRules(c *checker) {
+	c.name("workspace_name", naming.LogAnalyticsWorkspace)
+	c.oneOf("sku", "Free", "PerNode", "Premium", "Standard", "Standalone", "Unlimited", "CapacityReservation", "PerGB2018")
+
+	days, rng, ok := c.number("retention_in_days")
+	if !ok {
+		return
+	}
+	if sku, ok := c.value("sku"); ok && sku.Type().Equals(cty.String) && sku.AsString() == "Free" {
+		if days.Cmp(big.NewFloat(7)) != 0 {
+			c.report(rng, "retention_in_days is %s, the Free sku only allows 7", days.Text('g', -1))
+		}
+		return
+	}
+	c.between("retention_in_days", 30, 730)
+}
+
+// sqlSku matches the DTU and vCore SKUs of Azure SQL Database
+var sqlSku = regexp.MustCompile(`^(Basic|S(0|1|2|3|4|6|7|9|12)|P(1|2|4|6|11|15)|(GP|GP_S|BC|HS)_Gen5_(2|4|6|8|10|12|14|16|18|20|24|32|40|80|128))$`)
+
+var isoDuration = regexp.MustCompile(`^P[0-9]+[DWMY]$`)
+
+func sqlDatabaseRules(c *checker) {
+	c.name("sql_server_name", naming.SQLServer)
+	c.name("database_name", naming.SQLDatabase)
+	c.oneOf("sql_version", "2.0", "12.0")
+	c.oneOf("minimum_tls_version", "1.0", "1.1", "1.2", "Disabled")
+	c.between("ltr_week_of_year", 1, 52)
+
+	sku := ""
+	if value, rng, ok := c.str("sku_name"); ok {
+		if sqlSku.MatchString(value) {
+			sku = value
+		} else {
+			c.report(rng, "sku_name %q is not an Azure SQL Database SKU such as Basic, S0, P1, GP_Gen5_2 or GP_S_Gen5_2", value)
+		}
+	} else if value, ok := c.value("sku_name"); ok && value.Type().Equals(cty.String) {
+		sku = value.AsString()
+	}
+	basic := sku == "Basic"
+	standard := strings.HasPrefix(sku, "S") && !strings.HasPrefix(sku, "S_")
+
+	if basic {
+		c.between("backup_retention_days", 1, 7)
+		c.between("max_size_gb", 1, 2)
+	} else {
+		c.between("backup_retention_days", 1, 35)
+		c.between("max_size_gb", 1, 4096)
+	}
+	if hours, rng, ok := c.number("backup_interval_hours"); ok {
+		if hours.Cmp(big.NewFloat(12)) != 0 && hours.Cmp(big.NewFloat(24)) != 0 {
+			c.report(rng, "backup_interval_hours is %s, must be 12 or 24", hours.Text('g', -1))
+		}
+	}
+	for _, input := range []string{"ltr_weekly_retention", "ltr_monthly_retention", "ltr_yearly_retention"} {
+		if value, rng, ok := c.str(input); ok && !isoDuration.MatchString(value) {
+			c.report(rng, "%s %q is not an ISO 8601 duration such as P1W, P12M or P5Y", input, value)
+		}
+	}
+
+	if zoned, rng, ok := c.input("zone_redundant"); ok && zoned.Type().Equals(cty.Bool) && zoned.True() && (basic || standard) {
+		c.report(rng, "zone_redundant is not supported by the %s sku", sku)
+	}
+
+	if strings.HasPrefix(sku, "GP_S_") {
+		if delay, rng, ok := c.number("auto_pause_delay_in_minutes"); ok {
+			disabled := delay.Cmp(big.NewFloat(-1)) == 0
+			if !disabled && (delay.Cmp(big.NewFloat(60)) < 0 || delay.Cmp(big.NewFloat(10080)) > 0) {
+				c.report(rng, "auto_pause_delay_in_minutes is %s, must be -1 or between 60 and 10080", delay.Text('g', -1))
+			}
+		}
+		vcores, _ := strconv.ParseFloat(sku[strings.LastIndex(sku, "_")+1:], 64)
+		c.between("min_capacity", 0.5, vcores)
+	}
+}
+
+// reservedUsernames are rejected by Azure as Linux VM admin usernames
+var reservedUsernames = []string{
+	"1", "123", "a", "actuser", "adm", "admin", "admin1", "admin2", "administrator", "aspnet", "backup",
+	"console", "david", "guest", "john", "owner", "root", "server", "sql", "support", "support_388945a0",
+	"sys", "test", "test1", "test2", "test3", "user", "user1", "user2", "user3", "user4", "user5",
+}
+
+var diskTypes = []string{"Standard_LRS", "StandardSSD_LRS", "Premium_LRS", "StandardSSD_ZRS", "Premium_ZRS"}
+
+func virtualMachineRules(c *checker) {
+	c.name("vm_name", naming.VirtualMachine)
+	c.oneOf("os_disk_type", diskTypes...)
+	c.oneOf("data_disk_type", diskTypes...)
+	// Marketplace Linux images, including the default Ubuntu image, ship a
+	// 30 GB OS disk that cannot be shrunk
+	c.between("os_disk_size_gb", 30, 4095)
+	c.between("data_disk_size_gb", 1, 32767)
+
+	if size, rng, ok := c.str("vm_size"); ok && !strings.HasPrefix(size, "Standard_") && !strings.HasPrefix(size, "Basic_") {
+		c.report(rng, "vm_size %q is not a VM size such as Standard_D4s_v3", size)
+	}
+	if user, rng, ok := c.str("admin_username"); ok {
+		for _, reserved := range reservedUsernames {
+			if strings.EqualFold(user, reserved) {
+				c.report(rng, "admin_username %q is reserved by Azure", user)
+			}
+		}
+		if len(user) > 64 || strings.HasSuffix(user, ".") {
+			c.report(rng, "admin_username %q must be at most 64 characters and not end with '.'", user)
+		}
+	}
+}
+
+func keyVaultRules(c *checker) {
+	c.name("key_vault_name", naming.KeyVault)
+	c.oneOf("sku_name", "standard", "premium")
+	c.oneOf("network_acls_default_action", "Allow", "Deny")
+	c.between("soft_delete_retention_days", 7, 90)
+
+	if ranges, rng, ok := c.strings("allowed_ip_ranges"); ok {
+		for _, value := range ranges {
+			if !isAddressOrPrefix(value) {
+				c.report(rng, "allowed_ip_ranges: %q is not an IP address or CIDR prefix", value)
+			}
+		}
+	}
+}
+
+func containerInstanceRules(c *checker) {
+	c.name("container_group_name", naming.ContainerGroup)
+	c.oneOf("ip_address_type", "Public", "Private", "None")
+	c.oneOf("os_type", "Linux", "Windows")
+	c.oneOf("restart_policy", "Always", "Never", "OnFailure")
+	c.between("cpu", 0.1, 4)
+	c.between("memory", 0.1, 16)
+	c.between("container_port", 1, 65535)
+
+	if label, rng, ok := c.str("dns_name_label"); ok && label != "" {
+		c.name("dns_name_label", naming.DNSLabel)
+		if ipType, ok := c.value("ip_address_type"); ok && ipType.Type().Equals(cty.String) && ipType.AsString() != "Public" {
+			c.report(rng, "dns_name_label is only supported with ip_address_type Public, not %s", ipType.AsString())
+		}
+	}
+}
@@ -0,0 +1,692 @@
+// Package fakearm is an in-memory stand-in for the Azure Resource Manager
+// API, served with net/http/httptest, for testing Azure helpers offline.
+//
+// It covers the subset of ARM the tests and tools use: resource groups,
+// virtual networks and their subnets, network security groups, Key Vault
+// metadata (including soft delete and purge) and container groups. Requests
+// follow the ARM URL layout, so the Azure SDK clients used by terratest work
+// against it once they are created with the server's URL as base URI:
+//
+//	arm := fakearm.New(t)
+//	groups := resources.NewGroupsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+//	groups.Authorizer = autorest.NullAuthorizer{}
+//
+// Every write completes synchronously with provisioningState Succeeded, so
+// long-running operations finish on their first response. Paths and names
+// are matched case-insensitively, like ARM, and api-version is ignored.
+package fakearm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SubscriptionID is the only subscription the server knows about
+const SubscriptionID = "00000000-0000-0000-0000-000000000000"
+
+// Resource types served by the server
+const (
+	TypeResourceGroup        = "Microsoft.Resources/resourceGroups"
+	TypeVirtualNetwork       = "Microsoft.Network/virtualNetworks"
+	TypeSubnet               = "Microsoft.Network/virtualNetworks/subnets"
+	TypeNetworkSecurityGroup = "Microsoft.Network/networkSecurityGroups"
+	TypeKeyVault             = "Microsoft.KeyVault/vaults"
+	TypeDeletedKeyVault      = "Microsoft.KeyVault/deletedVaults"
+	TypeContainerGroup       = "Microsoft.ContainerInstance/containerGroups"
+)
+
+// softDeleteRetention is how long Azure keeps a deleted Key Vault by default
+const softDeleteRetention = 90 * 24 * time.Hour
+
+// resourceTypes maps "<namespace>/<type>" as written in a URL, lowercased,
+// to the canonical type of the resources in that collection
+var resourceTypes = map[string]string{
+	"microsoft.network/virtualnetworks":           TypeVirtualNetwork,
+	"microsoft.network/networksecuritygroups":     TypeNetworkSecurityGroup,
+	"microsoft.keyvault/vaults":                   TypeKeyVault,
+	"microsoft.containerinstance/containergroups": TypeContainerGroup,
+}
+
+// childTypes maps a parent type and child collection name to the child type
+var childTypes = map[string]string{
+	TypeVirtualNetwork + "/subnets": TypeSubnet,
+}
+
+// Server is a fake Resource Manager endpoint
+type Server struct {
+	*httptest.Server
+	SubscriptionID string
+
+	// Now returns the time recorded on soft-deleted vaults; it defaults to
+	// time.Now
+	Now func() time.Time
+
+	mu            sync.Mutex
+	resources     map[string]Object
+	deletedVaults map[string]Object
+	failures      map[string]failure
+}
+
+// Object is the JSON body of a resource as returned by the server
+type Object = map[string]interface{}
+
+type failure struct {
+	status int
+	code   string
+}
+
+// New starts a server that is closed when the test finishes
+func New(t testing.TB) *Server {
+	s := &Server{
+		SubscriptionID: SubscriptionID,
+		resources:      map[string]Object{},
+		deletedVaults:  map[string]Object{},
+		failures:       map[string]failure{},
+	}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// ResourceGroupID returns the ID of a resource group
+func (s *Server) ResourceGroupID(name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", s.SubscriptionID, name)
+}
+
+// ResourceID returns the ID of a resource of the given type, such as
+// TypeVirtualNetwork, in a resource group. Child types take the parent's
+// name followed by the child's.
+func (s *Server) ResourceID(resourceGroup string, resourceType string, names ...string) string {
+	parts := strings.Split(resourceType, "/")
+	id := s.ResourceGroupID(resourceGroup) + "/providers/" + parts[0]
+	for i, name := range names {
+		id += "/" + parts[i+1] + "/" + name
+	}
+	return id
+}
+
+// DeletedVaultID returns the ID of a soft-deleted Key Vault
+func (s *Server) DeletedVaultID(location string, name string) string {
+	return fmt.Sprintf("/subscriptions/%s/providers/Microsoft.KeyVault/locations/%s/deletedVaults/%s", s.SubscriptionID, location, name)
+}
+
+// Put stores a resource as if it had been created through the API. body may
+// be nil; id, name and type are filled in from the ID. It panics on an ID the
+// server does not support.
+func (s *Server) Put(id string, body Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	route, err := s.parse(id)
+	if err != nil {
+		panic(err)
+	}
+	s.store(route, body)
+}
+
+// AddResourceGroup stores a resource group
+func (s *Server) AddResourceGroup(name string, location string, tags map[string]string) {
+	s.Put(s.ResourceGroupID(name), Object{"location": location, "tags": stringMap(tags)})
+}
+
+// AddDeletedVault stores a soft-deleted Key Vault
+func (s *Server) AddDeletedVault(name string, location string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deletedVaults[strings.ToLower(name)] = s.deletedVault(name, location, "", stringMap(tags))
+}
+
+// Get returns a copy of the resource with the given ID
+func (s *Server) Get(id string) (Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, ok := s.resources[strings.ToLower(id)]
+	if !ok {
+		return nil, false
+	}
+	return s.render(body), true
+}
+
+// Exists reports whether a resource with the given ID exists
+func (s *Server) Exists(id string) bool {
+	_, ok := s.Get(id)
+	return ok
+}
+
+// IDs returns the IDs of every stored resource, sorted
+func (s *Server) IDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.resources))
+	for _, body := range s.resources {
+		ids = append(ids, body["id"].(string))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DeletedVaults returns the names of the soft-deleted Key Vaults, sorted
+func (s *Server) DeletedVaults() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.deletedVaults))
+	for _, body := range s.deletedVaults {
+		names = append(names, body["name"].(string))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fail makes every request with the given method and resource ID fail with
+// an ARM error of the given status and code
+func (s *Server) Fail(method string, id string, status int, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[method+" "+strings.ToLower(id)] = failure{status: status, code: code}
+}
+
+// route is a parsed request path
+type route struct {
+	// ID of the resource, or of the collection for lists
+	ID string
+	// Type of the resource, or of the collection's resources for lists
+	Type string
+	Name string
+	// ResourceGroup is empty for subscription-level routes
+	ResourceGroup string
+	// Parent is the ID of the parent resource for child resources
+	Parent string
+	List   bool
+	// Location and Action are set for deleted vault routes
+	Location string
+	Action   string
+}
+
+var subscriptionPath = regexp.MustCompile(`(?i)^/subscriptions/([^/]+)(/.*)?$`)
+
+// parse maps a request path onto a route
+func (s *Server) parse(path string) (route, error) {
+	match := subscriptionPath.FindStringSubmatch(strings.TrimSuffix(path, "/"))
+	if match == nil {
+		return route{}, fmt.Errorf("unsupported path %s", path)
+	}
+	if !strings.EqualFold(match[1], s.SubscriptionID) {
+		return route{}, fmt.Errorf("subscription %s not found", match[1])
+	}
+
+	segments := strings.Split(strings.TrimPrefix(match[2], "/"), "/")
+	lower := strings.Split(strings.ToLower(strings.TrimPrefix(match[2], "/")), "/")
+	prefix := "/subscriptions/" + s.SubscriptionID
+
+	// deleted vaults live outside resource groups
+	switch {
+	case len(lower) == 3 && lower[0] == "providers" && lower[1] == "microsoft.keyvault" && lower[2] == "deletedvaults":
+		return route{ID: prefix + "/providers/Microsoft.KeyVault/deletedVaults", Type: TypeDeletedKeyVault, List: true}, nil
+	case len(lower) >= 6 && lower[0] == "providers" && lower[1] == "microsoft.keyvault" && lower[2] == "locations" && lower[4] == "deletedvaults":
+		r := route{Type: TypeDeletedKeyVault, Location: segments[3], Name: segments[5]}
+		r.ID = s.DeletedVaultID(r.Location, r.Name)
+		if len(lower) == 7 {
+			r.Action = lower[6]
+		} else if len(lower) > 7 {
+			return route{}, fmt.Errorf("unsupported path %s", path)
+		}
+		return r, nil
+	case len(lower) == 3 && lower[0] == "providers":
+		resourceType, ok := resourceTypes[lower[1]+"/"+lower[2]]
+		if !ok {
+			return route{}, fmt.Errorf("resource type %s/%s is not supported", segments[1], segments[2])
+		}
+		return route{ID: prefix + "/providers/" + resourceType, Type: resourceType, List: true}, nil
+	}
+
+	if lower[0] != "resourcegroups" {
+		return route{}, fmt.Errorf("unsupported path %s", path)
+	}
+	if len(lower) == 1 {
+		return route{ID: prefix + "/resourceGroups", Type: TypeResourceGroup, List: true}, nil
+	}
+
+	r := route{ResourceGroup: segments[1]}
+	groupID := s.ResourceGroupID(segments[1])
+	switch {
+	case len(lower) == 2:
+		r.ID, r.Type, r.Name = groupID, TypeResourceGroup, segments[1]
+		return r, nil
+	case len(lower) < 5 || lower[2] != "providers":
+		return route{}, fmt.Errorf("unsupported path %s", path)
+	}
+
+	resourceType, ok := resourceTypes[lower[3]+"/"+lower[4]]
+	if !ok {
+		return route{}, fmt.Errorf("resource type %s/%s is not supported", segments[3], segments[4])
+	}
+	r.Type = resourceType
+	r.ID = groupID + "/providers/" + resourceType
+
+	rest := segments[5:]
+	if len(rest) == 0 {
+		r.List = true
+		return r, nil
+	}
+	r.Name = rest[0]
+	r.ID += "/" + rest[0]
+
+	switch len(rest) {
+	case 1:
+		return r, nil
+	case 2, 3:
+		childType, ok := childTypes[resourceType+"/"+strings.ToLower(rest[1])]
+		if !ok {
+			return route{}, fmt.Errorf("child type %s of %s is not supported", rest[1], resourceType)
+		}
+		r.Parent = r.ID
+		r.Type = childType
+		r.ID += "/" + childType[strings.LastIndex(childType, "/")+1:]
+		if len(rest) == 2 {
+			r.Name = ""
+			r.List = true
+			return r, nil
+		}
+		r.Name = rest[2]
+		r.ID += "/" + rest[2]
+		return r, nil
+	}
+	return route{}, fmt.Errorf("unsupported path %s", path)
+}
+
+// ServeHTTP implements the Resource Manager API
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.parse(req.URL.Path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "InvalidResourceType", err.Error())
+		return
+	}
+	if f, ok := s.failures[req.Method+" "+strings.ToLower(r.ID)]; ok {
+		writeError(w, f.status, f.code, fmt.Sprintf("%s %s failed", req.Method, r.ID))
+		return
+	}
+
+	switch {
+	case r.Type == TypeDeletedKeyVault:
+		s.serveDeletedVault(w, req, r)
+	case r.List && req.Method == http.MethodGet:
+		s.serveList(w, req, r)
+	case r.List:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", req.Method+" is not supported on "+r.ID)
+	default:
+		s.serveResource(w, req, r)
+	}
+}
+
+func (s *Server) serveResource(w http.ResponseWriter, req *http.Request, r route) {
+	key := strings.ToLower(r.ID)
+	existing, exists := s.resources[key]
+
+	switch req.Method {
+	case http.MethodGet:
+		if !exists {
+			writeNotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.render(existing))
+
+	case http.MethodHead:
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+	case http.MethodPut, http.MethodPatch:
+		var body Object
+		raw, err := io.ReadAll(req.Body)
+		if err == nil && len(raw) > 0 {
+			err = json.Unmarshal(raw, &body)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		if req.Method == http.MethodPatch {
+			if !exists {
+				writeNotFound(w, r)
+				return
+			}
+			body = merge(existing, body)
+		}
+		if status, code, message := s.checkPut(r, exists); status != 0 {
+			writeError(w, status, code, message)
+			return
+		}
+
+		stored := s.store(r, body)
+		status := http.StatusOK
+		if !exists {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, s.render(stored))
+
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.delete(key)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", req.Method+" is not supported on "+r.ID)
+	}
+}
+
+// checkPut applies the conflicts ARM reports when creating a resource
+func (s *Server) checkPut(r route, exists bool) (status int, code string, message string) {
+	if r.ResourceGroup != "" && r.Type != TypeResourceGroup {
+		if _, ok := s.resources[strings.ToLower(s.ResourceGroupID(r.ResourceGroup))]; !ok {
+			return http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group '%s' could not be found.", r.ResourceGroup)
+		}
+	}
+	if r.Parent != "" {
+		if _, ok := s.resources[strings.ToLower(r.Parent)]; !ok {
+			return http.StatusNotFound, "ParentResourceNotFound", fmt.Sprintf("Parent resource %s not found.", r.Parent)
+		}
+	}
+	if r.Type == TypeKeyVault && !exists {
+		// vault names are global and stay reserved while soft-deleted
+		if _, ok := s.deletedVaults[strings.ToLower(r.Name)]; ok {
+			return http.StatusConflict, "ConflictError", fmt.Sprintf("A vault with the same name already exists in deleted state. You need to either recover or purge existing key vault %s.", r.Name)
+		}
+		for _, body := range s.resources {
+			if body["type"] == TypeKeyVault && strings.EqualFold(body["name"].(string), r.Name) {
+				return http.StatusConflict, "VaultAlreadyExists", fmt.Sprintf("The vault name '%s' is already in use.", r.Name)
+			}
+		}
+	}
+	return 0, "", ""
+}
+
+// store saves a resource and the child resources declared inline in its
+// properties, returning the stored body
+func (s *Server) store(r route, body Object) Object {
+	stored := clone(body)
+	if stored == nil {
+		stored = Object{}
+	}
+	stored["id"] = r.ID
+	stored["name"] = r.Name
+	stored["type"] = r.Type
+
+	properties, _ := stored["properties"].(map[string]interface{})
+	if properties == nil {
+		properties = Object{}
+	}
+	properties["provisioningState"] = firstNonEmpty(properties["provisioningState"], "Succeeded")
+	stored["properties"] = properties
+
+	switch r.Type {
+	case TypeVirtualNetwork:
+		subnets, _ := properties["subnets"].([]interface{})
+		delete(properties, "subnets")
+		for _, subnet := range subnets {
+			subnet, ok := subnet.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := subnet["name"].(string)
+			child := r
+			child.Parent, child.Type, child.Name = r.ID, TypeSubnet, name
+			child.ID = r.ID + "/subnets/" + name
+			s.store(child, Object{"properties": subnet["properties"]})
+		}
+
+	case TypeKeyVault:
+		if properties["vaultUri"] == nil {
+			properties["vaultUri"] = fmt.Sprintf("https://%s.vault.azure.net/", r.Name)
+		}
+
+	case TypeContainerGroup:
+		if ip, ok := properties["ipAddress"].(map[string]interface{}); ok {
+			if label, ok := ip["dnsNameLabel"].(string); ok && label != "" {
+				ip["fqdn"] = fmt.Sprintf("%s.%s.azurecontainer.io", label, stored["location"])
+			}
+			if ip["ip"] == nil {
+				ip["ip"] = "20.0.0.4"
+			}
+		}
+	}
+
+	s.resources[strings.ToLower(r.ID)] = stored
+	return stored
+}
+
+// delete removes a resource and everything beneath it. Key Vaults are
+// soft-deleted rather than removed.
+func (s *Server) delete(key string) {
+	for id, body := range s.resources {
+		if id != key && !strings.HasPrefix(id, key+"/") {
+			continue
+		}
+		if body["type"] == TypeKeyVault {
+			location, _ := body["location"].(string)
+			tags, _ := body["tags"].(map[string]interface{})
+			s.deletedVaults[strings.ToLower(body["name"].(string))] = s.deletedVault(body["name"].(string), location, body["id"].(string), tags)
+		}
+		delete(s.resources, id)
+	}
+}
+
+func (s *Server) deletedVault(name string, location string, vaultID string, tags map[string]interface{}) Object {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	deletedAt := now().UTC()
+	if tags == nil {
+		tags = Object{}
+	}
+	return Object{
+		"id":   s.DeletedVaultID(location, name),
+		"name": name,
+		"type": TypeDeletedKeyVault,
+		"properties": Object{
+			"vaultId":            vaultID,
+			"location":           location,
+			"deletionDate":       deletedAt.Format(time.RFC3339),
+			"scheduledPurgeDate": deletedAt.Add(softDeleteRetention).Format(time.RFC3339),
+			"tags":               tags,
+		},
+	}
+}
+
+func (s *Server) serveDeletedVault(w http.ResponseWriter, req *http.Request, r route) {
+	if r.List {
+		if req.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", req.Method+" is not supported on "+r.ID)
+			return
+		}
+		writeJSON(w, http.StatusOK, Object{"value": sortedValues(s.deletedVaults)})
+		return
+	}
+
+	key := strings.ToLower(r.Name)
+	vault, ok := s.deletedVaults[key]
+	if ok {
+		location, _ := vault["properties"].(map[string]interface{})["location"].(string)
+		ok = strings.EqualFold(location, r.Location)
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("Deleted vault %s not found in %s.", r.Name, r.Location))
+		return
+	}
+
+	switch {
+	case req.Method == http.MethodGet && r.Action == "":
+		writeJSON(w, http.StatusOK, vault)
+	case req.Method == http.MethodPost && r.Action == "purge":
+		delete(s.deletedVaults, key)
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", req.Method+" is not supported on "+r.ID)
+	}
+}
+
+func (s *Server) serveList(w http.ResponseWriter, req *http.Request, r route) {
+	filter, err := parseTagFilter(req.URL.Query().Get("$filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidFilterInQueryString", err.Error())
+		return
+	}
+	if r.ResourceGroup != "" {
+		if _, ok := s.resources[strings.ToLower(s.ResourceGroupID(r.ResourceGroup))]; !ok {
+			writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group '%s' could not be found.", r.ResourceGroup))
+			return
+		}
+	}
+	if r.Parent != "" {
+		if _, ok := s.resources[strings.ToLower(r.Parent)]; !ok {
+			writeNotFound(w, route{ID: r.Parent, Type: TypeVirtualNetwork})
+			return
+		}
+	}
+
+	scope := strings.ToLower(r.ResourceGroup)
+	matches := map[string]Object{}
+	for id, body := range s.resources {
+		if body["type"] != r.Type || !filter(body) {
+			continue
+		}
+		switch {
+		case r.Parent != "" && !strings.HasPrefix(id, strings.ToLower(r.Parent)+"/"):
+			continue
+		case r.Type != TypeResourceGroup && scope != "" && !strings.HasPrefix(id, strings.ToLower(s.ResourceGroupID(r.ResourceGroup))+"/"):
+			continue
+		}
+		matches[id] = s.render(body)
+	}
+	writeJSON(w, http.StatusOK, Object{"value": sortedValues(matches)})
+}
+
+// render returns a copy of a stored body with computed properties, such as
+// the subnets of a virtual network, filled in
+func (s *Server) render(body Object) Object {
+	out := clone(body)
+
+	if out["type"] == TypeVirtualNetwork {
+		prefix := strings.ToLower(out["id"].(string)) + "/subnets/"
+		subnets := map[string]Object{}
+		for id, child := range s.resources {
+			if strings.HasPrefix(id, prefix) {
+				subnets[id] = child
+			}
+		}
+		out["properties"].(map[string]interface{})["subnets"] = sortedValues(subnets)
+	}
+	return out
+}
+
+var tagFilter = regexp.MustCompile(`^tagName eq '([^']*)' and tagValue eq '([^']*)'$`)
+
+// parseTagFilter supports the tag filter accepted by resource group lists
+func parseTagFilter(filter string) (func(Object) bool, error) {
+	if filter == "" {
+		return func(Object) bool { return true }, nil
+	}
+	match := tagFilter.FindStringSubmatch(filter)
+	if match == nil {
+		return nil, fmt.Errorf("unsupported $filter %q", filter)
+	}
+	return func(body Object) bool {
+		tags, _ := body["tags"].(map[string]interface{})
+		value, ok := tags[match[1]]
+		return ok && value == match[2]
+	}, nil
+}
+
+// clone deep-copies a JSON object
+func clone(body Object) Object {
+	raw, _ := json.Marshal(body)
+	var out Object
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+func sortedValues(m map[string]Object) []Object {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]Object, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, m[key])
+	}
+	return values
+}
+
+func merge(base Object, patch Object) Object {
+	merged := Object{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range patch {
+		if nested, ok := v.(map[string]interface{}); ok {
+			if existing, ok := merged[k].(map[string]interface{}); ok {
+				merged[k] = merge(existing, nested)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func firstNonEmpty(value interface{}, fallback string) interface{} {
+	if s, ok := value.(string); ok && s != "" {
+		return s
+	}
+	return fallback
+}
+
+func writeNotFound(w http.ResponseWriter, r route) {
+	code := "ResourceNotFound"
+	if r.Type == TypeResourceGroup {
+		code = "ResourceGroupNotFound"
+	}
+	writeError(w, http.StatusNotFound, code, fmt.Sprintf("The Resource '%s' was not found.", r.ID))
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, Object{"error": Object{"code": code, "message": message}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,233 @@
+package fakearm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2018-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/mgmt/2016-10-01/keyvault"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceGroupLifecycle(t *testing.T) {
+	arm := New(t)
+	ctx := context.Background()
+	groups := resources.NewGroupsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	groups.Authorizer = autorest.NullAuthorizer{}
+
+	exists, err := groups.CheckExistence(ctx, "test-rg")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, exists.StatusCode)
+
+	_, err = groups.CreateOrUpdate(ctx, "test-rg", resources.Group{
+		Location: to.StringPtr("eastus"),
+		Tags:     map[string]*string{"ManagedBy": to.StringPtr("terratest")},
+	})
+	require.NoError(t, err)
+	arm.AddResourceGroup("other-rg", "westus2", nil)
+
+	exists, err = groups.CheckExistence(ctx, "TEST-RG")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, exists.StatusCode, "names are case-insensitive")
+
+	group, err := groups.Get(ctx, "test-rg")
+	require.NoError(t, err)
+	assert.Equal(t, "/subscriptions/"+SubscriptionID+"/resourceGroups/test-rg", *group.ID)
+	assert.Equal(t, "Succeeded", *group.Properties.ProvisioningState)
+
+	page, err := groups.List(ctx, "tagName eq 'ManagedBy' and tagValue eq 'terratest'", nil)
+	require.NoError(t, err)
+	require.Len(t, page.Values(), 1)
+	assert.Equal(t, "test-rg", *page.Values()[0].Name)
+
+	future, err := groups.Delete(ctx, "test-rg")
+	require.NoError(t, err)
+	require.NoError(t, future.WaitForCompletionRef(ctx, groups.Client))
+	assert.Equal(t, []string{arm.ResourceGroupID("other-rg")}, arm.IDs())
+
+	_, err = groups.Get(ctx, "test-rg")
+	assert.Contains(t, err.Error(), "ResourceGroupNotFound")
+}
+
+func TestNetworkResources(t *testing.T) {
+	arm := New(t)
+	ctx := context.Background()
+	vnets := network.NewVirtualNetworksClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	vnets.Authorizer = autorest.NullAuthorizer{}
+	subnets := network.NewSubnetsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	subnets.Authorizer = autorest.NullAuthorizer{}
+	nsgs := network.NewSecurityGroupsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	nsgs.Authorizer = autorest.NullAuthorizer{}
+
+	vnet := network.VirtualNetwork{
+		Location: to.StringPtr("eastus"),
+		VirtualNetworkPropertiesFormat: &network.VirtualNetworkPropertiesFormat{
+			AddressSpace: &network.AddressSpace{AddressPrefixes: &[]string{"10.0.0.0/16"}},
+			Subnets: &[]network.Subnet{{
+				Name:                   to.StringPtr("container-subnet"),
+				SubnetPropertiesFormat: &network.SubnetPropertiesFormat{AddressPrefix: to.StringPtr("10.0.1.0/24")},
+			}},
+		},
+	}
+	_, err := vnets.CreateOrUpdate(ctx, "test-rg", "test-vnet", vnet)
+	assert.Contains(t, err.Error(), "ResourceGroupNotFound", "resources need their resource group")
+
+	arm.AddResourceGroup("test-rg", "eastus", nil)
+	vnetFuture, err := vnets.CreateOrUpdate(ctx, "test-rg", "test-vnet", vnet)
+	require.NoError(t, err)
+	require.NoError(t, vnetFuture.WaitForCompletionRef(ctx, vnets.Client))
+
+	subnetFuture, err := subnets.CreateOrUpdate(ctx, "test-rg", "test-vnet", "vm-subnet", network.Subnet{
+		SubnetPropertiesFormat: &network.SubnetPropertiesFormat{AddressPrefix: to.StringPtr("10.0.3.0/24")},
+	})
+	require.NoError(t, err)
+	require.NoError(t, subnetFuture.WaitForCompletionRef(ctx, subnets.Client))
+
+	got, err := vnets.Get(ctx, "test-rg", "test-vnet", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16"}, *got.AddressSpace.AddressPrefixes)
+	require.Len(t, *got.Subnets, 2, "subnets created inline and separately are both listed")
+	assert.Equal(t, "container-subnet", *(*got.Subnets)[0].Name)
+
+	subnet, err := subnets.Get(ctx, "test-rg", "test-vnet", "vm-subnet", "")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.3.0/24", *subnet.AddressPrefix)
+	assert.Equal(t, arm.ResourceID("test-rg", TypeSubnet, "test-vnet", "vm-subnet"), *subnet.ID)
+
+	_, err = subnets.CreateOrUpdate(ctx, "test-rg", "missing-vnet", "vm-subnet", network.Subnet{})
+	assert.Contains(t, err.Error(), "ParentResourceNotFound")
+
+	nsgFuture, err := nsgs.CreateOrUpdate(ctx, "test-rg", "vm-nsg", network.SecurityGroup{
+		Location: to.StringPtr("eastus"),
+		SecurityGroupPropertiesFormat: &network.SecurityGroupPropertiesFormat{
+			SecurityRules: &[]network.SecurityRule{{
+				Name: to.StringPtr("AllowSSH"),
+				SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
+					DestinationPortRange: to.StringPtr("22"),
+					Access:               network.SecurityRuleAccessAllow,
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, nsgFuture.WaitForCompletionRef(ctx, nsgs.Client))
+
+	nsg, err := nsgs.Get(ctx, "test-rg", "vm-nsg", "")
+	require.NoError(t, err)
+	require.Len(t, *nsg.SecurityRules, 1)
+	assert.Equal(t, "22", *(*nsg.SecurityRules)[0].DestinationPortRange)
+
+	deleteFuture, err := vnets.Delete(ctx, "test-rg", "test-vnet")
+	require.NoError(t, err)
+	require.NoError(t, deleteFuture.WaitForCompletionRef(ctx, vnets.Client))
+	assert.False(t, arm.Exists(arm.ResourceID("test-rg", TypeSubnet, "test-vnet", "vm-subnet")), "deleting a vnet deletes its subnets")
+	assert.True(t, arm.Exists(arm.ResourceID("test-rg", TypeNetworkSecurityGroup, "vm-nsg")))
+}
+
+func TestKeyVaultSoftDeleteAndPurge(t *testing.T) {
+	arm := New(t)
+	ctx := context.Background()
+	vaults := keyvault.NewVaultsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	vaults.Authorizer = autorest.NullAuthorizer{}
+	arm.AddResourceGroup("test-rg", "eastus", nil)
+	arm.AddResourceGroup("other-rg", "eastus", nil)
+
+	params := keyvault.VaultCreateOrUpdateParameters{
+		Location: to.StringPtr("eastus"),
+		Tags:     map[string]*string{"ManagedBy": to.StringPtr("terratest")},
+		Properties: &keyvault.VaultProperties{
+			TenantID: &uuid.Nil,
+			Sku:      &keyvault.Sku{Family: to.StringPtr("A"), Name: keyvault.Standard},
+		},
+	}
+	vault, err := vaults.CreateOrUpdate(ctx, "test-rg", "testkv-abc123", params)
+	require.NoError(t, err)
+	assert.Equal(t, "https://testkv-abc123.vault.azure.net/", *vault.Properties.VaultURI)
+
+	_, err = vaults.CreateOrUpdate(ctx, "other-rg", "testkv-abc123", params)
+	assert.Contains(t, err.Error(), "VaultAlreadyExists", "vault names are global")
+
+	// deleting the resource group soft-deletes the vault and keeps its name reserved
+	arm.Put(arm.ResourceGroupID("test-rg"), nil)
+	groups := resources.NewGroupsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	groups.Authorizer = autorest.NullAuthorizer{}
+	future, err := groups.Delete(ctx, "test-rg")
+	require.NoError(t, err)
+	require.NoError(t, future.WaitForCompletionRef(ctx, groups.Client))
+	assert.Equal(t, []string{"testkv-abc123"}, arm.DeletedVaults())
+
+	_, err = vaults.CreateOrUpdate(ctx, "other-rg", "testkv-abc123", params)
+	assert.Contains(t, err.Error(), "deleted state")
+
+	deleted, err := vaults.GetDeleted(ctx, "testkv-abc123", "eastus")
+	require.NoError(t, err)
+	assert.Equal(t, "terratest", *deleted.Properties.Tags["ManagedBy"])
+	assert.NotNil(t, deleted.Properties.ScheduledPurgeDate)
+
+	purge, err := vaults.PurgeDeleted(ctx, "testkv-abc123", "eastus")
+	require.NoError(t, err)
+	require.NoError(t, purge.WaitForCompletionRef(ctx, vaults.Client))
+	assert.Empty(t, arm.DeletedVaults())
+
+	_, err = vaults.CreateOrUpdate(ctx, "other-rg", "testkv-abc123", params)
+	assert.NoError(t, err, "a purged name can be reused")
+}
+
+func TestContainerGroupFQDN(t *testing.T) {
+	arm := New(t)
+	ctx := context.Background()
+	groups := containerinstance.NewContainerGroupsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	groups.Authorizer = autorest.NullAuthorizer{}
+	arm.AddResourceGroup("test-rg", "eastus", nil)
+
+	future, err := groups.CreateOrUpdate(ctx, "test-rg", "test-aci", containerinstance.ContainerGroup{
+		Location: to.StringPtr("eastus"),
+		ContainerGroupProperties: &containerinstance.ContainerGroupProperties{
+			OsType: containerinstance.Linux,
+			Containers: &[]containerinstance.Container{{
+				Name: to.StringPtr("test-container"),
+				ContainerProperties: &containerinstance.ContainerProperties{
+					Image: to.StringPtr("nginx:latest"),
+					Resources: &containerinstance.ResourceRequirements{
+						Requests: &containerinstance.ResourceRequests{CPU: to.Float64Ptr(0.5), MemoryInGB: to.Float64Ptr(0.5)},
+					},
+				},
+			}},
+			IPAddress: &containerinstance.IPAddress{
+				Type:         containerinstance.Public,
+				DNSNameLabel: to.StringPtr("test-aci-abc123"),
+				Ports:        &[]containerinstance.Port{{Port: to.Int32Ptr(80)}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, future.WaitForCompletionRef(ctx, groups.Client))
+
+	group, err := groups.Get(ctx, "test-rg", "test-aci")
+	require.NoError(t, err)
+	assert.Equal(t, "test-aci-abc123.eastus.azurecontainer.io", *group.IPAddress.Fqdn)
+	assert.NotEmpty(t, *group.IPAddress.IP)
+}
+
+func TestFailInjectsErrors(t *testing.T) {
+	arm := New(t)
+	ctx := context.Background()
+	groups := resources.NewGroupsClientWithBaseURI(arm.URL, arm.SubscriptionID)
+	groups.Authorizer = autorest.NullAuthorizer{}
+	arm.AddResourceGroup("locked-rg", "eastus", nil)
+	arm.Fail(http.MethodDelete, arm.ResourceGroupID("locked-rg"), http.StatusConflict, "ScopeLocked")
+
+	_, err := groups.Delete(ctx, "locked-rg")
+	assert.Contains(t, err.Error(), "ScopeLocked")
+	assert.True(t, arm.Exists(arm.ResourceGroupID("locked-rg")))
+
+	_, err = groups.Get(ctx, "locked-rg")
+	assert.NoError(t, err, "only the failing method is affected")
+}
@@ -0,0 +1,164 @@
+// Package repo locates the repository root and the Terraform modules under
+// it, independently of the directory the Go tests happen to run from.
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/require"
+)
+
+// ModulesDirName is the directory under the repository root holding the Terraform modules
+const ModulesDirName = "modules"
+
+// ErrRootNotFound is returned when no parent directory looks like the repository root
+var ErrRootNotFound = errors.New("repository root not found: no parent directory contains .git, or go.mod next to a modules/ directory")
+
+var (
+	rootOnce sync.Once
+	rootDir  string
+	rootErr  error
+)
+
+// Root returns the absolute path of the repository root. Starting from the
+// working directory it walks up until it finds a directory containing .git,
+// or containing go.mod next to a modules/ directory. Nested Go modules such
+// as test/unit have a go.mod but no modules/ directory, so they are skipped.
+func Root() (string, error) {
+	rootOnce.Do(func() {
+		wd, err := os.Getwd()
+		if err != nil {
+			rootErr = err
+			return
+		}
+		rootDir, rootErr = FindRoot(wd)
+	})
+	return rootDir, rootErr
+}
+
+// FindRoot walks up from dir looking for the repository root
+func FindRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if isRoot(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrRootNotFound
+		}
+		dir = parent
+	}
+}
+
+func isRoot(dir string) bool {
+	if exists(filepath.Join(dir, ".git")) {
+		return true
+	}
+	return exists(filepath.Join(dir, "go.mod")) && isDir(filepath.Join(dir, ModulesDirName))
+}
+
+// ModulesDir returns the absolute path of the modules/ directory
+func ModulesDir() (string, error) {
+	root, err := Root()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, ModulesDirName), nil
+}
+
+// ModuleDir returns the absolute path of the named module under modules/
+func ModuleDir(module string) (string, error) {
+	modulesDir, err := ModulesDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(modulesDir, module)
+	if !isDir(dir) {
+		return "", files.DirNotFoundError{Directory: dir}
+	}
+	return dir, nil
+}
+
+// TestDataDir returns the folder under test/unit/.test-data where the given
+// test keeps data that must survive between runs of individual test stages
+func TestDataDir(t *testing.T) string {
+	t.Helper()
+
+	root, err := Root()
+	require.NoError(t, err)
+	return filepath.Join(root, "test", "unit", ".test-data", strings.ReplaceAll(t.Name(), "/", "_"))
+}
+
+// CopyModuleToTemp copies the named module into a fresh temp folder and
+// returns the path of the copy, in the style of
+// test_structure.CopyTerraformFolderToTemp. Parallel tests applying the same
+// module each get their own .terraform directory and local state. The copy
+// is removed when the test finishes.
+//
+// When any SKIP_<stage> environment variable is set the module is copied to
+// TestDataDir(t)/<module> instead and kept, so its state survives between
+// runs of individual test stages. Only the .tf files are refreshed on each
+// run; .terraform and terraform.tfstate are left in place.
+func CopyModuleToTemp(t *testing.T, module string) string {
+	t.Helper()
+
+	dir, err := ModuleDir(module)
+	require.NoError(t, err)
+
+	if test_structure.SkipStageEnvVarSet() {
+		stableDir := filepath.Join(TestDataDir(t), module)
+		require.NoError(t, copyModuleFiles(dir, stableDir))
+		return stableDir
+	}
+
+	tempDir, err := files.CopyTerraformFolderToTemp(dir, "terratest-"+module+"-")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		os.RemoveAll(filepath.Dir(tempDir))
+	})
+	return tempDir
+}
+
+// copyModuleFiles copies the Terraform sources of a module into dest
+func copyModuleFiles(src string, dest string) error {
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || files.PathContainsTerraformState(entry.Name()) {
+			continue
+		}
+		if err := files.CopyFile(filepath.Join(src, entry.Name()), filepath.Join(dest, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
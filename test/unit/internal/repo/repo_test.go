@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootContainsModules(t *testing.T) {
+	root, err := Root()
+	require.NoError(t, err)
+
+	assert.DirExists(t, filepath.Join(root, ModulesDirName))
+	assert.FileExists(t, filepath.Join(root, "terragrunt.hcl"))
+}
+
+func TestFindRootSkipsNestedGoModules(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "test", "unit")
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ModulesDirName), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "go.mod"), []byte("module example/test\n"), 0o644))
+
+	found, err := FindRoot(nested)
+	require.NoError(t, err)
+	assert.Equal(t, root, found)
+}
+
+func TestModuleDirRejectsUnknownModule(t *testing.T) {
+	_, err := ModuleDir("does-not-exist")
+	assert.Error(t, err)
+}
+
+func TestCopyModuleToTempIsolatesEachCall(t *testing.T) {
+	original, err := ModuleDir("resource-group")
+	require.NoError(t, err)
+
+	first := CopyModuleToTemp(t, "resource-group")
+	second := CopyModuleToTemp(t, "resource-group")
+
+	assert.NotEqual(t, first, second)
+	assert.NotEqual(t, original, first)
+	assert.FileExists(t, filepath.Join(first, "main.tf"))
+	assert.FileExists(t, filepath.Join(second, "variables.tf"))
+}
+
+func TestCopyModuleToTempKeepsStableCopyWhenSkippingStages(t *testing.T) {
+	t.Setenv("SKIP_teardown", "true")
+
+	dir := CopyModuleToTemp(t, "resource-group")
+	t.Cleanup(func() {
+		os.RemoveAll(TestDataDir(t))
+		os.Remove(filepath.Dir(TestDataDir(t)))
+	})
+
+	assert.Equal(t, filepath.Join(TestDataDir(t), "resource-group"), dir)
+	assert.FileExists(t, filepath.Join(dir, "main.tf"))
+
+	// state written by an earlier stage run must survive the next copy
+	state := filepath.Join(dir, "terraform.tfstate")
+	require.NoError(t, os.WriteFile(state, []byte("{}"), 0o644))
+	assert.Equal(t, dir, CopyModuleToTemp(t, "resource-group"))
+	assert.FileExists(t, state)
+}
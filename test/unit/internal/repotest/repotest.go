@@ -0,0 +1,48 @@
+// Package repotest writes scratch repositories for tests: Terragrunt units
+// under environments/<env> applying the real modules, which the scratch
+// repository's modules/ links to.
+package repotest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/stretchr/testify/require"
+)
+
+// LinkModules links modules/ under root to the repository's modules, unless
+// it is already there
+func LinkModules(t testing.TB, root string) {
+	t.Helper()
+	modules, err := repo.ModulesDir()
+	require.NoError(t, err)
+	if _, err := os.Lstat(filepath.Join(root, repo.ModulesDirName)); os.IsNotExist(err) {
+		require.NoError(t, os.Symlink(modules, filepath.Join(root, repo.ModulesDirName)))
+	}
+}
+
+// WriteUnit writes environments/<env>/<unit>/terragrunt.hcl under root and
+// returns its path. The file sources modules/<module> from get_repo_root(),
+// linking modules/ first, and continues with body. Without a module the file
+// only holds body.
+func WriteUnit(t testing.TB, root string, env string, unit string, module string, body string) string {
+	t.Helper()
+	config := body
+	if module != "" {
+		LinkModules(t, root)
+		config = "terraform {\n  source = \"${get_repo_root()}/modules/" + module + "\"\n}\n" + body
+	}
+	dir := filepath.Join(root, "environments", env, unit)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	path := filepath.Join(dir, "terragrunt.hcl")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	return path
+}
+
+// Inputs returns an inputs block holding assignments, one per line, to
+// follow the terraform block of WriteUnit
+func Inputs(assignments string) string {
+	return "\ninputs = {\n" + assignments + "}\n"
+}
@@ -0,0 +1,28 @@
+package repotest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteUnit(t *testing.T) {
+	root := t.TempDir()
+	path := WriteUnit(t, root, "dev", "vault", "key-vault", Inputs("  sku_name = \"standard\"\n"))
+	assert.Equal(t, filepath.Join(root, "environments", "dev", "vault", "terragrunt.hcl"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "terraform {\n  source = \"${get_repo_root()}/modules/key-vault\"\n}\n\ninputs = {\n  sku_name = \"standard\"\n}\n", string(data))
+	assert.FileExists(t, filepath.Join(root, "modules", "key-vault", "variables.tf"), "modules/ links to the real modules")
+
+	// a second unit reuses the link
+	WriteUnit(t, root, "dev", "rg", "resource-group", "")
+
+	path = WriteUnit(t, t.TempDir(), "dev", "app", "", "dependency \"db\" {\n  config_path = \"../db\"\n}\n")
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "dependency \"db\" {\n  config_path = \"../db\"\n}\n", string(data))
+}
@@ -0,0 +1,402 @@
+package tfmodule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"github.com/zclconf/go-cty/cty/gocty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Instance is one planned instance of a resource or data source
+type Instance struct {
+	Resource *Resource
+	// Key is the count index or for_each key, cty.NilVal for resources
+	// that set neither
+	Key cty.Value
+	// Address is the resource address with its instance key, e.g.
+	// azurerm_key_vault_secret.db_admin_password[0] or
+	// azurerm_mssql_firewall_rule.custom["office"]
+	Address string
+}
+
+// Inputs returns the value of every module variable for the given inputs,
+// which take the form of terratest's Options.Vars. Missing inputs take
+// their defaults and every value is converted to its variable's type. It
+// fails on unknown or missing required inputs, values that do not convert
+// and validation conditions that do not hold.
+func (m *Module) Inputs(vars map[string]interface{}) (map[string]cty.Value, error) {
+	var errs []error
+	for name := range vars {
+		if _, ok := m.Variables[name]; !ok {
+			errs = append(errs, fmt.Errorf("module %s has no variable %q", m.Name, name))
+		}
+	}
+
+	values := map[string]cty.Value{}
+	for _, name := range m.VariableNames() {
+		v := m.Variables[name]
+		raw, ok := vars[name]
+		if !ok || (raw == nil && !v.Nullable) {
+			if !v.HasDefault {
+				errs = append(errs, fmt.Errorf("%s: variable %q is required", v.Range, name))
+				continue
+			}
+			values[name] = v.Default
+			continue
+		}
+
+		value := cty.NullVal(v.Type)
+		var err error
+		if raw != nil {
+			value, err = goValue(raw)
+		}
+		if err == nil {
+			value, err = v.Convert(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value for variable %q: %w", v.Range, name, err))
+			continue
+		}
+		values[name] = value
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": cty.ObjectVal(values)},
+		Functions: Functions(),
+	}
+	for _, name := range m.VariableNames() {
+		for _, validation := range m.Variables[name].Validations {
+			if err := validation.Check(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value for variable %q: %w", validation.Range, name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return values, nil
+}
+
+// Check evaluates the condition in ctx, which must define var, and returns the
+// error message as an error when the condition is false. Unknown results pass.
+func (v *Validation) Check(ctx *hcl.EvalContext) error {
+	if v.Condition == nil {
+		return nil
+	}
+	result, diags := v.Condition.Value(ctx)
+	if diags.HasErrors() {
+		return diags
+	}
+	result, err := convert.Convert(result, cty.Bool)
+	if err != nil || result.IsNull() {
+		return errors.New("validation condition is not a bool")
+	}
+	if result.IsKnown() && result.False() {
+		return errors.New(v.ErrorMessage)
+	}
+	return nil
+}
+
+// Expand evaluates the count and for_each of every resource and data source
+// for the given inputs and returns the instances Terraform would plan, in
+// declaration order. Resources come before data sources, count indexes are
+// ascending and for_each keys sorted.
+//
+// Attributes of other resources are unknown before apply, so an expression
+// that depends on them fails just as it does in terraform plan.
+func (m *Module) Expand(vars map[string]interface{}) ([]Instance, error) {
+	inputs, err := m.Inputs(vars)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := m.EvalContext(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []Instance
+	for _, r := range append(append([]*Resource{}, m.Resources...), m.DataSources...) {
+		expanded, err := r.expand(ctx)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, expanded...)
+	}
+	return instances, nil
+}
+
+// Addresses returns the addresses of instances
+func Addresses(instances []Instance) []string {
+	addresses := make([]string, len(instances))
+	for i, instance := range instances {
+		addresses[i] = instance.Address
+	}
+	return addresses
+}
+
+// EvalContext returns the context resource arguments are evaluated in: the
+// variables, the locals, the Terraform functions that need no provider, and
+// every resource and data source as an unknown value
+func (m *Module) EvalContext(inputs map[string]cty.Value) (*hcl.EvalContext, error) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": cty.ObjectVal(inputs)},
+		Functions: Functions(),
+	}
+
+	managed := map[string]map[string]cty.Value{}
+	data := map[string]map[string]cty.Value{}
+	for _, r := range m.Resources {
+		if managed[r.Type] == nil {
+			managed[r.Type] = map[string]cty.Value{}
+		}
+		managed[r.Type][r.Name] = cty.DynamicVal
+	}
+	for _, r := range m.DataSources {
+		if data[r.Type] == nil {
+			data[r.Type] = map[string]cty.Value{}
+		}
+		data[r.Type][r.Name] = cty.DynamicVal
+	}
+	for typ, names := range managed {
+		ctx.Variables[typ] = cty.ObjectVal(names)
+	}
+	dataTypes := map[string]cty.Value{}
+	for typ, names := range data {
+		dataTypes[typ] = cty.ObjectVal(names)
+	}
+	ctx.Variables["data"] = cty.ObjectVal(dataTypes)
+
+	locals, err := m.evalLocals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Variables["local"] = cty.ObjectVal(locals)
+	return ctx, nil
+}
+
+// evalLocals evaluates the locals that depend on no unevaluated local until
+// every local has a value, so locals may refer to each other in any order
+func (m *Module) evalLocals(ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+	values := map[string]cty.Value{}
+	for len(values) < len(m.Locals) {
+		progress := false
+		for name, expr := range m.Locals {
+			if _, done := values[name]; done || !localsReady(expr, values) {
+				continue
+			}
+			scope := ctx.NewChild()
+			scope.Variables = map[string]cty.Value{"local": cty.ObjectVal(values)}
+			value, diags := expr.Value(scope)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			values[name] = value
+			progress = true
+		}
+		if !progress {
+			var pending []string
+			for name := range m.Locals {
+				if _, done := values[name]; !done {
+					pending = append(pending, name)
+				}
+			}
+			sort.Strings(pending)
+			return nil, fmt.Errorf("module %s: locals %v refer to each other or to undeclared locals", m.Name, pending)
+		}
+	}
+	return values, nil
+}
+
+func localsReady(expr hcl.Expression, values map[string]cty.Value) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "local" || len(traversal) < 2 {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); ok {
+			if _, done := values[attr.Name]; !done {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *Resource) expand(ctx *hcl.EvalContext) ([]Instance, error) {
+	switch {
+	case r.Count != nil:
+		n, err := evalCount(r.Count, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid count for %s: %w", r.Count.Range(), r.Address, err)
+		}
+		instances := make([]Instance, n)
+		for i := range instances {
+			instances[i] = Instance{Resource: r, Key: cty.NumberIntVal(int64(i)), Address: fmt.Sprintf("%s[%d]", r.Address, i)}
+		}
+		return instances, nil
+	case r.ForEach != nil:
+		keys, err := evalForEach(r.ForEach, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid for_each for %s: %w", r.ForEach.Range(), r.Address, err)
+		}
+		instances := make([]Instance, len(keys))
+		for i, key := range keys {
+			instances[i] = Instance{Resource: r, Key: cty.StringVal(key), Address: fmt.Sprintf("%s[%q]", r.Address, key)}
+		}
+		return instances, nil
+	}
+	return []Instance{{Resource: r, Address: r.Address}}, nil
+}
+
+func evalCount(expr hcl.Expression, ctx *hcl.EvalContext) (int, error) {
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return 0, diags
+	}
+	if !value.IsWhollyKnown() {
+		return 0, errors.New("the value depends on resource attributes that cannot be determined until apply")
+	}
+	value, err := convert.Convert(value, cty.Number)
+	if err != nil || value.IsNull() {
+		return 0, errors.New("a whole number is required")
+	}
+	var n int
+	if err := gocty.FromCtyValue(value, &n); err != nil || n < 0 {
+		return 0, errors.New("a non-negative whole number is required")
+	}
+	return n, nil
+}
+
+// evalForEach returns the sorted keys of a map, object or set of strings
+func evalForEach(expr hcl.Expression, ctx *hcl.EvalContext) ([]string, error) {
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if !value.IsWhollyKnown() {
+		return nil, errors.New("the value depends on resource attributes that cannot be determined until apply")
+	}
+	if value.IsNull() {
+		return nil, errors.New("the given for_each argument value is null")
+	}
+
+	ty := value.Type()
+	var keys []string
+	switch {
+	case ty.IsMapType() || ty.IsObjectType():
+		for it := value.ElementIterator(); it.Next(); {
+			key, _ := it.Element()
+			keys = append(keys, key.AsString())
+		}
+	case ty.IsSetType() && ty.ElementType().Equals(cty.String):
+		for it := value.ElementIterator(); it.Next(); {
+			_, element := it.Element()
+			if element.IsNull() {
+				return nil, errors.New("sets used in for_each must not contain null")
+			}
+			keys = append(keys, element.AsString())
+		}
+	default:
+		return nil, fmt.Errorf("a map, or set of strings, is required, got %s", ty.FriendlyName())
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Convert applies the defaults of optional attributes and converts value to
+// the variable's type
+func (v *Variable) Convert(value cty.Value) (cty.Value, error) {
+	if v.defaults != nil {
+		value = v.defaults.Apply(value)
+	}
+	return convert.Convert(value, v.Type)
+}
+
+// goValue converts an input from terratest's Options.Vars by way of JSON,
+// which is also how terratest hands inputs to Terraform
+func goValue(raw interface{}) (cty.Value, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	ty, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(data, ty)
+}
+
+// Functions returns the Terraform functions that are pure cty functions. The
+// filesystem, encoding and crypto functions are left out.
+func Functions() map[string]function.Function {
+	return map[string]function.Function{
+		"abs":             stdlib.AbsoluteFunc,
+		"can":             tryfunc.CanFunc,
+		"ceil":            stdlib.CeilFunc,
+		"chomp":           stdlib.ChompFunc,
+		"chunklist":       stdlib.ChunklistFunc,
+		"coalesce":        stdlib.CoalesceFunc,
+		"coalescelist":    stdlib.CoalesceListFunc,
+		"compact":         stdlib.CompactFunc,
+		"concat":          stdlib.ConcatFunc,
+		"contains":        stdlib.ContainsFunc,
+		"distinct":        stdlib.DistinctFunc,
+		"element":         stdlib.ElementFunc,
+		"flatten":         stdlib.FlattenFunc,
+		"floor":           stdlib.FloorFunc,
+		"format":          stdlib.FormatFunc,
+		"formatlist":      stdlib.FormatListFunc,
+		"index":           stdlib.IndexFunc,
+		"join":            stdlib.JoinFunc,
+		"jsondecode":      stdlib.JSONDecodeFunc,
+		"jsonencode":      stdlib.JSONEncodeFunc,
+		"keys":            stdlib.KeysFunc,
+		"length":          stdlib.LengthFunc,
+		"lookup":          stdlib.LookupFunc,
+		"lower":           stdlib.LowerFunc,
+		"max":             stdlib.MaxFunc,
+		"merge":           stdlib.MergeFunc,
+		"min":             stdlib.MinFunc,
+		"parseint":        stdlib.ParseIntFunc,
+		"range":           stdlib.RangeFunc,
+		"regex":           stdlib.RegexFunc,
+		"regexall":        stdlib.RegexAllFunc,
+		"replace":         stdlib.ReplaceFunc,
+		"reverse":         stdlib.ReverseListFunc,
+		"setintersection": stdlib.SetIntersectionFunc,
+		"setproduct":      stdlib.SetProductFunc,
+		"setsubtract":     stdlib.SetSubtractFunc,
+		"setunion":        stdlib.SetUnionFunc,
+		"slice":           stdlib.SliceFunc,
+		"sort":            stdlib.SortFunc,
+		"split":           stdlib.SplitFunc,
+		"strrev":          stdlib.ReverseFunc,
+		"substr":          stdlib.SubstrFunc,
+		"title":           stdlib.TitleFunc,
+		"tolist":          stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"tomap":           stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+		"tonumber":        stdlib.MakeToFunc(cty.Number),
+		"toset":           stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tostring":        stdlib.MakeToFunc(cty.String),
+		"tobool":          stdlib.MakeToFunc(cty.Bool),
+		"trim":            stdlib.TrimFunc,
+		"trimprefix":      stdlib.TrimPrefixFunc,
+		"trimspace":       stdlib.TrimSpaceFunc,
+		"trimsuffix":      stdlib.TrimSuffixFunc,
+		"try":             tryfunc.TryFunc,
+		"upper":           stdlib.UpperFunc,
+		"values":          stdlib.ValuesFunc,
+		"zipmap":          stdlib.ZipmapFunc,
+	}
+}
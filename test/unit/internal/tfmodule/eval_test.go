@@ -0,0 +1,153 @@
+package tfmodule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandKeyVaultSecrets(t *testing.T) {
+	module := loadModule(t, "key-vault")
+
+	vars := tfvars.NewKeyVaultVars()
+	vars.KeyVaultName = "testkvsec-abc123"
+	vars.Location = "eastus"
+	vars.ResourceGroupName = "test-kv-sec-rg-abc123"
+
+	instances, err := module.Expand(tfvars.ToMap(vars))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"azurerm_key_vault.main", "data.azurerm_client_config.current"}, Addresses(instances))
+
+	vars.StoreDbCredentials = true
+	vars.StoreDockerhubCredentials = true
+	instances, err = module.Expand(tfvars.ToMap(vars))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"azurerm_key_vault.main",
+		"azurerm_key_vault_secret.db_admin_username[0]",
+		"azurerm_key_vault_secret.db_admin_password[0]",
+		"azurerm_key_vault_secret.dockerhub_username[0]",
+		"azurerm_key_vault_secret.dockerhub_password[0]",
+		"data.azurerm_client_config.current",
+	}, Addresses(instances))
+}
+
+func TestExpandVirtualMachineToggles(t *testing.T) {
+	module := loadModule(t, "virtual-machine")
+	vars := map[string]interface{}{
+		"vm_name":             "vm",
+		"location":            "eastus",
+		"resource_group_name": "rg",
+		"subnet_id":           "subnet",
+		"ssh_public_key":      "ssh-rsa AAAA",
+	}
+
+	instances, err := module.Expand(vars)
+	require.NoError(t, err)
+	addresses := Addresses(instances)
+	assert.Contains(t, addresses, "azurerm_public_ip.main[0]", "create_public_ip defaults to true")
+	assert.NotContains(t, addresses, "azurerm_network_interface_security_group_association.main[0]")
+
+	vars["create_public_ip"] = false
+	vars["create_data_disk"] = false
+	vars["network_security_group_id"] = "nsg"
+	instances, err = module.Expand(vars)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"azurerm_network_interface.main",
+		"azurerm_network_interface_security_group_association.main[0]",
+		"azurerm_linux_virtual_machine.main",
+	}, Addresses(instances))
+}
+
+func TestExpandSqlFirewallRules(t *testing.T) {
+	module := loadModule(t, "sql-database")
+
+	vars := tfvars.NewSqlDatabaseVars()
+	vars.SqlServerName = "testsql-abc123"
+	vars.DatabaseName = "testdb"
+	vars.ResourceGroupName = "rg"
+	vars.Location = "eastus"
+	vars.AdminUsername = "sqladmin"
+	vars.AdminPassword = "P@ssw0rd!"
+	inputs := tfvars.ToMap(vars)
+	inputs["allow_azure_services"] = false
+	inputs["subnet_id"] = "subnet"
+	inputs["firewall_rules"] = map[string]interface{}{
+		"office": map[string]string{"start_ip": "10.0.0.1", "end_ip": "10.0.0.9"},
+		"home":   map[string]string{"start_ip": "10.1.0.1", "end_ip": "10.1.0.1"},
+	}
+
+	instances, err := module.Expand(inputs)
+	require.NoError(t, err)
+	addresses := Addresses(instances)
+	assert.Contains(t, addresses, `azurerm_mssql_firewall_rule.custom["home"]`)
+	assert.Contains(t, addresses, `azurerm_mssql_firewall_rule.custom["office"]`)
+	assert.NotContains(t, addresses, "azurerm_mssql_firewall_rule.allow_azure_services[0]")
+	for _, instance := range instances {
+		if instance.Resource.Address == "azurerm_mssql_firewall_rule.custom" {
+			assert.Equal(t, "string", instance.Key.Type().FriendlyName())
+		}
+	}
+}
+
+func TestInputsRejectsBadInputs(t *testing.T) {
+	module := loadModule(t, "key-vault")
+
+	_, err := module.Inputs(map[string]interface{}{
+		"location":             "eastus",
+		"store_db_credentials": "maybe",
+		"key_vault_nmae":       "typo",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `module key-vault has no variable "key_vault_nmae"`)
+	assert.Contains(t, err.Error(), `variable "key_vault_name" is required`)
+	assert.Contains(t, err.Error(), `invalid value for variable "store_db_credentials"`)
+}
+
+func TestExpandEvaluatesLocalsAndValidations(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`
+variable "zones" {
+  type    = list(string)
+  default = ["1"]
+
+  validation {
+    condition     = length(var.zones) > 0
+    error_message = "At least one zone is required."
+  }
+}
+
+locals {
+  zone_set = toset(local.zones)
+  zones    = [for zone in var.zones : "zone-${zone}"]
+}
+
+resource "azurerm_public_ip" "zonal" {
+  for_each = local.zone_set
+}
+
+resource "azurerm_network_interface" "nic" {
+  count = length(azurerm_public_ip.zonal)
+}
+`), 0o644))
+	module, err := Load(dir)
+	require.NoError(t, err)
+
+	_, err = module.Inputs(map[string]interface{}{"zones": []string{}})
+	assert.ErrorContains(t, err, "At least one zone is required.")
+
+	_, err = module.Expand(map[string]interface{}{"zones": []string{"2", "1"}})
+	require.Error(t, err, "count cannot depend on another resource")
+	assert.Contains(t, err.Error(), "invalid count for azurerm_network_interface.nic")
+	assert.Contains(t, err.Error(), "cannot be determined until apply")
+
+	module.Resources = module.Resources[:1]
+	instances, err := module.Expand(map[string]interface{}{"zones": []string{"2", "1"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`azurerm_public_ip.zonal["zone-1"]`, `azurerm_public_ip.zonal["zone-2"]`}, Addresses(instances))
+}
@@ -0,0 +1,448 @@
+// Package tfmodule loads the Terraform modules under modules/ with the HCL
+// parser and describes what they declare: variables with their types,
+// defaults, sensitivity and validations, outputs with the resources they
+// read, locals, and the managed resources and data sources together with the
+// count, for_each and dynamic blocks that decide how many instances exist.
+//
+// Loading a module evaluates nothing beyond constant variable defaults.
+// Module.Expand then evaluates count and for_each for a set of inputs to
+// predict the resource instances Terraform would plan, so tests can check a
+// module's toggles without Terraform, provider credentials or an apply.
+package tfmodule
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// Resource modes, as in Terraform's resource addresses
+const (
+	ManagedMode = "managed"
+	DataMode    = "data"
+)
+
+// Module is everything declared by the .tf files of one module directory
+type Module struct {
+	// Name is the directory name, e.g. key-vault
+	Name      string
+	Dir       string
+	Variables map[string]*Variable
+	Outputs   map[string]*Output
+	Locals    map[string]hcl.Expression
+	// Resources are the managed resources in declaration order, reading the
+	// files in name order
+	Resources []*Resource
+	// DataSources are the data blocks, in the same order
+	DataSources []*Resource
+}
+
+// Variable is a declared input variable
+type Variable struct {
+	Name        string
+	Description string
+	// Type is the type constraint, cty.DynamicPseudoType when there is none
+	Type cty.Type
+	// Default is the default converted to Type, with the defaults of
+	// optional object attributes applied. It is cty.NilVal when HasDefault
+	// is false.
+	Default    cty.Value
+	HasDefault bool
+	Sensitive  bool
+	// Nullable is false only when the variable sets nullable = false
+	Nullable    bool
+	Validations []Validation
+	Range       hcl.Range
+
+	// defaults holds the defaults of optional object attributes in Type
+	defaults *typeexpr.Defaults
+}
+
+// Required reports whether callers must set the variable
+func (v *Variable) Required() bool {
+	return !v.HasDefault
+}
+
+// Validation is a validation block of a variable
+type Validation struct {
+	Condition    hcl.Expression
+	ErrorMessage string
+	Range        hcl.Range
+}
+
+// Output is a declared output value
+type Output struct {
+	Name        string
+	Description string
+	Sensitive   bool
+	Value       hcl.Expression
+	// References are the addresses of the resources and data sources the
+	// value reads, sorted, e.g. azurerm_key_vault.main
+	References []string
+	// Variables are the module variables the value reads, sorted
+	Variables []string
+	Range     hcl.Range
+}
+
+// Resource is a resource or data block
+type Resource struct {
+	Mode string
+	Type string
+	Name string
+	// Address is TYPE.NAME for managed resources and data.TYPE.NAME for data
+	// sources
+	Address string
+	// Count and ForEach are nil unless the block sets them
+	Count   hcl.Expression
+	ForEach hcl.Expression
+	// Dynamics are the dynamic blocks at any depth of the body
+	Dynamics []Dynamic
+	// Body is the block body, for checks that need attributes this package
+	// does not model
+	Body  *hclsyntax.Body
+	Range hcl.Range
+}
+
+// Dynamic is a dynamic block inside a resource
+type Dynamic struct {
+	// Address is the resource address followed by the generated block types
+	// of this and any enclosing dynamic blocks, e.g.
+	// azurerm_container_group.main.volume
+	Address string
+	ForEach hcl.Expression
+	Range   hcl.Range
+}
+
+// Load parses the .tf files of the module in dir
+func Load(dir string) (*Module, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s: no .tf files found", dir)
+	}
+	sort.Strings(paths)
+
+	module := &Module{
+		Name:      filepath.Base(dir),
+		Dir:       dir,
+		Variables: map[string]*Variable{},
+		Outputs:   map[string]*Output{},
+		Locals:    map[string]hcl.Expression{},
+	}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if err := module.addFile(file.Body.(*hclsyntax.Body)); err != nil {
+			return nil, err
+		}
+	}
+	return module, nil
+}
+
+// LoadAll loads every module directory under modulesDir, keyed by name
+func LoadAll(modulesDir string) (map[string]*Module, error) {
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := map[string]*Module{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		module, err := Load(filepath.Join(modulesDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		modules[module.Name] = module
+	}
+	return modules, nil
+}
+
+func (m *Module) addFile(body *hclsyntax.Body) error {
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "variable":
+			variable, err := loadVariable(block)
+			if err != nil {
+				return err
+			}
+			m.Variables[variable.Name] = variable
+		case "output":
+			output, err := loadOutput(block)
+			if err != nil {
+				return err
+			}
+			m.Outputs[output.Name] = output
+		case "locals":
+			for name, attr := range block.Body.Attributes {
+				m.Locals[name] = attr.Expr
+			}
+		case "resource":
+			m.Resources = append(m.Resources, loadResource(ManagedMode, block))
+		case "data":
+			m.DataSources = append(m.DataSources, loadResource(DataMode, block))
+		}
+	}
+	return nil
+}
+
+func loadVariable(block *hclsyntax.Block) (*Variable, error) {
+	v := &Variable{
+		Name:     block.Labels[0],
+		Type:     cty.DynamicPseudoType,
+		Nullable: true,
+		Range:    block.DefRange(),
+	}
+	attrs := block.Body.Attributes
+
+	if attr, ok := attrs["type"]; ok {
+		ty, defaults, diags := typeexpr.TypeConstraintWithDefaults(attr.Expr)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		v.Type, v.defaults = ty, defaults
+	}
+	if attr, ok := attrs["default"]; ok {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		value, err := v.Convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: default for variable %q: %w", attr.SrcRange, v.Name, err)
+		}
+		v.Default = value
+		v.HasDefault = true
+	}
+
+	var err error
+	if v.Description, err = stringAttr(attrs, "description"); err != nil {
+		return nil, err
+	}
+	if v.Sensitive, err = boolAttr(attrs, "sensitive", false); err != nil {
+		return nil, err
+	}
+	if v.Nullable, err = boolAttr(attrs, "nullable", true); err != nil {
+		return nil, err
+	}
+
+	for _, nested := range block.Body.Blocks {
+		if nested.Type != "validation" {
+			continue
+		}
+		validation := Validation{Range: nested.DefRange()}
+		if attr, ok := nested.Body.Attributes["condition"]; ok {
+			validation.Condition = attr.Expr
+		}
+		if validation.ErrorMessage, err = stringAttr(nested.Body.Attributes, "error_message"); err != nil {
+			return nil, err
+		}
+		v.Validations = append(v.Validations, validation)
+	}
+	return v, nil
+}
+
+func loadOutput(block *hclsyntax.Block) (*Output, error) {
+	o := &Output{Name: block.Labels[0], Range: block.DefRange()}
+	attrs := block.Body.Attributes
+
+	var err error
+	if o.Description, err = stringAttr(attrs, "description"); err != nil {
+		return nil, err
+	}
+	if o.Sensitive, err = boolAttr(attrs, "sensitive", false); err != nil {
+		return nil, err
+	}
+	if attr, ok := attrs["value"]; ok {
+		o.Value = attr.Expr
+		o.References = References(attr.Expr)
+		o.Variables = ReferencedVariables(attr.Expr)
+	}
+	return o, nil
+}
+
+func loadResource(mode string, block *hclsyntax.Block) *Resource {
+	r := &Resource{
+		Mode:  mode,
+		Type:  block.Labels[0],
+		Name:  block.Labels[1],
+		Body:  block.Body,
+		Range: block.DefRange(),
+	}
+	r.Address = r.Type + "." + r.Name
+	if mode == DataMode {
+		r.Address = "data." + r.Address
+	}
+	if attr, ok := block.Body.Attributes["count"]; ok {
+		r.Count = attr.Expr
+	}
+	if attr, ok := block.Body.Attributes["for_each"]; ok {
+		r.ForEach = attr.Expr
+	}
+	r.Dynamics = dynamics(r.Address, block.Body)
+	return r
+}
+
+// dynamics finds dynamic blocks at any depth, including inside ordinary
+// nested blocks such as the volume blocks of a container block
+func dynamics(address string, body *hclsyntax.Body) []Dynamic {
+	var found []Dynamic
+	for _, block := range body.Blocks {
+		if block.Type != "dynamic" || len(block.Labels) == 0 {
+			found = append(found, dynamics(address, block.Body)...)
+			continue
+		}
+		d := Dynamic{Address: address + "." + block.Labels[0], Range: block.DefRange()}
+		if attr, ok := block.Body.Attributes["for_each"]; ok {
+			d.ForEach = attr.Expr
+		}
+		found = append(found, d)
+		found = append(found, dynamics(d.Address, block.Body)...)
+	}
+	return found
+}
+
+// Resource returns the managed resource or data source at address, or nil
+func (m *Module) Resource(address string) *Resource {
+	for _, list := range [][]*Resource{m.Resources, m.DataSources} {
+		for _, r := range list {
+			if r.Address == address {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// VariableNames returns the declared variable names in sorted order
+func (m *Module) VariableNames() []string {
+	names := make([]string, 0, len(m.Variables))
+	for name := range m.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OutputNames returns the declared output names in sorted order
+func (m *Module) OutputNames() []string {
+	names := make([]string, 0, len(m.Outputs))
+	for name := range m.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// References returns the addresses of the resources and data sources read by
+// expr, sorted and without duplicates
+func References(expr hcl.Expression) []string {
+	seen := map[string]bool{}
+	var addresses []string
+	for _, traversal := range expr.Variables() {
+		address := resourceAddress(traversal)
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// resourceAddress returns the resource address a traversal starts with, or ""
+// for references to variables, locals and other named values
+func resourceAddress(traversal hcl.Traversal) string {
+	switch traversal.RootName() {
+	case "var", "local", "module", "each", "count", "path", "terraform", "self":
+		return ""
+	case "data":
+		if len(traversal) < 3 {
+			return ""
+		}
+		typ, ok1 := traversal[1].(hcl.TraverseAttr)
+		name, ok2 := traversal[2].(hcl.TraverseAttr)
+		if !ok1 || !ok2 {
+			return ""
+		}
+		return "data." + typ.Name + "." + name.Name
+	}
+	if len(traversal) < 2 {
+		return ""
+	}
+	name, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return ""
+	}
+	return traversal.RootName() + "." + name.Name
+}
+
+// ReferencedVariables returns the names of the module variables read by expr,
+// sorted and without duplicates
+func ReferencedVariables(expr hcl.Expression) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "var" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok || seen[attr.Name] {
+			continue
+		}
+		seen[attr.Name] = true
+		names = append(names, attr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stringAttr evaluates an optional constant string attribute
+func stringAttr(attrs hclsyntax.Attributes, name string) (string, error) {
+	attr, ok := attrs[name]
+	if !ok {
+		return "", nil
+	}
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return "", diags
+	}
+	value, err := convert.Convert(value, cty.String)
+	if err != nil || value.IsNull() || !value.IsKnown() {
+		return "", fmt.Errorf("%s: %s must be a string", attr.SrcRange, name)
+	}
+	return value.AsString(), nil
+}
+
+// boolAttr evaluates an optional constant bool attribute
+func boolAttr(attrs hclsyntax.Attributes, name string, fallback bool) (bool, error) {
+	attr, ok := attrs[name]
+	if !ok {
+		return fallback, nil
+	}
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return false, diags
+	}
+	value, err := convert.Convert(value, cty.Bool)
+	if err != nil || value.IsNull() || !value.IsKnown() {
+		return false, fmt.Errorf("%s: %s must be true or false", attr.SrcRange, name)
+	}
+	return value.True(), nil
+}
@@ -0,0 +1,154 @@
+package tfmodule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func loadModule(t *testing.T, name string) *Module {
+	dir, err := repo.ModuleDir(name)
+	require.NoError(t, err)
+	module, err := Load(dir)
+	require.NoError(t, err)
+	return module
+}
+
+func TestLoadAllFindsEveryModule(t *testing.T) {
+	dir, err := repo.ModulesDir()
+	require.NoError(t, err)
+
+	modules, err := LoadAll(dir)
+	require.NoError(t, err)
+	for _, name := range []string{"container-instance", "key-vault", "log-analytics", "networking", "resource-group", "sql-database", "virtual-machine"} {
+		require.Contains(t, modules, name)
+		assert.NotEmpty(t, modules[name].Variables, name)
+		assert.NotEmpty(t, modules[name].Outputs, name)
+		assert.NotEmpty(t, modules[name].Resources, name)
+	}
+}
+
+func TestKeyVaultDataSourceAndCountedSecrets(t *testing.T) {
+	module := loadModule(t, "key-vault")
+
+	require.Len(t, module.DataSources, 1)
+	config := module.DataSources[0]
+	assert.Equal(t, DataMode, config.Mode)
+	assert.Equal(t, "data.azurerm_client_config.current", config.Address)
+	assert.Same(t, config, module.Resource("data.azurerm_client_config.current"))
+
+	vault := module.Resource("azurerm_key_vault.main")
+	require.NotNil(t, vault)
+	assert.Nil(t, vault.Count)
+	assert.Nil(t, vault.ForEach)
+
+	secret := module.Resource("azurerm_key_vault_secret.db_admin_password")
+	require.NotNil(t, secret)
+	require.NotNil(t, secret.Count)
+	assert.Equal(t, []string{"store_db_credentials"}, ReferencedVariables(secret.Count))
+
+	assert.Equal(t, []string{"azurerm_key_vault.main"}, module.Outputs["key_vault_tenant_id"].References)
+}
+
+func TestVariableTypesAndDefaults(t *testing.T) {
+	kv := loadModule(t, "key-vault")
+	password := kv.Variables["db_admin_password"]
+	assert.True(t, password.Sensitive)
+	assert.True(t, password.Type.Equals(cty.String))
+	assert.Equal(t, cty.StringVal(""), password.Default)
+	assert.True(t, kv.Variables["allowed_ip_ranges"].Type.Equals(cty.List(cty.String)))
+	assert.True(t, kv.Variables["key_vault_name"].Required())
+
+	sql := loadModule(t, "sql-database")
+	assert.True(t, sql.Variables["admin_password"].Sensitive)
+	assert.False(t, sql.Variables["admin_password"].HasDefault)
+	rules := sql.Variables["firewall_rules"]
+	assert.True(t, rules.Type.IsMapType())
+	assert.True(t, rules.Default.Type().Equals(rules.Type), "defaults are converted to the declared type")
+	assert.Equal(t, 0, rules.Default.LengthInt())
+
+	aci := loadModule(t, "container-instance")
+	volumes := aci.Variables["volumes"]
+	assert.True(t, volumes.Type.IsListType())
+	assert.True(t, volumes.Type.ElementType().AttributeOptional("read_only"))
+	assert.True(t, volumes.Nullable)
+	assert.True(t, aci.Variables["secure_environment_variables"].Sensitive)
+}
+
+func TestOutputsSensitivityAndReferences(t *testing.T) {
+	la := loadModule(t, "log-analytics")
+	assert.True(t, la.Outputs["primary_shared_key"].Sensitive)
+	assert.False(t, la.Outputs["workspace_id"].Sensitive)
+
+	sql := loadModule(t, "sql-database")
+	connection := sql.Outputs["connection_string"]
+	assert.True(t, connection.Sensitive)
+	assert.Equal(t, []string{"azurerm_mssql_database.main", "azurerm_mssql_server.main"}, connection.References)
+	assert.Equal(t, []string{"admin_password", "admin_username"}, connection.Variables)
+
+	custom := sql.Resource("azurerm_mssql_firewall_rule.custom")
+	require.NotNil(t, custom)
+	require.NotNil(t, custom.ForEach)
+	assert.Equal(t, []string{"firewall_rules"}, ReferencedVariables(custom.ForEach))
+}
+
+func TestDynamicBlocks(t *testing.T) {
+	aci := loadModule(t, "container-instance")
+	group := aci.Resources[0]
+
+	var addresses []string
+	for _, d := range group.Dynamics {
+		require.NotNil(t, d.ForEach, d.Address)
+		addresses = append(addresses, d.Address)
+	}
+	assert.Contains(t, addresses, group.Address+".image_registry_credential")
+	assert.Contains(t, addresses, group.Address+".volume")
+}
+
+func TestLoadValidationsAndNullable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(`
+variable "sku" {
+  type     = string
+  default  = "standard"
+  nullable = false
+
+  validation {
+    condition     = contains(["standard", "premium"], var.sku)
+    error_message = "The sku must be standard or premium."
+  }
+}
+
+locals {
+  premium = var.sku == "premium"
+}
+`), 0o644))
+
+	module, err := Load(dir)
+	require.NoError(t, err)
+	sku := module.Variables["sku"]
+	assert.False(t, sku.Nullable)
+	require.Len(t, sku.Validations, 1)
+	assert.Equal(t, "The sku must be standard or premium.", sku.Validations[0].ErrorMessage)
+	assert.Equal(t, []string{"sku"}, ReferencedVariables(sku.Validations[0].Condition))
+	assert.Contains(t, module.Locals, "premium")
+	assert.Equal(t, 2, sku.Range.Start.Line)
+}
+
+func TestLoadRejectsInvalidDefault(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(`
+variable "count" {
+  type    = number
+  default = "many"
+}
+`), 0o644))
+
+	_, err := Load(dir)
+	assert.ErrorContains(t, err, `default for variable "count"`)
+}
@@ -0,0 +1,294 @@
+// Package janitor removes Azure resources leaked by the tests.
+//
+// Tests destroy what they create through t.Cleanup, but a panic, a killed
+// process or a cancelled CI run skips those cleanups and leaves resource
+// groups behind. Every group created through the fixtures carries the
+// standard tags of the naming package, so a Reaper can list the groups tagged
+// ManagedBy=terratest and delete those whose ExpiresAt has passed. Deleted Key
+// Vaults stay soft-deleted and keep their globally unique name reserved, so
+// soft-deleted vaults carrying the same tag are purged as well.
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/mgmt/2016-10-01/keyvault"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+)
+
+// Actions taken on a resource
+const (
+	ActionDelete = "delete"
+	ActionPurge  = "purge"
+	ActionKeep   = "keep"
+)
+
+// Reaper finds and removes leaked test resources in one subscription
+type Reaper struct {
+	Groups resources.GroupsClient
+	Vaults keyvault.VaultsClient
+
+	// TTL is the lifetime assumed for groups that have a CreatedAt tag but no
+	// ExpiresAt tag
+	TTL time.Duration
+	// DryRun reports what would be removed without removing anything
+	DryRun bool
+	// Now returns the current time; it defaults to time.Now
+	Now func() time.Time
+}
+
+// New returns a Reaper using the Resource Manager endpoint at baseURI
+func New(subscriptionID string, baseURI string, authorizer autorest.Authorizer) *Reaper {
+	groups := resources.NewGroupsClientWithBaseURI(baseURI, subscriptionID)
+	groups.Authorizer = authorizer
+	vaults := keyvault.NewVaultsClientWithBaseURI(baseURI, subscriptionID)
+	vaults.Authorizer = authorizer
+
+	return &Reaper{Groups: groups, Vaults: vaults, TTL: naming.DefaultTTL}
+}
+
+// Report lists every test resource the Reaper looked at
+type Report struct {
+	DryRun           bool       `json:"dry_run"`
+	Time             time.Time  `json:"time"`
+	ResourceGroups   []Resource `json:"resource_groups"`
+	DeletedKeyVaults []Resource `json:"deleted_key_vaults"`
+}
+
+// Resource is one resource group or soft-deleted Key Vault
+type Resource struct {
+	Name      string     `json:"name"`
+	Location  string     `json:"location,omitempty"`
+	TestName  string     `json:"test_name,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Action    string     `json:"action"`
+	Reason    string     `json:"reason"`
+	// Done is set once the delete or purge has completed
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+// Run deletes expired test resource groups, then purges soft-deleted test Key
+// Vaults. Failures to remove a single resource are recorded in the report;
+// the returned error is reserved for failures to list resources.
+func (r *Reaper) Run(ctx context.Context) (*Report, error) {
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	report := &Report{DryRun: r.DryRun, Time: now().UTC()}
+
+	groups, err := r.expiredGroups(ctx, report.Time)
+	if err != nil {
+		return nil, err
+	}
+	report.ResourceGroups = groups
+	r.deleteGroups(ctx, report.ResourceGroups)
+
+	vaults, err := r.deletedVaults(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.DeletedKeyVaults = vaults
+	r.purgeVaults(ctx, report.DeletedKeyVaults)
+
+	return report, nil
+}
+
+// Failed reports whether removing any resource failed
+func (r *Report) Failed() bool {
+	for _, list := range [][]Resource{r.ResourceGroups, r.DeletedKeyVaults} {
+		for _, resource := range list {
+			if resource.Error != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Reaper) expiredGroups(ctx context.Context, now time.Time) ([]Resource, error) {
+	filter := fmt.Sprintf("tagName eq '%s' and tagValue eq '%s'", naming.TagManagedBy, naming.ManagedBy)
+	page, err := r.Groups.List(ctx, filter, nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing resource groups: %w", err)
+	}
+
+	var found []Resource
+	for ; page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("listing resource groups: %w", err)
+		}
+		for _, group := range page.Values() {
+			resource := Resource{
+				Name:     value(group.Name),
+				Location: value(group.Location),
+				TestName: value(group.Tags[naming.TagTestName]),
+				Action:   ActionKeep,
+			}
+			if group.Properties != nil && value(group.Properties.ProvisioningState) == "Deleting" {
+				resource.Reason = "already being deleted"
+				found = append(found, resource)
+				continue
+			}
+
+			expiresAt, reason := r.expiry(group.Tags)
+			resource.Reason = reason
+			if !expiresAt.IsZero() {
+				resource.ExpiresAt = &expiresAt
+				if now.After(expiresAt) {
+					resource.Action = ActionDelete
+					resource.Reason = fmt.Sprintf("expired %s ago", now.Sub(expiresAt).Round(time.Minute))
+				}
+			}
+			found = append(found, resource)
+		}
+	}
+	return found, nil
+}
+
+// expiry returns when a group expires, from its ExpiresAt tag or else its
+// CreatedAt tag plus the TTL. The zero time means the expiry is unknown and
+// reason explains why.
+func (r *Reaper) expiry(tags map[string]*string) (expiresAt time.Time, reason string) {
+	if raw := value(tags[naming.TagExpiresAt]); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err == nil {
+			return parsed, "not expired"
+		}
+		reason = fmt.Sprintf("invalid %s tag %q", naming.TagExpiresAt, raw)
+	}
+	if raw := value(tags[naming.TagCreatedAt]); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err == nil {
+			return parsed.Add(r.TTL), "not expired"
+		}
+		return time.Time{}, fmt.Sprintf("invalid %s tag %q", naming.TagCreatedAt, raw)
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("no %s or %s tag", naming.TagExpiresAt, naming.TagCreatedAt)
+	}
+	return time.Time{}, reason
+}
+
+// deleteGroups starts every deletion before waiting on any of them, since a
+// resource group takes minutes to delete
+func (r *Reaper) deleteGroups(ctx context.Context, groups []Resource) {
+	if r.DryRun {
+		return
+	}
+
+	futures := map[int]resources.GroupsDeleteFuture{}
+	for i := range groups {
+		if groups[i].Action != ActionDelete {
+			continue
+		}
+		future, err := r.Groups.Delete(ctx, groups[i].Name)
+		if err != nil {
+			groups[i].Error = err.Error()
+			continue
+		}
+		futures[i] = future
+	}
+	for i, future := range futures {
+		if err := future.WaitForCompletionRef(ctx, r.Groups.Client); err != nil {
+			groups[i].Error = err.Error()
+			continue
+		}
+		groups[i].Done = true
+	}
+}
+
+func (r *Reaper) deletedVaults(ctx context.Context) ([]Resource, error) {
+	page, err := r.Vaults.ListDeleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing deleted key vaults: %w", err)
+	}
+
+	var found []Resource
+	for ; page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("listing deleted key vaults: %w", err)
+		}
+		for _, vault := range page.Values() {
+			if vault.Properties == nil || value(vault.Properties.Tags[naming.TagManagedBy]) != naming.ManagedBy {
+				continue
+			}
+			found = append(found, Resource{
+				Name:     value(vault.Name),
+				Location: value(vault.Properties.Location),
+				TestName: value(vault.Properties.Tags[naming.TagTestName]),
+				Action:   ActionPurge,
+				Reason:   "soft-deleted",
+			})
+		}
+	}
+	return found, nil
+}
+
+func (r *Reaper) purgeVaults(ctx context.Context, vaults []Resource) {
+	if r.DryRun {
+		return
+	}
+
+	for i := range vaults {
+		future, err := r.Vaults.PurgeDeleted(ctx, vaults[i].Name, vaults[i].Location)
+		if err == nil {
+			err = future.WaitForCompletionRef(ctx, r.Vaults.Client)
+		}
+		if err != nil {
+			vaults[i].Error = err.Error()
+			continue
+		}
+		vaults[i].Done = true
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes one line per resource
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if r.DryRun {
+		fmt.Fprintln(tw, "Dry run: nothing was deleted")
+	}
+	fmt.Fprintln(tw, "KIND\tNAME\tACTION\tSTATUS\tTEST\tREASON")
+	write := func(kind string, resource Resource) {
+		status := "-"
+		switch {
+		case resource.Error != "":
+			status = "failed: " + resource.Error
+		case resource.Done:
+			status = "done"
+		case resource.Action != ActionKeep && r.DryRun:
+			status = "skipped (dry run)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", kind, resource.Name, resource.Action, status, resource.TestName, resource.Reason)
+	}
+	for _, group := range r.ResourceGroups {
+		write("resource group", group)
+	}
+	for _, vault := range r.DeletedKeyVaults {
+		write("deleted key vault", vault)
+	}
+	return tw.Flush()
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,154 @@
+package janitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/fakearm"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testTags(createdAt time.Time, ttl time.Duration) map[string]string {
+	return map[string]string{
+		naming.TagManagedBy: naming.ManagedBy,
+		naming.TagTestName:  "TestExample",
+		naming.TagCreatedAt: createdAt.Format(time.RFC3339),
+		naming.TagExpiresAt: createdAt.Add(ttl).Format(time.RFC3339),
+	}
+}
+
+func newReaper(arm *fakearm.Server) *Reaper {
+	reaper := New(arm.SubscriptionID, arm.URL, autorest.NullAuthorizer{})
+	reaper.Now = func() time.Time { return now }
+	return reaper
+}
+
+func seed(arm *fakearm.Server) {
+	arm.AddResourceGroup("test-rg-expired", "eastus", testTags(now.Add(-8*time.Hour), 6*time.Hour))
+	arm.AddResourceGroup("test-rg-running", "eastus", testTags(now.Add(-time.Hour), 6*time.Hour))
+	arm.AddResourceGroup("test-rg-created-only", "eastus", map[string]string{
+		naming.TagManagedBy: naming.ManagedBy,
+		naming.TagCreatedAt: now.Add(-7 * time.Hour).Format(time.RFC3339),
+	})
+	arm.AddResourceGroup("test-rg-untimed", "eastus", map[string]string{naming.TagManagedBy: naming.ManagedBy})
+	arm.Put(arm.ResourceGroupID("test-rg-deleting"), fakearm.Object{
+		"location":   "eastus",
+		"tags":       testTags(now.Add(-8*time.Hour), 6*time.Hour),
+		"properties": fakearm.Object{"provisioningState": "Deleting"},
+	})
+	arm.AddResourceGroup("production-rg", "eastus", map[string]string{"Environment": "production"})
+
+	arm.AddDeletedVault("testkv-abc123", "eastus", testTags(now.Add(-8*time.Hour), 6*time.Hour))
+	arm.AddDeletedVault("prod-kv", "eastus", map[string]string{"Environment": "production"})
+}
+
+func TestRunDeletesExpiredGroupsAndPurgesTestVaults(t *testing.T) {
+	arm := fakearm.New(t)
+	seed(arm)
+
+	report, err := newReaper(arm).Run(context.Background())
+	require.NoError(t, err)
+	assert.False(t, report.Failed())
+
+	actions := map[string]Resource{}
+	for _, group := range report.ResourceGroups {
+		actions[group.Name] = group
+	}
+	assert.NotContains(t, actions, "production-rg", "only groups tagged ManagedBy=terratest are listed")
+	assert.Len(t, actions, 5)
+
+	assert.Equal(t, ActionDelete, actions["test-rg-expired"].Action)
+	assert.Equal(t, "expired 2h0m0s ago", actions["test-rg-expired"].Reason)
+	assert.True(t, actions["test-rg-expired"].Done)
+	assert.Equal(t, ActionDelete, actions["test-rg-created-only"].Action, "CreatedAt plus the default TTL has passed")
+	assert.Equal(t, ActionKeep, actions["test-rg-running"].Action)
+	assert.Equal(t, ActionKeep, actions["test-rg-untimed"].Action)
+	assert.Equal(t, "no ExpiresAt or CreatedAt tag", actions["test-rg-untimed"].Reason)
+	assert.Equal(t, ActionKeep, actions["test-rg-deleting"].Action)
+
+	require.Len(t, report.DeletedKeyVaults, 1)
+	assert.Equal(t, "testkv-abc123", report.DeletedKeyVaults[0].Name)
+	assert.True(t, report.DeletedKeyVaults[0].Done)
+
+	assert.Equal(t, []string{
+		arm.ResourceGroupID("production-rg"),
+		arm.ResourceGroupID("test-rg-deleting"),
+		arm.ResourceGroupID("test-rg-running"),
+		arm.ResourceGroupID("test-rg-untimed"),
+	}, arm.IDs())
+	assert.Equal(t, []string{"prod-kv"}, arm.DeletedVaults())
+}
+
+func TestRunDryRunDeletesNothing(t *testing.T) {
+	arm := fakearm.New(t)
+	seed(arm)
+
+	reaper := newReaper(arm)
+	reaper.DryRun = true
+	report, err := reaper.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Len(t, arm.IDs(), 6)
+	assert.Len(t, arm.DeletedVaults(), 2)
+	for _, group := range report.ResourceGroups {
+		assert.False(t, group.Done, group.Name)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteText(&out))
+	assert.Contains(t, out.String(), "Dry run: nothing was deleted")
+	assert.Regexp(t, `test-rg-expired\s+delete\s+skipped \(dry run\)`, out.String())
+
+	out.Reset()
+	require.NoError(t, report.WriteJSON(&out))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.True(t, decoded.DryRun)
+	assert.Len(t, decoded.ResourceGroups, 5)
+}
+
+func TestRunRecordsFailedDeletions(t *testing.T) {
+	arm := fakearm.New(t)
+	arm.AddResourceGroup("test-rg-locked", "eastus", testTags(now.Add(-8*time.Hour), 6*time.Hour))
+	arm.Fail(http.MethodDelete, arm.ResourceGroupID("test-rg-locked"), http.StatusConflict, "ScopeLocked")
+
+	report, err := newReaper(arm).Run(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, report.ResourceGroups, 1)
+	assert.True(t, report.Failed())
+	assert.False(t, report.ResourceGroups[0].Done)
+	assert.Contains(t, report.ResourceGroups[0].Error, "ScopeLocked")
+	assert.True(t, arm.Exists(arm.ResourceGroupID("test-rg-locked")))
+}
+
+func TestExpiryPrefersExpiresAt(t *testing.T) {
+	reaper := &Reaper{TTL: time.Hour}
+	tag := func(s string) *string { return &s }
+
+	expiresAt, _ := reaper.expiry(map[string]*string{
+		naming.TagCreatedAt: tag("2026-03-01T00:00:00Z"),
+		naming.TagExpiresAt: tag("2026-03-02T00:00:00Z"),
+	})
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), expiresAt)
+
+	expiresAt, _ = reaper.expiry(map[string]*string{
+		naming.TagCreatedAt: tag("2026-03-01T00:00:00Z"),
+		naming.TagExpiresAt: tag("tomorrow"),
+	})
+	assert.Equal(t, time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC), expiresAt, "an unreadable ExpiresAt falls back to CreatedAt plus TTL")
+
+	expiresAt, reason := reaper.expiry(map[string]*string{naming.TagExpiresAt: tag("tomorrow")})
+	assert.True(t, expiresAt.IsZero())
+	assert.True(t, strings.HasPrefix(reason, "invalid ExpiresAt tag"), reason)
+}
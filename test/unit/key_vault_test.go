@@ -1,118 +1,99 @@
 package test
 
 import (
-	"fmt"
-	"strings"
+	"context"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/fixtures"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestKeyVaultModule tests the key-vault module
 func TestKeyVaultModule(t *testing.T) {
 	t.Parallel()
 
-	uniqueID := strings.ToLower(random.UniqueId())
-	resourceGroupName := fmt.Sprintf("test-kv-rg-%s", uniqueID)
-	keyVaultName := fmt.Sprintf("testkv%s", uniqueID)
-	location := "eastus"
-
-	// First, create a resource group
-	rgOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/resource-group",
-		Vars: map[string]interface{}{
-			"resource_group_name": resourceGroupName,
-			"location":            location,
-			"tags":                map[string]string{"Environment": "test"},
-		},
-	})
+	fixtures.Setup(t, func() {
+		names := naming.For(t)
+
+		// First, create a resource group
+		rg := fixtures.NewResourceGroup(t, names.Name(naming.ResourceGroup, "test-kv-rg"), "eastus")
 
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
-
-	// Then create the key vault
-	kvOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/key-vault",
-		Vars: map[string]interface{}{
-			"key_vault_name":            keyVaultName,
-			"resource_group_name":       resourceGroupName,
-			"location":                  location,
-			"sku_name":                  "standard",
-			"soft_delete_retention_days": 7,
-			"purge_protection_enabled":  false,
-			"store_db_credentials":      false,
-			"store_dockerhub_credentials": false,
-			"network_acls_default_action": "Allow",
-			"allowed_ip_ranges":         []string{},
-			"allowed_subnet_ids":        []string{},
-			"tags":                      map[string]string{"Environment": "test"},
-		},
+		// Then create the key vault
+		kvVars := tfvars.NewKeyVaultVars()
+		kvVars.KeyVaultName = names.Name(naming.KeyVault, "testkv")
+		kvVars.ResourceGroupName = rg.Name
+		kvVars.Location = rg.Location
+		kvVars.Tags = fixtures.Tags(t)
+
+		kvOptions := kvVars.ToOptions(t)
+		fixtures.Apply(t, kvOptions)
 	})
 
-	defer terraform.Destroy(t, kvOptions)
-	terraform.InitAndApply(t, kvOptions)
+	fixtures.Validate(t, func() {
+		kvOptions := fixtures.LoadOptions(t, "key-vault")
+		keyVaultName := kvOptions.Vars["key_vault_name"].(string)
+
+		// Validate outputs
+		outputID := terraform.Output(t, kvOptions, "key_vault_id")
+		outputVaultURI := terraform.Output(t, kvOptions, "key_vault_uri")
+		outputName := terraform.Output(t, kvOptions, "key_vault_name")
 
-	// Validate outputs
-	outputID := terraform.Output(t, kvOptions, "id")
-	outputVaultURI := terraform.Output(t, kvOptions, "vault_uri")
-	outputName := terraform.Output(t, kvOptions, "name")
+		// Assertions
+		assert.NotEmpty(t, outputID, "Key Vault ID should not be empty")
+		assert.Contains(t, outputVaultURI, keyVaultName, "Vault URI should contain key vault name")
+		assert.Equal(t, keyVaultName, outputName, "Key Vault name should match")
 
-	// Assertions
-	assert.NotEmpty(t, outputID, "Key Vault ID should not be empty")
-	assert.Contains(t, outputVaultURI, keyVaultName, "Vault URI should contain key vault name")
-	assert.Equal(t, keyVaultName, outputName, "Key Vault name should match")
+		// Verify the Key Vault exists in Azure
+		exists, err := fixtures.Checker(t).KeyVaultExists(context.Background(), kvOptions.Vars["resource_group_name"].(string), keyVaultName)
+		require.NoError(t, err)
+		assert.True(t, exists, "Key Vault should exist in Azure")
+	})
 }
 
 // TestKeyVaultModuleWithSecrets tests key vault with stored secrets
 func TestKeyVaultModuleWithSecrets(t *testing.T) {
 	t.Parallel()
 
-	uniqueID := strings.ToLower(random.UniqueId())
-	resourceGroupName := fmt.Sprintf("test-kv-sec-rg-%s", uniqueID)
-	keyVaultName := fmt.Sprintf("testkvsec%s", uniqueID)
-	location := "eastus"
-
-	// Create resource group
-	rgOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/resource-group",
-		Vars: map[string]interface{}{
-			"resource_group_name": resourceGroupName,
-			"location":            location,
-			"tags":                map[string]string{"Environment": "test"},
-		},
-	})
-
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
-
-	// Create key vault with secrets
-	kvOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/key-vault",
-		Vars: map[string]interface{}{
-			"key_vault_name":              keyVaultName,
-			"resource_group_name":         resourceGroupName,
-			"location":                    location,
-			"sku_name":                    "standard",
-			"soft_delete_retention_days":  7,
-			"purge_protection_enabled":    false,
-			"store_db_credentials":        true,
-			"db_admin_username":           "testadmin",
-			"db_admin_password":           "TestP@ssw0rd123!",
-			"store_dockerhub_credentials": true,
-			"dockerhub_username":          "testuser",
-			"dockerhub_password":          "testpassword",
-			"network_acls_default_action": "Allow",
-			"allowed_ip_ranges":           []string{},
-			"allowed_subnet_ids":          []string{},
-			"tags":                        map[string]string{"Environment": "test"},
-		},
+	fixtures.Setup(t, func() {
+		names := naming.For(t)
+
+		// Create resource group
+		rg := fixtures.NewResourceGroup(t, names.Name(naming.ResourceGroup, "test-kv-sec-rg"), "eastus")
+
+		// Create key vault with secrets
+		kvVars := tfvars.NewKeyVaultVars()
+		kvVars.KeyVaultName = names.Name(naming.KeyVault, "testkvsec")
+		kvVars.ResourceGroupName = rg.Name
+		kvVars.Location = rg.Location
+		kvVars.StoreDbCredentials = true
+		kvVars.DbAdminUsername = "testadmin"
+		kvVars.DbAdminPassword = "TestP@ssw0rd123!"
+		kvVars.StoreDockerhubCredentials = true
+		kvVars.DockerhubUsername = "testuser"
+		kvVars.DockerhubPassword = "testpassword"
+		kvVars.Tags = fixtures.Tags(t)
+
+		kvOptions := kvVars.ToOptions(t)
+
+		// Both toggles add two secrets each
+		assert.Subset(t, fixtures.Planned(t, kvOptions), []string{
+			"azurerm_key_vault_secret.db_admin_username[0]",
+			"azurerm_key_vault_secret.db_admin_password[0]",
+			"azurerm_key_vault_secret.dockerhub_username[0]",
+			"azurerm_key_vault_secret.dockerhub_password[0]",
+		})
+
+		fixtures.Apply(t, kvOptions)
 	})
 
-	defer terraform.Destroy(t, kvOptions)
-	terraform.InitAndApply(t, kvOptions)
+	fixtures.Validate(t, func() {
+		kvOptions := fixtures.LoadOptions(t, "key-vault")
 
-	outputID := terraform.Output(t, kvOptions, "id")
-	assert.NotEmpty(t, outputID, "Key Vault ID should not be empty")
+		outputID := terraform.Output(t, kvOptions, "key_vault_id")
+		assert.NotEmpty(t, outputID, "Key Vault ID should not be empty")
+	})
 }
@@ -0,0 +1,337 @@
+// Package mocks checks that the mock_outputs of Terragrunt dependency blocks
+// look like the outputs they stand in for, and generates ones that do.
+//
+// Plans run with mocks until the dependency is applied, so a mock such as
+// "mock-subnet-id" lets a plan pass that the real subnet ID would fail, or
+// hides a module that splits an ID. The expected shape of each output is
+// inferred from the resource attribute the producing module's output reads:
+// an ARM resource ID of the right type for id, a GUID for tenant_id or
+// workspace_id, an FQDN, a location or an IP address.
+package mocks
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Dependency is a dependency block of a unit together with what the outputs
+// it mocks or reads look like
+type Dependency struct {
+	// Unit is the unit's terragrunt.hcl relative to the repository root
+	Unit string
+	Name string
+	// Module is the module the dependency applies, nil when it does not
+	// resolve; tgcontract reports that case
+	Module *tfmodule.Module
+	// Mocks are the entries of mock_outputs in the order written
+	Mocks []Mock
+	// Outputs are the outputs mocked or read by the unit, mocked ones first
+	Outputs []string
+	// Range is the block's header; MockRange the mock_outputs value, empty
+	// when there is none
+	Range     hcl.Range
+	MockRange hcl.Range
+	// ConfigPath is the config_path expression as written
+	ConfigPath string
+	// indent is the indentation of the block's attributes
+	indent string
+}
+
+// Mock is one mock_outputs entry
+type Mock struct {
+	Output string
+	Value  cty.Value
+	// Text is the value as written, empty for generated mocks
+	Text  string
+	Range hcl.Range
+}
+
+// Load parses the dependency blocks of every unit under root/environments.
+// Ranges are relative to root.
+func Load(root string) ([]*Dependency, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+	modules := map[string]*tfmodule.Module{}
+
+	var dependencies []*Dependency
+	for _, path := range paths {
+		unit, err := contract.ParseUnit(root, path)
+		if err != nil {
+			return nil, err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		body, err := contract.ParseConfig(path, unit.Path)
+		if err != nil {
+			return nil, err
+		}
+		ctx := contract.EvalContext(root, unit.Dir)
+
+		for _, block := range body.Blocks {
+			if block.Type != "dependency" || len(block.Labels) == 0 {
+				continue
+			}
+			d := &Dependency{Unit: unit.Path, Name: block.Labels[0], Range: block.DefRange(), indent: "  "}
+			if attr, ok := block.Body.Attributes["config_path"]; ok {
+				d.ConfigPath = string(attr.Expr.Range().SliceBytes(src))
+				d.indent = strings.Repeat(" ", attr.SrcRange.Start.Column-1)
+			}
+			for _, dependency := range unit.Dependencies {
+				if dependency.Name == d.Name {
+					d.Module, err = module(root, dependency.Dir, modules)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			seen := map[string]bool{}
+			if attr, ok := block.Body.Attributes["mock_outputs"]; ok {
+				d.MockRange = attr.Expr.Range()
+				if object, ok := attr.Expr.(*hclsyntax.ObjectConsExpr); ok {
+					for _, item := range object.Items {
+						name := hcl.ExprAsKeyword(item.KeyExpr)
+						if name == "" {
+							continue
+						}
+						value, diags := item.ValueExpr.Value(ctx)
+						if diags.HasErrors() {
+							value = cty.DynamicVal
+						}
+						d.Mocks = append(d.Mocks, Mock{
+							Output: name,
+							Value:  value,
+							Text:   string(item.ValueExpr.Range().SliceBytes(src)),
+							Range:  item.ValueExpr.Range(),
+						})
+						if !seen[name] {
+							seen[name] = true
+							d.Outputs = append(d.Outputs, name)
+						}
+					}
+				}
+			}
+			for _, ref := range unit.OutputRefs {
+				if ref.Dependency == d.Name && !seen[ref.Output] {
+					seen[ref.Output] = true
+					d.Outputs = append(d.Outputs, ref.Output)
+				}
+			}
+			dependencies = append(dependencies, d)
+		}
+	}
+	return dependencies, nil
+}
+
+// module loads the module the unit in dir applies, nil when there is none
+func module(root string, dir string, modules map[string]*tfmodule.Module) (*tfmodule.Module, error) {
+	unit, err := contract.ParseUnit(root, filepath.Join(dir, contract.UnitFileName))
+	if err != nil || unit.ModuleDir == "" {
+		return nil, nil
+	}
+	if m, ok := modules[unit.ModuleDir]; ok {
+		return m, nil
+	}
+	m, err := tfmodule.Load(unit.ModuleDir)
+	if err != nil {
+		return nil, err
+	}
+	modules[unit.ModuleDir] = m
+	return m, nil
+}
+
+// Expect returns the expectation of an output of the dependency
+func (d *Dependency) Expect(output string) Expectation {
+	if d.Module == nil {
+		return Expectation{}
+	}
+	return Infer(d.Module, output)
+}
+
+// Check returns a diagnostic for every mock that does not look like the
+// output it stands in for
+func (d *Dependency) Check() []contract.Diagnostic {
+	var diagnostics []contract.Diagnostic
+	for _, mock := range d.Mocks {
+		expectation := d.Expect(mock.Output)
+		if expectation.Shape == Any || !mock.Value.IsWhollyKnown() {
+			continue
+		}
+		var err error
+		if mock.Value.IsNull() || !mock.Value.Type().Equals(cty.String) {
+			err = fmt.Errorf("is not a string (from %s)", expectation.Source)
+		} else {
+			err = expectation.Check(mock.Value.AsString())
+		}
+		if err != nil {
+			diagnostics = append(diagnostics, contract.Diagnostic{
+				Range:   mock.Range,
+				Message: fmt.Sprintf("dependency %q: mock_outputs.%s %v", d.Name, mock.Output, err),
+			})
+		}
+	}
+	return diagnostics
+}
+
+// Check parses the dependency blocks under root/environments and checks
+// their mocks
+func Check(root string) ([]contract.Diagnostic, error) {
+	dependencies, err := Load(root)
+	if err != nil {
+		return nil, err
+	}
+	var diagnostics []contract.Diagnostic
+	for _, d := range dependencies {
+		diagnostics = append(diagnostics, d.Check()...)
+	}
+	return diagnostics, nil
+}
+
+// Generate returns a mock for every output the unit mocks or reads, in the
+// order of Outputs. Mocks that already look right are kept, the others get
+// a realistic value in location. Outputs of unknown shape keep their mock,
+// or get "mock-<output>".
+func (d *Dependency) Generate(location string) []Mock {
+	existing := map[string]Mock{}
+	for _, mock := range d.Mocks {
+		existing[mock.Output] = mock
+	}
+
+	var mocks []Mock
+	for _, output := range d.Outputs {
+		expectation := d.Expect(output)
+		mock, mocked := existing[output]
+		value := mock.Value
+		switch {
+		case mocked && (expectation.Shape == Any || !value.IsWhollyKnown()):
+		case mocked && !value.IsNull() && value.Type().Equals(cty.String) && expectation.Check(value.AsString()) == nil:
+		case expectation.Shape == Any:
+			mock = Mock{Output: output, Value: cty.StringVal("mock-" + strings.ReplaceAll(output, "_", "-"))}
+		default:
+			name := expectation.Name
+			if name == "main" && d.Module != nil {
+				name = d.Module.Name
+			}
+			mock = Mock{Output: output, Value: cty.StringVal(expectation.Example(name, location))}
+		}
+		mocks = append(mocks, Mock{Output: output, Value: mock.Value, Text: mock.Text})
+	}
+	return mocks
+}
+
+// Block returns the dependency block with the given mocks
+func (d *Dependency) Block(mocks []Mock) string {
+	return fmt.Sprintf("dependency %q {\n  config_path = %s\n\n  mock_outputs = %s\n}\n", d.Name, d.ConfigPath, object(mocks, "  "))
+}
+
+// object writes mocks as an object constructor whose closing brace is
+// indented by indent, aligning the equals signs as terraform fmt does
+func object(mocks []Mock, indent string) string {
+	width := 0
+	for _, mock := range mocks {
+		width = max(width, len(mock.Output))
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, mock := range mocks {
+		value := mock.Text
+		if value == "" {
+			value = strings.TrimSpace(string(hclwrite.TokensForValue(mock.Value).Bytes()))
+		}
+		fmt.Fprintf(&b, "%s  %-*s = %s\n", indent, width, mock.Output, value)
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// Fix rewrites the mock_outputs of the dependencies under root whose mocks
+// do not look right with those Generate returns, leaving the rest of each
+// file as written. It returns the files changed, relative to root.
+func Fix(root string, dependencies []*Dependency, location string) ([]string, error) {
+	byUnit := map[string][]*Dependency{}
+	for _, d := range dependencies {
+		if len(d.Check()) > 0 || len(d.Mocks) < len(d.Outputs) {
+			byUnit[d.Unit] = append(byUnit[d.Unit], d)
+		}
+	}
+	var changed []string
+	for unit := range byUnit {
+		changed = append(changed, unit)
+	}
+	sort.Strings(changed)
+
+	for _, unit := range changed {
+		path := filepath.Join(root, unit)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// rewrite from the end so earlier offsets stay valid
+		fixes := byUnit[unit]
+		sort.Slice(fixes, func(i, j int) bool { return fixes[i].Range.Start.Byte > fixes[j].Range.Start.Byte })
+		for _, d := range fixes {
+			mocks := object(d.Generate(location), d.indent)
+			if d.MockRange.Empty() {
+				src, err = insertMocks(src, d, mocks)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			src = splice(src, d.MockRange.Start.Byte, d.MockRange.End.Byte, mocks)
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return changed, nil
+}
+
+// insertMocks adds a mock_outputs attribute after the config_path line of a
+// dependency block that has none
+func insertMocks(src []byte, d *Dependency, mocks string) ([]byte, error) {
+	file, diags := hclsyntax.ParseConfig(src, d.Unit, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "dependency" || len(block.Labels) == 0 || block.Labels[0] != d.Name {
+			continue
+		}
+		attr, ok := block.Body.Attributes["config_path"]
+		if !ok {
+			break
+		}
+		end := attr.SrcRange.End.Byte
+		if newline := bytes.IndexByte(src[end:], '\n'); newline >= 0 {
+			end += newline
+		}
+		return splice(src, end, end, "\n\n"+d.indent+"mock_outputs = "+mocks), nil
+	}
+	return nil, fmt.Errorf("%s: dependency %q has no config_path", d.Unit, d.Name)
+}
+
+func splice(src []byte, start int, end int, text string) []byte {
+	out := append([]byte(nil), src[:start]...)
+	out = append(out, text...)
+	return append(out, src[end:]...)
+}
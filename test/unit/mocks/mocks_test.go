@@ -0,0 +1,148 @@
+package mocks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repotest"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnvironmentMocksAreRealistic fails when a mock_outputs value under
+// environments/ does not look like the output it stands in for; run
+// go run ./cmd/mockcheck -fix to replace it
+func TestEnvironmentMocksAreRealistic(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	diagnostics, err := Check(root)
+	require.NoError(t, err)
+	for _, diagnostic := range diagnostics {
+		t.Error(diagnostic)
+	}
+}
+
+func TestInfer(t *testing.T) {
+	modules, err := repo.ModulesDir()
+	require.NoError(t, err)
+	networking, err := tfmodule.Load(filepath.Join(modules, "networking"))
+	require.NoError(t, err)
+	resourceGroup, err := tfmodule.Load(filepath.Join(modules, "resource-group"))
+	require.NoError(t, err)
+
+	subnet := Infer(networking, "database_subnet_id")
+	assert.Equal(t, Expectation{Shape: ResourceID, ResourceType: "azurerm_subnet", Source: "azurerm_subnet.database.id", Name: "database"}, subnet)
+	assert.Equal(t, Location, Infer(resourceGroup, "resource_group_location").Shape)
+	assert.Equal(t, Any, Infer(resourceGroup, "resource_group_name").Shape)
+	assert.Equal(t, Any, Infer(networking, "no_such_output").Shape)
+
+	id := subnet.Example("database", "eastus")
+	assert.Equal(t, "/subscriptions/"+Subscription+"/resourceGroups/mock-rg/providers/Microsoft.Network/virtualNetworks/mock-virtualnetwork/subnets/mock-database", id)
+	assert.NoError(t, subnet.Check(id))
+
+	for value, problem := range map[string]string{
+		"mock-subnet-id": `"mock-subnet-id" is not an ARM resource ID (/subscriptions/<GUID>/resourceGroups/<name>/...) (from azurerm_subnet.database.id)`,
+		"/subscriptions/" + Subscription + "/resourceGroups/rg/providers/Microsoft.Network/networkSecurityGroups/nsg": `"/subscriptions/` + Subscription +
+			`/resourceGroups/rg/providers/Microsoft.Network/networkSecurityGroups/nsg" is the ID of a Microsoft.Network/networkSecurityGroups, not a Microsoft.Network/virtualNetworks/subnets (from azurerm_subnet.database.id)`,
+		"/subscriptions/mock/resourceGroups/rg": `"/subscriptions/mock/resourceGroups/rg" is not an ARM resource ID (/subscriptions/<GUID>/resourceGroups/<name>/...) (from azurerm_subnet.database.id)`,
+	} {
+		assert.EqualError(t, subnet.Check(value), problem)
+	}
+
+	for shape, values := range map[Shape][2]string{
+		GUID:      {"7d3e2a1c-51b4-4f0e-9a33-2c1d5e6f7a8b", "mock-workspace-id"},
+		FQDN:      {"sql-gogs.database.windows.net", "mock-fqdn"},
+		Location:  {"westeurope", "West Europe"},
+		IPAddress: {"10.0.3.4", "10.0.3"},
+		URL:       {"https://kv-gogs.vault.azure.net/", "kv-gogs.vault.azure.net"},
+	} {
+		expectation := Expectation{Shape: shape}
+		assert.NoError(t, expectation.Check(values[0]), shape)
+		assert.Error(t, expectation.Check(values[1]), shape)
+	}
+}
+
+func TestFix(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "dev", "resource-group", "resource-group", "")
+	repotest.WriteUnit(t, root, "dev", "networking", "networking", "")
+	path := repotest.WriteUnit(t, root, "dev", "vm", "virtual-machine", `
+dependency "resource_group" {
+  config_path = "../resource-group"
+}
+
+dependency "networking" {
+    config_path = "../networking"
+    mock_outputs = { vm_subnet_id = "mock-subnet-id", vm_nsg_id = 42 }
+}
+
+inputs = {
+  location            = dependency.resource_group.outputs.resource_group_location
+  resource_group_name = dependency.resource_group.outputs.resource_group_name
+  subnet_id           = dependency.networking.outputs.vm_subnet_id
+  nsg_id = dependency.networking.outputs.vm_nsg_id
+}
+`)
+
+	dependencies, err := Load(root)
+	require.NoError(t, err)
+	var diagnostics []string
+	for _, d := range dependencies {
+		for _, diagnostic := range d.Check() {
+			diagnostics = append(diagnostics, diagnostic.String())
+		}
+	}
+	assert.Equal(t, []string{
+		`environments/dev/vm/terragrunt.hcl:11:37: dependency "networking": mock_outputs.vm_subnet_id "mock-subnet-id" is not an ARM resource ID (/subscriptions/<GUID>/resourceGroups/<name>/...) (from azurerm_subnet.vm.id)`,
+		`environments/dev/vm/terragrunt.hcl:11:67: dependency "networking": mock_outputs.vm_nsg_id is not a string (from azurerm_network_security_group.vm.id)`,
+	}, diagnostics)
+
+	changed, err := Fix(root, dependencies, "westeurope")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environments/dev/vm/terragrunt.hcl"}, changed)
+
+	fixed, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `terraform {
+  source = "${get_repo_root()}/modules/virtual-machine"
+}
+
+dependency "resource_group" {
+  config_path = "../resource-group"
+
+  mock_outputs = {
+    resource_group_location = "westeurope"
+    resource_group_name     = "mock-resource-group-name"
+  }
+}
+
+dependency "networking" {
+    config_path = "../networking"
+    mock_outputs = {
+      vm_subnet_id = "/subscriptions/`+Subscription+`/resourceGroups/mock-rg/providers/Microsoft.Network/virtualNetworks/mock-virtualnetwork/subnets/mock-vm"
+      vm_nsg_id    = "/subscriptions/`+Subscription+`/resourceGroups/mock-rg/providers/Microsoft.Network/networkSecurityGroups/mock-vm"
+    }
+}
+
+inputs = {
+  location            = dependency.resource_group.outputs.resource_group_location
+  resource_group_name = dependency.resource_group.outputs.resource_group_name
+  subnet_id           = dependency.networking.outputs.vm_subnet_id
+  nsg_id = dependency.networking.outputs.vm_nsg_id
+}
+`, string(fixed), "only mock_outputs is rewritten")
+
+	dependencies, err = Load(root)
+	require.NoError(t, err)
+	for _, d := range dependencies {
+		assert.Empty(t, d.Check())
+		assert.Len(t, d.Mocks, len(d.Outputs), d.Name)
+	}
+	changed, err = Fix(root, dependencies, "westeurope")
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
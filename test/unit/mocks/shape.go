@@ -0,0 +1,255 @@
+package mocks
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Shape is the form of a value an output produces
+type Shape string
+
+const (
+	// Any is an output whose shape is not known, such as a name
+	Any        Shape = ""
+	ResourceID Shape = "ARM resource ID"
+	GUID       Shape = "GUID"
+	FQDN       Shape = "FQDN"
+	Location   Shape = "Azure location"
+	IPAddress  Shape = "IP address"
+	URL        Shape = "URL"
+)
+
+// attributeShapes maps provider attributes to the shape of their values
+var attributeShapes = map[string]Shape{
+	"id":                          ResourceID,
+	"tenant_id":                   GUID,
+	"principal_id":                GUID,
+	"client_id":                   GUID,
+	"object_id":                   GUID,
+	"workspace_id":                GUID,
+	"fqdn":                        FQDN,
+	"fully_qualified_domain_name": FQDN,
+	"location":                    Location,
+	"ip_address":                  IPAddress,
+	"private_ip_address":          IPAddress,
+	"vault_uri":                   URL,
+}
+
+// ARMTypes maps the resource types the modules create to the ARM resource
+// type in their IDs. The resource group has no provider segment.
+var ARMTypes = map[string]string{
+	"azurerm_resource_group":          "Microsoft.Resources/resourceGroups",
+	"azurerm_virtual_network":         "Microsoft.Network/virtualNetworks",
+	"azurerm_subnet":                  "Microsoft.Network/virtualNetworks/subnets",
+	"azurerm_network_security_group":  "Microsoft.Network/networkSecurityGroups",
+	"azurerm_network_interface":       "Microsoft.Network/networkInterfaces",
+	"azurerm_public_ip":               "Microsoft.Network/publicIPAddresses",
+	"azurerm_log_analytics_workspace": "Microsoft.OperationalInsights/workspaces",
+	"azurerm_key_vault":               "Microsoft.KeyVault/vaults",
+	"azurerm_mssql_server":            "Microsoft.Sql/servers",
+	"azurerm_mssql_database":          "Microsoft.Sql/servers/databases",
+	"azurerm_container_group":         "Microsoft.ContainerInstance/containerGroups",
+	"azurerm_linux_virtual_machine":   "Microsoft.Compute/virtualMachines",
+}
+
+// Locations are the Azure region names accepted as locations
+var Locations = map[string]bool{}
+
+func init() {
+	for _, location := range strings.Fields(`
+		australiacentral australiacentral2 australiaeast australiasoutheast
+		brazilsouth brazilsoutheast canadacentral canadaeast centralindia
+		centralus eastasia eastus eastus2 francecentral francesouth
+		germanynorth germanywestcentral israelcentral italynorth japaneast
+		japanwest jioindiacentral jioindiawest koreacentral koreasouth
+		mexicocentral northcentralus northeurope norwayeast norwaywest
+		polandcentral qatarcentral southafricanorth southafricawest
+		southcentralus southindia southeastasia spaincentral swedencentral
+		switzerlandnorth switzerlandwest uaecentral uaenorth uksouth ukwest
+		westcentralus westeurope westindia westus westus2 westus3`) {
+		Locations[location] = true
+	}
+}
+
+// Expectation is what a dependency output looks like once applied
+type Expectation struct {
+	Shape Shape `json:"shape,omitempty"`
+	// ResourceType is the azurerm resource type the output reads, e.g.
+	// azurerm_subnet
+	ResourceType string `json:"resource_type,omitempty"`
+	// Source is the attribute the shape is inferred from, e.g.
+	// azurerm_subnet.database.id
+	Source string `json:"source,omitempty"`
+	// Name is the Terraform name of the resource, e.g. database
+	Name string `json:"-"`
+}
+
+// Infer returns the expectation of a module output from the resource
+// attribute its value reads. Outputs that are not a plain attribute, or a
+// conditional choosing between one and null, are Any.
+func Infer(module *tfmodule.Module, output string) Expectation {
+	declared, ok := module.Outputs[output]
+	if !ok {
+		return Expectation{}
+	}
+	expr := declared.Value
+	if conditional, ok := expr.(*hclsyntax.ConditionalExpr); ok {
+		switch {
+		case isNull(conditional.FalseResult):
+			expr = conditional.TrueResult
+		case isNull(conditional.TrueResult):
+			expr = conditional.FalseResult
+		}
+	}
+	traversal, ok := expr.(*hclsyntax.ScopeTraversalExpr)
+	if !ok {
+		return Expectation{}
+	}
+
+	var parts []string
+	for _, step := range traversal.Traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			parts = append(parts, step.Name)
+		case hcl.TraverseAttr:
+			parts = append(parts, step.Name)
+		}
+	}
+	if len(parts) != 3 || parts[0] == "var" || parts[0] == "local" || parts[0] == "data" {
+		return Expectation{}
+	}
+	shape := attributeShapes[parts[2]]
+	if shape == Any {
+		return Expectation{}
+	}
+	return Expectation{Shape: shape, ResourceType: parts[0], Source: strings.Join(parts, "."), Name: parts[1]}
+}
+
+func isNull(expr hclsyntax.Expression) bool {
+	value, diags := expr.Value(nil)
+	return !diags.HasErrors() && value.IsNull()
+}
+
+var guid = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var hostname = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// Check returns an error describing how value does not have the expected
+// shape
+func (e Expectation) Check(value string) error {
+	var problem string
+	switch e.Shape {
+	case ResourceID:
+		problem = checkResourceID(value, ARMTypes[e.ResourceType])
+	case GUID:
+		if !guid.MatchString(value) {
+			problem = "is not a GUID"
+		}
+	case FQDN:
+		if !hostname.MatchString(value) {
+			problem = "is not a fully qualified domain name"
+		}
+	case Location:
+		if !Locations[value] {
+			problem = "is not an Azure location name such as eastus"
+		}
+	case IPAddress:
+		if _, err := netip.ParseAddr(value); err != nil {
+			problem = "is not an IP address"
+		}
+	case URL:
+		if u, err := url.Parse(value); err != nil || u.Scheme != "https" || !hostname.MatchString(u.Host) {
+			problem = "is not an https URL"
+		}
+	}
+	if problem == "" {
+		return nil
+	}
+	return fmt.Errorf("%q %s (from %s)", value, problem, e.Source)
+}
+
+// checkResourceID describes how id is not an ARM resource ID of armType
+func checkResourceID(id string, armType string) string {
+	segments := strings.Split(id, "/")
+	if len(segments) < 5 || segments[0] != "" || !strings.EqualFold(segments[1], "subscriptions") || !guid.MatchString(segments[2]) || !strings.EqualFold(segments[3], "resourceGroups") || segments[4] == "" {
+		return "is not an ARM resource ID (/subscriptions/<GUID>/resourceGroups/<name>/...)"
+	}
+	actual := "Microsoft.Resources/resourceGroups"
+	if len(segments) > 5 {
+		rest := segments[5:]
+		if !strings.EqualFold(rest[0], "providers") || len(rest) < 4 || len(rest)%2 != 0 {
+			return "is not an ARM resource ID (/subscriptions/<GUID>/resourceGroups/<name>/providers/<namespace>/<type>/<name>)"
+		}
+		types := []string{rest[1]}
+		for i := 2; i < len(rest); i += 2 {
+			if rest[i+1] == "" {
+				return "is not an ARM resource ID: a resource name is empty"
+			}
+			types = append(types, rest[i])
+		}
+		actual = strings.Join(types, "/")
+	}
+	if armType != "" && !strings.EqualFold(actual, armType) {
+		return fmt.Sprintf("is the ID of a %s, not a %s", actual, armType)
+	}
+	return ""
+}
+
+// Subscription is the subscription ID generated resource IDs use
+const Subscription = "00000000-0000-0000-0000-000000000000"
+
+// Example returns a realistic value of the expected shape. name is the
+// Terraform name of the resource, e.g. database for azurerm_subnet.database,
+// and location the location to use in IDs and host names.
+func (e Expectation) Example(name string, location string) string {
+	switch e.Shape {
+	case ResourceID:
+		return exampleResourceID(ARMTypes[e.ResourceType], name)
+	case GUID:
+		return Subscription
+	case FQDN:
+		switch e.ResourceType {
+		case "azurerm_mssql_server":
+			return "mock-" + name + ".database.windows.net"
+		case "azurerm_container_group":
+			return "mock-" + name + "." + location + ".azurecontainer.io"
+		}
+		return "mock-" + name + ".example.com"
+	case Location:
+		return location
+	case IPAddress:
+		return "10.0.0.4"
+	case URL:
+		if e.ResourceType == "azurerm_key_vault" {
+			return "https://mock-" + name + ".vault.azure.net/"
+		}
+		return "https://mock-" + name + ".example.com/"
+	}
+	return ""
+}
+
+// exampleResourceID returns the ID of a resource of armType named mock-<name>
+// in resource group mock-rg, with parents named after their type
+func exampleResourceID(armType string, name string) string {
+	id := "/subscriptions/" + Subscription + "/resourceGroups/mock-rg"
+	if armType == "" || armType == "Microsoft.Resources/resourceGroups" {
+		return id
+	}
+	types := strings.Split(armType, "/")
+	id += "/providers/" + types[0]
+	for i, t := range types[1:] {
+		segment := "mock-" + name
+		if i < len(types)-2 {
+			segment = "mock-" + strings.ToLower(strings.TrimSuffix(t, "s"))
+		}
+		id += "/" + t + "/" + segment
+	}
+	return id
+}
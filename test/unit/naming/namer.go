@@ -0,0 +1,119 @@
+package naming
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/require"
+)
+
+// Standard tags stamped on every test resource
+const (
+	TagTestID    = "TestID"
+	TagTestName  = "TestName"
+	TagCreatedAt = "CreatedAt"
+	TagExpiresAt = "ExpiresAt"
+	TagManagedBy = "ManagedBy"
+
+	// ManagedBy is the value of the ManagedBy tag
+	ManagedBy = "terratest"
+)
+
+// DefaultTTL is how long test resources are expected to live. A janitor may
+// delete anything tagged ManagedBy=terratest once its ExpiresAt has passed.
+const DefaultTTL = 6 * time.Hour
+
+// TTLEnvVar overrides DefaultTTL with a Go duration such as "48h", for
+// example to keep resources deployed with SKIP_teardown for a few days
+const TTLEnvVar = "TERRATEST_TTL"
+
+// maxTagValue is the Azure limit on tag value length
+const maxTagValue = 256
+
+// Namer builds the names and tags of one test's resources
+type Namer struct {
+	t         *testing.T
+	uniqueID  string
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+var (
+	namersMu sync.Mutex
+	namers   = map[*testing.T]*Namer{}
+)
+
+// For returns the Namer of the given test, creating it on first use. Every
+// caller within a test, including fixtures, shares the same unique ID and
+// timestamps.
+func For(t *testing.T) *Namer {
+	t.Helper()
+
+	namersMu.Lock()
+	defer namersMu.Unlock()
+
+	if n, ok := namers[t]; ok {
+		return n
+	}
+
+	ttl := DefaultTTL
+	if value := os.Getenv(TTLEnvVar); value != "" {
+		parsed, err := time.ParseDuration(value)
+		require.NoError(t, err, "%s must be a Go duration such as 48h", TTLEnvVar)
+		ttl = parsed
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	n := &Namer{
+		t:         t,
+		uniqueID:  strings.ToLower(random.UniqueId()),
+		createdAt: createdAt,
+		expiresAt: createdAt.Add(ttl),
+	}
+	namers[t] = n
+	t.Cleanup(func() {
+		namersMu.Lock()
+		defer namersMu.Unlock()
+		delete(namers, t)
+	})
+	return n
+}
+
+// UniqueID returns the random suffix shared by the test's resources
+func (n *Namer) UniqueID() string {
+	return n.uniqueID
+}
+
+// Name returns prefix joined with the test's unique ID, and fails the test
+// immediately when the result breaks the rule
+func (n *Namer) Name(rule Rule, prefix string) string {
+	n.t.Helper()
+
+	name, err := Build(rule, prefix, n.uniqueID)
+	require.NoError(n.t, err, "choose a shorter or valid prefix for the %s", rule.Resource)
+	return name
+}
+
+// Tags returns extra with the standard tags added. Standard tags win over
+// entries of the same name in extra, which is not modified.
+func (n *Namer) Tags(extra map[string]string) map[string]string {
+	tags := make(map[string]string, len(extra)+5)
+	for key, value := range extra {
+		tags[key] = value
+	}
+
+	testName := n.t.Name()
+	if len(testName) > maxTagValue {
+		testName = testName[:maxTagValue]
+	}
+	tags[TagTestID] = n.uniqueID
+	tags[TagTestName] = testName
+	tags[TagCreatedAt] = n.createdAt.Format(time.RFC3339)
+	tags[TagExpiresAt] = n.expiresAt.Format(time.RFC3339)
+	tags[TagManagedBy] = ManagedBy
+	return tags
+}
@@ -0,0 +1,183 @@
+// Package naming builds Azure resource names and tags for the tests.
+//
+// Azure rules differ per resource type: a Key Vault name is 3-24 characters,
+// a SQL server name must be lower case, a DNS label must start with a letter,
+// and so on. Breaking one of them is only reported by the Azure API, minutes
+// into an apply. A Namer joins a prefix and the test's unique ID, checks the
+// result against the Rule for its resource type and fails the test before
+// anything is created when the name cannot fit.
+//
+// The same Namer stamps the standard tags (TestID, TestName, CreatedAt,
+// ExpiresAt and ManagedBy=terratest) used to trace a resource back to its
+// test and to clean up whatever a failed run leaves behind.
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Rule describes the names Azure accepts for one resource type
+type Rule struct {
+	// Resource is the resource type, used in error messages
+	Resource  string
+	MinLength int
+	MaxLength int
+	// Extra lists the characters allowed besides ASCII letters and digits
+	Extra string
+	// Lowercase rejects upper case letters
+	Lowercase bool
+	// StartLetter requires the name to start with a letter
+	StartLetter bool
+	// NoStart and NoEnd list characters the name may not start or end with
+	NoStart string
+	NoEnd   string
+	// NoRepeat lists characters that may not appear twice in a row
+	NoRepeat string
+	// Separator joins the prefix and the unique ID
+	Separator string
+}
+
+// Rules for the resource types created by the modules under modules/
+var (
+	ResourceGroup = Rule{
+		Resource:  "resource group",
+		MinLength: 1,
+		MaxLength: 90,
+		Extra:     "-_.()",
+		NoEnd:     ".",
+		Separator: "-",
+	}
+	KeyVault = Rule{
+		Resource:    "key vault",
+		MinLength:   3,
+		MaxLength:   24,
+		Extra:       "-",
+		StartLetter: true,
+		NoEnd:       "-",
+		NoRepeat:    "-",
+		Separator:   "-",
+	}
+	SQLServer = Rule{
+		Resource:  "SQL server",
+		MinLength: 1,
+		MaxLength: 63,
+		Extra:     "-",
+		Lowercase: true,
+		NoStart:   "-",
+		NoEnd:     "-",
+		Separator: "-",
+	}
+	SQLDatabase = Rule{
+		Resource:  "SQL database",
+		MinLength: 1,
+		MaxLength: 128,
+		Extra:     "-_.",
+		NoEnd:     ".",
+		Separator: "-",
+	}
+	LogAnalyticsWorkspace = Rule{
+		Resource:  "Log Analytics workspace",
+		MinLength: 4,
+		MaxLength: 63,
+		Extra:     "-",
+		NoStart:   "-",
+		NoEnd:     "-",
+		Separator: "-",
+	}
+	VirtualNetwork = Rule{
+		Resource:  "virtual network",
+		MinLength: 2,
+		MaxLength: 64,
+		Extra:     "-_.",
+		NoStart:   "-_.",
+		NoEnd:     "-.",
+		Separator: "-",
+	}
+	ContainerGroup = Rule{
+		Resource:  "container group",
+		MinLength: 1,
+		MaxLength: 63,
+		Extra:     "-",
+		Lowercase: true,
+		NoStart:   "-",
+		NoEnd:     "-",
+		NoRepeat:  "-",
+		Separator: "-",
+	}
+	// DNSLabel applies to container group and public IP DNS name labels
+	DNSLabel = Rule{
+		Resource:    "DNS label",
+		MinLength:   3,
+		MaxLength:   63,
+		Extra:       "-",
+		Lowercase:   true,
+		StartLetter: true,
+		NoEnd:       "-",
+		Separator:   "-",
+	}
+	// VirtualMachine applies to Linux VM names, which Azure also uses as the
+	// computer name since the module does not set one
+	VirtualMachine = Rule{
+		Resource:  "virtual machine",
+		MinLength: 1,
+		MaxLength: 64,
+		Extra:     "-",
+		NoStart:   "-",
+		NoEnd:     "-",
+		Separator: "-",
+	}
+)
+
+// Build joins prefix and uniqueID with the rule's separator and validates the
+// result. Prefixes are lowercased for rules that only accept lower case; a
+// name that is still invalid is an error rather than being truncated, since
+// shortening it would weaken the unique ID.
+func Build(rule Rule, prefix string, uniqueID string) (string, error) {
+	if rule.Lowercase {
+		prefix = strings.ToLower(prefix)
+	}
+
+	name := uniqueID
+	if prefix != "" {
+		name = prefix + rule.Separator + uniqueID
+	}
+	if err := rule.Validate(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Validate reports why Azure would reject name for this resource type
+func (r Rule) Validate(name string) error {
+	if n := len(name); n < r.MinLength || n > r.MaxLength {
+		return fmt.Errorf("%s name %q is %d characters, must be %d-%d", r.Resource, name, n, r.MinLength, r.MaxLength)
+	}
+
+	for i, c := range name {
+		switch {
+		case c > unicode.MaxASCII:
+			return fmt.Errorf("%s name %q contains non-ASCII character %q", r.Resource, name, c)
+		case unicode.IsUpper(c) && r.Lowercase:
+			return fmt.Errorf("%s name %q must be lower case", r.Resource, name)
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+		case !strings.ContainsRune(r.Extra, c):
+			return fmt.Errorf("%s name %q contains %q; only letters, digits and %q are allowed", r.Resource, name, c, r.Extra)
+		case i > 0 && strings.ContainsRune(r.NoRepeat, c) && rune(name[i-1]) == c:
+			return fmt.Errorf("%s name %q contains consecutive %q", r.Resource, name, c)
+		}
+	}
+
+	first, last := rune(name[0]), rune(name[len(name)-1])
+	if r.StartLetter && !unicode.IsLetter(first) {
+		return fmt.Errorf("%s name %q must start with a letter", r.Resource, name)
+	}
+	if strings.ContainsRune(r.NoStart, first) {
+		return fmt.Errorf("%s name %q must not start with %q", r.Resource, name, first)
+	}
+	if strings.ContainsRune(r.NoEnd, last) {
+		return fmt.Errorf("%s name %q must not end with %q", r.Resource, name, last)
+	}
+	return nil
+}
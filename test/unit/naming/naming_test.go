@@ -0,0 +1,78 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	cases := []struct {
+		rule   Rule
+		prefix string
+		want   string
+		err    string
+	}{
+		{rule: ResourceGroup, prefix: "test-kv-sec-rg", want: "test-kv-sec-rg-abc123"},
+		{rule: KeyVault, prefix: "testkvsec", want: "testkvsec-abc123"},
+		{rule: KeyVault, prefix: "test-keyvault-secrets", err: "is 28 characters, must be 3-24"},
+		{rule: KeyVault, prefix: "1kv", err: "must start with a letter"},
+		{rule: KeyVault, prefix: "kv-", err: `consecutive '-'`},
+		{rule: SQLServer, prefix: "TestSQL", want: "testsql-abc123"},
+		{rule: SQLServer, prefix: "test_sql", err: `contains '_'`},
+		{rule: SQLServer, prefix: "-sql", err: `must not start with '-'`},
+		{rule: DNSLabel, prefix: "Test-ACI-Env", want: "test-aci-env-abc123"},
+		{rule: DNSLabel, prefix: "9aci", err: "must start with a letter"},
+		{rule: ContainerGroup, prefix: "test.aci", err: `contains '.'`},
+		{rule: VirtualNetwork, prefix: "_vnet", err: `must not start with '_'`},
+		{rule: LogAnalyticsWorkspace, prefix: "", want: "abc123"},
+		{rule: VirtualMachine, prefix: strings.Repeat("v", 60), err: "is 67 characters, must be 1-64"},
+	}
+	for _, c := range cases {
+		name, err := Build(c.rule, c.prefix, "abc123")
+		if c.err != "" {
+			require.Error(t, err, "%s %q", c.rule.Resource, c.prefix)
+			assert.Contains(t, err.Error(), c.err)
+			continue
+		}
+		require.NoError(t, err, "%s %q", c.rule.Resource, c.prefix)
+		assert.Equal(t, c.want, name)
+	}
+}
+
+func TestValidateRejectsUpperCaseWhereRequired(t *testing.T) {
+	assert.NoError(t, KeyVault.Validate("TestKV-abc123"))
+	assert.ErrorContains(t, SQLServer.Validate("TestSQL-abc123"), "must be lower case")
+	assert.ErrorContains(t, ResourceGroup.Validate("rg."), `must not end with '.'`)
+	assert.ErrorContains(t, DNSLabel.Validate("ab"), "is 2 characters, must be 3-63")
+}
+
+func TestNamerSharesIDAndTagsWithinATest(t *testing.T) {
+	t.Setenv(TTLEnvVar, "2h")
+
+	n := For(t)
+	require.Same(t, n, For(t))
+
+	assert.Equal(t, "testkv-"+n.UniqueID(), n.Name(KeyVault, "testkv"))
+
+	extra := map[string]string{"Environment": "test", TagManagedBy: "someone"}
+	tags := n.Tags(extra)
+	assert.Equal(t, "someone", extra[TagManagedBy], "extra tags must not be modified")
+	assert.Equal(t, "test", tags["Environment"])
+	assert.Equal(t, ManagedBy, tags[TagManagedBy])
+	assert.Equal(t, n.UniqueID(), tags[TagTestID])
+	assert.Equal(t, t.Name(), tags[TagTestName])
+
+	createdAt, err := time.Parse(time.RFC3339, tags[TagCreatedAt])
+	require.NoError(t, err)
+	expiresAt, err := time.Parse(time.RFC3339, tags[TagExpiresAt])
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, expiresAt.Sub(createdAt))
+
+	t.Run("subtest", func(t *testing.T) {
+		assert.NotSame(t, n, For(t), "each test gets its own unique ID")
+	})
+}
@@ -1,118 +1,115 @@
 package test
 
 import (
-	"fmt"
-	"strings"
+	"context"
+	"path"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/azure"
-	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/cidr"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/fixtures"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestNetworkingModule tests the networking module
 func TestNetworkingModule(t *testing.T) {
 	t.Parallel()
 
-	uniqueID := strings.ToLower(random.UniqueId())
-	resourceGroupName := fmt.Sprintf("test-net-rg-%s", uniqueID)
-	vnetName := fmt.Sprintf("test-vnet-%s", uniqueID)
-	location := "eastus"
-
-	// Create resource group first
-	rgOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/resource-group",
-		Vars: map[string]interface{}{
-			"resource_group_name": resourceGroupName,
-			"location":            location,
-			"tags":                map[string]string{"Environment": "test"},
-		},
+	fixtures.Setup(t, func() {
+		names := naming.For(t)
+
+		// Create resource group first
+		rg := fixtures.NewResourceGroup(t, names.Name(naming.ResourceGroup, "test-net-rg"), "eastus")
+
+		// Create networking resources
+		netVars := tfvars.NewNetworkingVars()
+		netVars.VnetName = names.Name(naming.VirtualNetwork, "test-vnet")
+		netVars.ResourceGroupName = rg.Name
+		netVars.Location = rg.Location
+		netVars.AddressSpace = []string{"10.0.0.0/16"}
+		netVars.ContainerSubnetPrefix = "10.0.1.0/24"
+		netVars.DatabaseSubnetPrefix = "10.0.2.0/24"
+		netVars.VMSubnetPrefix = "10.0.3.0/24"
+		netVars.AdminIPRange = "0.0.0.0/0"
+		netVars.Tags = fixtures.Tags(t)
+
+		netOptions := netVars.ToOptions(t)
+		fixtures.Apply(t, netOptions)
 	})
 
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
-
-	// Create networking resources
-	netOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/networking",
-		Vars: map[string]interface{}{
-			"vnet_name":               vnetName,
-			"resource_group_name":     resourceGroupName,
-			"location":                location,
-			"address_space":           []string{"10.0.0.0/16"},
-			"container_subnet_prefix": "10.0.1.0/24",
-			"database_subnet_prefix":  "10.0.2.0/24",
-			"vm_subnet_prefix":        "10.0.3.0/24",
-			"admin_ip_range":          "0.0.0.0/0",
-			"tags":                    map[string]string{"Environment": "test"},
-		},
+	fixtures.Validate(t, func() {
+		netOptions := fixtures.LoadOptions(t, "networking")
+		vnetName := netOptions.Vars["vnet_name"].(string)
+		resourceGroupName := netOptions.Vars["resource_group_name"].(string)
+
+		// Validate outputs
+		vnetID := terraform.Output(t, netOptions, "vnet_id")
+		containerSubnetID := terraform.Output(t, netOptions, "container_subnet_id")
+		databaseSubnetID := terraform.Output(t, netOptions, "database_subnet_id")
+		vmSubnetID := terraform.Output(t, netOptions, "vm_subnet_id")
+		vmNsgID := terraform.Output(t, netOptions, "vm_nsg_id")
+
+		// Assertions
+		assert.NotEmpty(t, vnetID, "VNet ID should not be empty")
+		assert.NotEmpty(t, containerSubnetID, "Container subnet ID should not be empty")
+		assert.NotEmpty(t, databaseSubnetID, "Database subnet ID should not be empty")
+		assert.NotEmpty(t, vmSubnetID, "VM subnet ID should not be empty")
+		assert.NotEmpty(t, vmNsgID, "VM NSG ID should not be empty")
+
+		// Verify the VNet, its subnets and the VM NSG exist in Azure
+		check := fixtures.Checker(t)
+		ctx := context.Background()
+		exists, err := check.VirtualNetworkExists(ctx, resourceGroupName, vnetName)
+		require.NoError(t, err)
+		assert.True(t, exists, "Virtual network should exist in Azure")
+		for _, subnetID := range []string{containerSubnetID, databaseSubnetID, vmSubnetID} {
+			exists, err := check.SubnetExists(ctx, resourceGroupName, vnetName, path.Base(subnetID))
+			require.NoError(t, err)
+			assert.True(t, exists, "Subnet %s should exist in Azure", subnetID)
+		}
+		exists, err = check.NetworkSecurityGroupExists(ctx, resourceGroupName, path.Base(vmNsgID))
+		require.NoError(t, err)
+		assert.True(t, exists, "VM NSG should exist in Azure")
 	})
-
-	defer terraform.Destroy(t, netOptions)
-	terraform.InitAndApply(t, netOptions)
-
-	// Validate outputs
-	vnetID := terraform.Output(t, netOptions, "vnet_id")
-	containerSubnetID := terraform.Output(t, netOptions, "container_subnet_id")
-	databaseSubnetID := terraform.Output(t, netOptions, "database_subnet_id")
-	vmSubnetID := terraform.Output(t, netOptions, "vm_subnet_id")
-	vmNsgID := terraform.Output(t, netOptions, "vm_nsg_id")
-
-	// Assertions
-	assert.NotEmpty(t, vnetID, "VNet ID should not be empty")
-	assert.NotEmpty(t, containerSubnetID, "Container subnet ID should not be empty")
-	assert.NotEmpty(t, databaseSubnetID, "Database subnet ID should not be empty")
-	assert.NotEmpty(t, vmSubnetID, "VM subnet ID should not be empty")
-	assert.NotEmpty(t, vmNsgID, "VM NSG ID should not be empty")
-
-	// Verify VNet exists in Azure
-	subscriptionID := azure.GetTargetAzureSubscription(t)
-	exists := azure.VirtualNetworkExists(t, vnetName, resourceGroupName, subscriptionID)
-	assert.True(t, exists, "Virtual network should exist in Azure")
 }
 
 // TestNetworkingModuleSubnetConfiguration tests subnet CIDR configurations
 func TestNetworkingModuleSubnetConfiguration(t *testing.T) {
 	t.Parallel()
 
-	uniqueID := strings.ToLower(random.UniqueId())
-	resourceGroupName := fmt.Sprintf("test-net-sub-rg-%s", uniqueID)
-	vnetName := fmt.Sprintf("test-vnet-sub-%s", uniqueID)
-	location := "westus2"
-
-	// Create resource group
-	rgOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/resource-group",
-		Vars: map[string]interface{}{
-			"resource_group_name": resourceGroupName,
-			"location":            location,
-			"tags":                map[string]string{"Environment": "test"},
-		},
-	})
-
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
-
-	// Create networking with custom CIDR ranges
-	netOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/networking",
-		Vars: map[string]interface{}{
-			"vnet_name":               vnetName,
-			"resource_group_name":     resourceGroupName,
-			"location":                location,
-			"address_space":           []string{"172.16.0.0/16"},
-			"container_subnet_prefix": "172.16.10.0/24",
-			"database_subnet_prefix":  "172.16.20.0/24",
-			"vm_subnet_prefix":        "172.16.30.0/24",
-			"admin_ip_range":          "10.0.0.0/8",
-			"tags":                    map[string]string{"Environment": "test"},
-		},
+	fixtures.Setup(t, func() {
+		names := naming.For(t)
+
+		// Create resource group
+		rg := fixtures.NewResourceGroup(t, names.Name(naming.ResourceGroup, "test-net-sub-rg"), "westus2")
+
+		// Create networking with custom CIDR ranges
+		netVars := tfvars.NewNetworkingVars()
+		netVars.VnetName = names.Name(naming.VirtualNetwork, "test-vnet-sub")
+		netVars.ResourceGroupName = rg.Name
+		netVars.Location = rg.Location
+		netVars.AddressSpace = []string{"172.16.0.0/16"}
+		netVars.ContainerSubnetPrefix = "172.16.10.0/24"
+		netVars.DatabaseSubnetPrefix = "172.16.20.0/24"
+		netVars.VMSubnetPrefix = "172.16.30.0/24"
+		netVars.AdminIPRange = "10.0.0.0/8"
+		netVars.Tags = fixtures.Tags(t)
+
+		network, err := cidr.FromVars("test", netVars)
+		require.NoError(t, err)
+		require.Empty(t, cidr.Check(network), "custom subnets must fit the address space without overlapping")
+
+		netOptions := netVars.ToOptions(t)
+		fixtures.Apply(t, netOptions)
 	})
 
-	defer terraform.Destroy(t, netOptions)
-	terraform.InitAndApply(t, netOptions)
+	fixtures.Validate(t, func() {
+		netOptions := fixtures.LoadOptions(t, "networking")
 
-	vnetID := terraform.Output(t, netOptions, "vnet_id")
-	assert.NotEmpty(t, vnetID, "VNet ID should not be empty")
+		vnetID := terraform.Output(t, netOptions, "vnet_id")
+		assert.NotEmpty(t, vnetID, "VNet ID should not be empty")
+	})
 }
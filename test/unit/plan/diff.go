@@ -0,0 +1,184 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/sensitive"
+)
+
+// Values shown in place of ones the plan does not reveal
+const (
+	Masked  = "(sensitive value)"
+	Unknown = "(known after apply)"
+)
+
+// maxValueLength is the length values are shortened to
+const maxValueLength = 60
+
+// AttributeChange is an attribute whose value a change alters. Values are
+// written as JSON, and masked when the plan marks them sensitive or the
+// attribute's name looks like a secret.
+type AttributeChange struct {
+	Path   string `json:"path"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// Forces is set when the attribute forces a replacement
+	Forces bool `json:"forces_replacement,omitempty"`
+}
+
+// Attributes returns the attributes the change alters, in path order. Nested
+// objects and lists are compared element by element.
+func (c Change) Attributes() []AttributeChange {
+	d := differ{replace: c.ReplacePaths}
+	d.walk(nil, decode(c.Before), decode(c.After), decode(c.AfterUnknown), decode(c.BeforeSensitive), decode(c.AfterSensitive))
+	sort.SliceStable(d.changes, func(i, j int) bool { return d.changes[i].Path < d.changes[j].Path })
+	return d.changes
+}
+
+type differ struct {
+	replace [][]any
+	changes []AttributeChange
+}
+
+func (d *differ) walk(path []any, before, after, unknown, beforeSensitive, afterSensitive any) {
+	if unknown == true {
+		d.add(path, before, nil, true, beforeSensitive == true, false)
+		return
+	}
+	beforeObject, beforeIsObject := before.(map[string]any)
+	afterObject, afterIsObject := after.(map[string]any)
+	if beforeIsObject || afterIsObject {
+		if (beforeIsObject || before == nil) && (afterIsObject || after == nil) {
+			unknownObject, _ := unknown.(map[string]any)
+			for _, key := range unionKeys(beforeObject, afterObject, unknownObject) {
+				d.walk(append(path, key), beforeObject[key], afterObject[key], child(unknown, key), child(beforeSensitive, key), child(afterSensitive, key))
+			}
+			return
+		}
+	}
+	beforeList, beforeIsList := before.([]any)
+	afterList, afterIsList := after.([]any)
+	if (beforeIsList || before == nil) && (afterIsList || after == nil) && (beforeIsList || afterIsList) {
+		for i := 0; i < max(len(beforeList), len(afterList)); i++ {
+			d.walk(append(path, i), index(beforeList, i), index(afterList, i), child(unknown, i), child(beforeSensitive, i), child(afterSensitive, i))
+		}
+		return
+	}
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	d.add(path, before, after, false, beforeSensitive == true, afterSensitive == true)
+}
+
+func (d *differ) add(path []any, before, after any, unknown bool, beforeSensitive, afterSensitive bool) {
+	secret := false
+	for _, step := range path {
+		if name, ok := step.(string); ok && sensitive.SecretName.MatchString(name) {
+			secret = true
+		}
+	}
+	change := AttributeChange{
+		Path:   PathString(path),
+		Before: format(before, beforeSensitive || secret),
+		After:  format(after, afterSensitive || secret),
+		Forces: d.forces(path),
+	}
+	if unknown {
+		change.After = Unknown
+	}
+	d.changes = append(d.changes, change)
+}
+
+// forces reports whether path is, or is inside, a path forcing replacement
+func (d *differ) forces(path []any) bool {
+	for _, replace := range d.replace {
+		if len(replace) > len(path) {
+			continue
+		}
+		match := true
+		for i, step := range replace {
+			if PathString([]any{step}) != PathString([]any{path[i]}) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func format(value any, masked bool) string {
+	switch {
+	case value == nil:
+		return "null"
+	case masked:
+		return Masked
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "?"
+	}
+	// cut on a rune, so multi-byte characters are not split
+	if runes := []rune(string(data)); len(runes) > maxValueLength {
+		return string(runes[:maxValueLength-3]) + "..."
+	}
+	return string(data)
+}
+
+func decode(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	return value
+}
+
+// child returns the marker of a key or index inside an after_unknown or
+// sensitive structure, where true marks a whole value
+func child(marker any, step any) any {
+	switch marker := marker.(type) {
+	case bool:
+		return marker
+	case map[string]any:
+		if key, ok := step.(string); ok {
+			return marker[key]
+		}
+	case []any:
+		if i, ok := step.(int); ok {
+			return index(marker, i)
+		}
+	}
+	return nil
+}
+
+func index(list []any, i int) any {
+	if i < len(list) {
+		return list[i]
+	}
+	return nil
+}
+
+func unionKeys(maps ...map[string]any) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,288 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DriftClass is how a resource or an attribute changed outside Terraform
+type DriftClass string
+
+const (
+	// DriftChanged is a resource or attribute whose value was edited
+	DriftChanged DriftClass = "changed"
+	// DriftDeleted is a resource deleted outside Terraform
+	DriftDeleted DriftClass = "deleted"
+	// DriftAdded is an attribute or block element set outside Terraform,
+	// e.g. an NSG rule added in the portal
+	DriftAdded DriftClass = "added"
+	// DriftRemoved is an attribute or block element removed outside
+	// Terraform
+	DriftRemoved DriftClass = "removed"
+)
+
+// AttributeDrift is an attribute whose value changed outside Terraform,
+// from the value Terraform last applied to the one in Azure. Values are
+// written and masked as in AttributeChange.
+type AttributeDrift struct {
+	Path   string     `json:"path"`
+	Class  DriftClass `json:"class"`
+	Before string     `json:"before"`
+	After  string     `json:"after"`
+}
+
+// DriftedResource is a resource changed outside Terraform
+type DriftedResource struct {
+	Address string     `json:"address"`
+	Type    string     `json:"type"`
+	Class   DriftClass `json:"class"`
+	// Attributes are the attributes that changed, none for a deleted resource
+	Attributes []AttributeDrift `json:"attributes"`
+}
+
+// UnitDrift is what the refresh-only plan of one unit found
+type UnitDrift struct {
+	// Unit is the unit's directory relative to the repository root
+	Unit      string            `json:"unit"`
+	Resources []DriftedResource `json:"resources"`
+	// Outputs are the outputs whose value refreshing the state changes.
+	// They are not drift on their own: an output also changes when the
+	// plan's inputs differ from those last applied.
+	Outputs []OutputSummary `json:"outputs"`
+	Errored bool            `json:"errored,omitempty"`
+}
+
+// HasDrift reports whether a resource changed outside Terraform
+func (u *UnitDrift) HasDrift() bool {
+	return len(u.Resources) > 0
+}
+
+// Drift classifies the resource drift of a refresh-only plan of unit by
+// resource and attribute
+func Drift(unit string, p *Plan) *UnitDrift {
+	u := &UnitDrift{Unit: unit, Resources: []DriftedResource{}, Outputs: []OutputSummary{}, Errored: p.Errored}
+	for _, rc := range p.ResourceDrift {
+		resource := DriftedResource{Address: rc.Address, Type: rc.Type, Class: DriftChanged, Attributes: []AttributeDrift{}}
+		switch rc.Change.Action() {
+		case NoOp, Read:
+			continue
+		case Delete:
+			resource.Class = DriftDeleted
+		default:
+			for _, attribute := range rc.Change.Attributes() {
+				resource.Attributes = append(resource.Attributes, AttributeDrift{
+					Path:   attribute.Path,
+					Class:  classify(attribute),
+					Before: attribute.Before,
+					After:  attribute.After,
+				})
+			}
+		}
+		u.Resources = append(u.Resources, resource)
+	}
+	sort.SliceStable(u.Resources, func(i, j int) bool { return u.Resources[i].Address < u.Resources[j].Address })
+
+	for _, name := range sortedKeys(p.OutputChanges) {
+		if action := p.OutputChanges[name].Action(); action != NoOp {
+			u.Outputs = append(u.Outputs, OutputSummary{Name: name, Action: action})
+		}
+	}
+	return u
+}
+
+func classify(attribute AttributeChange) DriftClass {
+	switch {
+	case attribute.Before == "null":
+		return DriftAdded
+	case attribute.After == "null":
+		return DriftRemoved
+	}
+	return DriftChanged
+}
+
+// DriftReport is what the refresh-only plans of the units of an
+// environment found
+type DriftReport struct {
+	Environment string       `json:"environment"`
+	Units       []*UnitDrift `json:"units"`
+	// Drifted are the units with drift
+	Drifted []string `json:"drifted"`
+	// Resources is the number of resources changed outside Terraform
+	Resources int `json:"resources"`
+}
+
+// NewDriftReport combines the drift of the units of env
+func NewDriftReport(env string, units ...*UnitDrift) *DriftReport {
+	r := &DriftReport{Environment: env, Units: []*UnitDrift{}, Drifted: []string{}}
+	for _, u := range units {
+		r.Units = append(r.Units, u)
+		r.Resources += len(u.Resources)
+		if u.HasDrift() {
+			r.Drifted = append(r.Drifted, u.Unit)
+		}
+	}
+	return r
+}
+
+// CollectDrift shows the refresh-only plan of every unit of env, given
+// relative to root, and classifies its drift. Every unit must have a plan.
+func CollectDrift(root string, env string, units []string, show Shower) (*DriftReport, error) {
+	var drift []*UnitDrift
+	err := each(root, units, show, func(unit string, p *Plan) {
+		drift = append(drift, Drift(unit, p))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewDriftReport(env, drift...), nil
+}
+
+// Errored reports whether a plan errored, so drift may have been missed
+func (r *DriftReport) Errored() bool {
+	for _, u := range r.Units {
+		if u.Errored {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *DriftReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// headline is e.g. "2 of 7 unit(s) drifted: 3 resource(s) changed outside
+// Terraform"
+func (r *DriftReport) headline() string {
+	if len(r.Drifted) == 0 {
+		return fmt.Sprintf("No drift in %d unit(s)", len(r.Units))
+	}
+	return fmt.Sprintf("%d of %d unit(s) drifted: %d resource(s) changed outside Terraform", len(r.Drifted), len(r.Units), r.Resources)
+}
+
+// undrifted lists the names of the units without drift
+func (r *DriftReport) undrifted() []string {
+	var names []string
+	for _, u := range r.Units {
+		if !u.HasDrift() && !u.Errored {
+			names = append(names, path.Base(u.Unit))
+		}
+	}
+	return names
+}
+
+// WriteText writes each drifted resource followed by its changed attributes
+func (r *DriftReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, u := range r.Units {
+		switch {
+		case u.Errored:
+			fmt.Fprintf(&b, "%s: plan errored\n", u.Unit)
+		case !u.HasDrift():
+			fmt.Fprintf(&b, "%s: no drift\n", u.Unit)
+			continue
+		}
+		for _, resource := range u.Resources {
+			fmt.Fprintf(&b, "%s: %s %s outside Terraform\n", u.Unit, resource.Address, resource.Class)
+			for _, attribute := range resource.Attributes {
+				fmt.Fprintf(&b, "  %s %s: %s → %s\n", attribute.Class, attribute.Path, attribute.Before, attribute.After)
+			}
+		}
+		for _, output := range u.Outputs {
+			fmt.Fprintf(&b, "%s: output.%s changes\n", u.Unit, output.Name)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Markdown returns the report with each drifted unit's resources and, for
+// the resources edited outside Terraform, the attributes that changed.
+// Sensitive values are masked.
+func (r *DriftReport) Markdown(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n**%s**\n", title, r.headline())
+	for _, u := range r.Units {
+		if !u.HasDrift() && !u.Errored {
+			continue
+		}
+		fmt.Fprintf(&b, "\n#### %s\n\n", path.Base(u.Unit))
+		if u.Errored {
+			b.WriteString("**The plan errored and is incomplete.**\n\n")
+		}
+		for _, resource := range u.Resources {
+			if resource.Class == DriftDeleted {
+				fmt.Fprintf(&b, "- **%s** deleted outside Terraform\n", markdownCode(resource.Address))
+				continue
+			}
+			fmt.Fprintf(&b, "- %s changed outside Terraform\n", markdownCode(resource.Address))
+			for i, attribute := range resource.Attributes {
+				if i == maxAttributes {
+					fmt.Fprintf(&b, "  - and %d more\n", len(resource.Attributes)-maxAttributes)
+					break
+				}
+				fmt.Fprintf(&b, "  - %s: %s → %s (%s)\n", markdownCode(attribute.Path), markdownCode(attribute.Before), markdownCode(attribute.After), attribute.Class)
+			}
+		}
+		if len(u.Outputs) > 0 {
+			outputs := make([]string, len(u.Outputs))
+			for i, output := range u.Outputs {
+				outputs[i] = markdownCode(output.Name)
+			}
+			fmt.Fprintf(&b, "\n**Outputs:** %s\n", strings.Join(outputs, ", "))
+		}
+	}
+	if names := r.undrifted(); len(names) > 0 {
+		fmt.Fprintf(&b, "\nNo drift in %s.\n", strings.Join(names, ", "))
+	}
+	return b.String()
+}
+
+// Jira returns the report in Jira wiki markup: a table of the drifted
+// attributes of every unit, with deleted resources in red
+func (r *DriftReport) Jira(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "h3. %s\n*%s*\n", title, r.headline())
+	var rows []string
+	for _, u := range r.Units {
+		unit := jiraEscape(path.Base(u.Unit))
+		if u.Errored {
+			rows = append(rows, fmt.Sprintf("|%s| |{color:red}*errored*{color}|The plan is incomplete|", unit))
+		}
+		for _, resource := range u.Resources {
+			address := jiraEscape(resource.Address)
+			if resource.Class == DriftDeleted {
+				rows = append(rows, fmt.Sprintf("|%s|%s|{color:red}*deleted*{color}| |", unit, address))
+				continue
+			}
+			if len(resource.Attributes) == 0 {
+				rows = append(rows, fmt.Sprintf("|%s|%s|changed| |", unit, address))
+			}
+			for _, attribute := range resource.Attributes {
+				rows = append(rows, fmt.Sprintf("|%s|%s|%s|%s: %s → %s|", unit, address, attribute.Class,
+					jiraEscape(attribute.Path), jiraEscape(attribute.Before), jiraEscape(attribute.After)))
+			}
+		}
+		if !u.HasDrift() {
+			continue
+		}
+		for _, output := range u.Outputs {
+			rows = append(rows, fmt.Sprintf("|%s|%s|changed| |", unit, jiraEscape("output."+output.Name)))
+		}
+	}
+	if len(rows) > 0 {
+		b.WriteString("||Unit||Resource||Drift||Details||\n")
+		b.WriteString(strings.Join(rows, "\n") + "\n")
+	}
+	if names := r.undrifted(); len(names) > 0 {
+		fmt.Fprintf(&b, "No drift in %s.\n", jiraEscape(strings.Join(names, ", ")))
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package plan
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectDrift(t *testing.T) *DriftReport {
+	t.Helper()
+	root := writePlans(t, map[string]string{
+		"resource-group": "no-changes.json",
+		"splunk-vm":      "drift.json",
+	})
+	units, err := Units(root, "staging")
+	require.NoError(t, err)
+	report, err := CollectDrift(root, "staging", units, Files("tfplan.json"))
+	require.NoError(t, err)
+	return report
+}
+
+func TestDrift(t *testing.T) {
+	report := collectDrift(t)
+	assert.Equal(t, []string{"environments/staging/splunk-vm"}, report.Drifted)
+	assert.Equal(t, 3, report.Resources)
+	assert.False(t, report.Errored())
+
+	u := report.Units[1]
+	assert.Equal(t, []DriftedResource{
+		{Address: "azurerm_linux_virtual_machine.main", Type: "azurerm_linux_virtual_machine", Class: DriftChanged, Attributes: []AttributeDrift{
+			{Path: "admin_password", Class: DriftChanged, Before: Masked, After: Masked},
+			{Path: "size", Class: DriftChanged, Before: `"Standard_B2s"`, After: `"Standard_D2s_v3"`},
+		}},
+		{Address: "azurerm_network_security_group.vm", Type: "azurerm_network_security_group", Class: DriftChanged, Attributes: []AttributeDrift{
+			{Path: "security_rule[1].access", Class: DriftAdded, Before: "null", After: `"Allow"`},
+			{Path: "security_rule[1].destination_port_range", Class: DriftAdded, Before: "null", After: `"3389"`},
+			{Path: "security_rule[1].name", Class: DriftAdded, Before: "null", After: `"allow-rdp"`},
+			{Path: "tags.Environment", Class: DriftRemoved, Before: `"staging"`, After: "null"},
+		}},
+		{Address: "azurerm_public_ip.vm[0]", Type: "azurerm_public_ip", Class: DriftDeleted, Attributes: []AttributeDrift{}},
+	}, u.Resources)
+	assert.Equal(t, []OutputSummary{{Name: "public_ip_address", Action: Update}}, u.Outputs, "outputs left as they are are not drift")
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "environments/staging/resource-group: no drift\n")
+	assert.Contains(t, text.String(), "environments/staging/splunk-vm: azurerm_public_ip.vm[0] deleted outside Terraform\n")
+	assert.Contains(t, text.String(), "  added security_rule[1].destination_port_range: null → \"3389\"\n")
+
+	p, err := Load(filepath.Join("testdata", "no-changes.json"))
+	require.NoError(t, err)
+	report = NewDriftReport("staging", Drift("environments/staging/resource-group", p))
+	assert.Empty(t, report.Drifted)
+	assert.Equal(t, "No drift in 1 unit(s)", report.headline())
+
+	// An output changes when the inputs differ from those last applied,
+	// e.g. a connection string built from other credentials
+	p.OutputChanges["resource_group_name"].Actions = []string{"update"}
+	u = Drift("environments/staging/resource-group", p)
+	assert.False(t, u.HasDrift(), "outputs alone are not drift")
+	assert.Equal(t, []OutputSummary{{Name: "resource_group_name", Action: Update}}, u.Outputs)
+	report = NewDriftReport("staging", u)
+	assert.Empty(t, report.Drifted)
+	assert.Equal(t, "h3. Drift\n*No drift in 1 unit(s)*\nNo drift in resource-group.\n", report.Jira("Drift"))
+}
+
+func TestDriftMarkdown(t *testing.T) {
+	assert.Equal(t, "### Drift: staging\n\n"+
+		"**1 of 2 unit(s) drifted: 3 resource(s) changed outside Terraform**\n\n"+
+		"#### splunk-vm\n\n"+
+		"- `azurerm_linux_virtual_machine.main` changed outside Terraform\n"+
+		"  - `admin_password`: `(sensitive value)` → `(sensitive value)` (changed)\n"+
+		"  - `size`: `\"Standard_B2s\"` → `\"Standard_D2s_v3\"` (changed)\n"+
+		"- `azurerm_network_security_group.vm` changed outside Terraform\n"+
+		"  - `security_rule[1].access`: `null` → `\"Allow\"` (added)\n"+
+		"  - `security_rule[1].destination_port_range`: `null` → `\"3389\"` (added)\n"+
+		"  - `security_rule[1].name`: `null` → `\"allow-rdp\"` (added)\n"+
+		"  - `tags.Environment`: `\"staging\"` → `null` (removed)\n"+
+		"- **`azurerm_public_ip.vm[0]`** deleted outside Terraform\n\n"+
+		"**Outputs:** `public_ip_address`\n\n"+
+		"No drift in resource-group.\n", collectDrift(t).Markdown("Drift: staging"))
+}
+
+func TestDriftJira(t *testing.T) {
+	assert.Equal(t, `h3. Drift: staging
+*1 of 2 unit(s) drifted: 3 resource(s) changed outside Terraform*
+||Unit||Resource||Drift||Details||
+|splunk-vm|azurerm\_linux\_virtual\_machine.main|changed|admin\_password: (sensitive value) → (sensitive value)|
+|splunk-vm|azurerm\_linux\_virtual\_machine.main|changed|size: "Standard\_B2s" → "Standard\_D2s\_v3"|
+|splunk-vm|azurerm\_network\_security\_group.vm|added|security\_rule\[1\].access: null → "Allow"|
+|splunk-vm|azurerm\_network\_security\_group.vm|added|security\_rule\[1\].destination\_port\_range: null → "3389"|
+|splunk-vm|azurerm\_network\_security\_group.vm|added|security\_rule\[1\].name: null → "allow-rdp"|
+|splunk-vm|azurerm\_network\_security\_group.vm|removed|tags.Environment: "staging" → null|
+|splunk-vm|azurerm\_public\_ip.vm\[0\]|{color:red}*deleted*{color}| |
+|splunk-vm|output.public\_ip\_address|changed| |
+No drift in resource-group.
+`, collectDrift(t).Jira("Drift: staging"))
+}
@@ -0,0 +1,82 @@
+package plan
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Normalize returns a copy of the plan that only differs from another plan
+// when the planned changes differ: the terraform version is cleared,
+// resource changes and drift are sorted by address and sensitive values are masked, so
+// two runs of the same plan, or a plan kept in a file, compare equal. Fields
+// the Plan type does not read, such as timestamp, prior_state and
+// configuration, are already gone once a plan is parsed.
+func Normalize(p *Plan) *Plan {
+	n := &Plan{
+		FormatVersion:   p.FormatVersion,
+		ResourceChanges: make([]ResourceChange, len(p.ResourceChanges)),
+		OutputChanges:   map[string]*Change{},
+		Errored:         p.Errored,
+	}
+	for i, rc := range p.ResourceChanges {
+		rc.Change = rc.Change.masked()
+		n.ResourceChanges[i] = rc
+	}
+	sort.SliceStable(n.ResourceChanges, func(i, j int) bool {
+		return n.ResourceChanges[i].Address < n.ResourceChanges[j].Address
+	})
+	for _, rc := range p.ResourceDrift {
+		rc.Change = rc.Change.masked()
+		n.ResourceDrift = append(n.ResourceDrift, rc)
+	}
+	sort.SliceStable(n.ResourceDrift, func(i, j int) bool {
+		return n.ResourceDrift[i].Address < n.ResourceDrift[j].Address
+	})
+	for name, change := range p.OutputChanges {
+		masked := change.masked()
+		n.OutputChanges[name] = &masked
+	}
+	return n
+}
+
+// masked returns the change with its sensitive values replaced by Masked
+func (c Change) masked() Change {
+	c.Before = mask(c.Before, c.BeforeSensitive)
+	c.After = mask(c.After, c.AfterSensitive)
+	return c
+}
+
+func mask(raw json.RawMessage, sensitive json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	data, err := json.Marshal(maskValue(decode(raw), decode(sensitive)))
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+func maskValue(value any, sensitive any) any {
+	if sensitive == true {
+		if value == nil {
+			return nil
+		}
+		return Masked
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(value))
+		for key, element := range value {
+			masked[key] = maskValue(element, child(sensitive, key))
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(value))
+		for i, element := range value {
+			masked[i] = maskValue(element, child(sensitive, i))
+		}
+		return masked
+	}
+	return value
+}
@@ -0,0 +1,123 @@
+// Package plan reads the JSON form of Terraform plans, as written by
+// terraform show -json tfplan, and summarises what each Terragrunt unit's
+// plan would do.
+//
+// Deciding whether an environment has changes from the plan's JSON rather
+// than its human-readable output keeps the decision exact when run-all
+// interleaves the output of several units, and lets later checks see each
+// resource's actions and the attributes that force a replacement.
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Plan is the part of the JSON plan format the summary reads, see
+// https://developer.hashicorp.com/terraform/internals/json-format
+type Plan struct {
+	FormatVersion    string             `json:"format_version"`
+	TerraformVersion string             `json:"terraform_version"`
+	ResourceChanges  []ResourceChange   `json:"resource_changes"`
+	OutputChanges    map[string]*Change `json:"output_changes"`
+	// ResourceDrift are the changes made outside Terraform that refreshing
+	// the state found, the only changes of a -refresh-only plan
+	ResourceDrift []ResourceChange `json:"resource_drift,omitempty"`
+	// Errored is set when the plan stopped on an error and is incomplete
+	Errored bool `json:"errored"`
+}
+
+// ResourceChange is the planned change of one resource instance
+type ResourceChange struct {
+	Address       string `json:"address"`
+	ModuleAddress string `json:"module_address,omitempty"`
+	Mode          string `json:"mode"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	// Index is the count or for_each key, absent for single instances
+	Index           json.RawMessage `json:"index,omitempty"`
+	PreviousAddress string          `json:"previous_address,omitempty"`
+	Change          Change          `json:"change"`
+	// ActionReason explains some actions, e.g. replace_because_tainted
+	ActionReason string `json:"action_reason,omitempty"`
+}
+
+// Change is a before and after pair. Before and After are kept raw and
+// decoded by the checks that need them.
+type Change struct {
+	Actions         []string        `json:"actions"`
+	Before          json.RawMessage `json:"before"`
+	After           json.RawMessage `json:"after"`
+	AfterUnknown    json.RawMessage `json:"after_unknown,omitempty"`
+	BeforeSensitive json.RawMessage `json:"before_sensitive,omitempty"`
+	AfterSensitive  json.RawMessage `json:"after_sensitive,omitempty"`
+	// ReplacePaths are the attribute paths that force a replacement, each a
+	// list of attribute names and indexes
+	ReplacePaths [][]any `json:"replace_paths,omitempty"`
+}
+
+// Action is what a plan does to a resource or output
+type Action string
+
+const (
+	NoOp    Action = "no-op"
+	Create  Action = "create"
+	Read    Action = "read"
+	Update  Action = "update"
+	Delete  Action = "delete"
+	Replace Action = "replace"
+)
+
+// Action reduces the change's actions to one. Both orders of delete and
+// create are a replacement.
+func (c Change) Action() Action {
+	switch strings.Join(c.Actions, ",") {
+	case "", "no-op":
+		return NoOp
+	case "create":
+		return Create
+	case "read":
+		return Read
+	case "update":
+		return Update
+	case "delete":
+		return Delete
+	case "delete,create", "create,delete":
+		return Replace
+	}
+	return Action(strings.Join(c.Actions, ","))
+}
+
+// Parse decodes a JSON plan. Unknown fields are ignored, since the format
+// gains fields in minor versions.
+func Parse(data []byte) (*Plan, error) {
+	var p Plan
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("not a terraform show -json plan: %w", err)
+	}
+	if p.FormatVersion == "" {
+		return nil, fmt.Errorf("not a terraform show -json plan: no format_version")
+	}
+	if major, _, _ := strings.Cut(p.FormatVersion, "."); major != "1" {
+		return nil, fmt.Errorf("unsupported plan format version %s", p.FormatVersion)
+	}
+	return &p, nil
+}
+
+// Load decodes the JSON plan in the file at path
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
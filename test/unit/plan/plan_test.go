@@ -0,0 +1,165 @@
+package plan
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Load(filepath.Join("testdata", "changes.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.6.6", p.TerraformVersion)
+	assert.Len(t, p.ResourceChanges, 6)
+
+	for data, message := range map[string]string{
+		`{"resource_changes": []}`:    "not a terraform show -json plan: no format_version",
+		`{"format_version": "2.0"}`:   "unsupported plan format version 2.0",
+		`Plan: 1 to add, 0 to change`: "not a terraform show -json plan: invalid character 'P' looking for beginning of value",
+	} {
+		_, err := Parse([]byte(data))
+		assert.EqualError(t, err, message)
+	}
+}
+
+func TestAction(t *testing.T) {
+	for actions, action := range map[string]Action{
+		"":              NoOp,
+		"no-op":         NoOp,
+		"create":        Create,
+		"read":          Read,
+		"update":        Update,
+		"delete":        Delete,
+		"delete,create": Replace,
+		"create,delete": Replace,
+	} {
+		change := Change{}
+		if actions != "" {
+			change.Actions = strings.Split(actions, ",")
+		}
+		assert.Equal(t, action, change.Action(), actions)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	p, err := Load(filepath.Join("testdata", "changes.json"))
+	require.NoError(t, err)
+
+	u := Summarize("environments/staging/splunk-vm", p)
+	assert.Equal(t, Counts{Create: 1, Update: 1, Delete: 1, Replace: 1}, u.Counts)
+	assert.True(t, u.HasChanges())
+
+	var addresses []string
+	for _, resource := range u.Resources {
+		addresses = append(addresses, string(resource.Action)+" "+resource.Address)
+	}
+	assert.Equal(t, []string{
+		"replace azurerm_linux_virtual_machine.main",
+		"create azurerm_managed_disk.data[0]",
+		"update azurerm_public_ip.main",
+		"delete azurerm_virtual_machine_extension.monitor",
+	}, addresses, "sorted by address, without no-op and read")
+	assert.Equal(t, []string{"admin_username"}, u.Resources[0].ReplacedBy)
+	assert.Equal(t, "delete_because_no_resource_config", u.Resources[3].Reason)
+	assert.Equal(t, []OutputSummary{{Name: "vm_id", Action: Update}}, u.Outputs)
+
+	assert.Equal(t, "os_disk[0].name", PathString([]any{"os_disk", 0, "name"}))
+}
+
+// writePlans writes environments/staging/<unit>/tfplan.json with the
+// contents of each testdata file
+func writePlans(t *testing.T, plans map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for unit, file := range plans {
+		dir := filepath.Join(root, "environments", "staging", unit)
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "terragrunt.hcl"), nil, 0o644))
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tfplan.json"), data, 0o644))
+	}
+	return root
+}
+
+func TestCollect(t *testing.T) {
+	root := writePlans(t, map[string]string{
+		"resource-group": "no-changes.json",
+		"splunk-vm":      "changes.json",
+	})
+
+	units, err := Units(root, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environments/staging/resource-group", "environments/staging/splunk-vm"}, units)
+	_, err = Units(root, "staging", "networking")
+	assert.EqualError(t, err, `environment "staging" has no unit "networking"`)
+	_, err = Units(root, "production")
+	assert.EqualError(t, err, `environment "production" has no units`)
+
+	summary, err := Collect(root, units, Files("tfplan.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environments/staging/splunk-vm"}, summary.Changed)
+	assert.Equal(t, Counts{Create: 1, Update: 1, Delete: 1, Replace: 1}, summary.Counts)
+	assert.Equal(t, ExitChanges, summary.ExitCode())
+
+	var text bytes.Buffer
+	require.NoError(t, summary.WriteText(&text))
+	assert.Equal(t, `environments/staging/resource-group: no changes
+environments/staging/splunk-vm: 1 to create, 1 to update, 1 to delete, 1 to replace
+  -/+  azurerm_linux_virtual_machine.main         forced by admin_username
+  +    azurerm_managed_disk.data[0]
+  ~    azurerm_public_ip.main
+  -    azurerm_virtual_machine_extension.monitor  delete_because_no_resource_config
+  ~    output.vm_id
+`, text.String())
+
+	units, err = Units(root, "staging", "resource-group")
+	require.NoError(t, err)
+	summary, err = Collect(root, units, Files("tfplan.json"))
+	require.NoError(t, err)
+	assert.Equal(t, ExitNoChanges, summary.ExitCode())
+
+	summary.Units[0].Errored = true
+	assert.Equal(t, ExitError, summary.ExitCode())
+}
+
+func TestCollectRequiresEveryPlan(t *testing.T) {
+	root := writePlans(t, map[string]string{
+		"networking": "",
+		"splunk-vm":  "changes.json",
+	})
+	units, err := Units(root, "staging")
+	require.NoError(t, err)
+
+	_, err = Collect(root, units, Files("tfplan.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "environments/staging/networking: open ")
+}
+
+func TestNormalize(t *testing.T) {
+	p, err := Load(filepath.Join("testdata", "changes.json"))
+	require.NoError(t, err)
+	n := Normalize(p)
+
+	assert.Empty(t, n.TerraformVersion)
+	var addresses []string
+	for _, rc := range n.ResourceChanges {
+		addresses = append(addresses, rc.Address)
+	}
+	assert.IsIncreasing(t, addresses)
+	for _, rc := range n.ResourceChanges {
+		if rc.Address == "azurerm_linux_virtual_machine.main" {
+			assert.Contains(t, string(rc.Change.After), `"admin_password":"(sensitive value)"`)
+			assert.NotContains(t, string(rc.Change.Before), "old-password")
+		}
+	}
+	assert.Equal(t, "1.6.6", p.TerraformVersion, "the plan itself is left as it was")
+}
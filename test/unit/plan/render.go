@@ -0,0 +1,382 @@
+package plan
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxAttributes is the number of changed attributes listed per resource
+const maxAttributes = 10
+
+// Limits of a Discord embed
+const (
+	discordDescription = 4096
+	discordFields      = 25
+	discordFieldValue  = 1024
+	// discordTotal is the limit of the titles, descriptions, field names and
+	// values and footers of all embeds of a message together
+	discordTotal = 6000
+)
+
+// discordReserved is left of discordTotal for the status embed
+// sendDiscordNotification sends before the summary's
+const discordReserved = 1000
+
+// discordMinFieldValue is the shortest field value worth showing; with less
+// room per unit, units are counted in a last field instead
+const discordMinFieldValue = 64
+
+// Embed colors, the same as the pipeline's notifications
+const (
+	colorGreen  = 3066993
+	colorYellow = 16776960
+	colorRed    = 15158332
+)
+
+// actionOrder lists the most destructive actions first
+var actionOrder = []Action{Replace, Delete, Update, Create}
+
+// actionTitles name the groups of resources in Markdown
+var actionTitles = map[Action]string{
+	Replace: "Replace",
+	Delete:  "Delete",
+	Update:  "Update",
+	Create:  "Create",
+}
+
+// headline is e.g. "2 of 7 units change: 1 to create, ..."
+func (s *Summary) headline() string {
+	if len(s.Changed) == 0 {
+		return fmt.Sprintf("No changes in %d unit(s)", len(s.Units))
+	}
+	return fmt.Sprintf("%d of %d unit(s) change: %s", len(s.Changed), len(s.Units), s.Counts)
+}
+
+// replacements lists each replaced resource as "address in unit", followed
+// by what forces it
+func (s *Summary) replacements(code func(string) string) []string {
+	var lines []string
+	for _, u := range s.Units {
+		for _, resource := range u.Resources {
+			if resource.Action == Replace {
+				lines = append(lines, fmt.Sprintf("%s in %s%s", code(resource.Address), code(path.Base(u.Unit)), cause(resource, code)))
+			}
+		}
+	}
+	return lines
+}
+
+// cause is ", forced by x, y" for a replacement, or terraform's reason
+func cause(resource ResourceSummary, code func(string) string) string {
+	if len(resource.ReplacedBy) > 0 {
+		forced := make([]string, len(resource.ReplacedBy))
+		for i, path := range resource.ReplacedBy {
+			forced[i] = code(path)
+		}
+		return ", forced by " + strings.Join(forced, ", ")
+	}
+	if resource.Reason != "" {
+		return " (" + code(resource.Reason) + ")"
+	}
+	return ""
+}
+
+// unchanged lists the names of the units without changes
+func (s *Summary) unchanged() []string {
+	var names []string
+	for _, u := range s.Units {
+		if !u.HasChanges() && !u.Errored {
+			names = append(names, path.Base(u.Unit))
+		}
+	}
+	return names
+}
+
+// byAction returns the resources of u with action
+func byAction(u *UnitSummary, action Action) []ResourceSummary {
+	var resources []ResourceSummary
+	for _, resource := range u.Resources {
+		if resource.Action == action {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// markdownCode writes s as inline code; backticks would end it early
+func markdownCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "'") + "`"
+}
+
+// Markdown returns the summary for a pull request comment: each changed unit
+// with its resources grouped by action, replacements first, and the
+// attributes updates and replacements change. Sensitive values are masked.
+func (s *Summary) Markdown(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n**%s**\n", title, s.headline())
+	if lines := s.replacements(markdownCode); len(lines) > 0 {
+		fmt.Fprintf(&b, "\n> [!WARNING]\n> %d resource(s) will be destroyed and created again:\n", len(lines))
+		for _, line := range lines {
+			fmt.Fprintf(&b, "> - %s\n", line)
+		}
+	}
+	for _, u := range s.Units {
+		if !u.HasChanges() && !u.Errored {
+			continue
+		}
+		fmt.Fprintf(&b, "\n#### %s\n", path.Base(u.Unit))
+		if u.Errored {
+			b.WriteString("\n**The plan errored and is incomplete.**\n")
+		}
+		for _, action := range actionOrder {
+			resources := byAction(u, action)
+			if len(resources) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n**%s (%d)**\n", actionTitles[action], len(resources))
+			for _, resource := range resources {
+				address := markdownCode(resource.Address)
+				if action == Replace || action == Delete {
+					address = "**" + address + "**"
+				}
+				fmt.Fprintf(&b, "- %s%s\n", address, cause(resource, markdownCode))
+				if action == Update || action == Replace {
+					writeAttributes(&b, resource)
+				}
+			}
+		}
+		if len(u.Outputs) > 0 {
+			outputs := make([]string, len(u.Outputs))
+			for i, output := range u.Outputs {
+				outputs[i] = fmt.Sprintf("%s (%s)", markdownCode(output.Name), output.Action)
+			}
+			fmt.Fprintf(&b, "\n**Outputs:** %s\n", strings.Join(outputs, ", "))
+		}
+	}
+	if names := s.unchanged(); len(names) > 0 {
+		fmt.Fprintf(&b, "\nNo changes in %s.\n", strings.Join(names, ", "))
+	}
+	return b.String()
+}
+
+func writeAttributes(b *strings.Builder, resource ResourceSummary) {
+	if resource.Change == nil {
+		return
+	}
+	attributes := resource.Change.Change.Attributes()
+	for i, attribute := range attributes {
+		if i == maxAttributes {
+			fmt.Fprintf(b, "  - and %d more\n", len(attributes)-maxAttributes)
+			break
+		}
+		forces := ""
+		if attribute.Forces {
+			forces = " (forces replacement)"
+		}
+		fmt.Fprintf(b, "  - %s: %s → %s%s\n", markdownCode(attribute.Path), markdownCode(attribute.Before), markdownCode(attribute.After), forces)
+	}
+}
+
+// DiscordEmbed is an embed of a Discord webhook message
+type DiscordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Fields      []DiscordField `json:"fields"`
+}
+
+// DiscordField is a field of an embed
+type DiscordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// Discord returns the summary as an embed: the counts and replacements in
+// the description, and a field per changed unit listing its resources the
+// way terraform marks them. The embed stays within Discord's limits, leaving
+// room for the status embed sent with it: lines that do not fit are left
+// out, and counted.
+func (s *Summary) Discord(title string) DiscordEmbed {
+	embed := DiscordEmbed{Title: title, Color: colorGreen, Fields: []DiscordField{}}
+	switch {
+	case s.ExitCode() == ExitError || s.Counts.Delete+s.Counts.Replace > 0:
+		embed.Color = colorRed
+	case len(s.Changed) > 0:
+		embed.Color = colorYellow
+	}
+
+	budget := discordTotal - discordReserved - utf8.RuneCountInString(title)
+	description := []string{"**" + s.headline() + "**"}
+	for _, line := range s.replacements(markdownCode) {
+		description = append(description, "⚠️ Replaces "+line)
+	}
+	// at most half the budget, so the units keep a share
+	embed.Description = limitLines(description, min(discordDescription, budget/2))
+	budget -= utf8.RuneCountInString(embed.Description)
+
+	var units []DiscordField
+	var lines [][]string
+	for _, u := range s.Units {
+		if !u.HasChanges() && !u.Errored {
+			continue
+		}
+		var unit []string
+		if u.Errored {
+			unit = append(unit, "**The plan errored and is incomplete.**")
+		}
+		for _, action := range actionOrder {
+			for _, resource := range byAction(u, action) {
+				line := markdownCode(symbols[action] + " " + resource.Address)
+				if action == Replace {
+					line += " ⚠️" + strings.TrimPrefix(cause(resource, markdownCode), ",")
+				}
+				unit = append(unit, line)
+			}
+		}
+		for _, output := range u.Outputs {
+			unit = append(unit, markdownCode(symbols[output.Action]+" output."+output.Name))
+		}
+		units = append(units, DiscordField{Name: path.Base(u.Unit)})
+		lines = append(lines, unit)
+	}
+	embed.Fields = fitFields(units, lines, budget)
+	return embed
+}
+
+// fitFields fills the value of each field with its lines within budget
+// characters in all. Units that do not fit in discordFields fields, or with
+// fewer than discordMinFieldValue characters each, are counted in a last
+// field.
+func fitFields(fields []DiscordField, lines [][]string, budget int) []DiscordField {
+	for shown := min(len(fields), discordFields); shown >= 0; shown-- {
+		var more *DiscordField
+		if shown < len(fields) {
+			if shown == discordFields {
+				continue
+			}
+			more = &DiscordField{Name: "…", Value: fmt.Sprintf("and %d more unit(s)", len(fields)-shown)}
+		}
+		room := budget
+		if more != nil {
+			room -= utf8.RuneCountInString(more.Name) + utf8.RuneCountInString(more.Value)
+		}
+		sizes := make([]int, shown)
+		for i := 0; i < shown; i++ {
+			room -= utf8.RuneCountInString(fields[i].Name)
+			sizes[i] = min(utf8.RuneCountInString(strings.Join(lines[i], "\n")), discordFieldValue)
+		}
+		limits, ok := share(sizes, room)
+		if !ok {
+			continue
+		}
+		fitted := []DiscordField{}
+		for i, limit := range limits {
+			field := fields[i]
+			field.Value = limitLines(lines[i], limit)
+			fitted = append(fitted, field)
+		}
+		if more != nil {
+			fitted = append(fitted, *more)
+		}
+		return fitted
+	}
+	return []DiscordField{}
+}
+
+// share splits room between values of the given sizes, giving short values
+// all they need and the rest an equal share. It fails when a value would get
+// fewer than discordMinFieldValue characters without fitting.
+func share(sizes []int, room int) ([]int, bool) {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]] < sizes[order[b]] })
+	limits := make([]int, len(sizes))
+	for n, i := range order {
+		limit := min(sizes[i], room/(len(order)-n))
+		if limit < sizes[i] && limit < discordMinFieldValue {
+			return nil, false
+		}
+		limits[i] = limit
+		room -= limit
+	}
+	return limits, room >= 0
+}
+
+// limitLines joins lines, leaving out those that do not fit in limit
+// characters and counting them in a last line
+func limitLines(lines []string, limit int) string {
+	text := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	// room for the count of the lines left out
+	budget := limit - utf8.RuneCountInString("\n… and 9999 more")
+	text = ""
+	for i, line := range lines {
+		next := line
+		if i > 0 {
+			next = text + "\n" + line
+		}
+		if utf8.RuneCountInString(next) > budget {
+			return strings.TrimPrefix(fmt.Sprintf("%s\n… and %d more", text, len(lines)-i), "\n")
+		}
+		text = next
+	}
+	return text
+}
+
+// jiraColors highlight destructive actions in Jira tables
+var jiraColors = map[Action]string{
+	Replace: "red",
+	Delete:  "red",
+}
+
+// jiraEscape keeps characters of addresses and paths from being read as
+// Jira markup
+func jiraEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "{", "\\{", "}", "\\}", "[", "\\[", "]", "\\]", "*", "\\*", "_", "\\_").Replace(s)
+}
+
+// Jira returns the summary in Jira wiki markup: a table of the changed
+// resources of every unit, with replacements and deletions in red
+func (s *Summary) Jira(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "h3. %s\n*%s*\n", title, s.headline())
+	var rows []string
+	for _, u := range s.Units {
+		unit := jiraEscape(path.Base(u.Unit))
+		if u.Errored {
+			rows = append(rows, fmt.Sprintf("|%s|{color:red}*errored*{color}| |The plan is incomplete|", unit))
+		}
+		for _, action := range actionOrder {
+			for _, resource := range byAction(u, action) {
+				label := string(action)
+				if color, ok := jiraColors[action]; ok {
+					label = fmt.Sprintf("{color:%s}*%s*{color}", color, action)
+				}
+				detail := strings.TrimSpace(strings.TrimPrefix(cause(resource, jiraEscape), ", "))
+				if detail == "" {
+					detail = " "
+				}
+				rows = append(rows, fmt.Sprintf("|%s|%s|%s|%s|", unit, label, jiraEscape(resource.Address), detail))
+			}
+		}
+		for _, output := range u.Outputs {
+			rows = append(rows, fmt.Sprintf("|%s|%s|%s| |", unit, output.Action, jiraEscape("output."+output.Name)))
+		}
+	}
+	if len(rows) > 0 {
+		b.WriteString("||Unit||Action||Resource||Details||\n")
+		b.WriteString(strings.Join(rows, "\n") + "\n")
+	}
+	if names := s.unchanged(); len(names) > 0 {
+		fmt.Fprintf(&b, "No changes in %s.\n", jiraEscape(strings.Join(names, ", ")))
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package plan
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectChanges(t *testing.T) *Summary {
+	t.Helper()
+	root := writePlans(t, map[string]string{
+		"resource-group": "no-changes.json",
+		"splunk-vm":      "changes.json",
+	})
+	units, err := Units(root, "staging")
+	require.NoError(t, err)
+	summary, err := Collect(root, units, Files("tfplan.json"))
+	require.NoError(t, err)
+	return summary
+}
+
+func TestAttributes(t *testing.T) {
+	change := Change{
+		Before:          json.RawMessage(`{"name": "sql", "connection_string": "Server=a", "rules": [{"port": 22}], "login": {"password": "a", "user": "sa"}}`),
+		After:           json.RawMessage(`{"name": "sql", "connection_string": "Server=b", "rules": [{"port": 22}, {"port": 443}], "login": {"password": "b", "user": "admin"}}`),
+		AfterUnknown:    json.RawMessage(`{"id": true}`),
+		BeforeSensitive: json.RawMessage(`{"login": true}`),
+		AfterSensitive:  json.RawMessage(`{"login": true}`),
+		ReplacePaths:    [][]any{{"rules"}},
+	}
+	assert.Equal(t, []AttributeChange{
+		{Path: "connection_string", Before: Masked, After: Masked},
+		{Path: "id", Before: "null", After: Unknown},
+		{Path: "login.password", Before: Masked, After: Masked},
+		{Path: "login.user", Before: Masked, After: Masked},
+		{Path: "rules[1].port", Before: "null", After: "443", Forces: true},
+	}, change.Attributes(), "secrets are masked by the plan's markers or by name")
+	long := Change{
+		Before: json.RawMessage(`{"description": null}`),
+		After:  json.RawMessage(`{"description": "` + strings.Repeat("é", 80) + `"}`),
+	}
+	after := long.Attributes()[0].After
+	assert.True(t, utf8.ValidString(after), "long values are cut on a character")
+	assert.Equal(t, `"`+strings.Repeat("é", maxValueLength-4)+"...", after)
+}
+
+func TestMarkdown(t *testing.T) {
+	assert.Equal(t, "### Plan: staging\n\n"+
+		"**1 of 2 unit(s) change: 1 to create, 1 to update, 1 to delete, 1 to replace**\n\n"+
+		"> [!WARNING]\n"+
+		"> 1 resource(s) will be destroyed and created again:\n"+
+		"> - `azurerm_linux_virtual_machine.main` in `splunk-vm`, forced by `admin_username`\n\n"+
+		"#### splunk-vm\n\n"+
+		"**Replace (1)**\n"+
+		"- **`azurerm_linux_virtual_machine.main`**, forced by `admin_username`\n"+
+		"  - `admin_password`: `(sensitive value)` → `(sensitive value)`\n"+
+		"  - `admin_username`: `\"azureuser\"` → `\"splunkadmin\"` (forces replacement)\n"+
+		"  - `custom_data`: `\"c3BsdW5r\"` → `\"c3BsdW5rMg==\"`\n"+
+		"  - `id`: `null` → `(known after apply)`\n\n"+
+		"**Delete (1)**\n"+
+		"- **`azurerm_virtual_machine_extension.monitor`** (`delete_because_no_resource_config`)\n\n"+
+		"**Update (1)**\n"+
+		"- `azurerm_public_ip.main`\n"+
+		"  - `tags.Owner`: `null` → `\"platform\"`\n\n"+
+		"**Create (1)**\n"+
+		"- `azurerm_managed_disk.data[0]`\n\n"+
+		"**Outputs:** `vm_id` (update)\n\n"+
+		"No changes in resource-group.\n", collectChanges(t).Markdown("Plan: staging"))
+}
+
+func TestDiscord(t *testing.T) {
+	embed := collectChanges(t).Discord("Plan: staging")
+	assert.Equal(t, colorRed, embed.Color, "a replacement is red")
+	assert.Equal(t, "**1 of 2 unit(s) change: 1 to create, 1 to update, 1 to delete, 1 to replace**\n"+
+		"⚠️ Replaces `azurerm_linux_virtual_machine.main` in `splunk-vm`, forced by `admin_username`", embed.Description)
+	require.Len(t, embed.Fields, 1, "units without changes have no field")
+	assert.Equal(t, "splunk-vm", embed.Fields[0].Name)
+	assert.Equal(t, "`-/+ azurerm_linux_virtual_machine.main` ⚠️ forced by `admin_username`\n"+
+		"`- azurerm_virtual_machine_extension.monitor`\n"+
+		"`~ azurerm_public_ip.main`\n"+
+		"`+ azurerm_managed_disk.data[0]`\n"+
+		"`~ output.vm_id`", embed.Fields[0].Value)
+
+	var units []*UnitSummary
+	for i := 0; i < 30; i++ {
+		u := &UnitSummary{Unit: "environments/staging/unit", Counts: Counts{Create: 200}}
+		for j := 0; j < 200; j++ {
+			u.Resources = append(u.Resources, ResourceSummary{Address: "azurerm_storage_blob.assets[" + strings.Repeat("x", j%7) + "]", Action: Create})
+		}
+		units = append(units, u)
+	}
+	embed = NewSummary(units...).Discord("Plan: staging")
+	assert.Equal(t, colorYellow, embed.Color)
+	assert.LessOrEqual(t, embedLength(embed), discordTotal-discordReserved, "the status embed must still fit in the message")
+	require.NotEmpty(t, embed.Fields)
+	last := embed.Fields[len(embed.Fields)-1]
+	assert.Regexp(t, `^and \d+ more unit\(s\)$`, last.Value)
+	value := embed.Fields[0].Value
+	assert.LessOrEqual(t, len([]rune(value)), discordFieldValue)
+	assert.GreaterOrEqual(t, len([]rune(value)), discordMinFieldValue)
+	assert.Regexp(t, `\n… and \d+ more$`, value)
+
+	// a few units share the budget, the short ones in full
+	units = units[:3]
+	units[1] = &UnitSummary{Unit: "environments/staging/dns", Counts: Counts{Create: 1},
+		Resources: []ResourceSummary{{Address: "azurerm_dns_zone.main", Action: Create}}}
+	embed = NewSummary(units...).Discord("Plan: staging")
+	assert.LessOrEqual(t, embedLength(embed), discordTotal-discordReserved)
+	require.Len(t, embed.Fields, 3)
+	assert.Equal(t, "`+ azurerm_dns_zone.main`", embed.Fields[1].Value)
+}
+
+// embedLength counts the characters Discord limits across a message's embeds
+func embedLength(embed DiscordEmbed) int {
+	n := len([]rune(embed.Title)) + len([]rune(embed.Description))
+	for _, field := range embed.Fields {
+		n += len([]rune(field.Name)) + len([]rune(field.Value))
+	}
+	return n
+}
+
+func TestJira(t *testing.T) {
+	assert.Equal(t, `h3. Plan: staging
+*1 of 2 unit(s) change: 1 to create, 1 to update, 1 to delete, 1 to replace*
+||Unit||Action||Resource||Details||
+|splunk-vm|{color:red}*replace*{color}|azurerm\_linux\_virtual\_machine.main|forced by admin\_username|
+|splunk-vm|{color:red}*delete*{color}|azurerm\_virtual\_machine\_extension.monitor|(delete\_because\_no\_resource\_config)|
+|splunk-vm|update|azurerm\_public\_ip.main| |
+|splunk-vm|create|azurerm\_managed\_disk.data\[0\]| |
+|splunk-vm|update|output.vm\_id| |
+No changes in resource-group.
+`, collectChanges(t).Jira("Plan: staging"))
+}
@@ -0,0 +1,147 @@
+package plan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+)
+
+// Shower returns the JSON plan of the unit in dir
+type Shower func(dir string) ([]byte, error)
+
+// Terragrunt returns a Shower running terragrunt show -json planFile in the
+// unit's directory, where terragrunt plan -out=planFile left it
+func Terragrunt(binary string, planFile string) Shower {
+	return func(dir string) ([]byte, error) {
+		cmd := exec.Command(binary, "show", "-json", "--terragrunt-non-interactive", planFile)
+		cmd.Dir = dir
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if message := lastLine(stderr.String()); message != "" {
+				err = fmt.Errorf("%w: %s", err, message)
+			}
+			return nil, fmt.Errorf("%s show -json %s: %w", binary, planFile, err)
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// RefreshOnly returns a Shower running terragrunt plan -refresh-only
+// -out=planFile in the unit's directory and showing the plan it saved. The
+// state is not locked: a refresh-only plan does not write it, and a deploy
+// running at the same time should not make the check fail.
+func RefreshOnly(binary string, planFile string) Shower {
+	show := Terragrunt(binary, planFile)
+	return func(dir string) ([]byte, error) {
+		cmd := exec.Command(binary, "plan", "-refresh-only", "-input=false", "-lock=false", "-out="+planFile, "--terragrunt-non-interactive")
+		cmd.Dir = dir
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if message := lastLine(stderr.String()); message != "" {
+				err = fmt.Errorf("%w: %s", err, message)
+			}
+			return nil, fmt.Errorf("%s plan -refresh-only: %w", binary, err)
+		}
+		return show(dir)
+	}
+}
+
+// Files returns a Shower reading the JSON plan from name in the unit's
+// directory, for plans already converted with terraform show -json
+func Files(name string) Shower {
+	return func(dir string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, name))
+	}
+}
+
+// Units returns the directories of the units of env relative to root,
+// sorted, or of only the named units
+func Units(root string, env string, names ...string) ([]string, error) {
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	var units []string
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if filepath.Base(filepath.Dir(dir)) != env {
+			continue
+		}
+		name := filepath.Base(dir)
+		if len(names) > 0 && !contains(names, name) {
+			continue
+		}
+		found[name] = true
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, filepath.ToSlash(rel))
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("environment %q has no unit %q", env, name)
+		}
+	}
+	if len(units) == 0 {
+		return nil, fmt.Errorf("environment %q has no units", env)
+	}
+	return units, nil
+}
+
+// Collect shows the plan of every unit, given relative to root, and
+// summarises them. Every unit must have a plan.
+func Collect(root string, units []string, show Shower) (*Summary, error) {
+	var summaries []*UnitSummary
+	err := each(root, units, show, func(unit string, p *Plan) {
+		summaries = append(summaries, Summarize(unit, p))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewSummary(summaries...), nil
+}
+
+// each shows and parses the plan of every unit and passes it to f, returning
+// the errors of the units whose plan cannot be read
+func each(root string, units []string, show Shower, f func(unit string, p *Plan)) error {
+	var errs []error
+	for _, unit := range units {
+		data, err := show(filepath.Join(root, filepath.FromSlash(unit)))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", unit, err))
+			continue
+		}
+		p, err := Parse(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", unit, err))
+			continue
+		}
+		f(unit, p)
+	}
+	return errors.Join(errs...)
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Exit codes of commands summarising plans, the same as those of terraform
+// plan -detailed-exitcode so Jenkins can branch on them either way
+const (
+	ExitNoChanges = 0
+	ExitError     = 1
+	ExitChanges   = 2
+)
+
+// Counts are the resources a plan changes by action. Unlike terraform's
+// "Plan:" line, replacements are counted once, as replacements.
+type Counts struct {
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Delete  int `json:"delete"`
+	Replace int `json:"replace"`
+}
+
+// Total is the number of resources changed
+func (c Counts) Total() int {
+	return c.Create + c.Update + c.Delete + c.Replace
+}
+
+func (c *Counts) add(action Action) {
+	switch action {
+	case Create:
+		c.Create++
+	case Update:
+		c.Update++
+	case Delete:
+		c.Delete++
+	case Replace:
+		c.Replace++
+	}
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d to create, %d to update, %d to delete, %d to replace", c.Create, c.Update, c.Delete, c.Replace)
+}
+
+// ResourceSummary is a resource the plan changes
+type ResourceSummary struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Action  Action `json:"action"`
+	Reason  string `json:"reason,omitempty"`
+	// ReplacedBy are the attributes forcing a replacement, e.g.
+	// admin_ssh_key[0].public_key
+	ReplacedBy []string `json:"replaced_by,omitempty"`
+	// Change is the change as planned, for checks that need the values
+	Change *ResourceChange `json:"-"`
+}
+
+// OutputSummary is an output whose value the plan changes
+type OutputSummary struct {
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+}
+
+// UnitSummary is what the plan of one unit does
+type UnitSummary struct {
+	// Unit is the unit's directory relative to the repository root
+	Unit      string            `json:"unit"`
+	Counts    Counts            `json:"counts"`
+	Resources []ResourceSummary `json:"resources"`
+	Outputs   []OutputSummary   `json:"outputs"`
+	Errored   bool              `json:"errored,omitempty"`
+}
+
+// HasChanges reports whether applying the plan changes a resource or an
+// output
+func (u *UnitSummary) HasChanges() bool {
+	return u.Counts.Total() > 0 || len(u.Outputs) > 0
+}
+
+// Summarize returns what the plan of unit does, leaving out resources it
+// only reads or leaves as they are
+func Summarize(unit string, p *Plan) *UnitSummary {
+	u := &UnitSummary{Unit: unit, Resources: []ResourceSummary{}, Outputs: []OutputSummary{}, Errored: p.Errored}
+	for i := range p.ResourceChanges {
+		rc := &p.ResourceChanges[i]
+		action := rc.Change.Action()
+		if action == NoOp || action == Read {
+			continue
+		}
+		resource := ResourceSummary{Address: rc.Address, Type: rc.Type, Action: action, Reason: rc.ActionReason, Change: rc}
+		for _, path := range rc.Change.ReplacePaths {
+			resource.ReplacedBy = append(resource.ReplacedBy, PathString(path))
+		}
+		u.Resources = append(u.Resources, resource)
+		u.Counts.add(action)
+	}
+	sort.SliceStable(u.Resources, func(i, j int) bool { return u.Resources[i].Address < u.Resources[j].Address })
+
+	for _, name := range sortedKeys(p.OutputChanges) {
+		if action := p.OutputChanges[name].Action(); action != NoOp {
+			u.Outputs = append(u.Outputs, OutputSummary{Name: name, Action: action})
+		}
+	}
+	return u
+}
+
+// PathString writes an attribute path of a plan as in configuration, e.g.
+// ["os_disk", 0, "name"] as os_disk[0].name
+func PathString(path []any) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch step := step.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(step)
+		default:
+			fmt.Fprintf(&b, "[%v]", step)
+		}
+	}
+	return b.String()
+}
+
+// Summary is what the plans of a set of units do
+type Summary struct {
+	Units  []*UnitSummary `json:"units"`
+	Counts Counts         `json:"counts"`
+	// Changed are the units whose plan has changes
+	Changed []string `json:"changed"`
+}
+
+// NewSummary combines the summaries of units
+func NewSummary(units ...*UnitSummary) *Summary {
+	s := &Summary{Units: []*UnitSummary{}, Changed: []string{}}
+	for _, u := range units {
+		s.Units = append(s.Units, u)
+		s.Counts.Create += u.Counts.Create
+		s.Counts.Update += u.Counts.Update
+		s.Counts.Delete += u.Counts.Delete
+		s.Counts.Replace += u.Counts.Replace
+		if u.HasChanges() {
+			s.Changed = append(s.Changed, u.Unit)
+		}
+	}
+	return s
+}
+
+// ExitCode is ExitError when a plan errored, ExitChanges when a plan has
+// changes and ExitNoChanges otherwise
+func (s *Summary) ExitCode() int {
+	for _, u := range s.Units {
+		if u.Errored {
+			return ExitError
+		}
+	}
+	if len(s.Changed) > 0 {
+		return ExitChanges
+	}
+	return ExitNoChanges
+}
+
+// WriteJSON writes the summary as indented JSON
+func (s *Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// symbols are the markers terraform plan prints for each action
+var symbols = map[Action]string{
+	Create:  "+",
+	Update:  "~",
+	Delete:  "-",
+	Replace: "-/+",
+}
+
+// WriteText writes each unit's counts followed by its changed resources and
+// outputs
+func (s *Summary) WriteText(w io.Writer) error {
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, u := range s.Units {
+		switch {
+		case u.Errored:
+			fmt.Fprintf(tw, "%s: plan errored\n", u.Unit)
+		case !u.HasChanges():
+			fmt.Fprintf(tw, "%s: no changes\n", u.Unit)
+			continue
+		default:
+			fmt.Fprintf(tw, "%s: %s\n", u.Unit, u.Counts)
+		}
+		for _, resource := range u.Resources {
+			detail := ""
+			if len(resource.ReplacedBy) > 0 {
+				detail = "forced by " + strings.Join(resource.ReplacedBy, ", ")
+			} else if resource.Reason != "" {
+				detail = resource.Reason
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", symbols[resource.Action], resource.Address, detail)
+		}
+		for _, output := range u.Outputs {
+			fmt.Fprintf(tw, "  %s\toutput.%s\t\n", symbols[output.Action], output.Name)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	// lines without a detail are padded to the detail column
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if _, err := io.WriteString(w, strings.TrimRight(line, " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,339 @@
+// Package policy evaluates the repository's own policy rules against every
+// Terragrunt unit: the module it applies, evaluated with the unit's resolved
+// inputs. Unlike Checkov and tfsec, which only see the modules, a rule can
+// depend on the environment, such as "production Key Vaults enable purge
+// protection".
+//
+// Rules are declared in YAML with an HCL condition, or in Go for checks a
+// condition cannot express. Every rule has a severity per environment, and
+// waivers accept a violation until they expire. Results are written as text,
+// JSON or SARIF for code scanning.
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Severity is how a violation is reported. Only errors fail the check.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Note    Severity = "note"
+	// Off disables the rule
+	Off Severity = "off"
+)
+
+// DefaultEnvironment is the key of Rule.Severity used for environments
+// without their own entry
+const DefaultEnvironment = "default"
+
+// Rule is a policy rule. A YAML rule has a Condition; a Go rule has a Check
+// function instead.
+type Rule struct {
+	ID          string `yaml:"id" json:"id"`
+	Description string `yaml:"description" json:"description"`
+	// Module limits the rule to the units applying this module; empty
+	// matches every unit
+	Module string `yaml:"module" json:"module,omitempty"`
+	// Resource is the address of a resource of the module. Its evaluated
+	// arguments and blocks are available to the condition as self.
+	Resource string `yaml:"resource" json:"resource,omitempty"`
+	// Condition is an HCL expression over var, local and self that must be
+	// true. Rules whose condition is unknown offline are skipped.
+	Condition string `yaml:"condition" json:"condition,omitempty"`
+	// Message is reported for a violation; the description is used when it
+	// is empty
+	Message string `yaml:"message" json:"message,omitempty"`
+	// Severity maps environment names, or DefaultEnvironment, to severities.
+	// Environments without an entry and no default get Warning.
+	Severity map[string]Severity `yaml:"severity" json:"severity"`
+
+	// Check implements a Go rule
+	Check func(unit *inputs.Unit) []Violation `yaml:"-" json:"-"`
+
+	condition hcl.Expression
+}
+
+// Violation is a failed check of a Go rule
+type Violation struct {
+	Message string
+	// Range points at the input or module code at fault
+	Range hcl.Range
+}
+
+// SeverityFor returns the severity of the rule in an environment
+func (r *Rule) SeverityFor(env string) Severity {
+	if severity, ok := r.Severity[env]; ok {
+		return severity
+	}
+	if severity, ok := r.Severity[DefaultEnvironment]; ok {
+		return severity
+	}
+	return Warning
+}
+
+// Result is one violation of a rule in one unit
+type Result struct {
+	Rule        string    `json:"rule"`
+	Environment string    `json:"environment"`
+	Unit        string    `json:"unit"`
+	Severity    Severity  `json:"severity"`
+	Message     string    `json:"message"`
+	Location    string    `json:"location"`
+	Range       hcl.Range `json:"-"`
+	// Waiver is the waiver accepting the violation, if any
+	Waiver *Waiver `json:"waiver,omitempty"`
+	// ExpiredWaiver is a waiver that matched but has expired
+	ExpiredWaiver *Waiver `json:"expired_waiver,omitempty"`
+}
+
+// Fails reports whether the result fails the check
+func (r Result) Fails() bool {
+	return r.Severity == Error && r.Waiver == nil
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("%s: %s: [%s] %s", r.Location, r.Severity, r.Rule, r.Message)
+	switch {
+	case r.Waiver != nil:
+		s += fmt.Sprintf(" (waived until %s: %s)", r.Waiver.Expires, r.Waiver.Reason)
+	case r.ExpiredWaiver != nil:
+		s += fmt.Sprintf(" (waiver expired on %s)", r.ExpiredWaiver.Expires)
+	}
+	return s
+}
+
+// Engine evaluates rules and applies waivers
+type Engine struct {
+	Rules   []*Rule
+	Waivers []Waiver
+	// Now decides which waivers have expired; the zero value means the
+	// current time
+	Now time.Time
+}
+
+// Evaluate checks every unit under root/environments, or only those of env
+// when it is not empty
+func (e *Engine) Evaluate(root string, env string) (*Report, error) {
+	if err := e.checkWaivers(); err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Rules: e.Rules}
+	for _, path := range paths {
+		unit, err := inputs.LoadUnit(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if unit.Module == nil || (env != "" && unit.Env != env) {
+			continue
+		}
+		results, err := e.Check(unit)
+		if err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, results...)
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		a, b := report.Results[i].Range, report.Results[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return report, nil
+}
+
+// Check evaluates the rules against one unit whose module resolves. An error
+// means a rule is broken, not that the unit violates it.
+func (e *Engine) Check(unit *inputs.Unit) ([]Result, error) {
+	var ctx *hcl.EvalContext
+	var results []Result
+	for _, rule := range e.Rules {
+		severity := rule.SeverityFor(unit.Env)
+		if severity == Off || (rule.Module != "" && rule.Module != unit.Module.Name) {
+			continue
+		}
+
+		var violations []Violation
+		if rule.Check != nil {
+			violations = rule.Check(unit)
+		} else {
+			if ctx == nil {
+				var err error
+				if ctx, err = unit.Module.EvalContext(unit.Variables()); err != nil {
+					return nil, fmt.Errorf("%s: %w", unit.Path, err)
+				}
+			}
+			violation, err := evaluate(rule, unit, ctx)
+			if err != nil {
+				return nil, err
+			}
+			if violation != nil {
+				violations = append(violations, *violation)
+			}
+		}
+
+		for _, violation := range violations {
+			result := Result{
+				Rule:        rule.ID,
+				Environment: unit.Env,
+				Unit:        unit.Path,
+				Severity:    severity,
+				Message:     violation.Message,
+				Range:       violation.Range,
+				Location:    location(violation.Range),
+			}
+			e.waive(&result, unit)
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func location(rng hcl.Range) string {
+	return fmt.Sprintf("%s:%d:%d", rng.Filename, rng.Start.Line, rng.Start.Column)
+}
+
+// evaluate returns the violation of a YAML rule, or nil when the condition
+// holds or is unknown offline
+func evaluate(rule *Rule, unit *inputs.Unit, ctx *hcl.EvalContext) (*Violation, error) {
+	var resource *tfmodule.Resource
+	if rule.Resource != "" {
+		if resource = unit.Module.Resource(rule.Resource); resource == nil {
+			return nil, fmt.Errorf("rule %s: module %s has no resource %s", rule.ID, unit.Module.Name, rule.Resource)
+		}
+		ctx = ctx.NewChild()
+		ctx.Variables = map[string]cty.Value{"self": bodyValue(resource.Body, ctx)}
+	}
+
+	value, diags := rule.condition.Value(ctx)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("rule %s: %s: %w", rule.ID, unit.Path, diags)
+	}
+	if !value.IsKnown() {
+		return nil, nil
+	}
+	if value.IsNull() || !value.Type().Equals(cty.Bool) {
+		return nil, fmt.Errorf("rule %s: %s: condition must be true or false", rule.ID, unit.Path)
+	}
+	if value.True() {
+		return nil, nil
+	}
+
+	message := rule.Message
+	if message == "" {
+		message = rule.Description
+	}
+	rng, defaulted := blame(rule, unit, resource)
+	if defaulted != "" {
+		message += fmt.Sprintf(" (%s is the module default)", defaulted)
+	}
+	return &Violation{Message: message, Range: rng}, nil
+}
+
+// bodyValue evaluates the arguments and nested blocks of a resource into an
+// object. Arguments that cannot be evaluated are unknown; a block type that
+// appears more than once becomes a tuple.
+func bodyValue(body *hclsyntax.Body, ctx *hcl.EvalContext) cty.Value {
+	attrs := map[string]cty.Value{}
+	for name, attr := range body.Attributes {
+		value, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			value = cty.DynamicVal
+		}
+		attrs[name] = value
+	}
+
+	blocks := map[string][]cty.Value{}
+	for _, block := range body.Blocks {
+		blocks[block.Type] = append(blocks[block.Type], bodyValue(block.Body, ctx))
+	}
+	for typ, values := range blocks {
+		if len(values) == 1 {
+			attrs[typ] = values[0]
+		} else {
+			attrs[typ] = cty.TupleVal(values)
+		}
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// blame returns where a violation is reported: the first input the condition
+// depends on that the unit sets, or the unit's terraform.source when every
+// such variable keeps its module default, together with that variable's name
+func blame(rule *Rule, unit *inputs.Unit, resource *tfmodule.Resource) (hcl.Range, string) {
+	var variables []string
+	for _, traversal := range rule.condition.Variables() {
+		switch traversal.RootName() {
+		case "var":
+			variables = append(variables, tfmodule.ReferencedVariables(&hclsyntax.ScopeTraversalExpr{Traversal: traversal})...)
+		case "self":
+			if resource == nil || len(traversal) < 2 {
+				continue
+			}
+			if step, ok := traversal[1].(hcl.TraverseAttr); ok {
+				variables = append(variables, selfVariables(resource.Body, step.Name)...)
+			}
+		}
+	}
+
+	for _, name := range variables {
+		if input, ok := unit.Values[name]; ok {
+			return input.Range, ""
+		}
+	}
+	defaulted := ""
+	if len(variables) > 0 {
+		defaulted = variables[0]
+	}
+	return unit.SourceRange, defaulted
+}
+
+// selfVariables returns the variables an argument or nested block of a
+// resource refers to
+func selfVariables(body *hclsyntax.Body, name string) []string {
+	if attr, ok := body.Attributes[name]; ok {
+		return tfmodule.ReferencedVariables(attr.Expr)
+	}
+	var variables []string
+	for _, block := range body.Blocks {
+		if block.Type != name {
+			continue
+		}
+		for _, attr := range sortedAttributes(block.Body) {
+			variables = append(variables, tfmodule.ReferencedVariables(attr.Expr)...)
+		}
+	}
+	return variables
+}
+
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte })
+	return attrs
+}
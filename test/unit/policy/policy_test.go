@@ -0,0 +1,222 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func repoRules(t *testing.T) []*Rule {
+	t.Helper()
+	root, err := repo.Root()
+	require.NoError(t, err)
+	rules, err := LoadRules(filepath.Join(root, "test", "unit", "policy", "rules.yaml"))
+	require.NoError(t, err)
+	return rules
+}
+
+func lines(report *Report) string {
+	var out bytes.Buffer
+	_ = report.WriteText(&out)
+	return out.String()
+}
+
+func TestEnvironmentsPassPolicy(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+	waivers, err := LoadWaivers(filepath.Join(root, "test", "unit", "policy", "waivers.yaml"))
+	require.NoError(t, err)
+
+	report, err := (&Engine{Rules: repoRules(t), Waivers: waivers}).Evaluate(root, "")
+	require.NoError(t, err)
+	for _, result := range report.Results {
+		if result.Fails() {
+			t.Error(result)
+		}
+	}
+}
+
+const openVault = `  key_vault_name              = "kv-policy-001"
+  purge_protection_enabled    = false
+  network_acls_default_action = "Allow"
+  tags                        = { Environment = "production", Project = "gogs-infra", ManagedBy = "Terragrunt" }
+`
+
+func TestSeverityDependsOnEnvironment(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "production", "key-vault", "key-vault", repotest.Inputs(openVault))
+	repotest.WriteUnit(t, root, "staging", "key-vault", "key-vault", repotest.Inputs(strings.Replace(openVault, `Environment = "production"`, `Environment = "staging"`, 1)))
+
+	report, err := (&Engine{Rules: repoRules(t)}).Evaluate(root, "")
+	require.NoError(t, err)
+	// a variable left at its module default is blamed on terraform.source
+	assert.Equal(t, `environments/production/key-vault/terragrunt.hcl:2:12: error: [GOGS-KV-002] Deleted Key Vault secrets stay recoverable for at least 30 days (soft_delete_retention_days is the module default)
+environments/production/key-vault/terragrunt.hcl:7:33: error: [GOGS-KV-001] Production Key Vaults enable purge protection and deny network access by default
+environments/staging/key-vault/terragrunt.hcl:2:12: note: [GOGS-KV-002] Deleted Key Vault secrets stay recoverable for at least 30 days (soft_delete_retention_days is the module default)
+environments/staging/key-vault/terragrunt.hcl:7:33: note: [GOGS-KV-001] Production Key Vaults enable purge protection and deny network access by default
+`, lines(report))
+	assert.True(t, report.Failed())
+
+	report, err = (&Engine{Rules: repoRules(t)}).Evaluate(root, "staging")
+	require.NoError(t, err)
+	assert.Len(t, report.Results, 2)
+	assert.False(t, report.Failed(), "notes do not fail")
+}
+
+func TestViolationPointsAtTheInput(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "production", "key-vault", "key-vault", repotest.Inputs(openVault+"  soft_delete_retention_days = 14\n"))
+
+	report, err := (&Engine{Rules: repoRules(t)}).Evaluate(root, "production")
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "environments/production/key-vault/terragrunt.hcl:7:33", report.Results[0].Location, "purge_protection_enabled")
+	assert.Equal(t, "GOGS-KV-001", report.Results[0].Rule)
+	assert.Equal(t, "environments/production/key-vault/terragrunt.hcl:10:32", report.Results[1].Location, "soft_delete_retention_days via self")
+	assert.Equal(t, "Deleted Key Vault secrets stay recoverable for at least 30 days", report.Results[1].Message)
+}
+
+func TestWaivers(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "production", "key-vault", "key-vault", repotest.Inputs(openVault+"  soft_delete_retention_days = 90\n"))
+	path := filepath.Join(t.TempDir(), "waivers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`waivers:
+  - rule: GOGS-KV-001
+    environment: production
+    unit: key-vault
+    reason: migration pending
+    expires: 2026-06-30
+`), 0o644))
+	waivers, err := LoadWaivers(path)
+	require.NoError(t, err)
+
+	engine := &Engine{Rules: repoRules(t), Waivers: waivers, Now: time.Date(2026, 6, 30, 23, 0, 0, 0, time.UTC)}
+	report, err := engine.Evaluate(root, "")
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.False(t, report.Failed())
+	assert.Equal(t, "migration pending", report.Results[0].Waiver.Reason)
+	assert.Contains(t, report.Results[0].String(), "(waived until 2026-06-30: migration pending)")
+
+	engine.Now = time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	report, err = engine.Evaluate(root, "")
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.True(t, report.Failed(), "expired waivers no longer apply")
+	assert.Contains(t, report.Results[0].String(), "(waiver expired on 2026-06-30)")
+
+	engine.Waivers = []Waiver{{Rule: "GOGS-KV-999", Environment: "production"}}
+	_, err = engine.Evaluate(root, "")
+	assert.EqualError(t, err, "waiver for unknown rule GOGS-KV-999")
+
+	require.NoError(t, os.WriteFile(path, []byte("waivers:\n  - rule: GOGS-KV-001\n    environment: production\n    reason: forever\n    expires: someday\n  - rule: GOGS-KV-002\n"), 0o644))
+	_, err = LoadWaivers(path)
+	assert.ErrorContains(t, err, `waiver 1: expires "someday" is not a YYYY-MM-DD date`)
+	assert.ErrorContains(t, err, "waiver 2 needs a rule, environment, reason and expires")
+}
+
+func TestTagRule(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "staging", "key-vault", "key-vault", repotest.Inputs(`  key_vault_name = "kv-policy-001"
+  tags           = { Environment = "production" }
+`))
+
+	report, err := (&Engine{Rules: GoRules}).Evaluate(root, "")
+	require.NoError(t, err)
+	assert.Equal(t, `environments/staging/key-vault/terragrunt.hcl:7:20: error: [GOGS-TAG-001] tags has no Project tag
+environments/staging/key-vault/terragrunt.hcl:7:20: error: [GOGS-TAG-001] tags has no ManagedBy tag
+environments/staging/key-vault/terragrunt.hcl:7:20: error: [GOGS-TAG-001] tag Environment is "production" in environment "staging"
+`, lines(report))
+}
+
+func TestLoadRulesRejectsInvalidRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`rules:
+  - id: BAD-001
+    resource: azurerm_key_vault.main
+    condition: dependency.kv.outputs.id != ""
+    severity:
+      production: fatal
+`), 0o644))
+	_, err := LoadRules(path)
+	require.Error(t, err)
+	for _, want := range []string{
+		"rule BAD-001 has no description",
+		"rule BAD-001 names a resource but no module",
+		`severity "fatal" for production is not one of error, warning, note, off`,
+		"condition refers to dependency; only var, local and self are available",
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - id: GOGS-TAG-001\n    description: again\n    condition: true\n"), 0o644))
+	_, err = LoadRules(path)
+	assert.EqualError(t, err, "rule GOGS-TAG-001 is declared twice")
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - id: X\n    descripton: typo\n"), 0o644))
+	_, err = LoadRules(path)
+	assert.ErrorContains(t, err, "field descripton not found")
+}
+
+func TestWriteSARIF(t *testing.T) {
+	root := t.TempDir()
+	repotest.WriteUnit(t, root, "production", "key-vault", "key-vault", repotest.Inputs(openVault+"  soft_delete_retention_days = 90\n"))
+	engine := &Engine{Rules: repoRules(t), Waivers: []Waiver{{Rule: "GOGS-KV-001", Environment: "production", Reason: "pending", Expires: "2999-01-01", expires: time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)}}}
+	report, err := engine.Evaluate(root, "")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteSARIF(&out))
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+				Suppressions []struct {
+					Kind string `json:"kind"`
+				} `json:"suppressions"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	require.Len(t, run.Results, 1)
+	result := run.Results[0]
+	assert.Equal(t, "GOGS-KV-001", result.RuleID)
+	assert.Equal(t, "GOGS-KV-001", run.Tool.Driver.Rules[result.RuleIndex].ID)
+	assert.Equal(t, "error", result.Level)
+	assert.Equal(t, "environments/production/key-vault/terragrunt.hcl", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, 7, result.Locations[0].PhysicalLocation.Region.StartLine)
+	require.Len(t, result.Suppressions, 1)
+	assert.Equal(t, "external", result.Suppressions[0].Kind)
+}
@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Report holds the results of an evaluation and the rules evaluated
+type Report struct {
+	Rules   []*Rule  `json:"rules"`
+	Results []Result `json:"results"`
+}
+
+// Failed reports whether any result fails the check
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Fails() {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of failing, waived and other results
+func (r *Report) Counts() (failing int, waived int, other int) {
+	for _, result := range r.Results {
+		switch {
+		case result.Fails():
+			failing++
+		case result.Waiver != nil:
+			waived++
+		default:
+			other++
+		}
+	}
+	return failing, waived, other
+}
+
+// WriteText writes one line per result
+func (r *Report) WriteText(w io.Writer) error {
+	for _, result := range r.Results {
+		if _, err := fmt.Fprintln(w, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// SARIF 2.1.0, the subset code scanning reads
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri,omitempty"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string       `json:"id"`
+		ShortDescription     sarifMessage `json:"shortDescription"`
+		FullDescription      sarifMessage `json:"fullDescription"`
+		DefaultConfiguration sarifConfig  `json:"defaultConfiguration"`
+	}
+	sarifConfig struct {
+		Level string `json:"level"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID       string             `json:"ruleId"`
+		RuleIndex    int                `json:"ruleIndex"`
+		Level        string             `json:"level"`
+		Message      sarifMessage       `json:"message"`
+		Locations    []sarifLocation    `json:"locations"`
+		Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+		Properties   map[string]string  `json:"properties,omitempty"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           sarifRegion   `json:"region"`
+	}
+	sarifArtifact struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine"`
+		EndColumn   int `json:"endColumn"`
+	}
+	sarifSuppression struct {
+		Kind          string `json:"kind"`
+		Justification string `json:"justification"`
+	}
+)
+
+// sarifLevel maps a severity to a SARIF level
+func sarifLevel(severity Severity) string {
+	if severity == Off {
+		return "none"
+	}
+	return string(severity)
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log. Locations are relative
+// to the repository root. Waived results carry an external suppression with
+// the waiver's reason, so code scanning shows them as dismissed.
+func (r *Report) WriteSARIF(w io.Writer) error {
+	driver := sarifDriver{
+		Name:           "gogs-policy",
+		InformationURI: "https://github.com/EzequielAndreus/gogs-fork-infrastructure-azure/tree/main/test/unit/policy",
+		Rules:          []sarifRule{},
+	}
+	index := map[string]int{}
+	for i, rule := range r.Rules {
+		index[rule.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			FullDescription:      sarifMessage{Text: fmt.Sprintf("%s (%s)", rule.Description, Describe(rule))},
+			DefaultConfiguration: sarifConfig{Level: sarifLevel(rule.SeverityFor(DefaultEnvironment))},
+		})
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	for _, result := range r.Results {
+		rng := result.Range
+		sarif := sarifResult{
+			RuleID:    result.Rule,
+			RuleIndex: index[result.Rule],
+			Level:     sarifLevel(result.Severity),
+			Message:   sarifMessage{Text: result.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifact{URI: rng.Filename, URIBaseID: "%SRCROOT%"},
+				Region: sarifRegion{
+					StartLine:   rng.Start.Line,
+					StartColumn: rng.Start.Column,
+					EndLine:     rng.End.Line,
+					EndColumn:   rng.End.Column,
+				},
+			}}},
+			Properties: map[string]string{"environment": result.Environment},
+		}
+		if result.Waiver != nil {
+			sarif.Suppressions = []sarifSuppression{{
+				Kind:          "external",
+				Justification: fmt.Sprintf("%s (waived until %s)", result.Waiver.Reason, result.Waiver.Expires),
+			}}
+		}
+		if result.ExpiredWaiver != nil {
+			sarif.Properties["expiredWaiver"] = result.ExpiredWaiver.Expires
+		}
+		run.Results = append(run.Results, sarif)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// GoRules are the rules implemented in Go
+var GoRules = []*Rule{
+	{
+		ID:          "GOGS-TAG-001",
+		Description: "Units tag their resources with Environment, Project and ManagedBy, and Environment names the unit's environment",
+		Severity:    map[string]Severity{DefaultEnvironment: Error},
+		Check:       checkTags,
+	},
+}
+
+// RequiredTags are the tags GOGS-TAG-001 requires
+var RequiredTags = []string{"Environment", "Project", "ManagedBy"}
+
+func checkTags(unit *inputs.Unit) []Violation {
+	if _, ok := unit.Module.Variables["tags"]; !ok {
+		return nil
+	}
+	input, set := unit.Values["tags"]
+	if !set {
+		return []Violation{{Message: "tags is not set", Range: unit.SourceRange}}
+	}
+	value := input.Value
+	if !value.IsWhollyKnown() || value.IsNull() || !value.CanIterateElements() {
+		return nil
+	}
+
+	tags := map[string]string{}
+	for it := value.ElementIterator(); it.Next(); {
+		key, element := it.Element()
+		if element.Type().Equals(cty.String) && !element.IsNull() {
+			tags[key.AsString()] = element.AsString()
+		}
+	}
+	var violations []Violation
+	for _, name := range RequiredTags {
+		if tags[name] == "" {
+			violations = append(violations, Violation{Message: fmt.Sprintf("tags has no %s tag", name), Range: input.Range})
+		}
+	}
+	if environment, ok := tags["Environment"]; ok && environment != unit.Env {
+		violations = append(violations, Violation{
+			Message: fmt.Sprintf("tag Environment is %q in environment %q", environment, unit.Env),
+			Range:   input.Range,
+		})
+	}
+	return violations
+}
+
+// ruleFile is the layout of a YAML rule file
+type ruleFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// LoadRules returns the Go rules followed by the rules of the YAML files.
+// Rule IDs must be unique, and every YAML rule needs a description and a
+// condition that parses.
+func LoadRules(paths ...string) ([]*Rule, error) {
+	rules := append([]*Rule(nil), GoRules...)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		var file ruleFile
+		if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, rule := range file.Rules {
+			if err := rule.parse(path); err != nil {
+				return nil, err
+			}
+		}
+		rules = append(rules, file.Rules...)
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("rule %s is declared twice", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+	return rules, nil
+}
+
+// parse checks a YAML rule and parses its condition
+func (r *Rule) parse(path string) error {
+	var errs []error
+	if r.ID == "" {
+		return fmt.Errorf("%s: a rule has no id", path)
+	}
+	if r.Description == "" {
+		errs = append(errs, fmt.Errorf("%s: rule %s has no description", path, r.ID))
+	}
+	if r.Resource != "" && r.Module == "" {
+		errs = append(errs, fmt.Errorf("%s: rule %s names a resource but no module", path, r.ID))
+	}
+	for env, severity := range r.Severity {
+		switch severity {
+		case Error, Warning, Note, Off:
+		default:
+			errs = append(errs, fmt.Errorf("%s: rule %s: severity %q for %s is not one of error, warning, note, off", path, r.ID, severity, env))
+		}
+	}
+
+	if r.Condition == "" {
+		errs = append(errs, fmt.Errorf("%s: rule %s has no condition", path, r.ID))
+	} else {
+		expr, diags := hclsyntax.ParseExpression([]byte(r.Condition), path, hcl.InitialPos)
+		if diags.HasErrors() {
+			errs = append(errs, fmt.Errorf("%s: rule %s: condition: %w", path, r.ID, diags))
+		}
+		r.condition = expr
+		for _, traversal := range expr.Variables() {
+			switch root := traversal.RootName(); root {
+			case "var", "local", "self":
+			default:
+				errs = append(errs, fmt.Errorf("%s: rule %s: condition refers to %s; only var, local and self are available", path, r.ID, root))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Describe summarizes a rule's severities, e.g. "production: error, default: note"
+func Describe(rule *Rule) string {
+	var envs []string
+	for env := range rule.Severity {
+		if env != DefaultEnvironment {
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
+
+	var parts []string
+	for _, env := range envs {
+		parts = append(parts, fmt.Sprintf("%s: %s", env, rule.Severity[env]))
+	}
+	parts = append(parts, fmt.Sprintf("%s: %s", DefaultEnvironment, rule.SeverityFor(DefaultEnvironment)))
+	return strings.Join(parts, ", ")
+}
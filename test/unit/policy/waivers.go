@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/inputs"
+	"gopkg.in/yaml.v3"
+)
+
+// DateLayout is the layout of Waiver.Expires
+const DateLayout = "2006-01-02"
+
+// Waiver accepts the violations of a rule in an environment, or in one unit
+// of it, until the end of the day it expires. An expired waiver no longer
+// applies and is reported next to the violation.
+//
+//	waivers:
+//	  - rule: GOGS-KV-001
+//	    environment: production
+//	    unit: key-vault
+//	    reason: Purge protection is enabled by the migration in the linked ticket
+//	    expires: 2026-12-31
+type Waiver struct {
+	Rule        string `yaml:"rule" json:"rule"`
+	Environment string `yaml:"environment" json:"environment"`
+	// Unit is the unit's directory name; empty waives every unit of the
+	// environment
+	Unit    string `yaml:"unit" json:"unit,omitempty"`
+	Reason  string `yaml:"reason" json:"reason"`
+	Expires string `yaml:"expires" json:"expires"`
+
+	expires time.Time
+}
+
+type waiverFile struct {
+	Waivers []Waiver `yaml:"waivers"`
+}
+
+// LoadWaivers reads a waiver file. Every waiver needs a rule, an environment,
+// a reason and an expiry date.
+func LoadWaivers(path string) ([]Waiver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file waiverFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	for i := range file.Waivers {
+		waiver := &file.Waivers[i]
+		if waiver.Rule == "" || waiver.Environment == "" || waiver.Reason == "" || waiver.Expires == "" {
+			errs = append(errs, fmt.Errorf("%s: waiver %d needs a rule, environment, reason and expires", path, i+1))
+			continue
+		}
+		expires, err := time.Parse(DateLayout, waiver.Expires)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: waiver %d: expires %q is not a YYYY-MM-DD date", path, i+1, waiver.Expires))
+			continue
+		}
+		waiver.expires = expires
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return file.Waivers, nil
+}
+
+// Expired reports whether the waiver has expired at now. A waiver is valid
+// through the whole day it expires, in UTC.
+func (w Waiver) Expired(now time.Time) bool {
+	return !now.UTC().Before(w.expires.AddDate(0, 0, 1))
+}
+
+// checkWaivers rejects waivers of rules the engine does not have, which are
+// usually typos that would otherwise waive nothing
+func (e *Engine) checkWaivers() error {
+	ids := map[string]bool{}
+	for _, rule := range e.Rules {
+		ids[rule.ID] = true
+	}
+	var errs []error
+	for _, waiver := range e.Waivers {
+		if !ids[waiver.Rule] {
+			errs = append(errs, fmt.Errorf("waiver for unknown rule %s", waiver.Rule))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waive attaches the waiver matching the result. A valid waiver wins over an
+// expired one.
+func (e *Engine) waive(result *Result, unit *inputs.Unit) {
+	now := e.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for i := range e.Waivers {
+		waiver := &e.Waivers[i]
+		if waiver.Rule != result.Rule || waiver.Environment != unit.Env || (waiver.Unit != "" && waiver.Unit != unit.Name) {
+			continue
+		}
+		if waiver.Expired(now) {
+			result.ExpiredWaiver = waiver
+			continue
+		}
+		result.Waiver = waiver
+		result.ExpiredWaiver = nil
+		return
+	}
+}
@@ -1,86 +1,83 @@
 package test
 
 import (
-	"fmt"
-	"strings"
+	"context"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/azure"
-	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/fixtures"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/naming"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/tfvars"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestResourceGroupModule tests the resource-group module
 func TestResourceGroupModule(t *testing.T) {
 	t.Parallel()
 
-	// Generate a random suffix to ensure unique resource names
-	uniqueID := strings.ToLower(random.UniqueId())
-	resourceGroupName := fmt.Sprintf("test-rg-%s", uniqueID)
-	location := "eastus"
-
-	// Terraform options for the module
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/resource-group",
-
-		Vars: map[string]interface{}{
-			"resource_group_name": resourceGroupName,
-			"location":            location,
-			"tags": map[string]string{
-				"Environment": "test",
-				"ManagedBy":   "terratest",
-				"TestID":      uniqueID,
-			},
-		},
+	fixtures.Setup(t, func() {
+		// Names share a random suffix to ensure unique resource names
+		names := naming.For(t)
+
+		// Terraform options for the module
+		rgVars := tfvars.NewResourceGroupVars()
+		rgVars.ResourceGroupName = names.Name(naming.ResourceGroup, "test-rg")
+		rgVars.Location = "eastus"
+		rgVars.Tags = names.Tags(map[string]string{
+			"Environment": "test",
+		})
+		terraformOptions := rgVars.ToOptions(t)
+
+		// Create the resources, destroying them in the teardown stage
+		fixtures.Apply(t, terraformOptions)
 	})
 
-	// Defer destruction of resources
-	defer terraform.Destroy(t, terraformOptions)
+	fixtures.Validate(t, func() {
+		terraformOptions := fixtures.LoadOptions(t, "resource-group")
+		resourceGroupName := terraformOptions.Vars["resource_group_name"].(string)
 
-	// Create the resources
-	terraform.InitAndApply(t, terraformOptions)
+		// Validate outputs
+		outputName := terraform.Output(t, terraformOptions, "resource_group_name")
+		outputLocation := terraform.Output(t, terraformOptions, "resource_group_location")
+		outputID := terraform.Output(t, terraformOptions, "resource_group_id")
 
-	// Validate outputs
-	outputName := terraform.Output(t, terraformOptions, "name")
-	outputLocation := terraform.Output(t, terraformOptions, "location")
-	outputID := terraform.Output(t, terraformOptions, "id")
+		// Assertions
+		assert.Equal(t, resourceGroupName, outputName, "Resource group name should match")
+		assert.Equal(t, terraformOptions.Vars["location"], outputLocation, "Location should match")
+		assert.NotEmpty(t, outputID, "Resource group ID should not be empty")
 
-	// Assertions
-	assert.Equal(t, resourceGroupName, outputName, "Resource group name should match")
-	assert.Equal(t, location, outputLocation, "Location should match")
-	assert.NotEmpty(t, outputID, "Resource group ID should not be empty")
-
-	// Verify the resource group exists in Azure
-	subscriptionID := azure.GetTargetAzureSubscription(t)
-	exists := azure.ResourceGroupExists(t, resourceGroupName, subscriptionID)
-	assert.True(t, exists, "Resource group should exist in Azure")
+		// Verify the resource group exists in Azure
+		exists, err := fixtures.Checker(t).ResourceGroupExists(context.Background(), resourceGroupName)
+		require.NoError(t, err)
+		assert.True(t, exists, "Resource group should exist in Azure")
+	})
 }
 
 // TestResourceGroupModuleWithCustomTags tests resource group with custom tags
 func TestResourceGroupModuleWithCustomTags(t *testing.T) {
 	t.Parallel()
 
-	uniqueID := strings.ToLower(random.UniqueId())
-	resourceGroupName := fmt.Sprintf("test-rg-tags-%s", uniqueID)
-
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/resource-group",
-
-		Vars: map[string]interface{}{
-			"resource_group_name": resourceGroupName,
-			"location":            "westus2",
-			"tags": map[string]string{
-				"Environment": "test",
-				"Project":     "infrastructure-test",
-				"CostCenter":  "testing",
-			},
-		},
+	fixtures.Setup(t, func() {
+		names := naming.For(t)
+
+		rgVars := tfvars.NewResourceGroupVars()
+		rgVars.ResourceGroupName = names.Name(naming.ResourceGroup, "test-rg-tags")
+		rgVars.Location = "westus2"
+		rgVars.Tags = names.Tags(map[string]string{
+			"Environment": "test",
+			"Project":     "infrastructure-test",
+			"CostCenter":  "testing",
+		})
+		terraformOptions := rgVars.ToOptions(t)
+
+		fixtures.Apply(t, terraformOptions)
 	})
 
-	defer terraform.Destroy(t, terraformOptions)
-	terraform.InitAndApply(t, terraformOptions)
+	fixtures.Validate(t, func() {
+		terraformOptions := fixtures.LoadOptions(t, "resource-group")
 
-	outputName := terraform.Output(t, terraformOptions, "name")
-	assert.Equal(t, resourceGroupName, outputName)
+		outputName := terraform.Output(t, terraformOptions, "resource_group_name")
+		assert.Equal(t, terraformOptions.Vars["resource_group_name"], outputName)
+	})
 }
@@ -0,0 +1,230 @@
+package sensitive
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Audit traces the secrets of every module under root/modules and of every
+// unit under root/environments. Ranges are relative to root.
+func Audit(root string) ([]Finding, error) {
+	a := &auditor{root: root, analyses: map[string]*Analysis{}}
+
+	modules, err := tfmodule.LoadAll(filepath.Join(root, "modules"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		analysis := Analyze(modules[name])
+		a.analyses[modules[name].Dir] = analysis
+		a.add(analysis.Findings())
+	}
+
+	paths, err := contract.FindUnits(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err := a.unit(path); err != nil {
+			return nil, err
+		}
+	}
+	return a.findings, nil
+}
+
+type auditor struct {
+	root     string
+	analyses map[string]*Analysis
+	findings []Finding
+	seen     map[string]bool
+}
+
+// add appends the findings not reported yet, with their ranges relative to
+// the repository root
+func (a *auditor) add(findings []Finding) {
+	if a.seen == nil {
+		a.seen = map[string]bool{}
+	}
+	for _, finding := range findings {
+		finding.Range = relative(finding.Range, a.root)
+		key := fmt.Sprintf("%s %s", finding.Range, finding.Sink)
+		if a.seen[key] {
+			continue
+		}
+		a.seen[key] = true
+		a.findings = append(a.findings, finding)
+	}
+}
+
+// analysis returns the analysis of the module a unit applies, nil when its
+// source does not resolve to a module
+func (a *auditor) analysis(unit *contract.Unit) (*Analysis, error) {
+	if unit.ModuleDir == "" {
+		return nil, nil
+	}
+	if analysis, ok := a.analyses[unit.ModuleDir]; ok {
+		return analysis, nil
+	}
+	module, err := tfmodule.Load(unit.ModuleDir)
+	if err != nil {
+		return nil, err
+	}
+	analysis := Analyze(module)
+	a.analyses[unit.ModuleDir] = analysis
+	a.add(analysis.Findings())
+	return analysis, nil
+}
+
+// unit checks that the secrets a unit passes to its module land in sensitive
+// variables, and traces them through the module when the module does not
+// expect a secret there
+func (a *auditor) unit(path string) error {
+	unit, err := contract.ParseUnit(a.root, path)
+	if err != nil {
+		return err
+	}
+	analysis, err := a.analysis(unit)
+	if err != nil || analysis == nil {
+		return err
+	}
+	body, err := contract.ParseConfig(path, unit.Path)
+	if err != nil {
+		return err
+	}
+	attr, ok := body.Attributes["inputs"]
+	if !ok {
+		return nil
+	}
+	object, ok := attr.Expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil
+	}
+
+	var extra []string
+	for _, item := range object.Items {
+		name := hcl.ExprAsKeyword(item.KeyExpr)
+		variable, declared := analysis.Module.Variables[name]
+		if !declared {
+			continue
+		}
+		source, err := a.secret(unit, item.ValueExpr)
+		if err != nil {
+			return err
+		}
+		if source == "" {
+			continue
+		}
+		if !variable.Sensitive {
+			a.add([]Finding{{
+				Module:  analysis.Module.Name,
+				Unit:    unit.Path,
+				Sink:    "var." + name,
+				Path:    []string{source, "var." + name},
+				Message: fmt.Sprintf("input %s carries %s but variable %s of module %s is not sensitive", name, source, name, analysis.Module.Name),
+				Range:   item.ValueExpr.Range(),
+			}})
+		}
+		if !analysis.Carries("var." + name) {
+			extra = append(extra, name)
+		}
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+
+	findings := Analyze(analysis.Module, extra...).Findings()
+	for i := range findings {
+		findings[i].Unit = unit.Path
+	}
+	a.add(findings)
+	return nil
+}
+
+// secret returns what makes an input value a secret: a sensitive or secret
+// carrying dependency output, or get_env of a variable named like a secret.
+// It is empty when the value is not a secret.
+func (a *auditor) secret(unit *contract.Unit, expr hclsyntax.Expression) (string, error) {
+	var source string
+	var err error
+	hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if source != "" || err != nil {
+			return nil
+		}
+		switch node := node.(type) {
+		case *hclsyntax.FunctionCallExpr:
+			if node.Name != "get_env" || len(node.Args) == 0 {
+				return nil
+			}
+			value, diags := node.Args[0].Value(nil)
+			if !diags.HasErrors() && value.Type().Equals(cty.String) && value.IsKnown() && !value.IsNull() && SecretName.MatchString(value.AsString()) {
+				source = fmt.Sprintf("get_env(%q)", value.AsString())
+			}
+		case *hclsyntax.ScopeTraversalExpr:
+			ref, ok := outputRef(node.Traversal)
+			if !ok {
+				return nil
+			}
+			var carries bool
+			carries, err = a.output(unit, ref)
+			if carries {
+				source = fmt.Sprintf("dependency.%s.outputs.%s", ref.Dependency, ref.Output)
+			}
+		}
+		return nil
+	})
+	return source, err
+}
+
+// outputRef recognizes dependency.<name>.outputs.<key>
+func outputRef(traversal hcl.Traversal) (contract.OutputRef, bool) {
+	if len(traversal) < 4 || traversal.RootName() != "dependency" {
+		return contract.OutputRef{}, false
+	}
+	dependency, ok1 := traversal[1].(hcl.TraverseAttr)
+	outputs, ok2 := traversal[2].(hcl.TraverseAttr)
+	output, ok3 := traversal[3].(hcl.TraverseAttr)
+	if !ok1 || !ok2 || !ok3 || outputs.Name != "outputs" {
+		return contract.OutputRef{}, false
+	}
+	return contract.OutputRef{Dependency: dependency.Name, Output: output.Name}, true
+}
+
+// output reports whether a dependency output is sensitive or carries a secret
+func (a *auditor) output(unit *contract.Unit, ref contract.OutputRef) (bool, error) {
+	for _, dependency := range unit.Dependencies {
+		if dependency.Name != ref.Dependency {
+			continue
+		}
+		depUnit, err := contract.ParseUnit(a.root, filepath.Join(dependency.Dir, contract.UnitFileName))
+		if err != nil {
+			// tgcontract reports dependencies that do not resolve
+			return false, nil
+		}
+		analysis, err := a.analysis(depUnit)
+		if err != nil || analysis == nil {
+			return false, err
+		}
+		output, ok := analysis.Module.Outputs[ref.Output]
+		if !ok {
+			return false, nil
+		}
+		if output.Sensitive {
+			return true, nil
+		}
+		_, carries := analysis.carries(output.Value, nil)
+		return carries, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,367 @@
+// Package sensitive traces secrets through the modules and the Terragrunt
+// units wiring them together, without running Terraform.
+//
+// A secret starts in a variable marked sensitive or named like one (password,
+// secret, token, shared_key, ...), or in a provider attribute named like one,
+// such as azurerm_log_analytics_workspace.main.primary_shared_key. It flows
+// through locals, resource and data source arguments, for_each iterators and
+// dynamic blocks. It must not reach:
+//
+//   - an output that is not marked sensitive
+//   - tags, names or plain environment_variables, which Azure shows to anyone
+//     who can read the resource
+//
+// Across modules, a unit input fed by a sensitive dependency output or by
+// get_env of a secret must land in a variable marked sensitive, or Terraform
+// prints it in the plan.
+//
+// Terraform only enforces the first rule for variables marked sensitive, and
+// only when planning. This catches the rest in go test.
+package sensitive
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/contract"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// SecretName matches variable and attribute names that hold secrets
+var SecretName = regexp.MustCompile(`(?i)(password|passwd|secret|token|shared_key|access_key|private_key|primary_key|secondary_key|workspace_key|connection_string)`)
+
+// sinkName matches resource arguments whose values Azure shows in plain text
+var sinkName = regexp.MustCompile(`^(tags|name|.+_name|dns_name_label|environment_variables)$`)
+
+// nestedSinkName matches the sinks that count inside nested blocks
+var nestedSinkName = regexp.MustCompile(`^(tags|environment_variables)$`)
+
+// Finding is a secret that reaches somewhere it should not
+type Finding struct {
+	// Module is the module the finding is in; Unit is set for findings in the
+	// wiring of a Terragrunt unit
+	Module string
+	Unit   string
+	// Sink is where the secret ends up, e.g. output.connection_string
+	Sink string
+	// Path lists the nodes the secret flows through, from the source to the
+	// sink
+	Path    []string
+	Message string
+	Range   hcl.Range
+}
+
+func (f Finding) String() string {
+	return contract.Diagnostic{Range: f.Range, Message: f.Message}.String()
+}
+
+// Analysis is the secret flow within one module
+type Analysis struct {
+	Module *tfmodule.Module
+	// from maps every node carrying a secret to the node it got it from;
+	// sources map to themselves
+	from map[string]string
+}
+
+// Analyze traces the secrets of a module. extra names variables that carry
+// secrets in a particular unit even though the module does not know it.
+func Analyze(module *tfmodule.Module, extra ...string) *Analysis {
+	a := &Analysis{Module: module, from: map[string]string{}}
+	for name, variable := range module.Variables {
+		if variable.Sensitive || SecretName.MatchString(name) {
+			a.from["var."+name] = "var." + name
+		}
+	}
+	for _, name := range extra {
+		a.from["var."+name] = "var." + name
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, name := range sortedKeys(module.Locals) {
+			changed = a.flow("local."+name, module.Locals[name], nil) || changed
+		}
+		for _, resource := range a.resources() {
+			changed = a.body(resource.Address, resource.Body, a.resourceScope(resource), false) || changed
+		}
+	}
+	return a
+}
+
+// body propagates secrets into the arguments of a resource body. Arguments
+// of nested blocks are attributed to the top-level block, e.g.
+// azurerm_container_group.main.container, so a reference to the block
+// carries them.
+func (a *Analysis) body(node string, body *hclsyntax.Body, scope map[string]string, nested bool) bool {
+	changed := false
+	for _, name := range sortedKeys(body.Attributes) {
+		changed = a.flow(node+"."+name, body.Attributes[name].Expr, scope) || changed
+	}
+	for _, block := range body.Blocks {
+		inner := a.dynamicScope(block, scope)
+		blockNode := node
+		if !nested {
+			blockNode = node + "." + blockType(block)
+		}
+		changed = a.body(blockNode, block.Body, inner, true) || changed
+	}
+	return changed
+}
+
+// blockType returns the type of a block, or the label of a dynamic block
+func blockType(block *hclsyntax.Block) string {
+	if block.Type == "dynamic" && len(block.Labels) > 0 {
+		return block.Labels[0]
+	}
+	return block.Type
+}
+
+// dynamicScope adds the iterator of a dynamic block to scope when the block
+// iterates over a secret
+func (a *Analysis) dynamicScope(block *hclsyntax.Block, scope map[string]string) map[string]string {
+	if block.Type != "dynamic" || len(block.Labels) == 0 {
+		return scope
+	}
+	forEach, ok := block.Body.Attributes["for_each"]
+	if !ok {
+		return scope
+	}
+	origin, ok := a.carries(forEach.Expr, scope)
+	if !ok {
+		return scope
+	}
+	inner := copyScope(scope)
+	inner[iterator(block)] = origin
+	return inner
+}
+
+// resourceScope returns the scope of a resource body: each carries a secret
+// when for_each iterates over one
+func (a *Analysis) resourceScope(resource *tfmodule.Resource) map[string]string {
+	scope := map[string]string{}
+	if resource.ForEach != nil {
+		if origin, ok := a.carries(resource.ForEach, nil); ok {
+			scope["each"] = origin
+		}
+	}
+	return scope
+}
+
+// iterator returns the name a dynamic block's content refers to its element by
+func iterator(block *hclsyntax.Block) string {
+	if attr, ok := block.Body.Attributes["iterator"]; ok {
+		if name := hcl.ExprAsKeyword(attr.Expr); name != "" {
+			return name
+		}
+	}
+	return block.Labels[0]
+}
+
+func copyScope(scope map[string]string) map[string]string {
+	copied := map[string]string{}
+	for k, v := range scope {
+		copied[k] = v
+	}
+	return copied
+}
+
+// flow marks node as carrying a secret when expr refers to one, and reports
+// whether that is new
+func (a *Analysis) flow(node string, expr hcl.Expression, scope map[string]string) bool {
+	if _, done := a.from[node]; done {
+		return false
+	}
+	origin, ok := a.carries(expr, scope)
+	if !ok {
+		return false
+	}
+	a.from[node] = origin
+	return true
+}
+
+// carries returns the first node carrying a secret that expr refers to
+func (a *Analysis) carries(expr hcl.Expression, scope map[string]string) (string, bool) {
+	for _, traversal := range expr.Variables() {
+		if origin, ok := scope[traversal.RootName()]; ok {
+			return origin, true
+		}
+		node := Node(traversal)
+		if node == "" {
+			continue
+		}
+		if _, ok := a.from[node]; ok {
+			return node, true
+		}
+		// a reference to a whole resource or block carries its arguments
+		for carrier := range a.from {
+			if strings.HasPrefix(carrier, node+".") {
+				return carrier, true
+			}
+		}
+		// provider attributes holding secrets, such as primary_shared_key
+		if parts := strings.Split(node, "."); !strings.HasPrefix(node, "var.") && !strings.HasPrefix(node, "local.") && SecretName.MatchString(parts[len(parts)-1]) {
+			a.from[node] = node
+			return node, true
+		}
+	}
+	return "", false
+}
+
+// Node names what a traversal refers to: var.<name>, local.<name>,
+// <type>.<name>.<attribute> or data.<type>.<name>.<attribute>, ignoring
+// index steps. It is empty for count, each, path and the like.
+func Node(traversal hcl.Traversal) string {
+	parts := []string{traversal.RootName()}
+	for _, step := range traversal[1:] {
+		if attr, ok := step.(hcl.TraverseAttr); ok {
+			parts = append(parts, attr.Name)
+		}
+	}
+
+	want := 3
+	switch parts[0] {
+	case "var", "local":
+		want = 2
+	case "data":
+		want = 4
+	case "count", "each", "path", "terraform", "self":
+		return ""
+	}
+	if len(parts) > want {
+		parts = parts[:want]
+	}
+	return strings.Join(parts, ".")
+}
+
+// Carries reports whether a node carries a secret
+func (a *Analysis) Carries(node string) bool {
+	_, ok := a.from[node]
+	return ok
+}
+
+// Path returns the nodes a secret flows through to reach node, from its
+// source
+func (a *Analysis) Path(node string) []string {
+	path := []string{node}
+	for {
+		from, ok := a.from[node]
+		if !ok || from == node {
+			break
+		}
+		path = append([]string{from}, path...)
+		node = from
+	}
+	return path
+}
+
+// Findings returns the variables named like secrets but not marked
+// sensitive, and the non-sensitive outputs and the tags, names and plain
+// environment variables that carry a secret
+func (a *Analysis) Findings() []Finding {
+	var findings []Finding
+	for _, name := range a.Module.VariableNames() {
+		variable := a.Module.Variables[name]
+		if SecretName.MatchString(name) && !variable.Sensitive {
+			findings = append(findings, Finding{
+				Module:  a.Module.Name,
+				Sink:    "var." + name,
+				Path:    []string{"var." + name},
+				Message: fmt.Sprintf("variable %s looks like a secret but is not sensitive, so plans print it", name),
+				Range:   variable.Range,
+			})
+		}
+	}
+	for _, name := range a.Module.OutputNames() {
+		output := a.Module.Outputs[name]
+		if output.Sensitive {
+			continue
+		}
+		if origin, ok := a.carries(output.Value, nil); ok {
+			path := append(a.Path(origin), "output."+name)
+			findings = append(findings, Finding{
+				Module:  a.Module.Name,
+				Sink:    "output." + name,
+				Path:    path,
+				Message: fmt.Sprintf("output %s is not sensitive but carries %s", name, describe(path)),
+				Range:   output.Range,
+			})
+		}
+	}
+
+	for _, resource := range a.resources() {
+		findings = append(findings, a.sinks(resource.Address, resource.Body, a.resourceScope(resource), false, false)...)
+	}
+	return findings
+}
+
+// resources returns the resources followed by the data sources
+func (a *Analysis) resources() []*tfmodule.Resource {
+	return append(append([]*tfmodule.Resource(nil), a.Module.Resources...), a.Module.DataSources...)
+}
+
+// sinks returns the arguments of a resource body Azure shows in plain text
+// that carry a secret. Names only count at the top level: the name of a
+// secure environment variable or a volume is not shown.
+// Everything inside a dynamic environment_variables block is shown.
+func (a *Analysis) sinks(node string, body *hclsyntax.Body, scope map[string]string, nested bool, shown bool) []Finding {
+	var findings []Finding
+	for _, name := range sortedKeys(body.Attributes) {
+		attr := body.Attributes[name]
+		if !shown && (!sinkName.MatchString(name) || (nested && !nestedSinkName.MatchString(name))) {
+			continue
+		}
+		if shown && name == "for_each" {
+			continue
+		}
+		if origin, ok := a.carries(attr.Expr, scope); ok {
+			sink := node + "." + name
+			path := append(a.Path(origin), sink)
+			findings = append(findings, Finding{
+				Module:  a.Module.Name,
+				Sink:    sink,
+				Path:    path,
+				Message: fmt.Sprintf("%s is shown in plain text but carries %s", sink, describe(path)),
+				Range:   attr.SrcRange,
+			})
+		}
+	}
+	for _, block := range body.Blocks {
+		blockNode := node
+		if block.Type != "content" {
+			blockNode = node + "." + blockType(block)
+		}
+		inner := shown || (block.Type == "dynamic" && nestedSinkName.MatchString(blockType(block)))
+		findings = append(findings, a.sinks(blockNode, block.Body, a.dynamicScope(block, scope), true, inner)...)
+	}
+	return findings
+}
+
+// describe names the source of a path and the nodes in between
+func describe(path []string) string {
+	if len(path) <= 2 {
+		return path[0]
+	}
+	return fmt.Sprintf("%s (via %s)", path[0], strings.Join(path[1:len(path)-1], ", "))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// relative rewrites the filename of a range relative to root
+func relative(rng hcl.Range, root string) hcl.Range {
+	if rel, err := filepath.Rel(root, rng.Filename); err == nil && filepath.IsAbs(rng.Filename) {
+		rng.Filename = rel
+	}
+	return rng
+}
@@ -0,0 +1,231 @@
+package sensitive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/tfmodule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestModulesKeepSecretsSensitive fails when a secret in modules/ or in the
+// inputs of environments/ can reach a plan, an output or a tag in plain text
+func TestModulesKeepSecretsSensitive(t *testing.T) {
+	root, err := repo.Root()
+	require.NoError(t, err)
+
+	findings, err := Audit(root)
+	require.NoError(t, err)
+	for _, finding := range findings {
+		t.Errorf("%s\n\tpath: %v", finding, finding.Path)
+	}
+}
+
+func write(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func analyze(t *testing.T, config string) *Analysis {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "app")
+	write(t, filepath.Join(dir, "main.tf"), config)
+	module, err := tfmodule.Load(dir)
+	require.NoError(t, err)
+	return Analyze(module)
+}
+
+func sinks(findings []Finding) []string {
+	var sinks []string
+	for _, finding := range findings {
+		sinks = append(sinks, finding.Sink)
+	}
+	return sinks
+}
+
+func TestSecretReachesOutputThroughLocals(t *testing.T) {
+	analysis := analyze(t, `
+variable "admin_password" {
+  type      = string
+  sensitive = true
+}
+
+locals {
+  credentials = "sqladmin:${var.admin_password}"
+  connection  = "Server=sql;${local.credentials}"
+}
+
+output "connection" {
+  value = local.connection
+}
+
+output "safe_connection" {
+  value     = local.connection
+  sensitive = true
+}
+`)
+
+	findings := analysis.Findings()
+	require.Len(t, findings, 1)
+	assert.Equal(t, "output.connection", findings[0].Sink)
+	assert.Equal(t, []string{"var.admin_password", "local.credentials", "local.connection", "output.connection"}, findings[0].Path)
+	assert.Equal(t, "output connection is not sensitive but carries var.admin_password (via local.credentials, local.connection)", findings[0].Message)
+	assert.Equal(t, 12, findings[0].Range.Start.Line)
+}
+
+func TestSecretReachesTagsAndNames(t *testing.T) {
+	analysis := analyze(t, `
+variable "dockerhub_password" {
+  type      = string
+  sensitive = true
+}
+
+variable "registry_token" {
+  type = string
+}
+
+resource "azurerm_container_group" "main" {
+  name     = "aci-${substr(var.registry_token, 0, 4)}"
+  location = "westeurope"
+  tags     = { Pull = var.dockerhub_password }
+
+  image_registry_credential {
+    password = var.dockerhub_password
+  }
+
+  container {
+    name = "app"
+
+    dynamic "secure_environment_variables" {
+      for_each = { PASSWORD = var.dockerhub_password }
+      content {
+        name  = secure_environment_variables.key
+        value = secure_environment_variables.value
+      }
+    }
+
+    dynamic "environment_variables" {
+      for_each = { PASSWORD = var.dockerhub_password }
+      content {
+        name  = environment_variables.key
+        value = environment_variables.value
+      }
+    }
+  }
+}
+
+output "registry" {
+  value = azurerm_container_group.main.image_registry_credential
+}
+
+output "location" {
+  value = azurerm_container_group.main.location
+}
+`)
+
+	assert.Equal(t, []string{
+		"var.registry_token",
+		"output.registry",
+		"azurerm_container_group.main.name",
+		"azurerm_container_group.main.tags",
+		"azurerm_container_group.main.container.environment_variables.name",
+		"azurerm_container_group.main.container.environment_variables.value",
+	}, sinks(analysis.Findings()))
+	assert.Equal(t, []string{"var.dockerhub_password", "azurerm_container_group.main.image_registry_credential.password", "output.registry"}, analysis.Findings()[1].Path)
+}
+
+func TestSecretFlowsThroughForEachAndProviderAttributes(t *testing.T) {
+	analysis := analyze(t, `
+variable "api_keys" {
+  type      = map(string)
+  sensitive = true
+}
+
+resource "azurerm_key_vault_secret" "key" {
+  for_each = var.api_keys
+  name     = "api-key-${each.key}"
+  value    = each.value
+}
+
+resource "azurerm_log_analytics_workspace" "main" {
+  name = "law"
+}
+
+output "workspace_id" {
+  value = azurerm_log_analytics_workspace.main.workspace_id
+}
+
+output "workspace" {
+  value = "${azurerm_log_analytics_workspace.main.workspace_id}:${azurerm_log_analytics_workspace.main.primary_shared_key}"
+}
+`)
+
+	findings := analysis.Findings()
+	assert.Equal(t, []string{"output.workspace", "azurerm_key_vault_secret.key.name"}, sinks(findings))
+	assert.Equal(t, []string{"azurerm_log_analytics_workspace.main.primary_shared_key", "output.workspace"}, findings[0].Path)
+	assert.Equal(t, []string{"var.api_keys", "azurerm_key_vault_secret.key.name"}, findings[1].Path)
+}
+
+func TestAuditFollowsSecretsAcrossUnits(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, "modules", "database", "main.tf"), `
+variable "admin_password" {
+  type      = string
+  sensitive = true
+}
+
+output "connection_string" {
+  value     = "Password=${var.admin_password}"
+  sensitive = true
+}
+`)
+	write(t, filepath.Join(root, "modules", "app", "main.tf"), `
+variable "settings" {
+  type = map(string)
+}
+
+variable "admin_password" {
+  type      = string
+  sensitive = true
+}
+
+resource "azurerm_linux_web_app" "main" {
+  name = "app"
+  tags = var.settings
+}
+`)
+	write(t, filepath.Join(root, "environments", "dev", "database", "terragrunt.hcl"), `terraform {
+  source = "${get_repo_root()}/modules/database"
+}
+
+inputs = {
+  admin_password = get_env("TF_VAR_db_admin_password", "")
+}
+`)
+	write(t, filepath.Join(root, "environments", "dev", "app", "terragrunt.hcl"), `terraform {
+  source = "${get_repo_root()}/modules/app"
+}
+
+dependency "database" {
+  config_path = "../database"
+}
+
+inputs = {
+  admin_password = get_env("TF_VAR_db_admin_password", "")
+  settings = {
+    DATABASE = dependency.database.outputs.connection_string
+  }
+}
+`)
+
+	findings, err := Audit(root)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, `environments/dev/app/terragrunt.hcl:11:14: input settings carries dependency.database.outputs.connection_string but variable settings of module app is not sensitive`, findings[0].String())
+	assert.Equal(t, "environments/dev/app/terragrunt.hcl", findings[1].Unit)
+	assert.Equal(t, `modules/app/main.tf:13:3: azurerm_linux_web_app.main.tags is shown in plain text but carries var.settings`, findings[1].String())
+}
@@ -0,0 +1,64 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// ContainerInstanceVars holds the inputs of the container-instance module
+type ContainerInstanceVars struct {
+	ContainerGroupName         string            `tfvar:"container_group_name,required"`
+	ContainerName              string            `tfvar:"container_name,required"`
+	Location                   string            `tfvar:"location,required"`
+	ResourceGroupName          string            `tfvar:"resource_group_name,required"`
+	DockerImage                string            `tfvar:"docker_image,required"`
+	DockerhubUsername          string            `tfvar:"dockerhub_username"`
+	DockerhubPassword          string            `tfvar:"dockerhub_password"`
+	CPU                        float64           `tfvar:"cpu"`
+	Memory                     float64           `tfvar:"memory"`
+	ContainerPort              int               `tfvar:"container_port"`
+	IPAddressType              string            `tfvar:"ip_address_type"`
+	DNSNameLabel               *string           `tfvar:"dns_name_label"`
+	OsType                     string            `tfvar:"os_type"`
+	RestartPolicy              string            `tfvar:"restart_policy"`
+	EnvironmentVariables       map[string]string `tfvar:"environment_variables"`
+	SecureEnvironmentVariables map[string]string `tfvar:"secure_environment_variables"`
+	Volumes                    []Volume          `tfvar:"volumes"`
+	LogAnalyticsWorkspaceID    string            `tfvar:"log_analytics_workspace_id"`
+	LogAnalyticsWorkspaceKey   string            `tfvar:"log_analytics_workspace_key"`
+	Tags                       map[string]string `tfvar:"tags"`
+}
+
+// Volume is one entry of the container-instance volumes variable
+type Volume struct {
+	Name               string  `tfvar:"name"`
+	MountPath          string  `tfvar:"mount_path"`
+	ReadOnly           *bool   `tfvar:"read_only"`
+	EmptyDir           *bool   `tfvar:"empty_dir"`
+	StorageAccountName *string `tfvar:"storage_account_name"`
+	StorageAccountKey  *string `tfvar:"storage_account_key"`
+	ShareName          *string `tfvar:"share_name"`
+}
+
+// NewContainerInstanceVars returns the container-instance inputs with module defaults applied
+func NewContainerInstanceVars() ContainerInstanceVars {
+	return ContainerInstanceVars{
+		CPU:                        1,
+		Memory:                     1.5,
+		ContainerPort:              80,
+		IPAddressType:              "Public",
+		OsType:                     "Linux",
+		RestartPolicy:              "Always",
+		EnvironmentVariables:       map[string]string{},
+		SecureEnvironmentVariables: map[string]string{},
+		Volumes:                    []Volume{},
+		Tags:                       map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v ContainerInstanceVars) Module() string { return "container-instance" }
+
+// ToOptions returns terratest options for applying the container-instance module
+func (v ContainerInstanceVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }
@@ -0,0 +1,45 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// KeyVaultVars holds the inputs of the key-vault module
+type KeyVaultVars struct {
+	KeyVaultName              string            `tfvar:"key_vault_name,required"`
+	Location                  string            `tfvar:"location,required"`
+	ResourceGroupName         string            `tfvar:"resource_group_name,required"`
+	SoftDeleteRetentionDays   int               `tfvar:"soft_delete_retention_days"`
+	PurgeProtectionEnabled    bool              `tfvar:"purge_protection_enabled"`
+	SkuName                   string            `tfvar:"sku_name"`
+	NetworkAclsDefaultAction  string            `tfvar:"network_acls_default_action"`
+	AllowedIPRanges           []string          `tfvar:"allowed_ip_ranges"`
+	AllowedSubnetIDs          []string          `tfvar:"allowed_subnet_ids"`
+	StoreDbCredentials        bool              `tfvar:"store_db_credentials"`
+	DbAdminUsername           string            `tfvar:"db_admin_username"`
+	DbAdminPassword           string            `tfvar:"db_admin_password"`
+	StoreDockerhubCredentials bool              `tfvar:"store_dockerhub_credentials"`
+	DockerhubUsername         string            `tfvar:"dockerhub_username"`
+	DockerhubPassword         string            `tfvar:"dockerhub_password"`
+	Tags                      map[string]string `tfvar:"tags"`
+}
+
+// NewKeyVaultVars returns the key-vault inputs with module defaults applied
+func NewKeyVaultVars() KeyVaultVars {
+	return KeyVaultVars{
+		SoftDeleteRetentionDays:  7,
+		SkuName:                  "standard",
+		NetworkAclsDefaultAction: "Allow",
+		AllowedIPRanges:          []string{},
+		AllowedSubnetIDs:         []string{},
+		Tags:                     map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v KeyVaultVars) Module() string { return "key-vault" }
+
+// ToOptions returns terratest options for applying the key-vault module
+func (v KeyVaultVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }
@@ -0,0 +1,36 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// LogAnalyticsVars holds the inputs of the log-analytics module
+type LogAnalyticsVars struct {
+	WorkspaceName           string            `tfvar:"workspace_name,required"`
+	Location                string            `tfvar:"location,required"`
+	ResourceGroupName       string            `tfvar:"resource_group_name,required"`
+	Sku                     string            `tfvar:"sku"`
+	RetentionInDays         int               `tfvar:"retention_in_days"`
+	EnableContainerInsights bool              `tfvar:"enable_container_insights"`
+	EnableSqlAnalytics      bool              `tfvar:"enable_sql_analytics"`
+	Tags                    map[string]string `tfvar:"tags"`
+}
+
+// NewLogAnalyticsVars returns the log-analytics inputs with module defaults applied
+func NewLogAnalyticsVars() LogAnalyticsVars {
+	return LogAnalyticsVars{
+		Sku:                     "PerGB2018",
+		RetentionInDays:         30,
+		EnableContainerInsights: true,
+		EnableSqlAnalytics:      true,
+		Tags:                    map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v LogAnalyticsVars) Module() string { return "log-analytics" }
+
+// ToOptions returns terratest options for applying the log-analytics module
+func (v LogAnalyticsVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }
@@ -0,0 +1,38 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// NetworkingVars holds the inputs of the networking module
+type NetworkingVars struct {
+	VnetName              string            `tfvar:"vnet_name,required"`
+	Location              string            `tfvar:"location,required"`
+	ResourceGroupName     string            `tfvar:"resource_group_name,required"`
+	AddressSpace          []string          `tfvar:"address_space"`
+	ContainerSubnetPrefix string            `tfvar:"container_subnet_prefix"`
+	DatabaseSubnetPrefix  string            `tfvar:"database_subnet_prefix"`
+	VMSubnetPrefix        string            `tfvar:"vm_subnet_prefix"`
+	AdminIPRange          string            `tfvar:"admin_ip_range"`
+	Tags                  map[string]string `tfvar:"tags"`
+}
+
+// NewNetworkingVars returns the networking inputs with module defaults applied
+func NewNetworkingVars() NetworkingVars {
+	return NetworkingVars{
+		AddressSpace:          []string{"10.0.0.0/16"},
+		ContainerSubnetPrefix: "10.0.1.0/24",
+		DatabaseSubnetPrefix:  "10.0.2.0/24",
+		VMSubnetPrefix:        "10.0.3.0/24",
+		AdminIPRange:          "*",
+		Tags:                  map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v NetworkingVars) Module() string { return "networking" }
+
+// ToOptions returns terratest options for applying the networking module
+func (v NetworkingVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }
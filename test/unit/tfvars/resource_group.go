@@ -0,0 +1,27 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// ResourceGroupVars holds the inputs of the resource-group module
+type ResourceGroupVars struct {
+	ResourceGroupName string            `tfvar:"resource_group_name,required"`
+	Location          string            `tfvar:"location,required"`
+	Tags              map[string]string `tfvar:"tags"`
+}
+
+// NewResourceGroupVars returns the resource-group inputs with module defaults applied
+func NewResourceGroupVars() ResourceGroupVars {
+	return ResourceGroupVars{
+		Tags: map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v ResourceGroupVars) Module() string { return "resource-group" }
+
+// ToOptions returns terratest options for applying the resource-group module
+func (v ResourceGroupVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }
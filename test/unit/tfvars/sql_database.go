@@ -0,0 +1,71 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// SqlDatabaseVars holds the inputs of the sql-database module
+type SqlDatabaseVars struct {
+	SqlServerName           string                  `tfvar:"sql_server_name,required"`
+	DatabaseName            string                  `tfvar:"database_name,required"`
+	Location                string                  `tfvar:"location,required"`
+	ResourceGroupName       string                  `tfvar:"resource_group_name,required"`
+	SqlVersion              string                  `tfvar:"sql_version"`
+	AdminUsername           string                  `tfvar:"admin_username,required"`
+	AdminPassword           string                  `tfvar:"admin_password,required"`
+	MinimumTLSVersion       string                  `tfvar:"minimum_tls_version"`
+	AzureadAdminUsername    string                  `tfvar:"azuread_admin_username"`
+	AzureadAdminObjectID    string                  `tfvar:"azuread_admin_object_id"`
+	Collation               string                  `tfvar:"collation"`
+	MaxSizeGb               int                     `tfvar:"max_size_gb"`
+	SkuName                 string                  `tfvar:"sku_name"`
+	ZoneRedundant           bool                    `tfvar:"zone_redundant"`
+	AutoPauseDelayInMinutes int                     `tfvar:"auto_pause_delay_in_minutes"`
+	MinCapacity             float64                 `tfvar:"min_capacity"`
+	BackupRetentionDays     int                     `tfvar:"backup_retention_days"`
+	BackupIntervalHours     int                     `tfvar:"backup_interval_hours"`
+	LtrWeeklyRetention      string                  `tfvar:"ltr_weekly_retention"`
+	LtrMonthlyRetention     string                  `tfvar:"ltr_monthly_retention"`
+	LtrYearlyRetention      string                  `tfvar:"ltr_yearly_retention"`
+	LtrWeekOfYear           int                     `tfvar:"ltr_week_of_year"`
+	SubnetID                string                  `tfvar:"subnet_id"`
+	AllowAzureServices      bool                    `tfvar:"allow_azure_services"`
+	FirewallRules           map[string]FirewallRule `tfvar:"firewall_rules"`
+	Tags                    map[string]string       `tfvar:"tags"`
+}
+
+// FirewallRule is one entry of the sql-database firewall_rules variable
+type FirewallRule struct {
+	StartIP string `tfvar:"start_ip"`
+	EndIP   string `tfvar:"end_ip"`
+}
+
+// NewSqlDatabaseVars returns the sql-database inputs with module defaults applied
+func NewSqlDatabaseVars() SqlDatabaseVars {
+	return SqlDatabaseVars{
+		SqlVersion:              "12.0",
+		MinimumTLSVersion:       "1.2",
+		Collation:               "SQL_Latin1_General_CP1_CI_AS",
+		MaxSizeGb:               32,
+		SkuName:                 "GP_S_Gen5_2",
+		AutoPauseDelayInMinutes: 60,
+		MinCapacity:             0.5,
+		BackupRetentionDays:     7,
+		BackupIntervalHours:     12,
+		LtrWeeklyRetention:      "P1W",
+		LtrMonthlyRetention:     "P1M",
+		LtrYearlyRetention:      "P1Y",
+		LtrWeekOfYear:           1,
+		AllowAzureServices:      true,
+		FirewallRules:           map[string]FirewallRule{},
+		Tags:                    map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v SqlDatabaseVars) Module() string { return "sql-database" }
+
+// ToOptions returns terratest options for applying the sql-database module
+func (v SqlDatabaseVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }
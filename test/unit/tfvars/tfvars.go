@@ -0,0 +1,170 @@
+// Package tfvars provides typed input structs for the Terraform modules under
+// modules/. Each struct mirrors the variables declared in the module's
+// variables.tf: New<Module>Vars returns the struct with every default applied,
+// and ToOptions converts it into terratest options for that module.
+//
+// Fields are mapped to Terraform variable names with a `tfvar` struct tag. A
+// tag of `tfvar:"name,required"` marks a variable without a default, which
+// ToOptions refuses to pass as an empty value. tfvars_test.go checks every
+// struct against the module's variables.tf, so a renamed, missing or
+// mistyped variable is caught offline instead of after an Azure apply.
+package tfvars
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// ModuleVars is implemented by every module input struct
+type ModuleVars interface {
+	// Module returns the name of the module directory under modules/
+	Module() string
+}
+
+// Registry returns every module input struct with its defaults applied,
+// keyed by module directory name
+func Registry() map[string]ModuleVars {
+	return map[string]ModuleVars{
+		"resource-group":     NewResourceGroupVars(),
+		"log-analytics":      NewLogAnalyticsVars(),
+		"networking":         NewNetworkingVars(),
+		"key-vault":          NewKeyVaultVars(),
+		"container-instance": NewContainerInstanceVars(),
+		"sql-database":       NewSqlDatabaseVars(),
+		"virtual-machine":    NewVirtualMachineVars(),
+	}
+}
+
+// Field describes one struct field mapped to a Terraform variable
+type Field struct {
+	GoName   string
+	Variable string
+	Required bool
+	Type     reflect.Type
+}
+
+// Fields returns the Terraform variables mapped by the given input struct
+func Fields(v ModuleVars) []Field {
+	typ := reflect.Indirect(reflect.ValueOf(v)).Type()
+
+	fields := make([]Field, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, required, ok := parseTag(field)
+		if !ok {
+			continue
+		}
+		fields = append(fields, Field{
+			GoName:   field.Name,
+			Variable: name,
+			Required: required,
+			Type:     field.Type,
+		})
+	}
+	return fields
+}
+
+// ToMap converts an input struct into the Vars map expected by terratest.
+// Nil pointers are omitted so Terraform falls back to a null default.
+func ToMap(v ModuleVars) map[string]interface{} {
+	return structToMap(reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// MissingRequired returns the required variables left at their zero value
+func MissingRequired(v ModuleVars) []string {
+	value := reflect.Indirect(reflect.ValueOf(v))
+
+	var missing []string
+	for _, field := range Fields(v) {
+		if field.Required && value.FieldByName(field.GoName).IsZero() {
+			missing = append(missing, field.Variable)
+		}
+	}
+	return missing
+}
+
+// toOptions builds the terratest options shared by every module's ToOptions.
+// TerraformDir points at a per-test copy of the module, so parallel tests
+// never share a .terraform directory or local state file.
+func toOptions(t *testing.T, v ModuleVars) *terraform.Options {
+	t.Helper()
+
+	missing := MissingRequired(v)
+	require.Empty(t, missing, "required variables for module %s are not set", v.Module())
+
+	return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: repo.CopyModuleToTemp(t, v.Module()),
+		Vars:         ToMap(v),
+	})
+}
+
+func parseTag(field reflect.StructField) (name string, required bool, ok bool) {
+	tag, ok := field.Tag.Lookup("tfvar")
+	if !ok || tag == "-" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "required" {
+			required = true
+		}
+	}
+	return parts[0], required, true
+}
+
+func structToMap(value reflect.Value) map[string]interface{} {
+	out := make(map[string]interface{}, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		name, _, ok := parseTag(value.Type().Field(i))
+		if !ok {
+			continue
+		}
+		field := value.Field(i)
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			continue
+		}
+		out[name] = toValue(field)
+	}
+	return out
+}
+
+// toValue converts nested structs into maps so terratest can format them as
+// HCL; primitives, slices and maps of primitives are passed through untouched
+func toValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return toValue(value.Elem())
+	case reflect.Struct:
+		return structToMap(value)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Struct {
+			return value.Interface()
+		}
+		out := make([]interface{}, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			out[i] = toValue(value.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if value.Type().Elem().Kind() != reflect.Struct {
+			return value.Interface()
+		}
+		out := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = toValue(iter.Value())
+		}
+		return out
+	default:
+		return value.Interface()
+	}
+}
@@ -0,0 +1,240 @@
+package tfvars
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/EzequielAndreus/gogs-fork-infrastructure-azure/test/unit/internal/repo"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// variable is the subset of a variable block the drift check compares
+type variable struct {
+	Type       cty.Type
+	Default    cty.Value
+	HasDefault bool
+}
+
+var variableSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+}
+
+var variableBodySchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "type"}, {Name: "default"}},
+}
+
+// loadVariables parses the variables.tf of the given module
+func loadVariables(t *testing.T, module string) map[string]variable {
+	t.Helper()
+
+	dir, err := repo.ModuleDir(module)
+	require.NoError(t, err)
+
+	file, diags := hclparse.NewParser().ParseHCLFile(filepath.Join(dir, "variables.tf"))
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	content, _, diags := file.Body.PartialContent(variableSchema)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	variables := make(map[string]variable, len(content.Blocks))
+	for _, block := range content.Blocks {
+		attrs, _, diags := block.Body.PartialContent(variableBodySchema)
+		require.False(t, diags.HasErrors(), diags.Error())
+
+		v := variable{Type: cty.DynamicPseudoType}
+		if attr, ok := attrs.Attributes["type"]; ok {
+			ty, _, diags := typeexpr.TypeConstraintWithDefaults(attr.Expr)
+			require.False(t, diags.HasErrors(), diags.Error())
+			v.Type = ty
+		}
+		if attr, ok := attrs.Attributes["default"]; ok {
+			val, diags := attr.Expr.Value(nil)
+			require.False(t, diags.HasErrors(), diags.Error())
+			v.Default = val
+			v.HasDefault = true
+		}
+		variables[block.Labels[0]] = v
+	}
+	return variables
+}
+
+// TestRegistryCoversEveryModule fails when a module directory has no input struct
+func TestRegistryCoversEveryModule(t *testing.T) {
+	modulesDir, err := repo.ModulesDir()
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(modulesDir)
+	require.NoError(t, err)
+
+	var modules []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			modules = append(modules, entry.Name())
+		}
+	}
+
+	registered := make([]string, 0, len(Registry()))
+	for module := range Registry() {
+		registered = append(registered, module)
+	}
+	sort.Strings(registered)
+
+	assert.Equal(t, modules, registered, "every module under modules/ should have an input struct")
+}
+
+// TestInputStructsMatchModuleVariables fails when a struct field drifts from
+// the variables declared in modules/*/variables.tf
+func TestInputStructsMatchModuleVariables(t *testing.T) {
+	for module, vars := range Registry() {
+		module, vars := module, vars
+
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+
+			declared := loadVariables(t, module)
+			defaults := ToMap(vars)
+			seen := map[string]bool{}
+
+			for _, field := range Fields(vars) {
+				seen[field.Variable] = true
+
+				decl, ok := declared[field.Variable]
+				if !assert.True(t, ok, "field %s maps to undeclared variable %q", field.GoName, field.Variable) {
+					continue
+				}
+
+				assert.Equal(t, !decl.HasDefault, field.Required,
+					"field %s: required tag should match whether %q has a default", field.GoName, field.Variable)
+				assert.NoError(t, checkType(field.Type, decl.Type), "field %s", field.GoName)
+
+				if decl.HasDefault {
+					assert.Equal(t, ctyToGeneric(t, decl.Default), goToGeneric(t, defaults[field.Variable]),
+						"field %s: default should mirror variables.tf", field.GoName)
+				}
+			}
+
+			for name := range declared {
+				assert.True(t, seen[name], "variable %q has no field in the %s input struct", name, module)
+			}
+		})
+	}
+}
+
+func TestToMapConvertsNestedStructs(t *testing.T) {
+	readOnly := true
+	vars := NewContainerInstanceVars()
+	vars.Volumes = []Volume{{Name: "data", MountPath: "/data", ReadOnly: &readOnly}}
+
+	result := ToMap(vars)
+
+	assert.NotContains(t, result, "dns_name_label", "nil pointers should be omitted")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "data", "mount_path": "/data", "read_only": true},
+	}, result["volumes"])
+}
+
+func TestMissingRequired(t *testing.T) {
+	vars := NewResourceGroupVars()
+	assert.Equal(t, []string{"resource_group_name", "location"}, MissingRequired(vars))
+
+	vars.ResourceGroupName = "test-rg"
+	vars.Location = "eastus"
+	assert.Empty(t, MissingRequired(vars))
+}
+
+// checkType reports whether a Go field type can carry values of the
+// declared Terraform type
+func checkType(goType reflect.Type, ty cty.Type) error {
+	if goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+
+	switch {
+	case ty == cty.DynamicPseudoType:
+		return nil
+	case ty == cty.String:
+		if goType.Kind() == reflect.String {
+			return nil
+		}
+	case ty == cty.Number:
+		switch goType.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			return nil
+		}
+	case ty == cty.Bool:
+		if goType.Kind() == reflect.Bool {
+			return nil
+		}
+	case ty.IsListType() || ty.IsSetType():
+		if goType.Kind() == reflect.Slice {
+			return checkType(goType.Elem(), ty.ElementType())
+		}
+	case ty.IsMapType():
+		if goType.Kind() == reflect.Map && goType.Key().Kind() == reflect.String {
+			return checkType(goType.Elem(), ty.ElementType())
+		}
+	case ty.IsObjectType():
+		if goType.Kind() == reflect.Struct {
+			return checkObject(goType, ty)
+		}
+	}
+	return fmt.Errorf("Go type %s cannot hold Terraform type %s", goType, ty.FriendlyName())
+}
+
+func checkObject(goType reflect.Type, ty cty.Type) error {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < goType.NumField(); i++ {
+		if name, _, ok := parseTag(goType.Field(i)); ok {
+			fields[name] = goType.Field(i).Type
+		}
+	}
+
+	for name, attrType := range ty.AttributeTypes() {
+		fieldType, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%s has no field for object attribute %q", goType, name)
+		}
+		if err := checkType(fieldType, attrType); err != nil {
+			return fmt.Errorf("%s.%s: %w", goType, name, err)
+		}
+		delete(fields, name)
+	}
+	for name := range fields {
+		return fmt.Errorf("%s field %q is not an attribute of %s", goType, name, ty.FriendlyName())
+	}
+	return nil
+}
+
+// ctyToGeneric and goToGeneric round-trip both sides through JSON so that
+// numbers, empty collections and nulls compare equal
+func ctyToGeneric(t *testing.T, value cty.Value) interface{} {
+	if value.IsNull() {
+		return nil
+	}
+	raw, err := ctyjson.SimpleJSONValue{Value: value}.MarshalJSON()
+	require.NoError(t, err)
+	return decode(t, raw)
+}
+
+func goToGeneric(t *testing.T, value interface{}) interface{} {
+	raw, err := json.Marshal(value)
+	require.NoError(t, err)
+	return decode(t, raw)
+}
+
+func decode(t *testing.T, raw []byte) interface{} {
+	var out interface{}
+	require.NoError(t, json.Unmarshal(raw, &out))
+	return out
+}
@@ -0,0 +1,56 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// VirtualMachineVars holds the inputs of the virtual-machine module
+type VirtualMachineVars struct {
+	VMName                 string            `tfvar:"vm_name,required"`
+	Location               string            `tfvar:"location,required"`
+	ResourceGroupName      string            `tfvar:"resource_group_name,required"`
+	SubnetID               string            `tfvar:"subnet_id,required"`
+	NetworkSecurityGroupID string            `tfvar:"network_security_group_id"`
+	VMSize                 string            `tfvar:"vm_size"`
+	AdminUsername          string            `tfvar:"admin_username"`
+	SSHPublicKey           string            `tfvar:"ssh_public_key,required"`
+	CreatePublicIP         bool              `tfvar:"create_public_ip"`
+	OsDiskType             string            `tfvar:"os_disk_type"`
+	OsDiskSizeGb           int               `tfvar:"os_disk_size_gb"`
+	ImagePublisher         string            `tfvar:"image_publisher"`
+	ImageOffer             string            `tfvar:"image_offer"`
+	ImageSku               string            `tfvar:"image_sku"`
+	ImageVersion           string            `tfvar:"image_version"`
+	CustomData             string            `tfvar:"custom_data"`
+	CreateDataDisk         bool              `tfvar:"create_data_disk"`
+	DataDiskType           string            `tfvar:"data_disk_type"`
+	DataDiskSizeGb         int               `tfvar:"data_disk_size_gb"`
+	Tags                   map[string]string `tfvar:"tags"`
+}
+
+// NewVirtualMachineVars returns the virtual-machine inputs with module defaults applied
+func NewVirtualMachineVars() VirtualMachineVars {
+	return VirtualMachineVars{
+		VMSize:         "Standard_D4s_v3",
+		AdminUsername:  "azureuser",
+		CreatePublicIP: true,
+		OsDiskType:     "Premium_LRS",
+		OsDiskSizeGb:   128,
+		ImagePublisher: "Canonical",
+		ImageOffer:     "0001-com-ubuntu-server-jammy",
+		ImageSku:       "22_04-lts-gen2",
+		ImageVersion:   "latest",
+		CreateDataDisk: true,
+		DataDiskType:   "Premium_LRS",
+		DataDiskSizeGb: 256,
+		Tags:           map[string]string{},
+	}
+}
+
+// Module returns the module directory name
+func (v VirtualMachineVars) Module() string { return "virtual-machine" }
+
+// ToOptions returns terratest options for applying the virtual-machine module
+func (v VirtualMachineVars) ToOptions(t *testing.T) *terraform.Options { return toOptions(t, v) }